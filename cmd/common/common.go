@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/cmd/common/cryptotools"
 	"github.com/wal-g/wal-g/cmd/common/st"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
@@ -69,6 +70,9 @@ func Init(cmd *cobra.Command, dbName string) {
 	// Add storage tools
 	cmd.AddCommand(st.StorageToolsCmd)
 
+	// Add encryption tools
+	cmd.AddCommand(cryptotools.CryptoToolsCmd)
+
 	// profiler
 	persistentPreRun := cmd.PersistentPreRun
 	persistentPostRun := cmd.PersistentPostRun
@@ -0,0 +1,113 @@
+package cryptotools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	checkShortDescription = "Checks that the configured encryption works and the stored objects can be decrypted"
+	checkPrefixFlag       = "prefix"
+)
+
+var (
+	checkPrefix        string
+	checkExpiryWarning time.Duration
+	checkSkipStorage   bool
+	checkJSON          bool
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: checkShortDescription,
+	Long: "Encrypts and decrypts a test payload with the configured crypter, then decrypts the beginning of a random " +
+		"object stored under the prefix to prove it can still be decrypted with the current key material. " +
+		"Prints the key fingerprint and, for PGP, the key expiry. " +
+		"Exits with 0 if everything is OK, 1 if the key expires soon, 2 if the check failed, " +
+		"and 3 if the check could not be done, e.g. the encryption is not configured or the storage is unavailable.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var folder storage.Folder
+		var storageErr error
+		if !checkSkipStorage {
+			var st storage.HashableStorage
+			st, storageErr = internal.ConfigureStorage(cmd.Context())
+			if storageErr == nil {
+				folder = st.RootFolder()
+			}
+		}
+
+		var result *internal.CryptoCheckResult
+		crypter, err := internal.ConfigureCrypterForSpecificConfig(viper.GetViper())
+		if err != nil {
+			result = &internal.CryptoCheckResult{}
+			result.AddProblem(internal.CryptoCheckCritical, "can't configure crypter: %v", err)
+		} else {
+			result = internal.HandleCryptoCheck(cmd.Context(), folder, crypter, internal.CryptoCheckArgs{
+				Prefix:        checkPrefix,
+				ExpiryWarning: checkExpiryWarning,
+			})
+		}
+		if storageErr != nil {
+			result.AddProblem(internal.CryptoCheckUnknown, "failed to configure the storage: %v", storageErr)
+		}
+
+		if checkJSON {
+			err := json.NewEncoder(os.Stdout).Encode(result)
+			tracelog.ErrorLogger.FatalOnError(err)
+		} else {
+			printCheckResult(os.Stdout, result)
+		}
+		os.Exit(int(result.Status))
+	},
+}
+
+func printCheckResult(output io.Writer, result *internal.CryptoCheckResult) {
+	_, _ = fmt.Fprintf(output, "Status: %s\n", result.Status)
+	if result.Crypter != "" {
+		_, _ = fmt.Fprintf(output, "Crypter: %s\n", result.Crypter)
+	}
+	if result.KeyFingerprint != "" {
+		_, _ = fmt.Fprintf(output, "Key fingerprint: %s\n", result.KeyFingerprint)
+	}
+	if result.KeyExpiry != nil {
+		_, _ = fmt.Fprintf(output, "Key expiry: %s\n", result.KeyExpiry.Format(time.RFC3339))
+	}
+	if result.SelfTest != "" {
+		_, _ = fmt.Fprintf(output, "Self-test: %s\n", result.SelfTest)
+	}
+	if result.Object != "" {
+		_, _ = fmt.Fprintf(output, "Stored object %s: %s\n", result.Object, result.ObjectCheck)
+	}
+	for _, message := range result.Messages {
+		_, _ = fmt.Fprintf(output, "- %s\n", message)
+	}
+}
+
+// SetCheckDefaultPrefix sets the default prefix of the objects one of which is checked. The databases set it to
+// the folder of their archives, e.g. WAL, which is listed faster than the backups.
+func SetCheckDefaultPrefix(prefix string) {
+	checkPrefix = prefix
+	checkCmd.Flags().Lookup(checkPrefixFlag).DefValue = prefix
+}
+
+func init() {
+	checkCmd.Flags().StringVar(&checkPrefix, checkPrefixFlag, utility.BaseBackupPath,
+		"Check one of the objects under this prefix, the folder of the database archives by default, "+
+			"to avoid listing the whole storage")
+	checkCmd.Flags().DurationVar(&checkExpiryWarning, "expiry-warning", 30*24*time.Hour,
+		"Warn if the key expires within this period")
+	checkCmd.Flags().BoolVar(&checkSkipStorage, "skip-storage", false, "Check only the crypter, without the storage")
+	checkCmd.Flags().BoolVar(&checkJSON, "json", false, "Print the result in JSON")
+	CryptoToolsCmd.AddCommand(checkCmd)
+}
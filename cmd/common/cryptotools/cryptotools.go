@@ -0,0 +1,13 @@
+package cryptotools
+
+import (
+	"github.com/spf13/cobra"
+)
+
+const CryptoToolsShortDescription = "Encryption tools"
+
+var CryptoToolsCmd = &cobra.Command{
+	Use:   "crypto",
+	Short: CryptoToolsShortDescription,
+	Long:  "Encryption tools allow to check the configured encryption before the encrypted data is needed.",
+}
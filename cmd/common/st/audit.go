@@ -0,0 +1,64 @@
+package st
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/internal/multistorage/policies"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/internal/storagetools/transfer"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const auditShortDescription = "Shows which backups and WAL are stored in which storages (Postgres only)"
+
+var (
+	auditRepair      bool
+	auditConcurrency int
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit [--repair]",
+	Short: auditShortDescription,
+	Long: "Lists every backup and WAL range in the primary and all alive failover storages, and reports backups " +
+		"without sentinels, with missing tar parts or split between storages, and gaps in WAL. Fails if any " +
+		"problems are found. With --repair, copies the backup pieces and WAL missing in the primary storage " +
+		"from the failover ones. (Postgres only)",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+		multiSt, err := internal.ConfigureMultiStorage(ctx, auditRepair)
+		tracelog.ErrorLogger.FatalfOnError("Failed to configure multi-storage: %v", err)
+		defer utility.LoggedClose(multiSt, "close multi-storage")
+
+		rootFolder, err := multistorage.UseAllAliveStorages(ctx, multiSt.RootFolder())
+		tracelog.ErrorLogger.FatalOnError(err)
+		rootFolder = multistorage.SetPolicies(rootFolder, policies.UniteAllStorages)
+		tracelog.InfoLogger.Printf("Auditing storages: %v", multistorage.UsedStorages(rootFolder))
+
+		var repairCfg *transfer.HandlerConfig
+		if auditRepair {
+			repairCfg = &transfer.HandlerConfig{
+				PreserveInSource:         true,
+				Concurrency:              auditConcurrency,
+				AppearanceChecks:         3,
+				AppearanceChecksInterval: time.Second,
+			}
+		}
+		err = storagetools.HandleAudit(ctx, rootFolder, os.Stdout, repairCfg)
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	auditCmd.Flags().BoolVar(&auditRepair, "repair", false,
+		"copy backup pieces and WAL missing in the primary storage from the failover ones")
+	auditCmd.Flags().IntVarP(&auditConcurrency, "concurrency", "c", 10,
+		"number of concurrent workers to copy files with --repair")
+	StorageToolsCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,32 @@
+package st
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const dedupGCShortDescription = "Deletes the deduplicated chunks which are no longer referenced"
+
+// dedupGCCmd represents the dedup-gc command
+var dedupGCCmd = &cobra.Command{
+	Use:   "dedup-gc",
+	Short: dedupGCShortDescription,
+	Long: "Deletes the chunks under the chunks/ folder which are not referenced by any deduplicated object and are " +
+		"older than WALG_DEDUPLICATION_GC_DELAY. Deleting backups doesn't remove their chunks, so this command " +
+		"is expected to be run after 'delete' or on a schedule.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+		err := exec.OnStorage(ctx, targetStorage, func(folder storage.Folder) error {
+			return storagetools.HandleDedupGC(ctx, folder)
+		})
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	StorageToolsCmd.AddCommand(dedupGCCmd)
+}
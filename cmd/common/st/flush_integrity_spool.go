@@ -0,0 +1,33 @@
+package st
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const flushIntegritySpoolShortDescription = "Writes the integrity manifests of the objects collected in the local spool"
+
+// flushIntegritySpoolCmd represents the flush-integrity-spool command
+var flushIntegritySpoolCmd = &cobra.Command{
+	Use:   "flush-integrity-spool",
+	Short: flushIntegritySpoolShortDescription,
+	Long: "Writes the integrity manifests of all the objects, such as WAL segments, which are collected " +
+		"in the local spool of the storage, without waiting for the batches to fill up. " +
+		"Run it before `st verify`, or periodically if the objects are uploaded rarely.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		st, err := exec.ConfigureStorage(cmd.Context(), targetStorage)
+		tracelog.ErrorLogger.FatalOnError(err)
+		defer utility.LoggedClose(st, "close storage")
+
+		err = storagetools.HandleFlushIntegritySpool(cmd.Context(), targetStorage, st)
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	StorageToolsCmd.AddCommand(flushIntegritySpoolCmd)
+}
@@ -0,0 +1,62 @@
+package st
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const reencryptShortDescription = "Re-encrypts objects with another key"
+
+var (
+	reencryptFromConfig   string
+	reencryptToConfig     string
+	reencryptTargetPrefix string
+	reencryptProgressPath string
+	reencryptConcurrency  int
+)
+
+// reencryptCmd represents the reencrypt command
+var reencryptCmd = &cobra.Command{
+	Use:   "reencrypt --from-config old.yaml --to-config new.yaml [prefix]",
+	Short: reencryptShortDescription,
+	Long: "Decrypts the objects under the prefix with the encryption settings from the old config and encrypts them " +
+		"with the settings from the new one. Objects are not decompressed. They are rewritten in place, or put to " +
+		"another prefix with --target-prefix. Rewritten objects are recorded in the progress file, so an interrupted " +
+		"run can be resumed by running the command again.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		prefix := ""
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+		cfg := storagetools.ReencryptConfig{
+			From:         internal.CrypterFromConfig(reencryptFromConfig),
+			To:           internal.CrypterFromConfig(reencryptToConfig),
+			TargetPrefix: reencryptTargetPrefix,
+			ProgressPath: reencryptProgressPath,
+			Concurrency:  reencryptConcurrency,
+		}
+		err := exec.OnStorage(ctx, targetStorage, func(folder storage.Folder) error {
+			return storagetools.HandleReencrypt(ctx, folder, prefix, cfg)
+		})
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	reencryptCmd.Flags().StringVar(&reencryptFromConfig, "from-config", "", "Config with the current encryption settings")
+	_ = reencryptCmd.MarkFlagRequired("from-config")
+	reencryptCmd.Flags().StringVar(&reencryptToConfig, "to-config", "", "Config with the new encryption settings")
+	_ = reencryptCmd.MarkFlagRequired("to-config")
+	reencryptCmd.Flags().StringVar(&reencryptTargetPrefix, "target-prefix", "",
+		"Put the re-encrypted objects under this prefix instead of rewriting them in place")
+	reencryptCmd.Flags().StringVar(&reencryptProgressPath, "progress-file", "wal-g-reencrypt.progress",
+		"Local file to record the progress in, so that the run can be resumed")
+	reencryptCmd.Flags().IntVarP(&reencryptConcurrency, "concurrency", "c", 10, "Number of concurrent workers")
+	StorageToolsCmd.AddCommand(reencryptCmd)
+}
@@ -0,0 +1,46 @@
+package st
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const tierShortDescription = "Moves old backups to another storage class"
+
+var tierStorageClass string
+var tierOlderThanDays int
+var tierRetainCount int
+var tierConfirm bool
+
+// tierCmd represents the tier command
+var tierCmd = &cobra.Command{
+	Use:   "tier --storage-class class [--older-than-days N] [--retain N]",
+	Short: tierShortDescription,
+	Long: "Moves the data objects of backups older than the specified number of days and/or beyond the specified " +
+		"number of the newest backups to the storage class: S3 storage class, GCS storage class or Azure access " +
+		"tier. Sentinels and other metadata stay in the current class. Without --confirm, only prints what would " +
+		"be moved.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+		olderThan := time.Duration(tierOlderThanDays) * 24 * time.Hour
+		err := exec.OnStorage(ctx, targetStorage, func(folder storage.Folder) error {
+			return storagetools.HandleTier(ctx, folder, tierStorageClass, olderThan, tierRetainCount, tierConfirm)
+		})
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	tierCmd.Flags().StringVar(&tierStorageClass, "storage-class", "", "Storage class to move the backups to")
+	_ = tierCmd.MarkFlagRequired("storage-class")
+	tierCmd.Flags().IntVar(&tierOlderThanDays, "older-than-days", 0, "Move backups older than the number of days")
+	tierCmd.Flags().IntVar(&tierRetainCount, "retain", -1, "Number of the newest backups to keep in the current class")
+	tierCmd.Flags().BoolVar(&tierConfirm, "confirm", false, "Confirms moving the backups")
+	StorageToolsCmd.AddCommand(tierCmd)
+}
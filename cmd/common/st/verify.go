@@ -0,0 +1,38 @@
+package st
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const verifyShortDescription = "Verifies objects by the prefix against the integrity manifests"
+
+var verifyConcurrency int
+var verifyFailOnMissing bool
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify prefix",
+	Short: verifyShortDescription,
+	Long: "Streams back the objects by the prefix and compares their size and SHA-256 with the ones recorded " +
+		"in the integrity manifests when the objects were uploaded with WALG_INTEGRITY_MANIFEST enabled. " +
+		"Objects uploaded without the manifests are not checked.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		err := exec.OnStorage(ctx, targetStorage, func(folder storage.Folder) error {
+			return storagetools.HandleVerify(ctx, args[0], folder, verifyConcurrency, verifyFailOnMissing)
+		})
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	verifyCmd.Flags().IntVar(&verifyConcurrency, "concurrency", 10, "Number of objects verified in parallel")
+	verifyCmd.Flags().BoolVar(&verifyFailOnMissing, "fail-on-missing", false,
+		"Fail if some of the objects listed in the manifests are missing")
+	StorageToolsCmd.AddCommand(verifyCmd)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/cmd/common/cryptotools"
 	"github.com/wal-g/wal-g/cmd/pg"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
@@ -69,6 +70,8 @@ var SegContentID string
 
 func init() {
 	common.Init(cmd, conf.GP)
+	// the WAL of the coordinator, which has the content ID -1
+	cryptotools.SetCheckDefaultPrefix(greenplum.FormatSegmentWalPath(-1) + "/")
 
 	_ = cmd.MarkFlagRequired("config") // config is required for Greenplum WAL-G
 	// wrap the Postgres command so it can be used in the same binary
@@ -6,8 +6,10 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/cmd/common/cryptotools"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/databases/mongo/models"
 )
 
 var dbShortDescription = "MongoDB backup tool"
@@ -41,6 +43,7 @@ func GetCmd() *cobra.Command {
 
 func init() {
 	common.Init(cmd, conf.MONGO)
+	cryptotools.SetCheckDefaultPrefix(models.OplogArchBasePath)
 	conf.AddTurboFlag(cmd)
 	conf.RequiredSettings[conf.MongoDBUriSetting] = true
 }
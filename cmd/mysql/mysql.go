@@ -6,9 +6,11 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/cmd/common/cryptotools"
 	"github.com/wal-g/wal-g/cmd/mysql/xb"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/databases/mysql"
 )
 
 var ShortDescription = "MySQL backup tool"
@@ -46,6 +48,7 @@ func GetCmd() *cobra.Command {
 
 func init() {
 	common.Init(cmd, conf.MYSQL)
+	cryptotools.SetCheckDefaultPrefix(mysql.BinlogPath)
 	conf.AddTurboFlag(cmd)
 	cmd.AddCommand(xb.XBToolsCmd)
 }
@@ -0,0 +1,44 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	backupVerifyShortDescription = "Verifies that a backup is restorable without restoring it"
+	backupVerifyLongDescription  = `Streams every tar part of the backup and checks that each file from the files metadata
+is present with the right size, that the page headers and checksums are valid, and that the WAL
+from the backup start LSN to the backup finish LSN is archived. Nothing is written to a data directory.
+Prints the report in JSON and exits with a non-zero code if the verification fails.`
+)
+
+var backupVerifyCmd = &cobra.Command{
+	Use:   "backup-verify backup_name | LATEST",
+	Short: backupVerifyShortDescription,
+	Long:  backupVerifyLongDescription,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.ConfigureLimiters()
+
+		backupSelector, err := internal.NewBackupNameSelector(args[0], true)
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		storage, err := internal.ConfigureStorage(cmd.Context())
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		status, err := postgres.HandleBackupVerify(cmd.Context(), storage.RootFolder(), backupSelector, os.Stdout)
+		tracelog.ErrorLogger.FatalOnError(err)
+		if status == postgres.StatusFailure {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	Cmd.AddCommand(backupVerifyCmd)
+}
@@ -19,11 +19,15 @@ const DeleteGarbageExamples = `  garbage           Deletes outdated WAL archives
   garbage BACKUPS   Deletes only leftover backups files from storage`
 const DeleteGarbageUse = "garbage [ARCHIVES|BACKUPS]"
 const afterFlag = "after"
+const tierStorageClassFlag = "tier-storage-class"
+const tierRetainFlag = "tier-retain"
 
 var confirmed = false
 var deleteWithoutBackups = false
 var useSentinelTime = false
 var deleteTargetUserData = ""
+var tierStorageClass = ""
+var tierRetainCount = 1
 
 // deleteCmd represents the delete command
 var deleteCmd = &cobra.Command{
@@ -88,6 +92,17 @@ func runDeleteRetain(cmd *cobra.Command, args []string) {
 	tracelog.ErrorLogger.FatalOnError(err)
 
 	afterValue, _ := cmd.Flags().GetString(afterFlag)
+	if tierStorageClass != "" {
+		if afterValue != "" {
+			tracelog.ErrorLogger.Fatalf("--%s can't be used together with --%s", tierStorageClassFlag, afterFlag)
+		}
+		// The newest backup is the one most likely to be restored, so it's never moved to a colder class
+		if tierRetainCount < 1 {
+			tracelog.ErrorLogger.Fatalf("--%s must be at least 1", tierRetainFlag)
+		}
+		err = deleteHandler.HandleTierRetained(cmd.Context(), args, tierRetainCount, tierStorageClass, confirmed)
+		tracelog.ErrorLogger.FatalfOnError("Failed to move retained backups to another storage class: %v", err)
+	}
 	if afterValue == "" {
 		deleteHandler.HandleDeleteRetain(cmd.Context(), args, confirmed)
 	} else {
@@ -166,6 +181,10 @@ func init() {
 	deleteTargetCmd.Flags().StringVar(
 		&deleteTargetUserData, internal.DeleteTargetUserDataFlag, "", internal.DeleteTargetUserDataDescription)
 	deleteRetainCmd.Flags().StringP(afterFlag, "a", "", "Set the time after which retain backups")
+	deleteRetainCmd.Flags().StringVar(&tierStorageClass, tierStorageClassFlag, "",
+		"Move retained backups, except for the --tier-retain newest ones, to the storage class")
+	deleteRetainCmd.Flags().IntVar(&tierRetainCount, tierRetainFlag, 1,
+		"Number of the newest retained backups that stay in the current storage class, at least 1")
 
 	deleteGarbageCmd.Flags().BoolVar(&deleteWithoutBackups, "without-backup-check", false, "skip check for existing non-permanent backups")
 
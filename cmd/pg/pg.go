@@ -7,11 +7,13 @@ import (
 	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/cmd/common/cryptotools"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
 	"github.com/wal-g/wal-g/internal/databases/postgres/orioledb"
 	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/utility"
 )
 
 const WalgShortDescription = "PostgreSQL backup tool"
@@ -71,5 +73,6 @@ func GetCmd() *cobra.Command {
 
 func configureCommand() {
 	common.Init(Cmd, conf.PG)
+	cryptotools.SetCheckDefaultPrefix(utility.WalPath)
 	conf.AddTurboFlag(Cmd)
 }
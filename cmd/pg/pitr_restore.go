@@ -0,0 +1,118 @@
+package pg
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/internal/multistorage/policies"
+)
+
+const (
+	pitrRestoreShortDescription = "Restores the cluster to a point in time"
+	pitrRestoreLongDescription  = `Fetches the newest backup finished before the recovery target, checks that the WAL
+up to the target is archived and writes the recovery config, so that Postgres recovers up to the target
+on the next start. Exactly one of the target flags must be specified.`
+
+	targetTimeDescription   = "Recover up to the time in RFC 3339 format, e.g. 2024-01-02T15:04:05Z"
+	targetLSNDescription    = "Recover up to the LSN, e.g. 0/3000028"
+	targetXidDescription    = "Recover up to the transaction ID"
+	targetNameDescription   = "Recover up to the restore point created with pg_create_restore_point()"
+	targetActionDescription = "Action after the target is reached: pause, promote or shutdown. Postgres default is used if not set"
+)
+
+var (
+	pitrTargetTime   string
+	pitrTargetLSN    string
+	pitrTargetXid    string
+	pitrTargetName   string
+	pitrTargetAction string
+)
+
+var pitrRestoreCmd = &cobra.Command{
+	Use:   "pitr-restore destination_directory --target-time <time> | --target-lsn <lsn> | --target-xid <xid> | --target-name <name>",
+	Short: pitrRestoreShortDescription,
+	Long:  pitrRestoreLongDescription,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.ConfigureLimiters()
+
+		target, err := createRecoveryTarget()
+		if err != nil {
+			fmt.Println(cmd.UsageString())
+			tracelog.ErrorLogger.FatalOnError(err)
+		}
+		switch pitrTargetAction {
+		case "", "pause", "promote", "shutdown":
+		default:
+			tracelog.ErrorLogger.Fatalf("Unknown target action '%s'\n", pitrTargetAction)
+		}
+
+		storage, err := internal.ConfigureMultiStorage(cmd.Context(), false)
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		rootFolder := multistorage.SetPolicies(storage.RootFolder(), policies.UniteAllStorages)
+		if targetStorage == "" {
+			rootFolder, err = multistorage.UseAllAliveStorages(cmd.Context(), rootFolder)
+		} else {
+			rootFolder, err = multistorage.UseSpecificStorage(cmd.Context(), targetStorage, rootFolder)
+		}
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		var pgFetcher internal.Fetcher
+		if viper.GetBool(conf.UseReverseUnpackSetting) {
+			pgFetcher = postgres.GetFetcherNew(args[0], "", "", viper.GetBool(conf.SkipRedundantTarsSetting),
+				postgres.ExtractProviderImpl{})
+		} else {
+			pgFetcher = postgres.GetFetcherOld(args[0], "", "", postgres.ExtractProviderImpl{})
+		}
+
+		err = postgres.HandlePitrRestore(cmd.Context(), rootFolder, args[0], target, pitrTargetAction, pgFetcher)
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+// createRecoveryTarget returns the recovery target from the only target flag specified
+func createRecoveryTarget() (postgres.RecoveryTarget, error) {
+	targets := map[postgres.RecoveryTargetType]string{
+		postgres.RecoveryTargetTime: pitrTargetTime,
+		postgres.RecoveryTargetLSN:  pitrTargetLSN,
+		postgres.RecoveryTargetXid:  pitrTargetXid,
+		postgres.RecoveryTargetName: pitrTargetName,
+	}
+	var target *postgres.RecoveryTarget
+	for targetType, value := range targets {
+		if value == "" {
+			continue
+		}
+		if target != nil {
+			return postgres.RecoveryTarget{}, errors.New("only one recovery target should be specified")
+		}
+		parsed, err := postgres.NewRecoveryTarget(targetType, value)
+		if err != nil {
+			return postgres.RecoveryTarget{}, err
+		}
+		target = &parsed
+	}
+	if target == nil {
+		return postgres.RecoveryTarget{}, errors.New("recovery target is not specified")
+	}
+	return *target, nil
+}
+
+func init() {
+	pitrRestoreCmd.Flags().StringVar(&pitrTargetTime, "target-time", "", targetTimeDescription)
+	pitrRestoreCmd.Flags().StringVar(&pitrTargetLSN, "target-lsn", "", targetLSNDescription)
+	pitrRestoreCmd.Flags().StringVar(&pitrTargetXid, "target-xid", "", targetXidDescription)
+	pitrRestoreCmd.Flags().StringVar(&pitrTargetName, "target-name", "", targetNameDescription)
+	pitrRestoreCmd.Flags().StringVar(&pitrTargetAction, "target-action", "", targetActionDescription)
+	pitrRestoreCmd.Flags().StringVar(&targetStorage, "target-storage", "", targetStorageDescription)
+
+	Cmd.AddCommand(pitrRestoreCmd)
+}
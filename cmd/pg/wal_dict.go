@@ -0,0 +1,50 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	walDictShortDescription      = "Manages zstd dictionaries WAL segments are compressed with"
+	walDictTrainShortDescription = "Trains a zstd dictionary on the most recent WAL segments and makes it current"
+
+	walDictSegmentsFlag        = "segments"
+	walDictSegmentsDescription = "Number of the most recent WAL segments to train the dictionary on"
+	walDictMaxSizeFlag         = "max-size"
+	walDictMaxSizeDescription  = "Maximum size of the dictionary in bytes"
+)
+
+var (
+	walDictCmd = &cobra.Command{
+		Use:   "wal-dict",
+		Short: walDictShortDescription,
+	}
+
+	walDictTrainCmd = &cobra.Command{
+		Use:   "train",
+		Short: walDictTrainShortDescription,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			uploader, err := internal.ConfigureUploaderWithoutCompressor(cmd.Context())
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			err = postgres.HandleWalDictionaryTrain(cmd.Context(), uploader, walDictSegments, walDictMaxSize)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+
+	walDictSegments int
+	walDictMaxSize  int
+)
+
+func init() {
+	Cmd.AddCommand(walDictCmd)
+	walDictCmd.AddCommand(walDictTrainCmd)
+	walDictTrainCmd.Flags().IntVar(&walDictSegments, walDictSegmentsFlag,
+		postgres.WalDictionaryDefaultSegments, walDictSegmentsDescription)
+	walDictTrainCmd.Flags().IntVar(&walDictMaxSize, walDictMaxSizeFlag,
+		postgres.WalDictionaryDefaultMaxSize, walDictMaxSizeDescription)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
 	"github.com/wal-g/wal-g/internal/databases/postgres/constants"
 )
@@ -29,6 +30,10 @@ var walFetchCmd = &cobra.Command{
 			tracelog.ErrorLogger.Print(err.Error())
 			os.Exit(constants.ExIoError)
 		}
+		if crypto.IsIntegrityError(err) {
+			tracelog.ErrorLogger.Printf("%v. It may be truncated or corrupted in storage.", err)
+			os.Exit(constants.ExDataError)
+		}
 		tracelog.ErrorLogger.FatalOnError(err)
 	},
 }
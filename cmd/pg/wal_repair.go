@@ -0,0 +1,36 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	walRepairShortDescription = "Copies the WAL segments missing from some failover storages from the others"
+	walRepairLongDescription  = "Takes the WAL segments that wal-push recorded to the repair journal because some " +
+		"storages didn't acknowledge them, and copies them to the alive storages they are missing from. " +
+		"wal-g daemon does the same periodically."
+)
+
+// walRepairCmd represents the walRepair command
+var walRepairCmd = &cobra.Command{
+	Use:   "wal-repair",
+	Short: walRepairShortDescription,
+	Long:  walRepairLongDescription,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		multiSt, err := internal.ConfigureMultiStorage(cmd.Context(), true)
+		tracelog.ErrorLogger.FatalOnError(err)
+		defer utility.LoggedClose(multiSt, "close multi-storage")
+
+		err = postgres.HandleWALRepair(cmd.Context(), multiSt)
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	Cmd.AddCommand(walRepairCmd)
+}
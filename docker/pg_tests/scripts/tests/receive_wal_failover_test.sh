@@ -0,0 +1,63 @@
+#!/bin/sh
+set -e -x
+
+. /tmp/tests/test_functions/prepare_config.sh
+prepare_config "/tmp/configs/receive_wal_failover_test_config.json"
+
+PGDATA_PRIMARY="${PGDATA}_primary"
+PGDATA_STANDBY="${PGDATA}_standby"
+PRIMARY_PORT=5432
+STANDBY_PORT=5433
+
+wait_for_file_in_storage() {
+  i=0
+  until wal-g --config=${TMP_CONFIG} st ls wal_005/ | grep -q "$1"; do
+    i=$((i + 1))
+    if [ $i -gt 60 ]; then
+      echo "$1 was not received"
+      return 1
+    fi
+    sleep 1
+  done
+}
+
+initdb ${PGDATA_PRIMARY}
+pg_ctl -D ${PGDATA_PRIMARY} -o "-p ${PRIMARY_PORT}" -w start
+pg_basebackup -D ${PGDATA_STANDBY} -R -p ${PRIMARY_PORT} --wal-method=stream
+pg_ctl -D ${PGDATA_STANDBY} -o "-p ${STANDBY_PORT}" -w start
+
+wal-g --config=${TMP_CONFIG} wal-receive &
+RECEIVE_PID=$!
+
+pgbench -i -s 5 -p ${PRIMARY_PORT} postgres
+psql -p ${PRIMARY_PORT} -c "select pg_switch_wal()"
+sleep 5
+
+# the slot must be kept on the standby too
+test "$(psql -p ${STANDBY_PORT} -tAc "select count(*) from pg_replication_slots where slot_name = 'walg'")" -eq 1
+
+pg_ctl -D ${PGDATA_PRIMARY} -w stop -m fast
+pg_ctl -D ${PGDATA_STANDBY} -w promote
+
+pgbench -i -s 5 -p ${STANDBY_PORT} postgres
+psql -p ${STANDBY_PORT} -c "select pg_switch_wal()"
+
+wait_for_file_in_storage 00000002.history
+wait_for_file_in_storage 00000002000000000000000
+
+VERIFY_OUTPUT=$(mktemp)
+PGPORT=${STANDBY_PORT} wal-g --config=${TMP_CONFIG} wal-verify integrity > "${VERIFY_OUTPUT}"
+cat "${VERIFY_OUTPUT}"
+
+kill ${RECEIVE_PID}
+pg_ctl -D ${PGDATA_STANDBY} -w stop -m immediate
+
+VERIFY_RESULT=$(awk 'BEGIN{FS=":"}$1~/integrity check status/{print $2}' "${VERIFY_OUTPUT}")
+if echo "$VERIFY_RESULT" | grep -qP "\bOK$"; then
+  rm -rf ${PGDATA_PRIMARY} ${PGDATA_STANDBY}
+  rm ${TMP_CONFIG}
+  echo "WAL receive failover success!!!!!!"
+  exit 0
+fi
+echo "WAL not received as expected after failover!!!!!"
+exit 1
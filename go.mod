@@ -4,6 +4,7 @@ go 1.27.0
 
 require (
 	cloud.google.com/go/storage v1.64.0
+	filippo.io/age v1.2.1
 	github.com/Azure/azure-sdk-for-go/sdk/azcore v1.23.0
 	github.com/Azure/azure-sdk-for-go/sdk/azidentity v1.14.0
 	github.com/Azure/azure-sdk-for-go/sdk/storage/azblob v1.8.0
@@ -50,6 +51,7 @@ require (
 	github.com/google/brotli/go/cbrotli v1.1.0
 	github.com/jedib0t/go-pretty/v6 v6.8.2
 	github.com/klauspost/compress v1.19.2
+	github.com/klauspost/pgzip v1.2.6
 	github.com/mongodb/mongo-tools v0.0.0-20260508170159-0b142f65e139
 	github.com/ncw/directio v1.0.5
 	github.com/ncw/swift/v2 v2.0.5
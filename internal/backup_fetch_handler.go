@@ -89,5 +89,21 @@ func HandleBackupFetch(ctx context.Context, folder storage.Folder, targetBackupS
 	tracelog.ErrorLogger.FatalfOnError("Failed to select backup: %v\n", err)
 	tracelog.DebugLogger.Printf("HandleBackupFetch(%s)\n", backup.Name)
 
+	err = restoreArchivedBackup(ctx, backup)
+	tracelog.ErrorLogger.FatalfOnError("Failed to restore backup from archive: %v\n", err)
+
 	fetcher(ctx, folder, backup)
 }
+
+// restoreArchivedBackup restores the backup and the backups it's incremented from, if WALG_ARCHIVE_RESTORE is set.
+func restoreArchivedBackup(ctx context.Context, backup Backup) error {
+	config, err := ConfigureArchiveRestore()
+	if err != nil || config == nil {
+		return err
+	}
+	backupNames, err := GetIncrementChain(ctx, backup)
+	if err != nil {
+		return err
+	}
+	return RestoreArchivedBackups(ctx, backup.Folder, backupNames, *config)
+}
@@ -13,10 +13,13 @@ type BackupFileDescription struct {
 	MTime         time.Time
 	CorruptBlocks *CorruptBlocksInfo `json:",omitempty"`
 	UpdatesCount  uint64
+	// Size is the size of the file in the tar, i.e. of the increment for the incremented files.
+	// Zero for the backups taken before it was recorded.
+	Size int64 `json:",omitempty"`
 }
 
 func NewBackupFileDescription(isIncremented, isSkipped bool, modTime time.Time) *BackupFileDescription {
-	return &BackupFileDescription{isIncremented, isSkipped, modTime, nil, 0}
+	return &BackupFileDescription{isIncremented, isSkipped, modTime, nil, 0, 0}
 }
 
 type CorruptBlocksInfo struct {
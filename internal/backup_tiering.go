@@ -0,0 +1,248 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+	"golang.org/x/sync/errgroup"
+)
+
+// tieringConcurrency limits the number of objects whose storage class is checked or changed at the same time.
+const tieringConcurrency = 16
+
+// TierBackups moves the data objects of the backups to the storage class. Sentinels and other JSON metadata stay in
+// the current class, so the backups can still be listed and selected without restoring anything.
+func TierBackups(ctx context.Context, rootFolder storage.Folder, backupNames []string, storageClass string, confirm bool) error {
+	var moved, skipped atomic.Int64
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	for _, backupName := range backupNames {
+		backupFolder, objects, err := listBackupDataObjects(ctx, baseBackupFolder, backupName)
+		if err != nil {
+			return err
+		}
+		tracelog.InfoLogger.Printf("Tiering backup %s to %s: %d objects\n", backupName, storageClass, len(objects))
+
+		errGroup, ctx := errgroup.WithContext(ctx)
+		errGroup.SetLimit(tieringConcurrency)
+		for _, object := range objects {
+			errGroup.Go(func() error {
+				currentClass, err := storage.GetStorageClass(ctx, backupFolder, object.GetName())
+				if _, ok := err.(storage.ObjectNotFoundError); ok {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("get storage class of %q: %w", object.GetName(), err)
+				}
+				if currentClass == storageClass {
+					skipped.Add(1)
+					return nil
+				}
+				if !confirm {
+					tracelog.InfoLogger.Printf("Object would be moved from %s to %s: %s/%s storage=%s\n",
+						currentClass, storageClass, backupName, object.GetName(), multistorage.GetStorage(object))
+					moved.Add(1)
+					return nil
+				}
+				err = storage.SetStorageClass(ctx, backupFolder, object.GetName(), storageClass)
+				if err != nil {
+					return fmt.Errorf("move %q to %s: %w", object.GetName(), storageClass, err)
+				}
+				tracelog.DebugLogger.Printf("Object moved from %s to %s: %s/%s\n",
+					currentClass, storageClass, backupName, object.GetName())
+				moved.Add(1)
+				return nil
+			})
+		}
+		if err := errGroup.Wait(); err != nil {
+			return fmt.Errorf("tier backup %s: %w", backupName, err)
+		}
+	}
+
+	if !confirm {
+		tracelog.InfoLogger.Printf("Dry run: objects would be moved to %s count=%d, already there count=%d. "+
+			"Run with --confirm to execute\n", storageClass, moved.Load(), skipped.Load())
+		return nil
+	}
+	tracelog.InfoLogger.Printf("Objects moved to %s: count=%d, already there count=%d\n",
+		storageClass, moved.Load(), skipped.Load())
+	return nil
+}
+
+// ArchiveRestoreConfig describes how the archived backup objects are restored before fetching them.
+type ArchiveRestoreConfig struct {
+	// Days is the number of days the temporary restored copies are kept for.
+	Days         int
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+// ConfigureArchiveRestore provides the config of restoring archived backups, or nil if it's disabled.
+func ConfigureArchiveRestore() (*ArchiveRestoreConfig, error) {
+	if !viper.GetBool(conf.ArchiveRestoreSetting) {
+		return nil, nil
+	}
+	days := viper.GetInt(conf.ArchiveRestoreDaysSetting)
+	if days < 1 {
+		return nil, fmt.Errorf("%s must be positive, got %d", conf.ArchiveRestoreDaysSetting, days)
+	}
+	timeout, err := conf.GetDurationSetting(conf.ArchiveRestoreTimeoutSetting)
+	if err != nil {
+		return nil, err
+	}
+	pollInterval, err := conf.GetDurationSetting(conf.ArchiveRestoreIntervalSetting)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveRestoreConfig{Days: days, PollInterval: pollInterval, Timeout: timeout}, nil
+}
+
+type archivedObject struct {
+	folder storage.Folder
+	name   string
+}
+
+// RestoreArchivedBackups requests the restore of all archived data objects of the backups, and waits until they
+// can be read. Restore requests for all objects are issued at once, since restoring from archive classes may take
+// hours.
+func RestoreArchivedBackups(ctx context.Context, baseBackupFolder storage.Folder, backupNames []string,
+	config ArchiveRestoreConfig) error {
+	var pending []archivedObject
+	for _, backupName := range backupNames {
+		backupFolder, objects, err := listBackupDataObjects(ctx, baseBackupFolder, backupName)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			pending = append(pending, archivedObject{backupFolder, object.GetName()})
+		}
+	}
+	tracelog.InfoLogger.Printf("Checking if %d objects of backups %v have to be restored from archive\n",
+		len(pending), backupNames)
+
+	deadline := time.Now().Add(config.Timeout)
+	for {
+		var err error
+		pending, err = restoreArchivedObjects(ctx, pending, config.Days)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().Add(config.PollInterval).After(deadline) {
+			return fmt.Errorf("%d archived objects weren't restored in %v, the first one is %q",
+				len(pending), config.Timeout, path.Join(pending[0].folder.GetPath(), pending[0].name))
+		}
+		tracelog.InfoLogger.Printf("Waiting for %d objects to be restored from archive, next check in %v\n",
+			len(pending), config.PollInterval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(config.PollInterval):
+		}
+	}
+}
+
+// restoreArchivedObjects requests the restore of the objects and provides the ones that aren't readable yet.
+func restoreArchivedObjects(ctx context.Context, objects []archivedObject, days int) ([]archivedObject, error) {
+	ready := make([]bool, len(objects))
+	errGroup, ctx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(tieringConcurrency)
+	for i, object := range objects {
+		errGroup.Go(func() error {
+			var err error
+			ready[i], err = storage.RestoreObject(ctx, object.folder, object.name, days)
+			if err != nil {
+				return fmt.Errorf("restore %q from archive: %w", object.name, err)
+			}
+			return nil
+		})
+	}
+	if err := errGroup.Wait(); err != nil {
+		return nil, err
+	}
+
+	pending := make([]archivedObject, 0)
+	for i, object := range objects {
+		if !ready[i] {
+			pending = append(pending, object)
+		}
+	}
+	return pending, nil
+}
+
+// GetIncrementChain provides the names of the backup and all backups it's incremented from, following the
+// "DeltaFrom" field of sentinels. Backups of databases that don't have increments are returned alone.
+func GetIncrementChain(ctx context.Context, backup Backup) ([]string, error) {
+	chain := []string{backup.Name}
+	for {
+		var sentinel struct {
+			IncrementFrom *string `json:"DeltaFrom,omitempty"`
+		}
+		name := chain[len(chain)-1]
+		err := FetchDto(ctx, backup.Folder, &sentinel, SentinelNameFromBackup(name))
+		if err != nil {
+			return nil, fmt.Errorf("fetch sentinel of backup %s: %w", name, err)
+		}
+		if sentinel.IncrementFrom == nil || *sentinel.IncrementFrom == "" {
+			return chain, nil
+		}
+		chain = append(chain, *sentinel.IncrementFrom)
+	}
+}
+
+// ExcludeIncrementBases removes the backups which any of the retained backups is incremented from, directly or through
+// other backups, since a retained delta backup can't be restored quickly while its bases are in a cold storage class.
+func ExcludeIncrementBases(ctx context.Context, baseBackupFolder storage.Folder, backupNames, retainedNames []string) (
+	[]string, error) {
+	bases := make(map[string]bool)
+	for _, name := range retainedNames {
+		if bases[name] {
+			// its chain is already known
+			continue
+		}
+		chain, err := GetIncrementChain(ctx, Backup{Name: name, Folder: baseBackupFolder})
+		if err != nil {
+			return nil, err
+		}
+		for _, base := range chain[1:] {
+			bases[base] = true
+		}
+	}
+
+	result := make([]string, 0, len(backupNames))
+	for _, name := range backupNames {
+		if bases[name] {
+			tracelog.InfoLogger.Printf("Backup %s is the base of a retained backup, it won't be moved", name)
+			continue
+		}
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+// listBackupDataObjects lists the data objects of the backup, relative to the returned backup folder.
+func listBackupDataObjects(ctx context.Context, baseBackupFolder storage.Folder, backupName string) (
+	storage.Folder, []storage.Object, error) {
+	backupFolder := baseBackupFolder.GetSubFolder(backupName)
+	objects, err := storage.ListFolderRecursively(ctx, backupFolder)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list objects of backup %s: %w", backupName, err)
+	}
+	dataObjects := make([]storage.Object, 0, len(objects))
+	for _, object := range objects {
+		if isBackupDataObject(path.Join(utility.BaseBackupPath, backupName, object.GetName())) {
+			dataObjects = append(dataObjects, object)
+		}
+	}
+	return backupFolder, dataObjects, nil
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func createTieringTestFolder(t *testing.T) storage.Folder {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	require.NoError(t, baseBackupFolder.PutObject(t.Context(), "base_1_backup_stop_sentinel.json",
+		strings.NewReader("{}")))
+	for _, name := range []string{
+		"base_1/metadata.json",
+		"base_1/tar_partitions/part_1.tar.br",
+		"base_1/tar_partitions/part_2.tar.br",
+	} {
+		require.NoError(t, baseBackupFolder.PutObject(t.Context(), name, &bytes.Buffer{}))
+	}
+	require.NoError(t, baseBackupFolder.PutObject(t.Context(), "base_2_backup_stop_sentinel.json",
+		strings.NewReader(`{"DeltaFrom":"base_1"}`)))
+	return folder
+}
+
+func TestTierBackups(t *testing.T) {
+	folder := createTieringTestFolder(t)
+	backupFolder := folder.GetSubFolder(utility.BaseBackupPath).GetSubFolder("base_1")
+
+	err := TierBackups(t.Context(), folder, []string{"base_1"}, "GLACIER", false)
+	require.NoError(t, err)
+	class, err := storage.GetStorageClass(t.Context(), backupFolder, "tar_partitions/part_1.tar.br")
+	require.NoError(t, err)
+	assert.Equal(t, memory.DefaultStorageClass, class, "dry run must not move objects")
+
+	err = TierBackups(t.Context(), folder, []string{"base_1"}, "GLACIER", true)
+	require.NoError(t, err)
+	for _, name := range []string{"tar_partitions/part_1.tar.br", "tar_partitions/part_2.tar.br"} {
+		class, err = storage.GetStorageClass(t.Context(), backupFolder, name)
+		require.NoError(t, err)
+		assert.Equal(t, "GLACIER", class, name)
+	}
+	class, err = storage.GetStorageClass(t.Context(), backupFolder, "metadata.json")
+	require.NoError(t, err)
+	assert.Equal(t, memory.DefaultStorageClass, class, "metadata must stay in the current class")
+}
+
+func TestRestoreArchivedBackups(t *testing.T) {
+	folder := createTieringTestFolder(t)
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	require.NoError(t, TierBackups(t.Context(), folder, []string{"base_1"}, "GLACIER", true))
+
+	config := ArchiveRestoreConfig{Days: 1, PollInterval: time.Millisecond, Timeout: time.Minute}
+	err := RestoreArchivedBackups(t.Context(), baseBackupFolder, []string{"base_1"}, config)
+	assert.NoError(t, err)
+}
+
+func TestRestoreArchivedBackupsTimeout(t *testing.T) {
+	folder := createTieringTestFolder(t)
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	require.NoError(t, TierBackups(t.Context(), folder, []string{"base_1"}, "GLACIER", true))
+
+	config := ArchiveRestoreConfig{Days: 1, PollInterval: time.Minute, Timeout: time.Second}
+	err := RestoreArchivedBackups(t.Context(), baseBackupFolder, []string{"base_1"}, config)
+	assert.ErrorContains(t, err, "2 archived objects weren't restored")
+}
+
+func TestGetIncrementChain(t *testing.T) {
+	folder := createTieringTestFolder(t)
+	backup := Backup{Name: "base_2", Folder: folder.GetSubFolder(utility.BaseBackupPath)}
+
+	chain, err := GetIncrementChain(t.Context(), backup)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base_2", "base_1"}, chain)
+}
+
+func TestExcludeIncrementBases(t *testing.T) {
+	folder := createTieringTestFolder(t)
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	require.NoError(t, baseBackupFolder.PutObject(t.Context(), "base_0_backup_stop_sentinel.json",
+		strings.NewReader("{}")))
+	require.NoError(t, baseBackupFolder.PutObject(t.Context(), "base_3_backup_stop_sentinel.json",
+		strings.NewReader(`{"DeltaFrom":"base_2"}`)))
+
+	names, err := ExcludeIncrementBases(t.Context(), baseBackupFolder,
+		[]string{"base_0", "base_1", "base_2"}, []string{"base_3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base_0"}, names)
+
+	names, err = ExcludeIncrementBases(t.Context(), baseBackupFolder,
+		[]string{"base_0", "base_1"}, []string{"base_2", "base_3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base_0"}, names)
+
+	names, err = ExcludeIncrementBases(t.Context(), baseBackupFolder, []string{"base_0", "base_1"}, []string{"base_0"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base_0", "base_1"}, names)
+}
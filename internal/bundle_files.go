@@ -27,7 +27,7 @@ func (files *RegularBundleFiles) AddSkippedFile(tarHeader *tar.Header, fileInfo
 
 func (files *RegularBundleFiles) AddFile(tarHeader *tar.Header, fileInfo os.FileInfo, isIncremented bool) {
 	files.AddFileDescription(tarHeader.Name,
-		BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime()})
+		BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime(), Size: tarHeader.Size})
 }
 
 func (files *RegularBundleFiles) AddFileDescription(name string, backupFileDescription BackupFileDescription) {
@@ -36,7 +36,8 @@ func (files *RegularBundleFiles) AddFileDescription(name string, backupFileDescr
 
 func (files *RegularBundleFiles) AddFileWithCorruptBlocks(tarHeader *tar.Header, fileInfo os.FileInfo,
 	isIncremented bool, corruptedBlocks []uint32, storeAllBlocks bool) {
-	fileDescription := BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime()}
+	fileDescription := BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime(),
+		Size: tarHeader.Size}
 	fileDescription.SetCorruptBlocks(corruptedBlocks, storeAllBlocks)
 	files.AddFileDescription(tarHeader.Name, fileDescription)
 }
@@ -5,9 +5,12 @@ import (
 	"io"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/compression"
+	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/objectheader"
 	"github.com/wal-g/wal-g/utility"
 )
 
@@ -30,10 +33,20 @@ func (err CompressAndEncryptError) Error() string {
 }
 
 // CompressAndEncrypt compresses input to a pipe reader. Output must be used or
-// pipe will block.
+// pipe will block. If WALG_OBJECT_HEADER is enabled, the output starts with objectheader.Header.
 func CompressAndEncrypt(source io.Reader, compressor compression.Compressor, crypter crypto.Crypter) io.Reader {
 	compressedReader, dstWriter := io.Pipe()
 
+	var header []byte
+	if viper.GetBool(conf.ObjectHeaderSetting) {
+		var err error
+		header, err = newObjectHeader(compressor, crypter).Marshal()
+		if err != nil {
+			_ = dstWriter.CloseWithError(newCompressingPipeWriterError("CompressAndEncrypt: object header marshal failed", err))
+			return compressedReader
+		}
+	}
+
 	var writeCloser io.WriteCloser = dstWriter
 	if crypter != nil {
 		var err error
@@ -53,6 +66,12 @@ func CompressAndEncrypt(source io.Reader, compressor compression.Compressor, cry
 	}
 
 	go func() {
+		// crypters don't write anything until the first write, so the header goes first
+		if len(header) > 0 {
+			if _, err := dstWriter.Write(header); err != nil {
+				return
+			}
+		}
 		if _, err := utility.FastCopy(compressedWriter, source); err != nil {
 			e := newCompressingPipeWriterError("CompressAndEncrypt: compression failed", err)
 			_ = dstWriter.CloseWithError(e)
@@ -74,3 +93,25 @@ func CompressAndEncrypt(source io.Reader, compressor compression.Compressor, cry
 	}()
 	return compressedReader
 }
+
+func newObjectHeader(compressor compression.Compressor, crypter crypto.Crypter) objectheader.Header {
+	header := objectheader.Header{}
+	if compressor != nil {
+		header.Compression = compressor.FileExtension()
+		if header.Compression == "" {
+			header.Compression = objectheader.NoCompression
+		}
+		if levelNamer, ok := compressor.(compression.LevelNamer); ok {
+			header.CompressionLevel = levelNamer.LevelName()
+		}
+	}
+	if crypter != nil {
+		header.Crypter = crypter.Name()
+		keyID, err := crypto.KeyFingerprint(crypter)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to get the key fingerprint for the object header: %v", err)
+		}
+		header.KeyID = keyID
+	}
+	return header
+}
@@ -0,0 +1,270 @@
+package adaptive
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+)
+
+const (
+	AlgorithmName = "adaptive"
+	FileExtension = "adaptive"
+
+	MethodNone = "none"
+	MethodZstd = "zstd"
+
+	// BlockSize is the size of the blocks of the stream the compression is chosen for separately
+	BlockSize = 1 << 20
+	// NoneRatioThreshold is the ratio of the block compressed with the fast level, starting from which the block
+	// is not compressed at all, since it's not worth the CPU time, e.g. for already compressed TOAST data
+	NoneRatioThreshold = 0.9
+	// StrongGainThreshold is the ratio of the sizes of the block compressed with the strong and the fast levels,
+	// below which the strong level is chosen
+	StrongGainThreshold = 0.9
+
+	DefaultStrongLevel = zstd.SpeedBetterCompression
+)
+
+// Decision describes the compression chosen for the blocks of an object and why. Method and Level are the ones
+// chosen for most of the blocks.
+type Decision struct {
+	Name             string        `json:"Name"`
+	Method           string        `json:"Method"`
+	Level            string        `json:"Level,omitempty"`
+	NoneBlocks       int           `json:"NoneBlocks"`
+	FastBlocks       int           `json:"FastBlocks"`
+	StrongBlocks     int           `json:"StrongBlocks"`
+	FastRatio        float64       `json:"FastRatio"`
+	FastTime         time.Duration `json:"FastTime"`
+	StrongRatio      float64       `json:"StrongRatio,omitempty"`
+	StrongTime       time.Duration `json:"StrongTime,omitempty"`
+	UncompressedSize int64         `json:"UncompressedSize"`
+	CompressedSize   int64         `json:"CompressedSize"`
+}
+
+// Compressor splits every stream into blocks of BlockSize and chooses for each of them between no compression,
+// the fast and the strong zstd level by compressing it with both levels. So the choice follows the data as it
+// changes within a tar part, e.g. from already compressed TOAST tables to plain relation files. The choice is
+// recorded at the beginning of each block, so Decompressor doesn't need anything else to read it.
+type Compressor struct {
+	FastLevel   zstd.EncoderLevel
+	StrongLevel zstd.EncoderLevel
+
+	mutex     sync.Mutex
+	decisions []Decision
+}
+
+// NewCompressor creates Compressor with the fastest zstd level as the fast one
+func NewCompressor(strongLevel zstd.EncoderLevel) *Compressor {
+	return &Compressor{FastLevel: zstd.SpeedFastest, StrongLevel: strongLevel}
+}
+
+func (compressor *Compressor) NewWriter(writer io.Writer) ioextensions.WriteFlushCloser {
+	return compressor.NewNamedWriter(writer, "")
+}
+
+// NewNamedWriter creates the compressing writer, which decision is reported by Decisions after it's closed
+func (compressor *Compressor) NewNamedWriter(writer io.Writer, name string) ioextensions.WriteFlushCloser {
+	fastEncoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(compressor.FastLevel), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return ioextensions.FailedWriter{Err: fmt.Errorf("create fast zstd encoder: %w", err)}
+	}
+	strongEncoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(compressor.StrongLevel), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		_ = fastEncoder.Close()
+		return ioextensions.FailedWriter{Err: fmt.Errorf("create strong zstd encoder: %w", err)}
+	}
+	return &Writer{
+		compressor:    compressor,
+		output:        &countingWriter{Writer: writer},
+		name:          name,
+		fastEncoder:   fastEncoder,
+		strongEncoder: strongEncoder,
+	}
+}
+
+func (compressor *Compressor) FileExtension() string {
+	return FileExtension
+}
+
+// Decisions provides the decisions made for the named streams so far, sorted by the name
+func (compressor *Compressor) Decisions() []Decision {
+	compressor.mutex.Lock()
+	defer compressor.mutex.Unlock()
+	decisions := append([]Decision(nil), compressor.decisions...)
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].Name < decisions[j].Name
+	})
+	return decisions
+}
+
+func (compressor *Compressor) addDecision(decision Decision) {
+	compressor.mutex.Lock()
+	defer compressor.mutex.Unlock()
+	compressor.decisions = append(compressor.decisions, decision)
+}
+
+// Decisions provides the decisions made by the compressor if it's adaptive, and nil otherwise
+func Decisions(compressor interface{}) []Decision {
+	adaptiveCompressor, ok := compressor.(*Compressor)
+	if !ok {
+		return nil
+	}
+	return adaptiveCompressor.Decisions()
+}
+
+// Writer buffers the stream by blocks and writes each of them compressed as chosen for it
+type Writer struct {
+	compressor    *Compressor
+	output        *countingWriter
+	name          string
+	fastEncoder   *zstd.Encoder
+	strongEncoder *zstd.Encoder
+
+	block            []byte
+	fastBuffer       []byte
+	strongBuffer     []byte
+	decision         Decision
+	fastInput        int64
+	fastOutput       int64
+	strongInput      int64
+	strongOutput     int64
+	uncompressedSize int64
+	started          bool
+	closed           bool
+}
+
+func (writer *Writer) Write(p []byte) (n int, err error) {
+	writer.uncompressedSize += int64(len(p))
+	for len(p) > 0 {
+		size := min(len(p), BlockSize-len(writer.block))
+		writer.block = append(writer.block, p[:size]...)
+		p = p[size:]
+		n += size
+		if len(writer.block) < BlockSize {
+			continue
+		}
+		if err = writer.writeBlock(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// writeBlock writes the buffered block with the method and the length of the payload before it. The stream header
+// is written before the first block, even if the stream is empty.
+func (writer *Writer) writeBlock() error {
+	if !writer.started {
+		writer.started = true
+		if _, err := writer.output.Write(append([]byte(magic), methodBlocks)); err != nil {
+			return err
+		}
+	}
+	if len(writer.block) == 0 {
+		return nil
+	}
+
+	method, payload := writer.encodeBlock(writer.block)
+	header := make([]byte, blockHeaderSize)
+	header[0] = method
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := writer.output.Write(header); err != nil {
+		return err
+	}
+	if _, err := writer.output.Write(payload); err != nil {
+		return err
+	}
+	writer.block = writer.block[:0]
+	return nil
+}
+
+// encodeBlock compresses the block with the fast level, and with the strong level too if it's compressible,
+// and provides the method and the payload chosen for it
+func (writer *Writer) encodeBlock(block []byte) (byte, []byte) {
+	start := time.Now()
+	writer.fastBuffer = writer.fastEncoder.EncodeAll(block, writer.fastBuffer[:0])
+	writer.decision.FastTime += time.Since(start)
+	writer.fastInput += int64(len(block))
+	writer.fastOutput += int64(len(writer.fastBuffer))
+	if float64(len(writer.fastBuffer)) >= float64(len(block))*NoneRatioThreshold {
+		writer.decision.NoneBlocks++
+		return methodNone, block
+	}
+
+	start = time.Now()
+	writer.strongBuffer = writer.strongEncoder.EncodeAll(block, writer.strongBuffer[:0])
+	writer.decision.StrongTime += time.Since(start)
+	writer.strongInput += int64(len(block))
+	writer.strongOutput += int64(len(writer.strongBuffer))
+	if float64(len(writer.strongBuffer)) < float64(len(writer.fastBuffer))*StrongGainThreshold {
+		writer.decision.StrongBlocks++
+		return methodZstd, writer.strongBuffer
+	}
+	writer.decision.FastBlocks++
+	return methodZstd, writer.fastBuffer
+}
+
+// Flush writes the incomplete block buffered so far
+func (writer *Writer) Flush() error {
+	return writer.writeBlock()
+}
+
+func (writer *Writer) Close() error {
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	defer func() {
+		_ = writer.fastEncoder.Close()
+		_ = writer.strongEncoder.Close()
+	}()
+
+	if err := writer.writeBlock(); err != nil {
+		return err
+	}
+	if writer.name != "" {
+		writer.compressor.addDecision(writer.summarize())
+	}
+	return nil
+}
+
+// summarize completes the decision with the method and the level chosen for most of the blocks, and the ratios
+// of all the blocks compressed with each level
+func (writer *Writer) summarize() Decision {
+	decision := writer.decision
+	decision.Name = writer.name
+	decision.Method = MethodNone
+	if decision.FastBlocks+decision.StrongBlocks > decision.NoneBlocks {
+		decision.Method = MethodZstd
+		decision.Level = writer.compressor.FastLevel.String()
+		if decision.StrongBlocks > decision.FastBlocks {
+			decision.Level = writer.compressor.StrongLevel.String()
+		}
+	}
+	if writer.fastInput > 0 {
+		decision.FastRatio = float64(writer.fastOutput) / float64(writer.fastInput)
+	}
+	if writer.strongInput > 0 {
+		decision.StrongRatio = float64(writer.strongOutput) / float64(writer.strongInput)
+	}
+	decision.UncompressedSize = writer.uncompressedSize
+	decision.CompressedSize = writer.output.written
+	return decision
+}
+
+type countingWriter struct {
+	io.Writer
+	written int64
+}
+
+func (writer *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = writer.Writer.Write(p)
+	writer.written += int64(n)
+	return
+}
@@ -0,0 +1,113 @@
+package adaptive
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, compressor *Compressor, name string, data []byte) []byte {
+	var compressed bytes.Buffer
+	writer := compressor.NewNamedWriter(&compressed, name)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return compressed.Bytes()
+}
+
+func decompress(t *testing.T, compressed []byte) []byte {
+	reader, err := Decompressor{}.Decompress(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return data
+}
+
+func TestCompressor_ChoosesByData(t *testing.T) {
+	random := make([]byte, 2*BlockSize)
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+	text := bytes.Repeat([]byte("INSERT INTO accounts VALUES (42, 'compressible');\n"), 2*BlockSize/50)
+
+	compressor := NewCompressor(DefaultStrongLevel)
+	for name, data := range map[string][]byte{"random": random, "text": text, "small": []byte("small"), "empty": {}} {
+		compressed := compress(t, compressor, name, data)
+		assert.Equal(t, data, decompress(t, compressed), name)
+	}
+
+	decisions := make(map[string]Decision)
+	for _, decision := range compressor.Decisions() {
+		decisions[decision.Name] = decision
+	}
+	require.Len(t, decisions, 4)
+
+	assert.Equal(t, MethodNone, decisions["random"].Method)
+	assert.Equal(t, 2, decisions["random"].NoneBlocks)
+	assert.GreaterOrEqual(t, decisions["random"].FastRatio, NoneRatioThreshold)
+	assert.Equal(t, int64(len(random)), decisions["random"].UncompressedSize)
+	assert.Equal(t, int64(len(random)+len(magic)+1+2*blockHeaderSize), decisions["random"].CompressedSize)
+
+	assert.Equal(t, MethodZstd, decisions["text"].Method)
+	assert.NotEmpty(t, decisions["text"].Level)
+	assert.Equal(t, 2, decisions["text"].FastBlocks+decisions["text"].StrongBlocks)
+	assert.Less(t, decisions["text"].FastRatio, NoneRatioThreshold)
+	assert.Less(t, decisions["text"].CompressedSize, decisions["text"].UncompressedSize)
+
+	assert.Equal(t, MethodNone, decisions["empty"].Method)
+	assert.Equal(t, Decision{Name: "empty", Method: MethodNone, CompressedSize: int64(len(magic) + 1)}, decisions["empty"])
+}
+
+func TestCompressor_ChoosesByBlock(t *testing.T) {
+	data := make([]byte, BlockSize)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	data = append(data, bytes.Repeat([]byte("compressible relation page "), 2*BlockSize/27)...)
+
+	compressor := NewCompressor(DefaultStrongLevel)
+	compressed := compress(t, compressor, "mixed", data)
+	assert.Equal(t, data, decompress(t, compressed))
+
+	decisions := compressor.Decisions()
+	require.Len(t, decisions, 1)
+	assert.Equal(t, 1, decisions[0].NoneBlocks)
+	assert.Equal(t, 2, decisions[0].FastBlocks+decisions[0].StrongBlocks)
+	assert.Less(t, decisions[0].CompressedSize, int64(BlockSize+BlockSize/2))
+}
+
+func TestDecompressor_SingleMethodStream(t *testing.T) {
+	compressed := append([]byte(magic), methodNone)
+	compressed = append(compressed, "stored as is"...)
+	assert.Equal(t, "stored as is", string(decompress(t, compressed)))
+}
+
+func TestCompressor_Flush(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := NewCompressor(DefaultStrongLevel).NewWriter(&compressed)
+	_, err := writer.Write([]byte("before flush "))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+	_, err = writer.Write([]byte("after flush"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, "before flush after flush", string(decompress(t, compressed.Bytes())))
+}
+
+func TestDecompressor_TruncatedBlock(t *testing.T) {
+	compressed := compress(t, NewCompressor(DefaultStrongLevel), "", []byte("some data"))
+	reader, err := Decompressor{}.Decompress(bytes.NewReader(compressed[:len(compressed)-2]))
+	require.NoError(t, err)
+	defer reader.Close()
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+}
+
+func TestDecompressor_InvalidMagic(t *testing.T) {
+	_, err := Decompressor{}.Decompress(bytes.NewReader([]byte("not adaptive")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package adaptive
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	zstdcompression "github.com/wal-g/wal-g/internal/compression/zstd"
+)
+
+// Every adaptive stream starts with the magic and the byte of the chosen method. The streams written by the
+// older versions are compressed with a single method, while the current ones consist of blocks, each starting with
+// the byte of its method and the big-endian uint32 length of its payload.
+const (
+	magic = "WGAD"
+
+	methodNone   byte = 0
+	methodZstd   byte = 1
+	methodBlocks byte = 2
+
+	blockHeaderSize = 5
+	// maxPayloadSize protects from allocating too much for a corrupted block header, zstd output of a block is only
+	// slightly larger than the block
+	maxPayloadSize = 2 * BlockSize
+)
+
+type Decompressor struct{}
+
+func (decompressor Decompressor) Decompress(src io.Reader) (io.ReadCloser, error) {
+	prefix := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(src, prefix); err != nil {
+		return nil, errors.Wrap(err, "failed to read the adaptive compression method")
+	}
+	if string(prefix[:len(magic)]) != magic {
+		return nil, errors.New("not an adaptive compression stream: invalid magic")
+	}
+
+	switch prefix[len(magic)] {
+	case methodNone:
+		return io.NopCloser(src), nil
+	case methodZstd:
+		return zstdcompression.Decompressor{}.Decompress(src)
+	case methodBlocks:
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create zstd decoder")
+		}
+		return &blockReader{src: src, decoder: decoder}, nil
+	default:
+		return nil, errors.Errorf("unknown adaptive compression method %d", prefix[len(magic)])
+	}
+}
+
+func (decompressor Decompressor) FileExtension() string {
+	return FileExtension
+}
+
+// blockReader decodes the blocks of the stream one by one
+type blockReader struct {
+	src     io.Reader
+	decoder *zstd.Decoder
+
+	payload []byte
+	decoded []byte
+	pending []byte
+}
+
+func (reader *blockReader) Read(p []byte) (int, error) {
+	for len(reader.pending) == 0 {
+		if err := reader.readBlock(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, reader.pending)
+	reader.pending = reader.pending[n:]
+	return n, nil
+}
+
+func (reader *blockReader) readBlock() error {
+	header := make([]byte, blockHeaderSize)
+	if _, err := io.ReadFull(reader.src, header); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return errors.Wrap(err, "failed to read the adaptive compression block header")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxPayloadSize {
+		return errors.Errorf("adaptive compression block is too large: %d bytes", size)
+	}
+	if cap(reader.payload) < int(size) {
+		reader.payload = make([]byte, size)
+	}
+	reader.payload = reader.payload[:size]
+	if _, err := io.ReadFull(reader.src, reader.payload); err != nil {
+		return errors.Wrap(err, "failed to read the adaptive compression block")
+	}
+
+	switch header[0] {
+	case methodNone:
+		reader.pending = reader.payload
+	case methodZstd:
+		decoded, err := reader.decoder.DecodeAll(reader.payload, reader.decoded[:0])
+		if err != nil {
+			return errors.Wrap(err, "failed to decompress the adaptive compression block")
+		}
+		reader.decoded = decoded
+		reader.pending = decoded
+	default:
+		return errors.Errorf("unknown adaptive compression block method %d", header[0])
+	}
+	return nil
+}
+
+func (reader *blockReader) Close() error {
+	reader.decoder.Close()
+	return nil
+}
@@ -0,0 +1,11 @@
+package compression
+
+import (
+	"github.com/wal-g/wal-g/internal/compression/adaptive"
+)
+
+func init() {
+	Decompressors = append(Decompressors, adaptive.Decompressor{})
+	Compressors[adaptive.AlgorithmName] = adaptive.NewCompressor(adaptive.DefaultStrongLevel)
+	CompressingAlgorithms = append(CompressingAlgorithms, adaptive.AlgorithmName)
+}
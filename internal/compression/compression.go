@@ -16,6 +16,26 @@ type Decompressor interface {
 	FileExtension() string
 }
 
+// LevelNamer is implemented by compressors with a configurable level, so that the level can be recorded in
+// object headers.
+type LevelNamer interface {
+	LevelName() string
+}
+
+// NamedCompressor is implemented by compressors that report statistics for every compressed object, so they need
+// to know its name.
+type NamedCompressor interface {
+	NewNamedWriter(writer io.Writer, name string) ioextensions.WriteFlushCloser
+}
+
+// NewWriter creates the compressing writer for the named object.
+func NewWriter(compressor Compressor, writer io.Writer, name string) ioextensions.WriteFlushCloser {
+	if namedCompressor, ok := compressor.(NamedCompressor); ok {
+		return namedCompressor.NewNamedWriter(writer, name)
+	}
+	return compressor.NewWriter(writer)
+}
+
 func GetDecompressorByCompressor(compressor Compressor) Decompressor {
 	return FindDecompressor(compressor.FileExtension())
 }
@@ -10,11 +10,12 @@ import (
 	"github.com/wal-g/wal-g/internal/compression/none"
 )
 
-var CompressingAlgorithms = []string{lz4.AlgorithmName, lzma.AlgorithmName, none.AlgorithmName}
+var CompressingAlgorithms = []string{lz4.AlgorithmName, lzma.AlgorithmName, gzip.AlgorithmName, none.AlgorithmName}
 
 var Compressors = map[string]Compressor{
 	lz4.AlgorithmName:  lz4.Compressor{},
 	lzma.AlgorithmName: lzma.Compressor{},
+	gzip.AlgorithmName: gzip.Compressor{},
 	none.AlgorithmName: none.Compressor{},
 }
 
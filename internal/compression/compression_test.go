@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal/compression/gzip"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
 	"github.com/wal-g/wal-g/utility"
 )
 
@@ -65,3 +67,83 @@ func TestBigDataCompression(t *testing.T) {
 		testCompressor(compressor, testData, t)
 	}
 }
+
+var parallelCompressors = map[string]Compressor{
+	"zstd": zstd.Compressor{Concurrency: 4},
+	"gzip": gzip.Compressor{Concurrency: 4},
+}
+
+func TestParallelCompression(t *testing.T) {
+	const BigDataSize = 10 << 20
+	var testData bytes.Buffer
+	io.Copy(&testData, io.LimitReader(NewBiasedRandomReader(), BigDataSize))
+	for name, compressor := range parallelCompressors {
+		t.Run(name, func(t *testing.T) {
+			testCompressor(compressor, testData, t)
+			testCompressor(compressor, *bytes.NewBufferString("small"), t)
+		})
+	}
+}
+
+func benchmarkData(b *testing.B) []byte {
+	const BenchmarkDataSize = 16 << 20
+	var testData bytes.Buffer
+	_, err := io.Copy(&testData, io.LimitReader(NewBiasedRandomReader(), BenchmarkDataSize))
+	assert.NoError(b, err)
+	return testData.Bytes()
+}
+
+func BenchmarkCompression(b *testing.B) {
+	data := benchmarkData(b)
+	for _, compressingAlgorithm := range CompressingAlgorithms {
+		compressor := Compressors[compressingAlgorithm]
+		b.Run(compressingAlgorithm, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				compressingWriter := compressor.NewWriter(io.Discard)
+				_, err := compressingWriter.Write(data)
+				assert.NoError(b, err)
+				assert.NoError(b, compressingWriter.Close())
+			}
+		})
+	}
+}
+
+func BenchmarkDecompression(b *testing.B) {
+	data := benchmarkData(b)
+	for _, compressingAlgorithm := range CompressingAlgorithms {
+		compressor := Compressors[compressingAlgorithm]
+		var compressed bytes.Buffer
+		compressingWriter := compressor.NewWriter(&compressed)
+		_, err := compressingWriter.Write(data)
+		assert.NoError(b, err)
+		assert.NoError(b, compressingWriter.Close())
+
+		decompressor := GetDecompressorByCompressor(compressor)
+		b.Run(compressingAlgorithm, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				dr, err := decompressor.Decompress(bytes.NewReader(compressed.Bytes()))
+				assert.NoError(b, err)
+				_, err = io.Copy(io.Discard, dr)
+				assert.NoError(b, err)
+				assert.NoError(b, dr.Close())
+			}
+		})
+	}
+}
+
+func BenchmarkParallelCompression(b *testing.B) {
+	data := benchmarkData(b)
+	for name, compressor := range parallelCompressors {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				compressingWriter := compressor.NewWriter(io.Discard)
+				_, err := compressingWriter.Write(data)
+				assert.NoError(b, err)
+				assert.NoError(b, compressingWriter.Close())
+			}
+		})
+	}
+}
@@ -2,13 +2,36 @@ package gzip
 
 import (
 	"compress/gzip"
+	"fmt"
 	"io"
+
+	"github.com/klauspost/pgzip"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+)
+
+const (
+	AlgorithmName = "gzip"
+
+	// ParallelBlockSize is the size of the blocks compressed by the workers at once
+	ParallelBlockSize = 1 << 20
 )
 
-type Compressor struct{}
+// Compressor writes gzip streams. With Concurrency above one, the stream is split into blocks compressed
+// by that many goroutines at once, and the output is still a single ordinary gzip member.
+type Compressor struct {
+	Concurrency int
+}
+
+func (compressor Compressor) NewWriter(writer io.Writer) ioextensions.WriteFlushCloser {
+	if compressor.Concurrency <= 1 {
+		return gzip.NewWriter(writer)
+	}
 
-func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
-	return gzip.NewWriter(writer)
+	parallelWriter := pgzip.NewWriter(writer)
+	if err := parallelWriter.SetConcurrency(ParallelBlockSize, compressor.Concurrency); err != nil {
+		return ioextensions.FailedWriter{Err: fmt.Errorf("configure parallel gzip writer: %w", err)}
+	}
+	return parallelWriter
 }
 
 func (compressor Compressor) FileExtension() string {
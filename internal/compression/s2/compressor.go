@@ -0,0 +1,24 @@
+package s2
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+)
+
+const (
+	AlgorithmName = "s2"
+	FileExtension = "s2"
+)
+
+// Compressor writes S2 streams. Blocks are compressed concurrently on all available cores.
+type Compressor struct{}
+
+func (compressor Compressor) NewWriter(writer io.Writer) ioextensions.WriteFlushCloser {
+	return s2.NewWriter(writer)
+}
+
+func (compressor Compressor) FileExtension() string {
+	return FileExtension
+}
@@ -0,0 +1,17 @@
+package s2
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/s2"
+)
+
+type Decompressor struct{}
+
+func (decompressor Decompressor) Decompress(src io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(s2.NewReader(src)), nil
+}
+
+func (decompressor Decompressor) FileExtension() string {
+	return FileExtension
+}
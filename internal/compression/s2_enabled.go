@@ -0,0 +1,11 @@
+package compression
+
+import (
+	"github.com/wal-g/wal-g/internal/compression/s2"
+)
+
+func init() {
+	Decompressors = append(Decompressors, s2.Decompressor{})
+	Compressors[s2.AlgorithmName] = s2.Compressor{}
+	CompressingAlgorithms = append(CompressingAlgorithms, s2.AlgorithmName)
+}
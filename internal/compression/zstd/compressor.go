@@ -1,6 +1,7 @@
 package zstd
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/klauspost/compress/zstd"
@@ -14,8 +15,14 @@ const (
 
 // Compressor writes zstd-compressed streams. A zero Level keeps the historical
 // default (zstd.SpeedDefault), so an unconfigured Compressor behaves as before.
+// A non-empty Dictionary is used for the compression and its ID is recorded in
+// the frame header, so Decompressor can find it with the DictionaryLoader.
+// With Concurrency above one, the stream is compressed by that many goroutines
+// in independent frames of ParallelBlockSize (see parallelWriter).
 type Compressor struct {
-	Level zstd.EncoderLevel
+	Level       zstd.EncoderLevel
+	Dictionary  []byte
+	Concurrency int
 }
 
 func (compressor Compressor) NewWriter(writer io.Writer) ioextensions.WriteFlushCloser {
@@ -23,16 +30,33 @@ func (compressor Compressor) NewWriter(writer io.Writer) ioextensions.WriteFlush
 	if level == 0 { // level not set: preserve the previous default
 		level = zstd.SpeedDefault
 	}
-	zw, err := zstd.NewWriter(writer,
-		zstd.WithEncoderLevel(level),
-	)
+	options := []zstd.EOption{zstd.WithEncoderLevel(level)}
+	if len(compressor.Dictionary) > 0 {
+		options = append(options, zstd.WithEncoderDict(compressor.Dictionary))
+	}
+	if compressor.Concurrency > 1 {
+		options = append(options, zstd.WithEncoderConcurrency(compressor.Concurrency))
+		encoder, err := zstd.NewWriter(nil, options...)
+		if err != nil {
+			return ioextensions.FailedWriter{Err: fmt.Errorf("create zstd encoder: %w", err)}
+		}
+		return newParallelWriter(writer, encoder, compressor.Concurrency)
+	}
+	zw, err := zstd.NewWriter(writer, options...)
 	if err != nil {
-		panic(err)
+		return ioextensions.FailedWriter{Err: fmt.Errorf("create zstd encoder: %w", err)}
 	}
 
 	return zw
 }
 
+func (compressor Compressor) LevelName() string {
+	if compressor.Level == 0 {
+		return zstd.SpeedDefault.String()
+	}
+	return compressor.Level.String()
+}
+
 func (compressor Compressor) FileExtension() string {
 	return FileExtension
 }
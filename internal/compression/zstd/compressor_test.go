@@ -111,3 +111,65 @@ func TestEncoderLevelFromName(t *testing.T) {
 	_, ok = EncoderLevelFromName("nonsense")
 	assert.False(t, ok)
 }
+
+func TestParallelWriter(t *testing.T) {
+	buff := make([]byte, 3*ParallelBlockSize+17)
+	rand.New(rand.NewSource(0x1337c0deb357beef)).Read(buff[:len(buff)/2])
+
+	var comp bytes.Buffer
+	wc := Compressor{Concurrency: 4}.NewWriter(&comp)
+	_, err := wc.Write(buff[:100])
+	require.NoError(t, err)
+	require.NoError(t, wc.Flush())
+	_, err = wc.Write(buff[100:])
+	require.NoError(t, err)
+	require.NoError(t, wc.Close())
+
+	rdr, err := Decompressor{}.Decompress(&comp)
+	require.NoError(t, err)
+	decomp, err := io.ReadAll(rdr)
+	require.NoError(t, err)
+	require.NoError(t, rdr.Close())
+	assert.True(t, bytes.Equal(buff, decomp), "roundtrip mismatch")
+}
+
+func TestParallelWriter_Empty(t *testing.T) {
+	var comp bytes.Buffer
+	require.NoError(t, Compressor{Concurrency: 4}.NewWriter(&comp).Close())
+
+	rdr, err := Decompressor{}.Decompress(&comp)
+	require.NoError(t, err)
+	decomp, err := io.ReadAll(rdr)
+	require.NoError(t, err)
+	assert.Empty(t, decomp)
+}
+
+func TestDecompress_DoesNotWaitForHeader(t *testing.T) {
+	buff := []byte("the header of this stream is sent after Decompress returns")
+	var comp bytes.Buffer
+	wc := Compressor{}.NewWriter(&comp)
+	_, err := wc.Write(buff)
+	require.NoError(t, err)
+	require.NoError(t, wc.Close())
+
+	pipeReader, pipeWriter := io.Pipe()
+	rdr, err := Decompressor{}.Decompress(pipeReader)
+	require.NoError(t, err)
+	go func() {
+		_, err := pipeWriter.Write(comp.Bytes())
+		_ = pipeWriter.CloseWithError(err)
+	}()
+	decomp, err := io.ReadAll(rdr)
+	require.NoError(t, err)
+	require.NoError(t, rdr.Close())
+	assert.Equal(t, buff, decomp)
+}
+
+func TestNewWriter_InvalidDictionary(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		wc := Compressor{Dictionary: []byte("not a dictionary"), Concurrency: concurrency}.NewWriter(io.Discard)
+		_, err := wc.Write([]byte("data"))
+		assert.Error(t, err)
+		assert.Error(t, wc.Close())
+	}
+}
@@ -1,22 +1,106 @@
 package zstd
 
 import (
+	"bufio"
 	"io"
+	"sync"
 
 	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
 	"github.com/wal-g/wal-g/internal/compression/computils"
 )
 
+// DictionaryLoader provides the dictionary with the given ID for the streams compressed with it
+type DictionaryLoader func(id uint32) ([]byte, error)
+
+var (
+	dictionaryLoader      DictionaryLoader
+	dictionaryLoaderMutex sync.RWMutex
+)
+
+// SetDictionaryLoader sets the loader of the dictionaries the streams were compressed with,
+// without it such streams can't be decompressed
+func SetDictionaryLoader(loader DictionaryLoader) {
+	dictionaryLoaderMutex.Lock()
+	defer dictionaryLoaderMutex.Unlock()
+	dictionaryLoader = loader
+}
+
+func getDictionaryLoader() DictionaryLoader {
+	dictionaryLoaderMutex.RLock()
+	defer dictionaryLoaderMutex.RUnlock()
+	return dictionaryLoader
+}
+
 type Decompressor struct{}
 
+// Decompress doesn't read the stream, the dictionary it was compressed with is detected on the first Read,
+// so that the caller isn't blocked until the frame header is received
 func (decompressor Decompressor) Decompress(src io.Reader) (io.ReadCloser, error) {
-	zstdReader, err := zstd.NewReader(computils.NewUntilEOFReader(src))
+	return &decompressingReader{src: src}, nil
+}
+
+type decompressingReader struct {
+	src    io.Reader
+	reader io.ReadCloser
+	err    error
+}
+
+func (r *decompressingReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		if r.err == nil {
+			r.reader, r.err = newDecompressingReader(r.src)
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+	}
+	return r.reader.Read(p)
+}
+
+func (r *decompressingReader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
+
+func newDecompressingReader(src io.Reader) (io.ReadCloser, error) {
+	bufferedSrc := bufio.NewReader(src)
+	options, err := dictionaryOptions(bufferedSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	zstdReader, err := zstd.NewReader(computils.NewUntilEOFReader(bufferedSrc), options...)
 	if err != nil {
 		return nil, err
 	}
 	return zstdReader.IOReadCloser(), nil
 }
 
+// dictionaryOptions loads the dictionary the stream was compressed with, if its frame header refers to one
+func dictionaryOptions(src *bufio.Reader) ([]zstd.DOption, error) {
+	// the stream may be shorter than the maximum header, the header decoding tells if it's enough
+	headerBytes, _ := src.Peek(zstd.HeaderMaxSize)
+	var header zstd.Header
+	if err := header.Decode(headerBytes); err != nil || header.DictionaryID == 0 {
+		// leave reporting of the invalid stream to the decoder
+		return nil, nil
+	}
+
+	loader := getDictionaryLoader()
+	if loader == nil {
+		return nil, errors.Errorf("zstd stream is compressed with dictionary %08x, but dictionaries are not available",
+			header.DictionaryID)
+	}
+	dictionary, err := loader(header.DictionaryID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load zstd dictionary %08x", header.DictionaryID)
+	}
+	return []zstd.DOption{zstd.WithDecoderDicts(dictionary)}, nil
+}
+
 func (decompressor Decompressor) FileExtension() string {
 	return FileExtension
 }
@@ -0,0 +1,127 @@
+package zstd
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ParallelBlockSize is the size of the blocks compressed by the workers at once
+const ParallelBlockSize = 4 << 20
+
+// parallelWriter splits the stream into blocks and compresses them by several goroutines at once. Every block is
+// an independent zstd frame and the frames are written in order, so the output is an ordinary multi-frame stream,
+// which Decompressor reads as is.
+type parallelWriter struct {
+	encoder *zstd.Encoder
+	output  io.Writer
+
+	block     []byte
+	submitted bool
+	closed    bool
+
+	// blocks are queued in the stream order, each one provides its frame when it's compressed
+	blocks  chan chan []byte
+	pending sync.WaitGroup
+	done    chan struct{}
+
+	errMutex sync.Mutex
+	err      error
+}
+
+func newParallelWriter(output io.Writer, encoder *zstd.Encoder, concurrency int) *parallelWriter {
+	writer := &parallelWriter{
+		encoder: encoder,
+		output:  output,
+		blocks:  make(chan chan []byte, concurrency),
+		done:    make(chan struct{}),
+	}
+	go writer.writeFrames()
+	return writer
+}
+
+func (writer *parallelWriter) Write(p []byte) (n int, err error) {
+	if err = writer.getErr(); err != nil {
+		return 0, err
+	}
+	for len(p) > 0 {
+		if writer.block == nil {
+			writer.block = make([]byte, 0, ParallelBlockSize)
+		}
+		count := min(len(p), ParallelBlockSize-len(writer.block))
+		writer.block = append(writer.block, p[:count]...)
+		p = p[count:]
+		n += count
+		if len(writer.block) == ParallelBlockSize {
+			writer.submit()
+		}
+	}
+	return n, nil
+}
+
+// submit queues the current block for the compression, it blocks while the queue is full
+func (writer *parallelWriter) submit() {
+	block := writer.block
+	writer.block = nil
+	writer.submitted = true
+
+	frame := make(chan []byte, 1)
+	writer.pending.Add(1)
+	writer.blocks <- frame
+	go func() {
+		frame <- writer.encoder.EncodeAll(block, nil)
+	}()
+}
+
+func (writer *parallelWriter) writeFrames() {
+	defer close(writer.done)
+	for frame := range writer.blocks {
+		compressed := <-frame
+		if writer.getErr() == nil {
+			if _, err := writer.output.Write(compressed); err != nil {
+				writer.setErr(err)
+			}
+		}
+		writer.pending.Done()
+	}
+}
+
+// Flush compresses the incomplete block and waits until all the frames are written
+func (writer *parallelWriter) Flush() error {
+	if len(writer.block) > 0 {
+		writer.submit()
+	}
+	writer.pending.Wait()
+	return writer.getErr()
+}
+
+func (writer *parallelWriter) Close() error {
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+
+	// an empty stream still consists of a frame
+	if len(writer.block) > 0 || !writer.submitted {
+		writer.submit()
+	}
+	close(writer.blocks)
+	<-writer.done
+	if err := writer.encoder.Close(); err != nil {
+		writer.setErr(err)
+	}
+	return writer.getErr()
+}
+
+func (writer *parallelWriter) getErr() error {
+	writer.errMutex.Lock()
+	defer writer.errMutex.Unlock()
+	return writer.err
+}
+
+func (writer *parallelWriter) setErr(err error) {
+	writer.errMutex.Lock()
+	defer writer.errMutex.Unlock()
+	writer.err = err
+}
@@ -47,6 +47,7 @@ const (
 	DiskRateLimitSetting          = "WALG_DISK_RATE_LIMIT"
 	NetworkRateLimitSetting       = "WALG_NETWORK_RATE_LIMIT"
 	UseWalDeltaSetting            = "WALG_USE_WAL_DELTA"
+	UseWalSummariesSetting        = "WALG_USE_WAL_SUMMARIES"
 	UseReverseUnpackSetting       = "WALG_USE_REVERSE_UNPACK"
 	SkipRedundantTarsSetting      = "WALG_SKIP_REDUNDANT_TARS"
 	VerifyPageChecksumsSetting    = "WALG_VERIFY_PAGE_CHECKSUMS"
@@ -79,6 +80,43 @@ const (
 	PgpEnvelopeCacheExpiration    = "WALG_ENVELOPE_CACHE_EXPIRATION"
 	DirectIO                      = "WALG_DIRECT_IO"
 	DirectIOBlockCountSetting     = "WALG_DIRECT_IO_BLOCK_COUNT"
+	UseDeduplicationSetting       = "WALG_USE_DEDUPLICATION"
+	DeduplicationChunkSizeSetting = "WALG_DEDUPLICATION_CHUNK_SIZE"
+	DeduplicationGCDelaySetting   = "WALG_DEDUPLICATION_GC_DELAY"
+	IntegrityManifestSetting      = "WALG_INTEGRITY_MANIFEST"
+	ArchiveRestoreSetting         = "WALG_ARCHIVE_RESTORE"
+	ArchiveRestoreDaysSetting     = "WALG_ARCHIVE_RESTORE_DAYS"
+	ArchiveRestoreTimeoutSetting  = "WALG_ARCHIVE_RESTORE_TIMEOUT"
+	ArchiveRestoreIntervalSetting = "WALG_ARCHIVE_RESTORE_INTERVAL"
+
+	ReadCachePathSetting = "WALG_READ_CACHE_PATH"
+	ReadCacheSizeSetting = "WALG_READ_CACHE_SIZE"
+	ReadCacheTTLSetting  = "WALG_READ_CACHE_TTL"
+
+	DownloadRangeConcurrencySetting = "WALG_DOWNLOAD_RANGE_CONCURRENCY"
+	DownloadRangePartSizeSetting    = "WALG_DOWNLOAD_RANGE_PART_SIZE"
+
+	AgeRecipientsSetting   = "WALG_AGE_RECIPIENTS"
+	AgeIdentityPathSetting = "WALG_AGE_IDENTITY_PATH"
+
+	PgpEnvelopeVaultAddressSetting      = "WALG_ENVELOPE_PGP_VAULT_ADDRESS"
+	PgpEnvelopeVaultTokenSetting        = "WALG_ENVELOPE_PGP_VAULT_TOKEN"
+	PgpEnvelopeVaultRoleIDSetting       = "WALG_ENVELOPE_PGP_VAULT_ROLE_ID"
+	PgpEnvelopeVaultSecretIDSetting     = "WALG_ENVELOPE_PGP_VAULT_SECRET_ID"
+	PgpEnvelopeVaultAppRoleMountSetting = "WALG_ENVELOPE_PGP_VAULT_APPROLE_MOUNT"
+	PgpEnvelopeVaultTransitMountSetting = "WALG_ENVELOPE_PGP_VAULT_TRANSIT_MOUNT"
+	PgpEnvelopeVaultTransitKeySetting   = "WALG_ENVELOPE_PGP_VAULT_TRANSIT_KEY"
+
+	ObjectHeaderSetting      = "WALG_OBJECT_HEADER"
+	DecryptionKeyringSetting = "WALG_DECRYPTION_KEYRING"
+
+	AeadKeySetting          = "WALG_AEAD_KEY"
+	AeadKeyPathSetting      = "WALG_AEAD_KEY_PATH"
+	AeadKeyTransformSetting = "WALG_AEAD_KEY_TRANSFORM"
+
+	ZstdDictionarySetting = "WALG_ZSTD_DICTIONARY"
+
+	CompressionConcurrencySetting = "WALG_COMPRESSION_CONCURRENCY"
 
 	PgDataSetting           = "PGDATA"
 	UserSetting             = "USER" // TODO : do something with it
@@ -123,8 +161,17 @@ const (
 	FailoverStorageCacheEMAAlphaDeadMax  = "WALG_FAILOVER_STORAGES_CACHE_EMA_ALPHA_DEAD_MAX"
 	FailoverStorageCacheEMAAlphaDeadMin  = "WALG_FAILOVER_STORAGES_CACHE_EMA_ALPHA_DEAD_MIN"
 	FailoverStoragesCheckSize            = "WALG_FAILOVER_STORAGES_CHECK_SIZE"
+	FailoverStoragesPutQuorum            = "WALG_FAILOVER_STORAGES_PUT_QUORUM"
+	FailoverStoragesRepairInterval       = "WALG_FAILOVER_STORAGES_REPAIR_INTERVAL"
+	FailoverStoragesRepairWindow         = "WALG_FAILOVER_STORAGES_REPAIR_WINDOW"
 	PgDaemonWALUploadTimeout             = "WALG_DAEMON_WAL_UPLOAD_TIMEOUT"
 	PgTargetStorage                      = "WALG_TARGET_STORAGE"
+	PgReceiveClusterHosts                = "WALG_RECEIVE_CLUSTER_HOSTS"
+	PgReceiveReconnectTimeout            = "WALG_RECEIVE_RECONNECT_TIMEOUT"
+	PgReceiveSyncMode                    = "WALG_RECEIVE_SYNC_MODE"
+	PgReceivePartialUploadInterval       = "WALG_RECEIVE_PARTIAL_UPLOAD_INTERVAL"
+	PgReceivePartialUploadSize           = "WALG_RECEIVE_PARTIAL_UPLOAD_SIZE"
+	PgFetchPartialWal                    = "WALG_FETCH_PARTIAL_WAL"
 	DisablePartialRestore                = "WALG_DISABLE_PARTIAL_RESTORE"
 
 	ProfileSamplingRatio = "PROFILE_SAMPLING_RATIO"
@@ -269,6 +316,7 @@ var (
 		DeltaMaxStepsSetting:         "0",
 		CompressionMethodSetting:     "lz4",
 		UseWalDeltaSetting:           "false",
+		UseWalSummariesSetting:       "false",
 		TarSizeThresholdSetting:      "1073741823", // (1 << 30) - 1
 		TarDisableFsyncSetting:       "false",
 		TotalBgUploadedLimit:         "32",
@@ -288,6 +336,32 @@ var (
 		DirectIO:                     "false",
 		DirectIOBlockCountSetting:    "32",
 		LogLevelSetting:              "NORMAL",
+
+		UseDeduplicationSetting:       "false",
+		DeduplicationChunkSizeSetting: "1MB",
+		DeduplicationGCDelaySetting:   "24h",
+		IntegrityManifestSetting:      "false",
+		ArchiveRestoreSetting:         "false",
+		ArchiveRestoreDaysSetting:     "1",
+		ArchiveRestoreTimeoutSetting:  "48h",
+		ArchiveRestoreIntervalSetting: "5m",
+
+		ReadCacheSizeSetting: "1GB",
+		ReadCacheTTLSetting:  "10m",
+
+		DownloadRangeConcurrencySetting: "1",
+		DownloadRangePartSizeSetting:    "64MB",
+
+		PgpEnvelopeVaultAppRoleMountSetting: "approle",
+		PgpEnvelopeVaultTransitMountSetting: "transit",
+
+		ObjectHeaderSetting: "false",
+
+		AeadKeyTransformSetting: "hex",
+
+		ZstdDictionarySetting: "false",
+
+		CompressionConcurrencySetting: "1",
 	}
 
 	MongoDefaultSettings = map[string]string{
@@ -325,15 +399,24 @@ var (
 	}
 
 	PGDefaultSettings = map[string]string{
-		PgWalSize:                 "16",
-		PgWalPageSize:             "8192",
-		PgBlockSize:               "8192",
-		PgBackRestStanza:          "main",
-		PgAliveCheckInterval:      "1m",
-		FailoverStoragesCheckSize: "1mb",
-		PgDaemonWALUploadTimeout:  "60s",
-		ForceWalDetal:             "false",
-		PgAppName:                 "wal-g",
+		PgWalSize:                      "16",
+		PgWalPageSize:                  "8192",
+		PgBlockSize:                    "8192",
+		PgBackRestStanza:               "main",
+		PgAliveCheckInterval:           "1m",
+		FailoverStoragesCheckSize:      "1mb",
+		PgDaemonWALUploadTimeout:       "60s",
+		PgReceiveReconnectTimeout:      "5m",
+		PgReceiveSyncMode:              "false",
+		PgReceivePartialUploadInterval: "1s",
+		PgReceivePartialUploadSize:     "1mb",
+		PgFetchPartialWal:              "false",
+		ForceWalDetal:                  "false",
+		PgAppName:                      "wal-g",
+
+		FailoverStoragesPutQuorum:      "0",
+		FailoverStoragesRepairInterval: "10m",
+		FailoverStoragesRepairWindow:   "24h",
 	}
 
 	GPDefaultSettings = map[string]string{
@@ -376,6 +459,7 @@ var (
 		DiskRateLimitSetting:          true,
 		NetworkRateLimitSetting:       true,
 		UseWalDeltaSetting:            true,
+		UseWalSummariesSetting:        true,
 		LogLevelSetting:               true,
 		LogDestinationSetting:         true,
 		TarSizeThresholdSetting:       true,
@@ -393,6 +477,17 @@ var (
 		PgpEnvelopeYcEndpointSetting:  true,
 		DirectIO:                      false,
 		DirectIOBlockCountSetting:     false,
+		UseDeduplicationSetting:       true,
+		DeduplicationChunkSizeSetting: true,
+		DeduplicationGCDelaySetting:   true,
+		IntegrityManifestSetting:      true,
+		ArchiveRestoreSetting:         true,
+		ArchiveRestoreDaysSetting:     true,
+		ArchiveRestoreTimeoutSetting:  true,
+		ArchiveRestoreIntervalSetting: true,
+		ReadCachePathSetting:          true,
+		ReadCacheSizeSetting:          true,
+		ReadCacheTTLSetting:           true,
 		LibsodiumKeySetting:           true,
 		LibsodiumKeyPathSetting:       true,
 		LibsodiumKeyTransform:         true,
@@ -415,6 +510,31 @@ var (
 		StatsdAddressSetting:          true,
 		StatsdExtraTagsSetting:        true,
 
+		DownloadRangeConcurrencySetting: true,
+		DownloadRangePartSizeSetting:    true,
+
+		AgeRecipientsSetting:   true,
+		AgeIdentityPathSetting: true,
+
+		PgpEnvelopeVaultAddressSetting:      true,
+		PgpEnvelopeVaultTokenSetting:        true,
+		PgpEnvelopeVaultRoleIDSetting:       true,
+		PgpEnvelopeVaultSecretIDSetting:     true,
+		PgpEnvelopeVaultAppRoleMountSetting: true,
+		PgpEnvelopeVaultTransitMountSetting: true,
+		PgpEnvelopeVaultTransitKeySetting:   true,
+
+		ObjectHeaderSetting:      true,
+		DecryptionKeyringSetting: true,
+
+		AeadKeySetting:          true,
+		AeadKeyPathSetting:      true,
+		AeadKeyTransformSetting: true,
+
+		ZstdDictionarySetting: true,
+
+		CompressionConcurrencySetting: true,
+
 		ProfileSamplingRatio: true,
 		ProfileMode:          true,
 		ProfilePath:          true,
@@ -498,6 +618,18 @@ var (
 		SSHUsername:       true,
 		SSHPrivateKeyPath: true,
 
+		// WebDAV
+		"WALG_WEBDAV_PREFIX":     true,
+		"WEBDAV_USERNAME":        true,
+		"WEBDAV_PASSWORD":        true,
+		"WEBDAV_CA_CERT_FILE":    true,
+		"WEBDAV_SKIP_VALIDATION": true,
+
+		// External helper
+		"WALG_EXTERNAL_PREFIX":     true,
+		"EXTERNAL_COMMAND":         true,
+		"EXTERNAL_SKIP_VALIDATION": true,
+
 		//File
 		"WALG_FILE_PREFIX": true,
 
@@ -544,7 +676,16 @@ var (
 		FailoverStorageCacheEMAAlphaDeadMax:  true,
 		FailoverStorageCacheEMAAlphaDeadMin:  true,
 		FailoverStoragesCheckSize:            true,
+		FailoverStoragesPutQuorum:            true,
+		FailoverStoragesRepairInterval:       true,
+		FailoverStoragesRepairWindow:         true,
 		PgDaemonWALUploadTimeout:             true,
+		PgReceiveClusterHosts:                true,
+		PgReceiveReconnectTimeout:            true,
+		PgReceiveSyncMode:                    true,
+		PgReceivePartialUploadInterval:       true,
+		PgReceivePartialUploadSize:           true,
+		PgFetchPartialWal:                    true,
 		DisablePartialRestore:                true,
 		ForceWalDetal:                        true,
 		PgAppName:                            true,
@@ -680,6 +821,11 @@ var (
 		SSHPassword:                   true,
 		SwiftOsPassword:               true,
 		MongoDBExtraInternalDatabases: true,
+
+		PgpEnvelopeVaultTokenSetting:    true,
+		PgpEnvelopeVaultSecretIDSetting: true,
+
+		AeadKeySetting: true,
 	}
 
 	complexSettings = map[string]bool{
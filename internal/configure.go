@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -16,18 +17,27 @@ import (
 	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/adaptive"
+	"github.com/wal-g/wal-g/internal/compression/gzip"
 	zstdcompression "github.com/wal-g/wal-g/internal/compression/zstd"
 	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/crypto/aead"
+	"github.com/wal-g/wal-g/internal/crypto/age"
 	"github.com/wal-g/wal-g/internal/crypto/awskms"
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
 	cachenvlpr "github.com/wal-g/wal-g/internal/crypto/envelope/enveloper/cached"
+	vaultenvlpr "github.com/wal-g/wal-g/internal/crypto/envelope/enveloper/vault"
 	yckmsenvlpr "github.com/wal-g/wal-g/internal/crypto/envelope/enveloper/yckms"
 	envopenpgp "github.com/wal-g/wal-g/internal/crypto/envelope/openpgp"
 	"github.com/wal-g/wal-g/internal/crypto/openpgp"
 	"github.com/wal-g/wal-g/internal/crypto/yckms"
+	"github.com/wal-g/wal-g/internal/dedup"
 	"github.com/wal-g/wal-g/internal/fsutil"
+	"github.com/wal-g/wal-g/internal/integrity"
 	"github.com/wal-g/wal-g/internal/limiters"
 	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/internal/multistorage/consts"
 	"github.com/wal-g/wal-g/internal/multistorage/stats/cache"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
@@ -99,7 +109,7 @@ type ZstdLevelWithoutZstdMethodError struct {
 
 func newZstdLevelWithoutZstdMethodError(method string) ZstdLevelWithoutZstdMethodError {
 	return ZstdLevelWithoutZstdMethodError{
-		errors.Errorf("WALG_ZSTD_LEVEL is set but the compression method is '%s', not 'zstd'",
+		errors.Errorf("WALG_ZSTD_LEVEL is set but the compression method is '%s', not 'zstd' or 'adaptive'",
 			method)}
 }
 
@@ -107,6 +117,20 @@ func (err ZstdLevelWithoutZstdMethodError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+type CompressionConcurrencyUnsupportedError struct {
+	error
+}
+
+func newCompressionConcurrencyUnsupportedError(method string) CompressionConcurrencyUnsupportedError {
+	return CompressionConcurrencyUnsupportedError{
+		errors.Errorf("WALG_COMPRESSION_CONCURRENCY is set but the compression method is '%s', not 'zstd' or 'gzip'",
+			method)}
+}
+
+func (err CompressionConcurrencyUnsupportedError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
 type UnmarshallingError struct {
 	error
 }
@@ -144,12 +168,18 @@ func ConfigureStorage(ctx context.Context) (storage.HashableStorage, error) {
 		})
 	}
 	rootWraps = append(rootWraps, ConfigureStoragePrefix)
+	if viper.GetBool(conf.UseDeduplicationSetting) {
+		rootWraps = append(rootWraps, ConfigureDeduplication(viper.GetViper()))
+	}
 
 	st, err := ConfigureStorageForSpecificConfig(ctx, viper.GetViper(), rootWraps...)
 	if err != nil {
 		return nil, err
 	}
 
+	ConfigureZstdDictionaryLoader(ctx, func() (StorageFolderReader, error) {
+		return NewFolderReader(st.RootFolder()), nil
+	})
 	return st, nil
 }
 
@@ -161,6 +191,30 @@ func ConfigureStoragePrefix(folder storage.Folder) storage.Folder {
 	return folder
 }
 
+// ConfigureDeduplication provides a wrap that stores backup data as content-defined chunks shared between backups.
+func ConfigureDeduplication(config *viper.Viper) storage.WrapRootFolder {
+	avgChunkSize := int(config.GetSizeInBytes(conf.DeduplicationChunkSizeSetting))
+	if avgChunkSize < dedup.MinAvgChunkSize {
+		tracelog.WarningLogger.Printf("%s is too small, using %d bytes instead",
+			conf.DeduplicationChunkSizeSetting, dedup.MinAvgChunkSize)
+		avgChunkSize = dedup.MinAvgChunkSize
+	}
+	dedupConfig := dedup.Config{
+		AvgChunkSize:      avgChunkSize,
+		GCDelay:           config.GetDuration(conf.DeduplicationGCDelaySetting),
+		ShouldDeduplicate: isBackupDataObject,
+	}
+	return func(folder storage.Folder) storage.Folder {
+		return dedup.NewFolder(folder, dedupConfig)
+	}
+}
+
+// isBackupDataObject tells if the object contains backup data, like tar partitions or streams, as opposed to
+// sentinels and other JSON metadata that have to stay readable as-is.
+func isBackupDataObject(objectPath string) bool {
+	return strings.HasPrefix(objectPath, utility.BaseBackupPath) && !strings.Contains(path.Base(objectPath), ".json")
+}
+
 // TODO: something with that
 // when provided multiple 'keys' in the config,
 // this function will always return only one concrete 'storage'.
@@ -224,7 +278,19 @@ func ConfigureCompressor() (compression.Compressor, error) {
 	if !ok {
 		return nil, newUnknownCompressionMethodError(compressionMethod)
 	}
-	if levelName := viper.GetString(conf.ZstdLevelSetting); levelName != "" {
+	levelName := viper.GetString(conf.ZstdLevelSetting)
+	if compressionMethod == adaptive.AlgorithmName {
+		// the level is the strong one, the compressor is new so that it reports only the decisions of this run
+		strongLevel := adaptive.DefaultStrongLevel
+		if levelName != "" {
+			level, levelOK := zstdcompression.EncoderLevelFromName(levelName)
+			if !levelOK {
+				return nil, newUnknownZstdLevelError(levelName)
+			}
+			strongLevel = level
+		}
+		compressor = adaptive.NewCompressor(strongLevel)
+	} else if levelName != "" {
 		if compressionMethod != zstdcompression.AlgorithmName {
 			return nil, newZstdLevelWithoutZstdMethodError(compressionMethod)
 		}
@@ -234,7 +300,26 @@ func ConfigureCompressor() (compression.Compressor, error) {
 		}
 		compressor = zstdcompression.Compressor{Level: level}
 	}
-	return compressor, nil
+	return configureCompressionConcurrency(compressor, compressionMethod)
+}
+
+// configureCompressionConcurrency makes the compressor use several goroutines for a single stream
+// if WALG_COMPRESSION_CONCURRENCY is above one
+func configureCompressionConcurrency(compressor compression.Compressor, method string) (compression.Compressor, error) {
+	concurrency := viper.GetInt(conf.CompressionConcurrencySetting)
+	if concurrency <= 1 {
+		return compressor, nil
+	}
+	switch typedCompressor := compressor.(type) {
+	case zstdcompression.Compressor:
+		typedCompressor.Concurrency = concurrency
+		return typedCompressor, nil
+	case gzip.Compressor:
+		typedCompressor.Concurrency = concurrency
+		return typedCompressor, nil
+	default:
+		return nil, newCompressionConcurrencyUnsupportedError(method)
+	}
 }
 
 func getPGArchiveStatusFolderPath() string {
@@ -264,19 +349,25 @@ func ConfigureUploader(ctx context.Context) (*RegularUploader, error) {
 		return nil, errors.Wrap(err, "failed to configure storage")
 	}
 
-	uploader, err := ConfigureUploaderToFolder(st.RootFolder())
-	return uploader, err
+	return configureUploaderToFolder(st.RootFolder(), NewIntegritySpool(multistorage.StorageKey(consts.DefaultStorage, st)))
 }
 
 // ConfigureUploaderToFolder connects to storage with the specified folder and creates an Uploader.
 // It makes sure that a valid session has started; if invalid, returns AWS error and `<nil>` value.
 func ConfigureUploaderToFolder(folder storage.Folder) (uploader *RegularUploader, err error) {
+	return configureUploaderToFolder(folder, integritySpoolOf(folder))
+}
+
+func configureUploaderToFolder(folder storage.Folder, integritySpool *integrity.Spool) (uploader *RegularUploader, err error) {
 	compressor, err := ConfigureCompressor()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to configure compression")
 	}
 
 	uploader = NewRegularUploader(compressor, folder)
+	if viper.GetBool(conf.IntegrityManifestSetting) {
+		uploader.EnableIntegrityManifest(integritySpool)
+	}
 	return uploader, err
 }
 
@@ -287,6 +378,9 @@ func ConfigureUploaderWithoutCompressor(ctx context.Context) (Uploader, error) {
 	}
 
 	uploader := NewRegularUploader(nil, st.RootFolder())
+	if viper.GetBool(conf.IntegrityManifestSetting) {
+		uploader.EnableIntegrityManifest(NewIntegritySpool(multistorage.StorageKey(consts.DefaultStorage, st)))
+	}
 	return uploader, err
 }
 
@@ -341,6 +435,8 @@ func ConfigureCrypterForSpecificConfig(config *viper.Viper) (crypto.Crypter, err
 	isPgpKey := pgpKey || pgpKeyPath || legacyGpg
 	isEnvelopePgpKey := envelopePgpKey || envelopePgpKeyPath
 	isLibsodium := libsodiumKey || libsodiumKeyPath
+	isAge := config.IsSet(conf.AgeRecipientsSetting) || config.IsSet(conf.AgeIdentityPathSetting)
+	isAead := config.IsSet(conf.AeadKeySetting) || config.IsSet(conf.AeadKeyPathSetting)
 
 	if isPgpKey && isEnvelopePgpKey {
 		return nil, errors.New("there is no way to configure plain gpg and envelope gpg at the same time, please choose one")
@@ -357,11 +453,23 @@ func ConfigureCrypterForSpecificConfig(config *viper.Viper) (crypto.Crypter, err
 		return yckms.YcCrypterFromKeyIDAndCredential(config.GetString(conf.YcKmsKeyIDSetting), config.GetString(conf.YcSaKeyFileSetting)), nil
 	case isLibsodium:
 		return configureLibsodiumCrypter(config)
+	case isAge:
+		return age.CrypterFromRecipients(config.GetString(conf.AgeRecipientsSetting),
+			config.GetString(conf.AgeIdentityPathSetting)), nil
+	case isAead:
+		return configureAeadCrypter(config), nil
 	default:
 		return nil, nil
 	}
 }
 
+func configureAeadCrypter(config *viper.Viper) crypto.Crypter {
+	if config.IsSet(conf.AeadKeySetting) {
+		return aead.CrypterFromKey(config.GetString(conf.AeadKeySetting), config.GetString(conf.AeadKeyTransformSetting))
+	}
+	return aead.CrypterFromKeyPath(config.GetString(conf.AeadKeyPathSetting), config.GetString(conf.AeadKeyTransformSetting))
+}
+
 func configurePgpCrypter(config *viper.Viper) (crypto.Crypter, error) {
 	loadPassphrase := func() (string, bool) {
 		return conf.GetSetting(conf.PgpKeyPassphraseSetting)
@@ -384,15 +492,7 @@ func configurePgpCrypter(config *viper.Viper) (crypto.Crypter, error) {
 }
 
 func configureEnvelopePgpCrypter(config *viper.Viper) (crypto.Crypter, error) {
-	if !config.IsSet(conf.PgpEnvelopeYcKmsKeyIDSetting) {
-		return nil, errors.New("yandex cloud KMS key for client-side encryption and decryption must be configured")
-	}
-
-	yckmsEnveloper, err := yckmsenvlpr.EnveloperFromKeyIDAndCredential(
-		config.GetString(conf.PgpEnvelopeYcKmsKeyIDSetting),
-		config.GetString(conf.PgpEnvelopeYcSaKeyFileSetting),
-		config.GetString(conf.PgpEnvelopeYcEndpointSetting),
-	)
+	kmsEnveloper, err := configureKmsEnveloper(config)
 	if err != nil {
 		return nil, err
 	}
@@ -400,7 +500,7 @@ func configureEnvelopePgpCrypter(config *viper.Viper) (crypto.Crypter, error) {
 	if err != nil {
 		return nil, err
 	}
-	enveloper := cachenvlpr.EnveloperWithCache(yckmsEnveloper, expiration)
+	enveloper := cachenvlpr.EnveloperWithCache(kmsEnveloper, expiration)
 
 	if config.IsSet(conf.PgpEnvelopKeyPathSetting) {
 		return envopenpgp.CrypterFromKeyPath(viper.GetString(conf.PgpEnvelopKeyPathSetting), enveloper), nil
@@ -411,6 +511,36 @@ func configureEnvelopePgpCrypter(config *viper.Viper) (crypto.Crypter, error) {
 	return nil, errors.New("there is no any supported envelope gpg crypter configuration")
 }
 
+func configureKmsEnveloper(config *viper.Viper) (envelope.Enveloper, error) {
+	isYcKms := config.IsSet(conf.PgpEnvelopeYcKmsKeyIDSetting)
+	isVaultTransit := config.IsSet(conf.PgpEnvelopeVaultTransitKeySetting)
+
+	switch {
+	case isYcKms && isVaultTransit:
+		return nil, errors.New("yandex cloud KMS and vault transit envelope keys can not be configured at the same time, please choose one")
+	case isYcKms:
+		return yckmsenvlpr.EnveloperFromKeyIDAndCredential(
+			config.GetString(conf.PgpEnvelopeYcKmsKeyIDSetting),
+			config.GetString(conf.PgpEnvelopeYcSaKeyFileSetting),
+			config.GetString(conf.PgpEnvelopeYcEndpointSetting),
+		)
+	case isVaultTransit:
+		return vaultenvlpr.EnveloperFromTransitKey(
+			config.GetString(conf.PgpEnvelopeVaultAddressSetting),
+			config.GetString(conf.PgpEnvelopeVaultTransitMountSetting),
+			config.GetString(conf.PgpEnvelopeVaultTransitKeySetting),
+			vaultenvlpr.Auth{
+				Token:        config.GetString(conf.PgpEnvelopeVaultTokenSetting),
+				RoleID:       config.GetString(conf.PgpEnvelopeVaultRoleIDSetting),
+				SecretID:     config.GetString(conf.PgpEnvelopeVaultSecretIDSetting),
+				AppRoleMount: config.GetString(conf.PgpEnvelopeVaultAppRoleMountSetting),
+			},
+		)
+	default:
+		return nil, errors.New("yandex cloud KMS or vault transit key for client-side encryption and decryption must be configured")
+	}
+}
+
 func GetDeltaConfig() (maxDeltas int, fromFull bool) {
 	maxDeltas = viper.GetInt(conf.DeltaMaxStepsSetting)
 	if origin, hasOrigin := conf.GetSetting(conf.DeltaOriginSetting); hasOrigin {
@@ -546,7 +676,12 @@ func StorageFromConfig(ctx context.Context, configFile string) (storage.Storage,
 	conf.CheckAllowedSettings(config)
 	conf.SetDefaultValues(config)
 
-	folder, err := ConfigureStorageForSpecificConfig(ctx, config)
+	var rootWraps []storage.WrapRootFolder
+	if config.GetBool(conf.UseDeduplicationSetting) {
+		rootWraps = append(rootWraps, ConfigureDeduplication(config))
+	}
+
+	folder, err := ConfigureStorageForSpecificConfig(ctx, config, rootWraps...)
 
 	if err != nil {
 		tracelog.ErrorLogger.Println("Failed configure folder according to config " + configFile)
@@ -595,6 +730,9 @@ func ConfigureFailoverStorages(ctx context.Context) (failovers map[string]storag
 			})
 		}
 		rootWraps = append(rootWraps, ConfigureStoragePrefix)
+		if viper.GetBool(conf.UseDeduplicationSetting) {
+			rootWraps = append(rootWraps, ConfigureDeduplication(viper.GetViper()))
+		}
 
 		st, err := ConfigureStorageForSpecificConfig(ctx, cfg, rootWraps...)
 		if err != nil {
@@ -701,6 +839,11 @@ func ConfigureMultiStorage(ctx context.Context, checkWrite bool) (ms *multistora
 	if err != nil {
 		return nil, err
 	}
+
+	// the dictionary may be uploaded to a failover storage only
+	ConfigureZstdDictionaryLoader(ctx, func() (StorageFolderReader, error) {
+		return PrepareMultiStorageFolderReader(ctx, ms.RootFolder(), "")
+	})
 	return ms, nil
 }
 
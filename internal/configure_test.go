@@ -12,6 +12,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/gzip"
 	"github.com/wal-g/wal-g/internal/compression/lz4"
 	"github.com/wal-g/wal-g/internal/compression/lzma"
 	walgzstd "github.com/wal-g/wal-g/internal/compression/zstd"
@@ -200,6 +201,27 @@ func TestConfigureCompressor_FailsOnInvalidZstdLevel(t *testing.T) {
 	assert.Equal(t, compressor, nil)
 	resetToDefaults()
 }
+func TestConfigureCompressor_ConcurrencyForZstdAndGzip(t *testing.T) {
+	viper.Set(config.CompressionMethodSetting, "zstd")
+	viper.Set(config.CompressionConcurrencySetting, 4)
+	compressor, err := internal.ConfigureCompressor()
+	assert.NoError(t, err)
+	assert.Equal(t, walgzstd.Compressor{Concurrency: 4}, compressor)
+
+	viper.Set(config.CompressionMethodSetting, "gzip")
+	compressor, err = internal.ConfigureCompressor()
+	assert.NoError(t, err)
+	assert.Equal(t, gzip.Compressor{Concurrency: 4}, compressor)
+	resetToDefaults()
+}
+func TestConfigureCompressor_FailsOnConcurrencyWithUnsupportedMethod(t *testing.T) {
+	viper.Set(config.CompressionMethodSetting, "lz4")
+	viper.Set(config.CompressionConcurrencySetting, 4)
+	compressor, err := internal.ConfigureCompressor()
+	assert.Error(t, err)
+	assert.Equal(t, compressor, nil)
+	resetToDefaults()
+}
 func TestConfigureCompressor_FailsOnZstdLevelWithoutZstdMethod(t *testing.T) {
 	viper.Set(config.CompressionMethodSetting, "lz4")
 	viper.Set(config.ZstdLevelSetting, "fastest")
@@ -20,12 +20,14 @@ import (
 const defaultRawCopyConcurrency = 8
 
 var compressionExtensions = map[string]struct{}{
-	".br":   {},
-	".gz":   {},
-	".lz4":  {},
-	".lzma": {},
-	".lzo":  {},
-	".zst":  {},
+	".adaptive": {},
+	".br":       {},
+	".gz":       {},
+	".lz4":      {},
+	".lzma":     {},
+	".lzo":      {},
+	".s2":       {},
+	".zst":      {},
 }
 
 // StripCompressionExtension recognizes every WAL-G archive suffix regardless
@@ -0,0 +1,134 @@
+package aead
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	KeyTransformBase64 = "base64"
+	KeyTransformHex    = "hex"
+
+	fingerprintBytes = 8
+)
+
+// Crypter encrypts data with XChaCha20-Poly1305 in fixed-size frames. Every frame is authenticated along with its
+// number and the mark of the final frame, so the truncated or reordered streams are detected during decryption.
+type Crypter struct {
+	key []byte
+
+	KeyInline    string
+	KeyPath      string
+	KeyTransform string
+
+	mutex sync.RWMutex
+}
+
+func (crypter *Crypter) Name() string {
+	return "AEAD/Crypter"
+}
+
+// CrypterFromKey creates Crypter from key
+func CrypterFromKey(key string, keyTransform string) crypto.Crypter {
+	return &Crypter{KeyInline: key, KeyTransform: keyTransform}
+}
+
+// CrypterFromKeyPath creates Crypter from key path
+func CrypterFromKeyPath(path string, keyTransform string) crypto.Crypter {
+	return &Crypter{KeyPath: path, KeyTransform: keyTransform}
+}
+
+func (crypter *Crypter) setup() error {
+	crypter.mutex.RLock()
+	if crypter.key != nil {
+		crypter.mutex.RUnlock()
+		return nil
+	}
+	crypter.mutex.RUnlock()
+
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+
+	if crypter.key != nil {
+		return nil
+	}
+
+	if crypter.KeyInline == "" && crypter.KeyPath == "" {
+		return errors.New("AEAD Crypter: must have a key or key path")
+	}
+
+	keyString := crypter.KeyInline
+	if keyString == "" {
+		keyFileContents, err := os.ReadFile(crypter.KeyPath)
+		if err != nil {
+			return fmt.Errorf("AEAD Crypter: unable to read key from file: %v", err)
+		}
+		keyString = strings.TrimSpace(string(keyFileContents))
+	}
+
+	key, err := keyTransform(keyString, crypter.KeyTransform)
+	if err != nil {
+		return fmt.Errorf("AEAD Crypter: during key transform: %v", err)
+	}
+
+	crypter.key = key
+	return nil
+}
+
+func keyTransform(keyString string, transform string) ([]byte, error) {
+	var key []byte
+	var err error
+	switch transform {
+	case KeyTransformHex:
+		key, err = hex.DecodeString(keyString)
+	case KeyTransformBase64:
+		key, err = base64.StdEncoding.DecodeString(keyString)
+	default:
+		return nil, fmt.Errorf("unknown key transform '%s' (must be %s or %s)", transform, KeyTransformHex, KeyTransformBase64)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while %s decoding key: %v", transform, err)
+	}
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("key must be exactly %d bytes (got %d bytes)", chacha20poly1305.KeySize, len(key))
+	}
+	return key, nil
+}
+
+// Encrypt creates encryption writer from ordinary writer
+func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	if err := crypter.setup(); err != nil {
+		return nil, err
+	}
+
+	return NewWriter(writer, crypter.key)
+}
+
+// Decrypt creates decrypted reader from ordinary reader
+func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	if err := crypter.setup(); err != nil {
+		return nil, err
+	}
+
+	return NewReader(reader, crypter.key)
+}
+
+// KeyFingerprint provides the prefix of the key hash, which identifies the key without revealing it
+func (crypter *Crypter) KeyFingerprint() (string, error) {
+	if err := crypter.setup(); err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(crypter.key)
+	return "aead:" + hex.EncodeToString(hash[:fingerprintBytes]), nil
+}
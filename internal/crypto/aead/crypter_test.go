@@ -0,0 +1,131 @@
+package aead
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const testKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func encrypt(t *testing.T, crypter crypto.Crypter, data []byte) []byte {
+	var encrypted bytes.Buffer
+	writer, err := crypter.Encrypt(&encrypted)
+	require.NoError(t, err)
+	_, err = writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return encrypted.Bytes()
+}
+
+func decrypt(crypter crypto.Crypter, encrypted []byte) ([]byte, error) {
+	reader, err := crypter.Decrypt(bytes.NewReader(encrypted))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func randomBytes(t *testing.T, size int) []byte {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	return data
+}
+
+func TestCrypter_EncryptDecrypt(t *testing.T) {
+	crypter := CrypterFromKey(testKey, KeyTransformHex)
+
+	for _, size := range []int{0, 1, frameSize - 1, frameSize, frameSize + 1, 3*frameSize + 17} {
+		data := randomBytes(t, size)
+		encrypted := encrypt(t, crypter, data)
+
+		decrypted, err := decrypt(crypter, encrypted)
+		require.NoError(t, err, size)
+		assert.Equal(t, data, decrypted, size)
+	}
+}
+
+func TestCrypter_DetectsTruncation(t *testing.T) {
+	crypter := CrypterFromKey(testKey, KeyTransformHex)
+	data := randomBytes(t, 2*frameSize)
+	encrypted := encrypt(t, crypter, data)
+	cipherFrameSize := frameSize + chacha20poly1305.Overhead
+
+	for name, length := range map[string]int{
+		"empty":               0,
+		"in the header":       headerSize - 1,
+		"after the header":    headerSize,
+		"at the frame border": headerSize + cipherFrameSize,
+		"in the frame":        headerSize + cipherFrameSize + 100,
+		"in the final frame":  len(encrypted) - 1,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := decrypt(crypter, encrypted[:length])
+			assert.True(t, crypto.IsIntegrityError(err), "%v", err)
+		})
+	}
+}
+
+func TestCrypter_DetectsReorderedFrames(t *testing.T) {
+	crypter := CrypterFromKey(testKey, KeyTransformHex)
+	encrypted := encrypt(t, crypter, randomBytes(t, 3*frameSize))
+	cipherFrameSize := frameSize + chacha20poly1305.Overhead
+
+	first := encrypted[headerSize : headerSize+cipherFrameSize]
+	second := encrypted[headerSize+cipherFrameSize : headerSize+2*cipherFrameSize]
+	reordered := append([]byte{}, encrypted[:headerSize]...)
+	reordered = append(reordered, second...)
+	reordered = append(reordered, first...)
+	reordered = append(reordered, encrypted[headerSize+2*cipherFrameSize:]...)
+
+	_, err := decrypt(crypter, reordered)
+	assert.True(t, crypto.IsIntegrityError(err), "%v", err)
+}
+
+func TestCrypter_DetectsAppendedData(t *testing.T) {
+	crypter := CrypterFromKey(testKey, KeyTransformHex)
+	encrypted := encrypt(t, crypter, randomBytes(t, frameSize))
+
+	_, err := decrypt(crypter, append(encrypted, encrypt(t, crypter, []byte("tail"))...))
+	assert.True(t, crypto.IsIntegrityError(err), "%v", err)
+}
+
+func TestCrypter_WrongKey(t *testing.T) {
+	encrypted := encrypt(t, CrypterFromKey(testKey, KeyTransformHex), []byte("secret"))
+
+	otherKey := hex.EncodeToString(randomBytes(t, chacha20poly1305.KeySize))
+	_, err := decrypt(CrypterFromKey(otherKey, KeyTransformHex), encrypted)
+	assert.Error(t, err)
+}
+
+func TestCrypter_InvalidKey(t *testing.T) {
+	for name, crypter := range map[string]crypto.Crypter{
+		"short":             CrypterFromKey("0001", KeyTransformHex),
+		"invalid base64":    CrypterFromKey("!!!", KeyTransformBase64),
+		"unknown transform": CrypterFromKey(testKey, "none"),
+		"no key":            CrypterFromKeyPath("", KeyTransformHex),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := crypter.Encrypt(io.Discard)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCrypter_KeyFingerprint(t *testing.T) {
+	fingerprint, err := CrypterFromKey(testKey, KeyTransformHex).(*Crypter).KeyFingerprint()
+	require.NoError(t, err)
+	assert.Regexp(t, "^aead:[0-9a-f]{16}$", fingerprint)
+}
+
+func TestSelfTest(t *testing.T) {
+	assert.NoError(t, crypto.SelfTest(CrypterFromKey(testKey, KeyTransformHex)))
+}
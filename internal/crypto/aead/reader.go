@@ -0,0 +1,147 @@
+package aead
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Reader wraps ordinary reader with the frame-by-frame decryption. It fails with crypto.IntegrityError if a frame
+// is modified, reordered or missing, including the final one.
+type Reader struct {
+	source io.Reader
+	frames *bufio.Reader
+
+	aead   cipher.AEAD
+	header []byte
+	nonce  []byte
+
+	in     []byte
+	out    []byte
+	outIdx int
+
+	frame uint64
+	done  bool
+
+	// In case of using io.Pipe we can't read header until writer doesn't write, therefore we use these sync
+	onceHeader sync.Once
+	headerErr  error
+}
+
+// NewReader creates Reader from ordinary reader and key
+func NewReader(reader io.Reader, key []byte) (io.Reader, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		source: reader,
+		aead:   aead,
+		nonce:  make([]byte, 0, chacha20poly1305.NonceSizeX),
+	}, nil
+}
+
+func (reader *Reader) readHeader() {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(reader.source, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			reader.headerErr = crypto.NewIntegrityError("AEAD stream is truncated: the header is incomplete")
+			return
+		}
+		reader.headerErr = errors.Wrap(err, "failed to read AEAD header")
+		return
+	}
+
+	if string(header[:len(magic)]) != magic {
+		reader.headerErr = errors.New("not an AEAD stream: invalid magic")
+		return
+	}
+	if version := header[len(magic)]; version != formatVersion {
+		reader.headerErr = errors.Errorf("unsupported AEAD stream version %d", version)
+		return
+	}
+	plaintextFrameSize := binary.BigEndian.Uint32(header[len(magic)+1:])
+	if plaintextFrameSize == 0 || plaintextFrameSize > maxFrameSize {
+		reader.headerErr = errors.Errorf("invalid AEAD frame size %d", plaintextFrameSize)
+		return
+	}
+
+	reader.header = header
+	reader.in = make([]byte, int(plaintextFrameSize)+chacha20poly1305.Overhead)
+	reader.frames = bufio.NewReader(reader.source)
+}
+
+// Read implements io.Reader
+func (reader *Reader) Read(p []byte) (n int, err error) {
+	reader.onceHeader.Do(reader.readHeader)
+	if reader.headerErr != nil {
+		return 0, reader.headerErr
+	}
+
+	for reader.outIdx >= len(reader.out) {
+		if reader.done {
+			return 0, io.EOF
+		}
+		if err = reader.readNextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	n = copy(p, reader.out[reader.outIdx:])
+	reader.outIdx += n
+	return n, nil
+}
+
+func (reader *Reader) readNextFrame() error {
+	n, err := io.ReadFull(reader.frames, reader.in)
+
+	var final bool
+	switch err {
+	case nil:
+		// the full frame is final only if nothing follows it
+		if _, err = reader.frames.Peek(1); err != nil && err != io.EOF {
+			return err
+		}
+		final = err == io.EOF
+	case io.ErrUnexpectedEOF:
+		final = true
+	case io.EOF:
+		return crypto.NewIntegrityError("AEAD stream is truncated: frame %d is missing", reader.frame)
+	default:
+		return err
+	}
+
+	if n < chacha20poly1305.Overhead {
+		return crypto.NewIntegrityError("AEAD stream is truncated: frame %d is incomplete", reader.frame)
+	}
+
+	reader.out, err = reader.open(reader.in[:n], final)
+	if err != nil {
+		// try the opposite mark to tell what exactly is wrong with the stream
+		if _, oppositeErr := reader.open(reader.in[:n], !final); oppositeErr == nil {
+			if final {
+				return crypto.NewIntegrityError("AEAD stream is truncated after frame %d", reader.frame)
+			}
+			return crypto.NewIntegrityError("AEAD stream has unexpected data after the final frame %d", reader.frame)
+		}
+		return crypto.NewIntegrityError("AEAD frame %d failed authentication: the stream is corrupted or reordered",
+			reader.frame)
+	}
+
+	reader.outIdx = 0
+	reader.frame++
+	reader.done = final
+	return nil
+}
+
+func (reader *Reader) open(frame []byte, final bool) ([]byte, error) {
+	reader.nonce = makeNonce(reader.nonce, reader.header, reader.frame, final)
+	return reader.aead.Open(reader.out[:0], reader.nonce, frame, reader.header)
+}
@@ -0,0 +1,42 @@
+package aead
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The stream starts with the header: magic, format version, plaintext frame size (uint32, big endian) and the random
+// nonce prefix. It's followed by the frames, each one is up to frameSize bytes of plaintext sealed with the nonce
+// made of the prefix, the frame number (uint64, big endian) and the final frame mark. The header is authenticated
+// as the additional data of every frame.
+const (
+	magic         = "WALGAEAD"
+	formatVersion = 1
+
+	frameSize    = 64 * 1024
+	maxFrameSize = 16 * 1024 * 1024
+
+	noncePrefixSize = chacha20poly1305.NonceSizeX - 8 - 1
+	headerSize      = len(magic) + 1 + 4 + noncePrefixSize
+
+	notFinalFrame = 0
+	finalFrame    = 1
+)
+
+func makeHeader(frameSize uint32, noncePrefix []byte) []byte {
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, formatVersion)
+	header = binary.BigEndian.AppendUint32(header, frameSize)
+	return append(header, noncePrefix...)
+}
+
+func makeNonce(nonce []byte, header []byte, frame uint64, final bool) []byte {
+	nonce = append(nonce[:0], header[headerSize-noncePrefixSize:]...)
+	nonce = binary.BigEndian.AppendUint64(nonce, frame)
+	if final {
+		return append(nonce, finalFrame)
+	}
+	return append(nonce, notFinalFrame)
+}
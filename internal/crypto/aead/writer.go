@@ -0,0 +1,103 @@
+package aead
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Writer wraps ordinary writer with the frame-by-frame encryption
+type Writer struct {
+	io.Writer
+
+	aead   cipher.AEAD
+	header []byte
+	nonce  []byte
+
+	in    []byte
+	inIdx int
+	out   []byte
+
+	frame         uint64
+	headerWritten bool
+	closed        bool
+}
+
+// NewWriter creates Writer from ordinary writer and key
+func NewWriter(writer io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+
+	return &Writer{
+		Writer: writer,
+
+		aead:   aead,
+		header: makeHeader(frameSize, noncePrefix),
+		nonce:  make([]byte, 0, chacha20poly1305.NonceSizeX),
+
+		in:  make([]byte, frameSize),
+		out: make([]byte, 0, frameSize+chacha20poly1305.Overhead),
+	}, nil
+}
+
+// Write implements io.Writer. The frame is sealed only when the next byte arrives, since the last frame must be
+// marked as final on Close.
+func (writer *Writer) Write(p []byte) (n int, err error) {
+	if writer.closed {
+		return 0, errors.New("write to the closed AEAD writer")
+	}
+
+	for len(p) > 0 {
+		if writer.inIdx == len(writer.in) {
+			if err = writer.writeFrame(false); err != nil {
+				return
+			}
+		}
+
+		copied := copy(writer.in[writer.inIdx:], p)
+		writer.inIdx += copied
+		p = p[copied:]
+		n += copied
+	}
+
+	return
+}
+
+// Close seals the final frame. It doesn't close the underlying writer.
+func (writer *Writer) Close() error {
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+
+	return writer.writeFrame(true)
+}
+
+func (writer *Writer) writeFrame(final bool) error {
+	if !writer.headerWritten {
+		if _, err := writer.Writer.Write(writer.header); err != nil {
+			return err
+		}
+		writer.headerWritten = true
+	}
+
+	writer.nonce = makeNonce(writer.nonce, writer.header, writer.frame, final)
+	writer.out = writer.aead.Seal(writer.out[:0], writer.nonce, writer.in[:writer.inIdx], writer.header)
+	if _, err := writer.Writer.Write(writer.out); err != nil {
+		return err
+	}
+
+	writer.frame++
+	writer.inIdx = 0
+	return nil
+}
@@ -0,0 +1,155 @@
+package age
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"filippo.io/age"
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+)
+
+// Crypter is the age (X25519) Crypter implementation. Data is encrypted to all recipients, so any of their
+// identities can decrypt it.
+type Crypter struct {
+	Recipients   []string
+	IdentityPath string
+
+	recipients []age.Recipient
+	identities []age.Identity
+
+	mutex sync.RWMutex
+}
+
+func (crypter *Crypter) Name() string {
+	return "Age/Crypter"
+}
+
+// CrypterFromRecipients creates Crypter from a list of age recipients separated by commas or whitespaces, and a path
+// to the file with identities. Recipients are needed for encryption, and identities are needed for decryption.
+func CrypterFromRecipients(recipients string, identityPath string) crypto.Crypter {
+	return &Crypter{
+		Recipients: strings.FieldsFunc(recipients, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		}),
+		IdentityPath: identityPath,
+	}
+}
+
+func (crypter *Crypter) setupRecipients() error {
+	crypter.mutex.RLock()
+	if crypter.recipients != nil {
+		crypter.mutex.RUnlock()
+		return nil
+	}
+	crypter.mutex.RUnlock()
+
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	if crypter.recipients != nil {
+		return nil
+	}
+
+	if len(crypter.Recipients) == 0 {
+		return errors.New("age Crypter: must have at least one recipient to encrypt")
+	}
+	recipients := make([]age.Recipient, 0, len(crypter.Recipients))
+	for _, recipientString := range crypter.Recipients {
+		recipient, err := age.ParseX25519Recipient(recipientString)
+		if err != nil {
+			return fmt.Errorf("age Crypter: invalid recipient %q: %v", recipientString, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	crypter.recipients = recipients
+	return nil
+}
+
+func (crypter *Crypter) setupIdentities() error {
+	crypter.mutex.RLock()
+	if crypter.identities != nil {
+		crypter.mutex.RUnlock()
+		return nil
+	}
+	crypter.mutex.RUnlock()
+
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	if crypter.identities != nil {
+		return nil
+	}
+
+	if crypter.IdentityPath == "" {
+		return errors.New("age Crypter: must have an identity path to decrypt")
+	}
+	identityFile, err := os.Open(crypter.IdentityPath)
+	if err != nil {
+		return fmt.Errorf("age Crypter: unable to open identity file: %v", err)
+	}
+	defer identityFile.Close()
+
+	identities, err := age.ParseIdentities(identityFile)
+	if err != nil {
+		return fmt.Errorf("age Crypter: unable to parse identity file: %v", err)
+	}
+	crypter.identities = identities
+	return nil
+}
+
+// Encrypt creates encryption writer from ordinary writer
+func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	if err := crypter.setupRecipients(); err != nil {
+		return nil, err
+	}
+
+	// age writes the header immediately, which can block if the underlying writer is a pipe that nobody reads yet
+	bufferedWriter := bufio.NewWriter(writer)
+	encryptedWriter, err := age.Encrypt(bufferedWriter, crypter.recipients...)
+	if err != nil {
+		return nil, errors.Wrap(err, "age encryption error")
+	}
+
+	return ioextensions.NewOnCloseFlusher(encryptedWriter, bufferedWriter), nil
+}
+
+// KeyFingerprint provides the recipients the data is encrypted to. They are public keys, so it is safe to show them,
+// and `age-keygen -y` tells which recipient an identity belongs to. If only identities are configured, which is
+// enough for decryption, their recipients are provided.
+func (crypter *Crypter) KeyFingerprint() (string, error) {
+	if len(crypter.Recipients) > 0 || crypter.IdentityPath == "" {
+		if err := crypter.setupRecipients(); err != nil {
+			return "", err
+		}
+		return "age:" + strings.Join(crypter.Recipients, ","), nil
+	}
+
+	if err := crypter.setupIdentities(); err != nil {
+		return "", err
+	}
+	recipients := make([]string, 0, len(crypter.identities))
+	for _, identity := range crypter.identities {
+		if x25519Identity, ok := identity.(*age.X25519Identity); ok {
+			recipients = append(recipients, x25519Identity.Recipient().String())
+		}
+	}
+	return "age:" + strings.Join(recipients, ","), nil
+}
+
+// Decrypt creates decrypted reader from ordinary reader
+func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	if err := crypter.setupIdentities(); err != nil {
+		return nil, err
+	}
+
+	decryptedReader, err := age.Decrypt(reader, crypter.identities...)
+	if err != nil {
+		return nil, errors.Wrap(err, "age decryption error")
+	}
+	return decryptedReader, nil
+}
@@ -0,0 +1,107 @@
+package age
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+func writeIdentity(t *testing.T, identity *age.X25519Identity) string {
+	path := filepath.Join(t.TempDir(), "identity.txt")
+	content := "# created: 2024-01-01T00:00:00Z\n# public key: " + identity.Recipient().String() + "\n" +
+		identity.String() + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func encrypt(t *testing.T, crypter crypto.Crypter, secret string) []byte {
+	var encrypted bytes.Buffer
+	writer, err := crypter.Encrypt(&encrypted)
+	require.NoError(t, err)
+	_, err = io.WriteString(writer, secret)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return encrypted.Bytes()
+}
+
+func decrypt(crypter crypto.Crypter, encrypted []byte) (string, error) {
+	reader, err := crypter.Decrypt(bytes.NewReader(encrypted))
+	if err != nil {
+		return "", err
+	}
+	decrypted, err := io.ReadAll(reader)
+	return string(decrypted), err
+}
+
+func TestCrypter_EncryptionCycle(t *testing.T) {
+	onCall, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	recovery, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	secret := strings.Repeat(" so very secret thing ", 10000)
+	recipients := onCall.Recipient().String() + ", " + recovery.Recipient().String()
+	encrypted := encrypt(t, CrypterFromRecipients(recipients, ""), secret)
+	assert.NotContains(t, string(encrypted), "secret")
+
+	for name, identity := range map[string]*age.X25519Identity{"on-call": onCall, "recovery": recovery} {
+		t.Run(name, func(t *testing.T) {
+			decrypted, err := decrypt(CrypterFromRecipients("", writeIdentity(t, identity)), encrypted)
+			require.NoError(t, err)
+			assert.Equal(t, secret, decrypted)
+		})
+	}
+
+	t.Run("not a recipient", func(t *testing.T) {
+		_, err := decrypt(CrypterFromRecipients("", writeIdentity(t, other)), encrypted)
+		assert.Error(t, err)
+	})
+}
+
+func TestCrypter_ConfigurationErrors(t *testing.T) {
+	_, err := CrypterFromRecipients("", "").Encrypt(io.Discard)
+	assert.Error(t, err)
+
+	_, err = CrypterFromRecipients("age1invalid", "").Encrypt(io.Discard)
+	assert.Error(t, err)
+
+	_, err = CrypterFromRecipients("", "").Decrypt(strings.NewReader(""))
+	assert.Error(t, err)
+
+	_, err = CrypterFromRecipients("", filepath.Join(t.TempDir(), "missing")).Decrypt(strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func TestCrypter_KeyFingerprint(t *testing.T) {
+	onCall, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	recovery, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	recipients := onCall.Recipient().String() + "," + recovery.Recipient().String()
+	encrypting, err := crypto.KeyFingerprint(CrypterFromRecipients(recipients, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "age:"+recipients, encrypting)
+
+	decrypting, err := crypto.KeyFingerprint(CrypterFromRecipients("", writeIdentity(t, recovery)))
+	require.NoError(t, err)
+	assert.Equal(t, "age:"+recovery.Recipient().String(), decrypting)
+	assert.True(t, crypto.FingerprintsMatch(encrypting, decrypting))
+}
+
+func TestSelfTest(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	crypter := CrypterFromRecipients(identity.Recipient().String(), writeIdentity(t, identity))
+	assert.NoError(t, crypto.SelfTest(crypter))
+}
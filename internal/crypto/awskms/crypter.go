@@ -62,6 +62,11 @@ func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
 	return sio.DecryptReader(reader, sio.Config{Key: crypter.SymmetricKey.GetKey()})
 }
 
+// KeyFingerprint provides the ID of the KMS key the symmetric keys are encrypted with
+func (crypter *Crypter) KeyFingerprint() (string, error) {
+	return "awskms:" + crypter.SymmetricKey.GetKeyID(), nil
+}
+
 // CrypterFromKeyID creates AWS KMS Crypter with given KMS Key ID
 func CrypterFromKeyID(CseKmsID string, CseKmsRegion string) crypto.Crypter {
 	return &Crypter{SymmetricKey: NewSymmetricKey(CseKmsID, 32, 184, CseKmsRegion)}
@@ -54,3 +54,7 @@ func TestEncryptionCycle(t *testing.T) {
 
 	assert.Equal(t, someSecret, string(decryptedBytes), "Decrypted text not equals open text")
 }
+
+func TestSelfTest(t *testing.T) {
+	assert.NoError(t, crypto.SelfTest(MockCrypterFromKeyID("AWSKMSKEYID")))
+}
@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SelfTestPayloadSize is big enough for the test payload to span several chunks of the chunked crypters
+const SelfTestPayloadSize = 256 << 10
+
+// SelfTest encrypts a random payload and decrypts it back, so that a misconfigured crypter is found before the
+// encrypted data is needed. It doesn't depend on anything but the crypter and its key material.
+func SelfTest(crypter Crypter) error {
+	payload := make([]byte, SelfTestPayloadSize)
+	if _, err := rand.Read(payload); err != nil {
+		return errors.Wrap(err, "failed to generate the test payload")
+	}
+
+	var encrypted bytes.Buffer
+	writer, err := crypter.Encrypt(&encrypted)
+	if err != nil {
+		return errors.Wrap(err, "failed to start the encryption")
+	}
+	if _, err = writer.Write(payload); err != nil {
+		return errors.Wrap(err, "failed to encrypt the test payload")
+	}
+	if err = writer.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish the encryption")
+	}
+	if bytes.Contains(encrypted.Bytes(), payload[:64]) {
+		return errors.New("the encrypted payload contains the plain text")
+	}
+
+	reader, err := crypter.Decrypt(&encrypted)
+	if err != nil {
+		return errors.Wrap(err, "failed to start the decryption")
+	}
+	decrypted, err := io.ReadAll(reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt the test payload")
+	}
+	if !bytes.Equal(payload, decrypted) {
+		return errors.New("the decrypted payload differs from the original one")
+	}
+	return nil
+}
+
+// KeyExpirer is implemented by crypters which keys can expire, like PGP ones.
+type KeyExpirer interface {
+	// KeyExpiry provides the time the data can't be encrypted with the key anymore, expires is false if never.
+	KeyExpiry() (expiry time.Time, expires bool, err error)
+}
+
+// KeyExpiry provides the expiry of the key the crypter encrypts with, expires is false if the crypter's keys
+// don't expire.
+func KeyExpiry(crypter Crypter) (expiry time.Time, expires bool, err error) {
+	expirer, ok := crypter.(KeyExpirer)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	return expirer.KeyExpiry()
+}
@@ -0,0 +1,56 @@
+package crypto_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+// plainCrypter doesn't encrypt anything, like a crypter misconfigured to pass the data through
+type plainCrypter struct {
+	fakeCrypter
+}
+
+func (crypter *plainCrypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{writer}, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+type expiringCrypter struct {
+	fakeCrypter
+	expiry time.Time
+}
+
+func (crypter *expiringCrypter) KeyExpiry() (time.Time, bool, error) {
+	return crypter.expiry, true, nil
+}
+
+func TestSelfTest_PlainText(t *testing.T) {
+	err := crypto.SelfTest(&plainCrypter{})
+	assert.ErrorContains(t, err, "plain text")
+}
+
+func TestSelfTest_EncryptionError(t *testing.T) {
+	assert.Error(t, crypto.SelfTest(&fakeCrypter{}))
+}
+
+func TestKeyExpiry(t *testing.T) {
+	_, expires, err := crypto.KeyExpiry(&fakeCrypter{})
+	require.NoError(t, err)
+	assert.False(t, expires)
+
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	found, expires, err := crypto.KeyExpiry(&expiringCrypter{expiry: expiry})
+	require.NoError(t, err)
+	assert.True(t, expires)
+	assert.Equal(t, expiry, found)
+}
@@ -8,3 +8,24 @@ type Crypter interface {
 	Encrypt(writer io.Writer) (io.WriteCloser, error)
 	Decrypt(reader io.Reader) (io.Reader, error)
 }
+
+// KeyFingerprinter is implemented by crypters that can identify the key they encrypt with,
+// so that it is possible to tell which key is needed to decrypt the data later.
+type KeyFingerprinter interface {
+	// KeyFingerprint provides a short identifier of the key, like "pgp:0123456789ABCDEF".
+	// It must not reveal the key itself.
+	KeyFingerprint() (string, error)
+}
+
+// KeyFingerprint provides the fingerprint of the key the crypter encrypts with.
+// It returns an empty string if there is no crypter or it can't identify its key.
+func KeyFingerprint(crypter Crypter) (string, error) {
+	if crypter == nil {
+		return "", nil
+	}
+	fingerprinter, ok := crypter.(KeyFingerprinter)
+	if !ok {
+		return "", nil
+	}
+	return fingerprinter.KeyFingerprint()
+}
@@ -83,3 +83,23 @@ func GetSecretRingArmor(keyID string) ([]byte, error) {
 	}
 	return out, nil
 }
+
+// IntegrityError is returned by the authenticated crypters when the encrypted stream is truncated, reordered or
+// modified, so the decrypted data must not be used.
+type IntegrityError struct {
+	error
+}
+
+func NewIntegrityError(format string, args ...interface{}) IntegrityError {
+	return IntegrityError{errors.Errorf(format, args...)}
+}
+
+func (err IntegrityError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// IsIntegrityError tells if the error or any error it wraps is IntegrityError.
+func IsIntegrityError(err error) bool {
+	var integrityErr IntegrityError
+	return errors.As(err, &integrityErr)
+}
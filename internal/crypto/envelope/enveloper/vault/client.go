@@ -0,0 +1,149 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+const requestTimeout = 30 * time.Second
+
+// Auth configures how the client authenticates in Vault. Either Token, or RoleID and SecretID must be set.
+type Auth struct {
+	Token string
+
+	RoleID       string
+	SecretID     string
+	AppRoleMount string
+}
+
+// client is a minimal client of the Vault HTTP API.
+type client struct {
+	address    string
+	auth       Auth
+	httpClient *http.Client
+
+	mutex sync.Mutex
+	token string
+}
+
+func newClient(address string, auth Auth) (*client, error) {
+	if auth.Token == "" && (auth.RoleID == "" || auth.SecretID == "") {
+		return nil, errors.New("vault: either token or AppRole role ID and secret ID must be configured")
+	}
+	if auth.AppRoleMount == "" {
+		auth.AppRoleMount = "approle"
+	}
+	return &client{
+		address:    strings.TrimSuffix(address, "/"),
+		auth:       auth,
+		httpClient: &http.Client{Timeout: requestTimeout},
+		token:      auth.Token,
+	}, nil
+}
+
+type response struct {
+	Data map[string]interface{} `json:"data"`
+	Auth *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+type responseError struct {
+	statusCode int
+	errors     []string
+}
+
+func (err responseError) Error() string {
+	return fmt.Sprintf("vault responded with status %d: %s", err.statusCode, strings.Join(err.errors, "; "))
+}
+
+// write sends a POST request to the path. AppRole tokens are obtained on the first request, and obtained again once
+// if Vault denies the request, e.g. when the token has expired.
+func (c *client) write(ctx context.Context, path string, body interface{}) (*response, error) {
+	token, err := c.getToken(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := c.do(ctx, path, token, body)
+	var rspErr responseError
+	if errors.As(err, &rspErr) && rspErr.statusCode == http.StatusForbidden && c.auth.Token == "" {
+		tracelog.DebugLogger.Printf("Vault denied the request to %s, logging in with AppRole again", path)
+		token, err = c.getToken(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err = c.do(ctx, path, token, body)
+	}
+	return rsp, err
+}
+
+func (c *client) getToken(ctx context.Context, renew bool) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.token != "" && !renew {
+		return c.token, nil
+	}
+
+	rsp, err := c.do(ctx, "auth/"+c.auth.AppRoleMount+"/login", "", map[string]string{
+		"role_id":   c.auth.RoleID,
+		"secret_id": c.auth.SecretID,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "vault: AppRole login")
+	}
+	if rsp.Auth == nil || rsp.Auth.ClientToken == "" {
+		return "", errors.New("vault: AppRole login response has no client token")
+	}
+	c.token = rsp.Auth.ClientToken
+	return c.token, nil
+}
+
+func (c *client) do(ctx context.Context, path, token string, body interface{}) (*response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.address+"/v1/"+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	httpRsp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpRsp.Body.Close()
+	rspBody, err := io.ReadAll(httpRsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &response{}
+	if len(rspBody) > 0 {
+		err = json.Unmarshal(rspBody, rsp)
+		if err != nil && httpRsp.StatusCode == http.StatusOK {
+			return nil, errors.Wrap(err, "vault: parse response")
+		}
+	}
+	if httpRsp.StatusCode != http.StatusOK && httpRsp.StatusCode != http.StatusNoContent {
+		return nil, responseError{statusCode: httpRsp.StatusCode, errors: rsp.Errors}
+	}
+	return rsp, nil
+}
@@ -0,0 +1,212 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+)
+
+const (
+	magic              = "envelope-vault-transit"
+	schemeVersion byte = 1
+	sizeofInt32        = 4
+
+	ciphertextPrefix = "vault:v"
+)
+
+// Enveloper unwraps keys with the Vault Transit secrets engine. Wrapped keys are Transit ciphertexts, which start
+// with the version of the Transit key they are encrypted with, like "vault:v3:...".
+type Enveloper struct {
+	client       *client
+	transitMount string
+	keyName      string
+}
+
+func (enveloper *Enveloper) Name() string {
+	return "vault"
+}
+
+func (enveloper *Enveloper) ReadEncryptedKey(r io.Reader) (*envelope.EncryptedKey, error) {
+	header, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if header.keyName != enveloper.keyName {
+		return nil, errors.Errorf("envelope vault: key is wrapped with transit key %q, but %q is configured",
+			header.keyName, enveloper.keyName)
+	}
+	tracelog.DebugLogger.Printf("Encrypted key %s is wrapped with version %d of transit key %q\n",
+		header.encryptedKey.ID(), header.keyVersion, header.keyName)
+	return header.encryptedKey, nil
+}
+
+func (enveloper *Enveloper) DecryptKey(encryptedKey *envelope.EncryptedKey) ([]byte, error) {
+	rsp, err := enveloper.client.write(context.Background(), enveloper.transitMount+"/decrypt/"+enveloper.keyName,
+		map[string]string{"ciphertext": string(encryptedKey.Data)})
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope vault: decrypt key")
+	}
+	plaintext, ok := rsp.Data["plaintext"].(string)
+	if !ok {
+		return nil, errors.New("envelope vault: decrypt response has no plaintext")
+	}
+	key, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope vault: decode plaintext")
+	}
+	return key, nil
+}
+
+func (enveloper *Enveloper) SerializeEncryptedKey(encryptedKey *envelope.EncryptedKey) []byte {
+	keyVersion, err := KeyVersion(encryptedKey.Data)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Unable to get the transit key version of the encrypted key: %v", err)
+	}
+	return serializeHeader(header{
+		keyName:      enveloper.keyName,
+		keyVersion:   keyVersion,
+		encryptedKey: encryptedKey,
+	})
+}
+
+// KeyVersion provides the version of the Transit key the ciphertext is encrypted with.
+func KeyVersion(ciphertext []byte) (int, error) {
+	rest, ok := strings.CutPrefix(string(ciphertext), ciphertextPrefix)
+	if !ok {
+		return 0, errors.New("envelope vault: ciphertext has no vault prefix")
+	}
+	versionString, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return 0, errors.New("envelope vault: ciphertext has no key version")
+	}
+	version, err := strconv.Atoi(versionString)
+	if err != nil {
+		return 0, fmt.Errorf("envelope vault: invalid key version %q", versionString)
+	}
+	return version, nil
+}
+
+type header struct {
+	keyName      string
+	keyVersion   int
+	encryptedKey *envelope.EncryptedKey
+}
+
+func serializeHeader(h header) []byte {
+	/*
+		magic value "envelope-vault-transit"
+		scheme version (current version is 1)
+		uint32 - keyID len
+		keyID ...
+		uint32 - transit key name len
+		transit key name ...
+		uint32 - transit key version
+		uint32 - encrypted key len
+		encrypted key ...
+	*/
+
+	result := append([]byte(magic), schemeVersion)
+	result = appendBytes(result, []byte(h.encryptedKey.ID()))
+	result = appendBytes(result, []byte(h.keyName))
+	result = binary.LittleEndian.AppendUint32(result, uint32(h.keyVersion))
+	return appendBytes(result, h.encryptedKey.Data)
+}
+
+func appendBytes(result []byte, data []byte) []byte {
+	result = binary.LittleEndian.AppendUint32(result, uint32(len(data)))
+	return append(result, data...)
+}
+
+func readHeader(r io.Reader) (header, error) {
+	magicSchemeBytes := make([]byte, len(magic)+1)
+	_, err := io.ReadFull(r, magicSchemeBytes)
+	if err != nil {
+		return header{}, err
+	}
+	if string(magicSchemeBytes[0:len(magic)]) != magic {
+		return header{}, errors.New("envelope vault: invalid encrypted header format")
+	}
+	if schemeVersion != magicSchemeBytes[len(magic)] {
+		return header{}, errors.New("envelope vault: scheme version is not supported")
+	}
+
+	keyID, err := readBytes(r)
+	if err != nil {
+		return header{}, err
+	}
+	keyName, err := readBytes(r)
+	if err != nil {
+		return header{}, err
+	}
+	keyVersion, err := readUint32(r)
+	if err != nil {
+		return header{}, err
+	}
+	encryptedKey, err := readBytes(r)
+	if err != nil {
+		return header{}, err
+	}
+
+	if ciphertextVersion, err := KeyVersion(encryptedKey); err == nil && ciphertextVersion != int(keyVersion) {
+		return header{}, errors.Errorf("envelope vault: header has key version %d, but the key is encrypted with %d",
+			keyVersion, ciphertextVersion)
+	}
+	return header{
+		keyName:      string(keyName),
+		keyVersion:   int(keyVersion),
+		encryptedKey: envelope.NewEncryptedKey(string(keyID), encryptedKey),
+	}, nil
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	bytes := make([]byte, sizeofInt32)
+	_, err := io.ReadFull(r, bytes)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(bytes), nil
+}
+
+func readBytes(r io.Reader) ([]byte, error) {
+	length, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// EnveloperFromTransitKey creates Enveloper that unwraps keys with the named key of the Transit engine mounted at
+// transitMount in the Vault at address.
+func EnveloperFromTransitKey(address, transitMount, keyName string, auth Auth) (envelope.Enveloper, error) {
+	if address == "" {
+		return nil, errors.New("envelope vault: Vault address must be configured")
+	}
+	if keyName == "" {
+		return nil, errors.New("envelope vault: transit key name must be configured")
+	}
+	if transitMount == "" {
+		transitMount = "transit"
+	}
+	vaultClient, err := newClient(address, auth)
+	if err != nil {
+		return nil, err
+	}
+	return &Enveloper{
+		client:       vaultClient,
+		transitMount: strings.Trim(transitMount, "/"),
+		keyName:      keyName,
+	}, nil
+}
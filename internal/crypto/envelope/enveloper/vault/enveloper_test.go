@@ -0,0 +1,213 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+	"github.com/wal-g/wal-g/internal/crypto/envelope/enveloper/cached"
+)
+
+const (
+	testKeyName  = "walg"
+	testRoleID   = "role"
+	testSecretID = "secret"
+)
+
+// fakeVault is an in-process stand-in for Vault with the Transit and AppRole engines. Its "encryption" is just
+// base64, which is enough to check what the enveloper sends.
+type fakeVault struct {
+	mutex        sync.Mutex
+	validTokens  map[string]bool
+	logins       int
+	decryptCalls int
+}
+
+func newFakeVault(t *testing.T, tokens ...string) (*fakeVault, string) {
+	vault := &fakeVault{validTokens: map[string]bool{}}
+	for _, token := range tokens {
+		vault.validTokens[token] = true
+	}
+	server := httptest.NewServer(http.HandlerFunc(vault.handle))
+	t.Cleanup(server.Close)
+	return vault, server.URL
+}
+
+func (v *fakeVault) expireTokens() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.validTokens = map[string]bool{}
+}
+
+func (v *fakeVault) handle(w http.ResponseWriter, r *http.Request) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Method != http.MethodPost {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"bad request"}})
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/v1/auth/approle/login":
+		if body["role_id"] != testRoleID || body["secret_id"] != testSecretID {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid role or secret ID"}})
+			return
+		}
+		v.logins++
+		token := "approle-token-" + strings.Repeat("x", v.logins)
+		v.validTokens[token] = true
+		writeJSON(w, http.StatusOK, map[string]interface{}{"auth": map[string]string{"client_token": token}})
+	case strings.HasPrefix(r.URL.Path, "/v1/transit/decrypt/"):
+		if !v.validTokens[r.Header.Get("X-Vault-Token")] {
+			writeJSON(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		v.decryptCalls++
+		keyName := strings.TrimPrefix(r.URL.Path, "/v1/transit/decrypt/")
+		parts := strings.SplitN(body["ciphertext"], ":", 3)
+		if keyName != testKeyName || len(parts) != 3 || parts[0] != "vault" {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid ciphertext"}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]string{"plaintext": parts[2]}})
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func fakeCiphertext(version string, plaintext string) []byte {
+	return []byte("vault:v" + version + ":" + base64.StdEncoding.EncodeToString([]byte(plaintext)))
+}
+
+func TestSerializeDeserializeKeyHeader(t *testing.T) {
+	enveloper, err := EnveloperFromTransitKey("http://vault", "", testKeyName, Auth{Token: "token"})
+	require.NoError(t, err)
+
+	expected := envelope.NewEncryptedKey("example", fakeCiphertext("3", "key"))
+	serialized := enveloper.SerializeEncryptedKey(expected)
+
+	header, err := readHeader(bytes.NewReader(serialized))
+	require.NoError(t, err)
+	assert.Equal(t, testKeyName, header.keyName)
+	assert.Equal(t, 3, header.keyVersion)
+
+	encryptedKey, err := enveloper.ReadEncryptedKey(bytes.NewReader(serialized))
+	require.NoError(t, err)
+	assert.Equal(t, expected.ID(), encryptedKey.ID())
+	assert.Equal(t, expected.Data, encryptedKey.Data)
+}
+
+func TestReadEncryptedKey_OtherTransitKey(t *testing.T) {
+	enveloper, err := EnveloperFromTransitKey("http://vault", "", testKeyName, Auth{Token: "token"})
+	require.NoError(t, err)
+	serialized := serializeHeader(header{
+		keyName:      "other",
+		keyVersion:   1,
+		encryptedKey: envelope.NewEncryptedKey("example", fakeCiphertext("1", "key")),
+	})
+
+	_, err = enveloper.ReadEncryptedKey(bytes.NewReader(serialized))
+	assert.ErrorContains(t, err, `"other"`)
+}
+
+func TestReadEncryptedKey_VersionMismatch(t *testing.T) {
+	serialized := serializeHeader(header{
+		keyName:      testKeyName,
+		keyVersion:   1,
+		encryptedKey: envelope.NewEncryptedKey("example", fakeCiphertext("2", "key")),
+	})
+
+	_, err := readHeader(bytes.NewReader(serialized))
+	assert.Error(t, err)
+}
+
+func TestKeyVersion(t *testing.T) {
+	version, err := KeyVersion([]byte("vault:v12:abc"))
+	require.NoError(t, err)
+	assert.Equal(t, 12, version)
+
+	for _, invalid := range []string{"", "abc", "vault:v12", "vault:vx:abc"} {
+		_, err := KeyVersion([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDecryptKey_Token(t *testing.T) {
+	_, address := newFakeVault(t, "token")
+
+	enveloper, err := EnveloperFromTransitKey(address, "transit", testKeyName, Auth{Token: "token"})
+	require.NoError(t, err)
+	key, err := enveloper.DecryptKey(envelope.NewEncryptedKey("", fakeCiphertext("1", "data key")))
+	require.NoError(t, err)
+	assert.Equal(t, "data key", string(key))
+
+	enveloper, err = EnveloperFromTransitKey(address, "transit", testKeyName, Auth{Token: "wrong"})
+	require.NoError(t, err)
+	_, err = enveloper.DecryptKey(envelope.NewEncryptedKey("", fakeCiphertext("1", "data key")))
+	assert.ErrorContains(t, err, "permission denied")
+}
+
+func TestDecryptKey_AppRole(t *testing.T) {
+	vault, address := newFakeVault(t)
+	auth := Auth{RoleID: testRoleID, SecretID: testSecretID}
+
+	enveloper, err := EnveloperFromTransitKey(address, "", testKeyName, auth)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		key, err := enveloper.DecryptKey(envelope.NewEncryptedKey("", fakeCiphertext("1", "data key")))
+		require.NoError(t, err)
+		assert.Equal(t, "data key", string(key))
+	}
+	assert.Equal(t, 1, vault.logins)
+
+	// expired tokens are renewed
+	vault.expireTokens()
+	key, err := enveloper.DecryptKey(envelope.NewEncryptedKey("", fakeCiphertext("1", "data key")))
+	require.NoError(t, err)
+	assert.Equal(t, "data key", string(key))
+	assert.Equal(t, 2, vault.logins)
+
+	enveloper, err = EnveloperFromTransitKey(address, "", testKeyName, Auth{RoleID: testRoleID, SecretID: "wrong"})
+	require.NoError(t, err)
+	_, err = enveloper.DecryptKey(envelope.NewEncryptedKey("", fakeCiphertext("1", "data key")))
+	assert.ErrorContains(t, err, "invalid role or secret ID")
+}
+
+func TestDecryptKey_Cached(t *testing.T) {
+	vault, address := newFakeVault(t, "token")
+	enveloper, err := EnveloperFromTransitKey(address, "", testKeyName, Auth{Token: "token"})
+	require.NoError(t, err)
+	cachedEnveloper := cached.EnveloperWithCache(enveloper, 0)
+
+	for i := 0; i < 3; i++ {
+		key, err := cachedEnveloper.DecryptKey(envelope.NewEncryptedKey("", fakeCiphertext("1", "data key")))
+		require.NoError(t, err)
+		assert.Equal(t, "data key", string(key))
+	}
+	assert.Equal(t, 1, vault.decryptCalls)
+}
+
+func TestEnveloperFromTransitKey_ConfigurationErrors(t *testing.T) {
+	_, err := EnveloperFromTransitKey("", "", testKeyName, Auth{Token: "token"})
+	assert.Error(t, err)
+	_, err = EnveloperFromTransitKey("http://vault", "", "", Auth{Token: "token"})
+	assert.Error(t, err)
+	_, err = EnveloperFromTransitKey("http://vault", "", testKeyName, Auth{RoleID: testRoleID})
+	assert.Error(t, err)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/crypto/envelope"
+	pgp "github.com/wal-g/wal-g/internal/crypto/openpgp"
 	"github.com/wal-g/wal-g/internal/ioextensions"
 )
 
@@ -83,6 +84,45 @@ func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
 	return ioextensions.NewOnCloseFlusher(encryptedWriter, bufferedWriter), nil
 }
 
+// KeyFingerprint provides the enveloper and the IDs of the wrapped keys. The keys are unwrapped to get their IDs.
+func (crypter *Crypter) KeyFingerprint() (string, error) {
+	err := crypter.setupEncryptedKey()
+	if err != nil {
+		return "", err
+	}
+	key, err := crypter.enveloper.DecryptKey(crypter.encryptedKey)
+	if err != nil {
+		return "", errors.Wrapf(err, "can't decrypt encryption key")
+	}
+	entityList, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	if err != nil {
+		return "", errors.Wrapf(err, "can't read decrypyed gpg key")
+	}
+	keyID, err := encodeKeyID(entityList)
+	if err != nil {
+		return "", errors.Wrapf(err, "can't encode gpg key id")
+	}
+	return "envelope-" + crypter.enveloper.Name() + "-pgp:" + keyID, nil
+}
+
+// KeyExpiry provides the earliest expiry of the wrapped keys. The keys are unwrapped to get it.
+func (crypter *Crypter) KeyExpiry() (time.Time, bool, error) {
+	err := crypter.setupEncryptedKey()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	key, err := crypter.enveloper.DecryptKey(crypter.encryptedKey)
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "can't decrypt encryption key")
+	}
+	entityList, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "can't read decrypyed gpg key")
+	}
+	expiry, expires := pgp.EntityListExpiry(entityList)
+	return expiry, expires, nil
+}
+
 // Decrypt creates decrypted reader from ordinary reader
 func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
 	// need read header at first, with length less than maxHeaderLenAllowed
@@ -121,3 +121,15 @@ func TestEncodeMultiKeyID(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "3BE0C94F8BDCA96B,F1A31F9064762905", keyID, "Key id is mismatch")
 }
+
+func TestSelfTest(t *testing.T) {
+	enveloper := MockedEnveloper(t)
+	assert.NoError(t, crypto.SelfTest(MockArmedCrypterFromKeyPath(enveloper)))
+}
+
+func TestKeyExpiry(t *testing.T) {
+	enveloper := MockedEnveloper(t)
+	_, expires, err := crypto.KeyExpiry(MockArmedCrypterFromKeyPath(enveloper))
+	assert.NoError(t, err)
+	assert.False(t, expires)
+}
@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/wal-g/tracelog"
+)
+
+// Keyring holds the crypters that can decrypt objects, and selects one of them by the crypter name and the key
+// fingerprint recorded in the object header.
+type Keyring struct {
+	crypters []Crypter
+
+	mutex        sync.Mutex
+	fingerprints map[int]string
+}
+
+// NewKeyring creates Keyring from the crypters, nil crypters are skipped. Earlier crypters take precedence.
+func NewKeyring(crypters ...Crypter) *Keyring {
+	keyring := &Keyring{fingerprints: make(map[int]string)}
+	for _, crypter := range crypters {
+		if crypter != nil {
+			keyring.crypters = append(keyring.crypters, crypter)
+		}
+	}
+	return keyring
+}
+
+// Find provides the first crypter with the name, which key matches the fingerprint. If the fingerprint is empty or
+// the crypter can't tell its own one, the crypter is selected by the name only. Returns nil if there is no such
+// crypter.
+func (keyring *Keyring) Find(name, fingerprint string) Crypter {
+	for i, crypter := range keyring.crypters {
+		if crypter.Name() != name {
+			continue
+		}
+		if fingerprint == "" {
+			return crypter
+		}
+		ownFingerprint := keyring.fingerprint(i)
+		if ownFingerprint == "" || FingerprintsMatch(ownFingerprint, fingerprint) {
+			return crypter
+		}
+	}
+	return nil
+}
+
+func (keyring *Keyring) fingerprint(i int) string {
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	if fingerprint, ok := keyring.fingerprints[i]; ok {
+		return fingerprint
+	}
+	fingerprint, err := KeyFingerprint(keyring.crypters[i])
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to get the key fingerprint of %s: %v", keyring.crypters[i].Name(), err)
+	}
+	keyring.fingerprints[i] = fingerprint
+	return fingerprint
+}
+
+// FingerprintsMatch tells if the fingerprints have the same scheme and share at least one key, e.g. when data is
+// encrypted for several PGP keys or age recipients, and only one of them is available for decryption.
+func FingerprintsMatch(first, second string) bool {
+	firstScheme, firstKeys, _ := strings.Cut(first, ":")
+	secondScheme, secondKeys, _ := strings.Cut(second, ":")
+	if firstScheme != secondScheme {
+		return false
+	}
+	for _, firstKey := range strings.Split(firstKeys, ",") {
+		for _, secondKey := range strings.Split(secondKeys, ",") {
+			if firstKey == secondKey {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package crypto_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+type fakeCrypter struct {
+	name        string
+	fingerprint string
+}
+
+func (crypter *fakeCrypter) Name() string {
+	return crypter.name
+}
+
+func (crypter *fakeCrypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (crypter *fakeCrypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	return reader, nil
+}
+
+func (crypter *fakeCrypter) KeyFingerprint() (string, error) {
+	if crypter.fingerprint == "" {
+		return "", errors.New("unknown key")
+	}
+	return crypter.fingerprint, nil
+}
+
+func TestKeyring_Find(t *testing.T) {
+	current := &fakeCrypter{name: "pgp", fingerprint: "pgp:CURRENT"}
+	previous := &fakeCrypter{name: "pgp", fingerprint: "pgp:PREVIOUS"}
+	age := &fakeCrypter{name: "age", fingerprint: "age:age1b,age1c"}
+	unknown := &fakeCrypter{name: "unknown"}
+	keyring := crypto.NewKeyring(current, nil, previous, age, unknown)
+
+	assert.Same(t, current, keyring.Find("pgp", "pgp:CURRENT"))
+	assert.Same(t, previous, keyring.Find("pgp", "pgp:PREVIOUS"))
+	assert.Same(t, current, keyring.Find("pgp", ""))
+	assert.Nil(t, keyring.Find("pgp", "pgp:OTHER"))
+	assert.Same(t, age, keyring.Find("age", "age:age1a,age1c"))
+	assert.Nil(t, keyring.Find("age", "age:age1a"))
+	assert.Same(t, unknown, keyring.Find("unknown", "unknown:KEY"))
+	assert.Nil(t, keyring.Find("libsodium", ""))
+}
+
+func TestFingerprintsMatch(t *testing.T) {
+	assert.True(t, crypto.FingerprintsMatch("pgp:A", "pgp:A"))
+	assert.True(t, crypto.FingerprintsMatch("pgp:A,B", "pgp:C,B"))
+	assert.False(t, crypto.FingerprintsMatch("pgp:A", "pgp:B"))
+	assert.False(t, crypto.FingerprintsMatch("pgp:A", "libsodium:A"))
+}
@@ -6,6 +6,8 @@ package libsodium
 import "C"
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -20,6 +22,7 @@ const (
 	chunkSize         = 8192
 	libsodiumKeybytes = 32
 	minimalKeyLength  = 25
+	fingerprintBytes  = 8
 )
 
 // libsodium should always be initialized
@@ -109,6 +112,16 @@ func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
 	return NewReader(reader, crypter.key), nil
 }
 
+// KeyFingerprint provides the prefix of the key hash, which identifies the key without revealing it
+func (crypter *Crypter) KeyFingerprint() (string, error) {
+	if err := crypter.setup(); err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(crypter.key)
+	return "libsodium:" + hex.EncodeToString(hash[:fingerprintBytes]), nil
+}
+
 var _ error = &ErrShortKey{}
 
 type ErrShortKey struct {
@@ -114,3 +114,8 @@ func TestEncryptionCycleFromKeyPath(t *testing.T) {
 		EncryptionCycle(t, crypter)
 	}
 }
+
+func TestSelfTest(t *testing.T) {
+	crypter := CrypterFromKey("4c0829fdfe7ae1987918edc585b1a90556d901eaea963c7625bb5734576dfb59", KeyTransformHex)
+	assert.NoError(t, crypto.SelfTest(crypter))
+}
@@ -3,11 +3,14 @@ package openpgp
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/pkg/errors"
 	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/ioextensions"
@@ -128,6 +131,76 @@ func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
 	return ioextensions.NewOnCloseFlusher(encryptedWriter, bufferedWriter), nil
 }
 
+// KeyFingerprint provides the IDs of the public keys the data is encrypted for
+func (crypter *Crypter) KeyFingerprint() (string, error) {
+	err := crypter.setupPubKey()
+	if err != nil {
+		return "", err
+	}
+
+	keyIDs := make([]string, len(crypter.PubKey))
+	for i, entity := range crypter.PubKey {
+		keyIDs[i] = fmt.Sprintf("%016X", entity.PrimaryKey.KeyId)
+	}
+	return "pgp:" + strings.Join(keyIDs, ","), nil
+}
+
+// KeyExpiry provides the earliest expiry of the keys the data is encrypted for
+func (crypter *Crypter) KeyExpiry() (time.Time, bool, error) {
+	err := crypter.setupPubKey()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	expiry, expires := EntityListExpiry(crypter.PubKey)
+	return expiry, expires, nil
+}
+
+// EntityListExpiry provides the earliest expiry of the keys, expires is false if none of them expires
+func EntityListExpiry(entityList openpgp.EntityList) (earliest time.Time, expires bool) {
+	for _, entity := range entityList {
+		expiry, entityExpires := entityExpiry(entity)
+		if entityExpires && (!expires || expiry.Before(earliest)) {
+			earliest, expires = expiry, true
+		}
+	}
+	return earliest, expires
+}
+
+// entityExpiry provides the expiry of the primary key or of the last expiring encryption subkey, whichever is earlier
+func entityExpiry(entity *openpgp.Entity) (time.Time, bool) {
+	primarySignature, _ := entity.PrimarySelfSignature()
+	expiry, expires := signatureKeyExpiry(entity.PrimaryKey, primarySignature)
+
+	var subkeysExpiry time.Time
+	subkeysExpire := false
+	for _, subkey := range entity.Subkeys {
+		if subkey.Sig == nil || !subkey.Sig.FlagsValid ||
+			!(subkey.Sig.FlagEncryptCommunications || subkey.Sig.FlagEncryptStorage) {
+			continue
+		}
+		subkeyExpiry, subkeyExpires := signatureKeyExpiry(subkey.PublicKey, subkey.Sig)
+		if !subkeyExpires {
+			subkeysExpire = false
+			break
+		}
+		if !subkeysExpire || subkeyExpiry.After(subkeysExpiry) {
+			subkeysExpiry, subkeysExpire = subkeyExpiry, true
+		}
+	}
+
+	if subkeysExpire && (!expires || subkeysExpiry.Before(expiry)) {
+		return subkeysExpiry, true
+	}
+	return expiry, expires
+}
+
+func signatureKeyExpiry(key *packet.PublicKey, signature *packet.Signature) (time.Time, bool) {
+	if signature == nil || signature.KeyLifetimeSecs == nil || *signature.KeyLifetimeSecs == 0 {
+		return time.Time{}, false
+	}
+	return key.CreationTime.Add(time.Duration(*signature.KeyLifetimeSecs) * time.Second), true
+}
+
 // Decrypt creates decrypted reader from ordinary reader
 func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
 	err := crypter.loadSecret()
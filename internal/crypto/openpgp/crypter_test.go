@@ -5,8 +5,12 @@ import (
 	"io"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wal-g/wal-g/internal/crypto"
 )
 
@@ -69,3 +73,35 @@ func TestEncryptionCycleFromEnv(t *testing.T) {
 func TestEncryptionCycleFromKeyPath(t *testing.T) {
 	EncryptionCycle(t, MockArmedCrypterFromKeyPath())
 }
+
+func TestKeyFingerprint(t *testing.T) {
+	fromEnv, err := crypto.KeyFingerprint(MockArmedCrypterFromEnv())
+	assert.NoError(t, err)
+	fromKeyPath, err := crypto.KeyFingerprint(MockArmedCrypterFromKeyPath())
+	assert.NoError(t, err)
+
+	assert.Regexp(t, "^pgp:[0-9A-F]{16}$", fromEnv)
+	assert.Equal(t, fromEnv, fromKeyPath)
+}
+
+func TestSelfTest(t *testing.T) {
+	assert.NoError(t, crypto.SelfTest(MockArmedCrypterFromKeyPath()))
+}
+
+func TestKeyExpiry(t *testing.T) {
+	_, expires, err := crypto.KeyExpiry(MockArmedCrypterFromKeyPath())
+	require.NoError(t, err)
+	assert.False(t, expires)
+
+	config := &packet.Config{KeyLifetimeSecs: 3600}
+	shortLived, err := openpgp.NewEntity("short", "", "short@example.com", config)
+	require.NoError(t, err)
+	config.KeyLifetimeSecs = 7200
+	longLived, err := openpgp.NewEntity("long", "", "long@example.com", config)
+	require.NoError(t, err)
+
+	expiry, expires, err := crypto.KeyExpiry(&Crypter{PubKey: openpgp.EntityList{longLived, shortLived}})
+	require.NoError(t, err)
+	assert.True(t, expires)
+	assert.Equal(t, shortLived.PrimaryKey.CreationTime.Add(time.Hour).Unix(), expiry.Unix())
+}
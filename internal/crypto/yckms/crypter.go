@@ -14,6 +14,7 @@ import (
 )
 
 type YcCrypter struct {
+	keyID        string
 	symmetricKey YcSymmetricKeyInterface
 }
 
@@ -56,6 +57,11 @@ func (crypter *YcCrypter) Decrypt(reader io.Reader) (io.Reader, error) {
 	return sio.DecryptReader(reader, sio.Config{Key: crypter.symmetricKey.GetKey(), CipherSuites: []byte{sio.AES_GCM}})
 }
 
+// KeyFingerprint provides the ID of the KMS key the symmetric keys are encrypted with
+func (crypter *YcCrypter) KeyFingerprint() (string, error) {
+	return "yckms:" + crypter.keyID, nil
+}
+
 func YcCrypterFromKeyIDAndCredential(keyID string, saFilePath string) crypto.Crypter {
 	credentials := resolveCredentials(saFilePath)
 	sdk, err := ycsdk.Build(
@@ -64,5 +70,5 @@ func YcCrypterFromKeyIDAndCredential(keyID string, saFilePath string) crypto.Cry
 	)
 	tracelog.ErrorLogger.FatalfOnError("Can't initialize yc sdk: %v", err)
 
-	return &YcCrypter{symmetricKey: YcSymmetricKeyFromKeyIDAndSdk(keyID, sdk)}
+	return &YcCrypter{keyID: keyID, symmetricKey: YcSymmetricKeyFromKeyIDAndSdk(keyID, sdk)}
 }
@@ -81,3 +81,7 @@ func TestYcCrypterEncryptionCycle(t *testing.T) {
 
 	assert.Equal(t, testSecretString, string(decryptedData), "Decrypted text not equal to plain text")
 }
+
+func TestSelfTest(t *testing.T) {
+	assert.NoError(t, crypto.SelfTest(MockedYcCrypter()))
+}
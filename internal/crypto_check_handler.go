@@ -0,0 +1,283 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/objectheader"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// CryptoCheckStatus is the outcome of HandleCryptoCheck. Its values are the exit codes monitoring systems
+// expect from the checks, like the Nagios plugins do.
+type CryptoCheckStatus int
+
+const (
+	CryptoCheckOK CryptoCheckStatus = iota
+	CryptoCheckWarning
+	CryptoCheckCritical
+	CryptoCheckUnknown
+)
+
+const (
+	// cryptoCheckReadLimit is how much of the stored object is decrypted, the beginning is enough to prove the key
+	cryptoCheckReadLimit = 1 << 20
+	// cryptoCheckAttempts limits the number of sampled objects, since the unencrypted ones are skipped
+	cryptoCheckAttempts = 5
+	// cryptoCheckPlainPeekSize is how much of the object without a header is kept to check if it's unencrypted
+	cryptoCheckPlainPeekSize = 64 << 10
+	// cryptoCheckPlainMinSize is how much has to be decompressed from the beginning to consider the object unencrypted
+	cryptoCheckPlainMinSize = 512
+)
+
+func (status CryptoCheckStatus) String() string {
+	switch status {
+	case CryptoCheckOK:
+		return "OK"
+	case CryptoCheckWarning:
+		return "WARNING"
+	case CryptoCheckCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// severity orders the statuses so that CRITICAL is never hidden by UNKNOWN
+func (status CryptoCheckStatus) severity() int {
+	switch status {
+	case CryptoCheckOK:
+		return 0
+	case CryptoCheckWarning:
+		return 1
+	case CryptoCheckUnknown:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func (status CryptoCheckStatus) MarshalText() ([]byte, error) {
+	return []byte(status.String()), nil
+}
+
+// CryptoCheckArgs describes what HandleCryptoCheck checks besides the crypter itself
+type CryptoCheckArgs struct {
+	// Prefix limits the stored objects one of which is decrypted
+	Prefix string
+	// ExpiryWarning is how long before the key expiry the check warns
+	ExpiryWarning time.Duration
+}
+
+type CryptoCheckResult struct {
+	Status         CryptoCheckStatus `json:"status"`
+	Crypter        string            `json:"crypter,omitempty"`
+	KeyFingerprint string            `json:"key_fingerprint,omitempty"`
+	KeyExpiry      *time.Time        `json:"key_expiry,omitempty"`
+	SelfTest       string            `json:"self_test,omitempty"`
+	Object         string            `json:"object,omitempty"`
+	ObjectCheck    string            `json:"object_check,omitempty"`
+	Messages       []string          `json:"messages,omitempty"`
+}
+
+// AddProblem records the problem and raises the status to the given one, if it's less severe
+func (result *CryptoCheckResult) AddProblem(status CryptoCheckStatus, format string, args ...interface{}) {
+	if status.severity() > result.Status.severity() {
+		result.Status = status
+	}
+	result.Messages = append(result.Messages, fmt.Sprintf(format, args...))
+}
+
+// HandleCryptoCheck checks that the crypter can encrypt and decrypt a test payload, and that one of the objects
+// stored under the prefix can be decrypted with the current key material. The storage check is skipped if there
+// is no folder.
+func HandleCryptoCheck(ctx context.Context, folder storage.Folder, crypter crypto.Crypter,
+	args CryptoCheckArgs) *CryptoCheckResult {
+	result := &CryptoCheckResult{Status: CryptoCheckOK}
+	if crypter == nil {
+		result.AddProblem(CryptoCheckUnknown, "encryption is not configured")
+		return result
+	}
+	result.Crypter = crypter.Name()
+
+	fingerprint, err := crypto.KeyFingerprint(crypter)
+	if err != nil {
+		result.AddProblem(CryptoCheckCritical, "failed to get the key fingerprint: %v", err)
+	}
+	result.KeyFingerprint = fingerprint
+
+	checkKeyExpiry(crypter, args.ExpiryWarning, result)
+
+	result.SelfTest = "ok"
+	if err = crypto.SelfTest(crypter); err != nil {
+		result.SelfTest = "failed"
+		result.AddProblem(CryptoCheckCritical, "self-test failed: %v", err)
+	}
+
+	if folder != nil {
+		checkStoredObjectDecryption(ctx, folder, crypter, args.Prefix, result)
+	}
+	return result
+}
+
+func checkKeyExpiry(crypter crypto.Crypter, expiryWarning time.Duration, result *CryptoCheckResult) {
+	expiry, expires, err := crypto.KeyExpiry(crypter)
+	if err != nil {
+		result.AddProblem(CryptoCheckCritical, "failed to get the key expiry: %v", err)
+		return
+	}
+	if !expires {
+		return
+	}
+	result.KeyExpiry = &expiry
+
+	now := time.Now()
+	switch {
+	case !expiry.After(now):
+		result.AddProblem(CryptoCheckCritical, "the key expired at %s", expiry.Format(time.RFC3339))
+	case expiry.Before(now.Add(expiryWarning)):
+		result.AddProblem(CryptoCheckWarning, "the key expires at %s", expiry.Format(time.RFC3339))
+	}
+}
+
+// checkStoredObjectDecryption decrypts the beginning of a random encrypted object, the crypter is selected by
+// the object header if there is one. The status is UNKNOWN if no object is decrypted, since the key material
+// isn't proven to work then.
+func checkStoredObjectDecryption(ctx context.Context, folder storage.Folder, crypter crypto.Crypter, prefix string,
+	result *CryptoCheckResult) {
+	objects, err := storage.ListFolderRecursivelyWithPrefix(ctx, folder, prefix)
+	if err != nil {
+		result.AddProblem(CryptoCheckUnknown, "failed to list the storage: %v", err)
+		return
+	}
+
+	var candidates []string
+	for _, object := range objects {
+		// sentinels and other metadata are stored as plain JSON
+		if object.GetSize() > 0 && !strings.HasSuffix(object.GetName(), ".json") {
+			candidates = append(candidates, object.GetName())
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	for _, name := range candidates[:min(len(candidates), cryptoCheckAttempts)] {
+		checked, unencrypted, err := decryptStoredObject(ctx, folder, crypter, name)
+		var readErr cryptoCheckReadError
+		if errors.As(err, &readErr) {
+			result.Messages = append(result.Messages, fmt.Sprintf("failed to read object %s: %v", name, err))
+			continue
+		}
+		if !checked {
+			continue
+		}
+		result.Object = name
+		if unencrypted {
+			result.ObjectCheck = "not encrypted"
+			result.AddProblem(CryptoCheckWarning, "object %s is not encrypted", name)
+			return
+		}
+		if err != nil {
+			result.ObjectCheck = "failed"
+			result.AddProblem(CryptoCheckCritical, "object %s can't be decrypted with the current key material: %v",
+				name, err)
+			return
+		}
+		result.ObjectCheck = "ok"
+		return
+	}
+	result.AddProblem(CryptoCheckUnknown, "no encrypted object could be checked under %q", prefix)
+}
+
+// cryptoCheckReadError means the object can't be read from the storage, so it tells nothing about the key material
+type cryptoCheckReadError struct {
+	error
+}
+
+func (err cryptoCheckReadError) Unwrap() error {
+	return err.error
+}
+
+// decryptStoredObject tells if the object is encrypted and can be decrypted, the object with a header saying it's
+// unencrypted is not checked. An object without a header that can't be decrypted is reported as unencrypted if
+// its beginning is a plain tar or is readable by the decompressor its extension refers to.
+func decryptStoredObject(ctx context.Context, folder storage.Folder, crypter crypto.Crypter,
+	name string) (checked, unencrypted bool, err error) {
+	reader, err := folder.ReadObject(ctx, name)
+	if err != nil {
+		return false, false, cryptoCheckReadError{err}
+	}
+	defer utility.LoggedClose(reader, "")
+
+	header, body, err := objectheader.Read(reader)
+	if err != nil {
+		return true, false, err
+	}
+	if header != nil {
+		if !header.IsEncrypted() {
+			return false, false, nil
+		}
+		crypter, err = SelectCrypter(header, crypter)
+		if err != nil {
+			return true, false, err
+		}
+	}
+
+	bufferedBody := bufio.NewReaderSize(body, cryptoCheckPlainPeekSize)
+	beginning, err := bufferedBody.Peek(cryptoCheckPlainPeekSize)
+	if err != nil && err != io.EOF {
+		return false, false, cryptoCheckReadError{err}
+	}
+	beginning = bytes.Clone(beginning)
+	err = decrypt(bufferedBody, crypter)
+	if err != nil && header == nil && looksUnencrypted(name, beginning) {
+		return true, true, nil
+	}
+	return true, false, err
+}
+
+func decrypt(body io.Reader, crypter crypto.Crypter) error {
+	decrypted, err := crypter.Decrypt(body)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(io.Discard, decrypted, cryptoCheckReadLimit)
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+// looksUnencrypted checks if the beginning of the object is a plain tar or a compressed stream
+func looksUnencrypted(name string, beginning []byte) bool {
+	const tarMagicOffset, tarMagic = 257, "ustar"
+	if len(beginning) >= tarMagicOffset+len(tarMagic) &&
+		string(beginning[tarMagicOffset:tarMagicOffset+len(tarMagic)]) == tarMagic {
+		return true
+	}
+
+	decompressor := compression.FindDecompressor(path.Ext(name))
+	if decompressor == nil {
+		return false
+	}
+	decompressed, err := decompressor.Decompress(bytes.NewReader(beginning))
+	if err != nil {
+		return false
+	}
+	defer utility.LoggedClose(decompressed, "")
+	// the beginning is cut, so the stream is expected to end unexpectedly
+	size, err := io.Copy(io.Discard, io.LimitReader(decompressed, cryptoCheckPlainMinSize))
+	return size == cryptoCheckPlainMinSize && (err == nil || errors.Is(err, io.ErrUnexpectedEOF))
+}
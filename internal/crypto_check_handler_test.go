@@ -0,0 +1,142 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/crypto/aead"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	cryptoCheckKey      = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	cryptoCheckOtherKey = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+)
+
+type expiringCrypter struct {
+	crypto.Crypter
+	expiry time.Time
+}
+
+func (crypter *expiringCrypter) KeyExpiry() (time.Time, bool, error) {
+	return crypter.expiry, true, nil
+}
+
+func putEncrypted(t *testing.T, folder storage.Folder, name string, crypter crypto.Crypter) {
+	encrypted, err := io.ReadAll(internal.CompressAndEncrypt(bytes.NewReader(make([]byte, 4096)), nil, crypter))
+	require.NoError(t, err)
+	require.NoError(t, folder.PutObject(t.Context(), name, bytes.NewReader(encrypted)))
+}
+
+func TestHandleCryptoCheck_OK(t *testing.T) {
+	crypter := aead.CrypterFromKey(cryptoCheckKey, aead.KeyTransformHex)
+	folder := memory.NewFolder("", memory.NewKVS())
+	putEncrypted(t, folder, "basebackups_005/base_000000010000000000000002/tar_partitions/part_1.tar", crypter)
+	require.NoError(t, folder.PutObject(t.Context(), "basebackups_005/base_000000010000000000000002_backup_stop_sentinel.json",
+		bytes.NewReader([]byte("{}"))))
+
+	result := internal.HandleCryptoCheck(t.Context(), folder, crypter, internal.CryptoCheckArgs{})
+	assert.Equal(t, internal.CryptoCheckOK, result.Status, result.Messages)
+	assert.Equal(t, crypter.Name(), result.Crypter)
+	assert.NotEmpty(t, result.KeyFingerprint)
+	assert.Nil(t, result.KeyExpiry)
+	assert.Equal(t, "ok", result.SelfTest)
+	assert.Equal(t, "basebackups_005/base_000000010000000000000002/tar_partitions/part_1.tar", result.Object)
+	assert.Equal(t, "ok", result.ObjectCheck)
+}
+
+func TestHandleCryptoCheck_ObjectEncryptedWithOtherKey(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	putEncrypted(t, folder, "wal_005/000000010000000000000001.br",
+		aead.CrypterFromKey(cryptoCheckOtherKey, aead.KeyTransformHex))
+
+	crypter := aead.CrypterFromKey(cryptoCheckKey, aead.KeyTransformHex)
+	result := internal.HandleCryptoCheck(t.Context(), folder, crypter, internal.CryptoCheckArgs{Prefix: "wal_005/"})
+	assert.Equal(t, internal.CryptoCheckCritical, result.Status)
+	assert.Equal(t, "ok", result.SelfTest)
+	assert.Equal(t, "failed", result.ObjectCheck)
+}
+
+func TestHandleCryptoCheck_UnencryptedObject(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	compressed, err := io.ReadAll(internal.CompressAndEncrypt(bytes.NewReader(bytes.Repeat([]byte("plain"), 1024)),
+		compression.Compressors[lz4.AlgorithmName], nil))
+	require.NoError(t, err)
+	require.NoError(t, folder.PutObject(t.Context(), "wal_005/000000010000000000000001.lz4", bytes.NewReader(compressed)))
+
+	crypter := aead.CrypterFromKey(cryptoCheckKey, aead.KeyTransformHex)
+	result := internal.HandleCryptoCheck(t.Context(), folder, crypter, internal.CryptoCheckArgs{Prefix: "wal_005/"})
+	assert.Equal(t, internal.CryptoCheckWarning, result.Status, result.Messages)
+	assert.Equal(t, "not encrypted", result.ObjectCheck)
+}
+
+func TestHandleCryptoCheck_NoObjects(t *testing.T) {
+	crypter := aead.CrypterFromKey(cryptoCheckKey, aead.KeyTransformHex)
+	result := internal.HandleCryptoCheck(t.Context(), memory.NewFolder("", memory.NewKVS()), crypter,
+		internal.CryptoCheckArgs{})
+	assert.Equal(t, internal.CryptoCheckUnknown, result.Status)
+	assert.Empty(t, result.ObjectCheck)
+	assert.Len(t, result.Messages, 1)
+}
+
+type unreadableFolder struct {
+	*memory.Folder
+}
+
+func (folder unreadableFolder) ReadObject(context.Context, string) (io.ReadCloser, error) {
+	return nil, errors.New("storage is unavailable")
+}
+
+func TestHandleCryptoCheck_UnreadableObject(t *testing.T) {
+	crypter := aead.CrypterFromKey(cryptoCheckKey, aead.KeyTransformHex)
+	folder := memory.NewFolder("", memory.NewKVS())
+	putEncrypted(t, folder, "wal_005/000000010000000000000001.br", crypter)
+
+	result := internal.HandleCryptoCheck(t.Context(), unreadableFolder{folder}, crypter,
+		internal.CryptoCheckArgs{Prefix: "wal_005/"})
+	assert.Equal(t, internal.CryptoCheckUnknown, result.Status, result.Messages)
+	assert.Empty(t, result.ObjectCheck)
+	assert.Len(t, result.Messages, 2)
+}
+
+func TestHandleCryptoCheck_NotConfigured(t *testing.T) {
+	result := internal.HandleCryptoCheck(t.Context(), nil, nil, internal.CryptoCheckArgs{})
+	assert.Equal(t, internal.CryptoCheckUnknown, result.Status)
+}
+
+func TestHandleCryptoCheck_KeyExpiry(t *testing.T) {
+	crypter := aead.CrypterFromKey(cryptoCheckKey, aead.KeyTransformHex)
+	args := internal.CryptoCheckArgs{ExpiryWarning: 30 * 24 * time.Hour}
+
+	result := internal.HandleCryptoCheck(t.Context(), nil,
+		&expiringCrypter{Crypter: crypter, expiry: time.Now().Add(365 * 24 * time.Hour)}, args)
+	assert.Equal(t, internal.CryptoCheckOK, result.Status, result.Messages)
+	assert.NotNil(t, result.KeyExpiry)
+
+	result = internal.HandleCryptoCheck(t.Context(), nil,
+		&expiringCrypter{Crypter: crypter, expiry: time.Now().Add(24 * time.Hour)}, args)
+	assert.Equal(t, internal.CryptoCheckWarning, result.Status)
+
+	result = internal.HandleCryptoCheck(t.Context(), nil,
+		&expiringCrypter{Crypter: crypter, expiry: time.Now().Add(-time.Hour)}, args)
+	assert.Equal(t, internal.CryptoCheckCritical, result.Status)
+}
+
+func TestCryptoCheckResult_AddProblem(t *testing.T) {
+	result := &internal.CryptoCheckResult{}
+	result.AddProblem(internal.CryptoCheckCritical, "failed")
+	result.AddProblem(internal.CryptoCheckUnknown, "unavailable")
+	assert.Equal(t, internal.CryptoCheckCritical, result.Status)
+	assert.Equal(t, []string{"failed", "unavailable"}, result.Messages)
+}
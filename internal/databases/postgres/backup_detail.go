@@ -60,5 +60,10 @@ func (bd *BackupDetail) PrintableFields() []printlist.TableField {
 			PrettyName: "Permanent",
 			Value:      fmt.Sprintf("%v", bd.IsPermanent),
 		},
+		printlist.TableField{
+			Name:       "key_fingerprint",
+			PrettyName: "Key fingerprint",
+			Value:      bd.KeyFingerprint,
+		},
 	)
 }
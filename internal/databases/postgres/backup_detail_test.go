@@ -27,6 +27,7 @@ func TestBackupDetail_PrintableFields(t *testing.T) {
 			StartLsn:       1111111111111111,
 			FinishLsn:      2222222222222222,
 			IsPermanent:    true,
+			KeyFingerprint: "pgp:1234567890ABCDEF",
 		},
 	}
 	got := bd.PrintableFields()
@@ -106,6 +107,12 @@ func TestBackupDetail_PrintableFields(t *testing.T) {
 			Value:       "true",
 			PrettyValue: nil,
 		},
+		{
+			Name:        "key_fingerprint",
+			PrettyName:  "Key fingerprint",
+			Value:       "pgp:1234567890ABCDEF",
+			PrettyValue: nil,
+		},
 	}
 	assert.Equal(t, want, got)
 }
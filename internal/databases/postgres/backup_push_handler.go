@@ -18,7 +18,9 @@ import (
 	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/adaptive"
 	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/databases/postgres/orioledb"
 	"github.com/wal-g/wal-g/internal/multistorage"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
@@ -77,6 +79,9 @@ type CurBackupInfo struct {
 	dataCatalogSize  int64
 	incrementCount   int
 	StartChkpNum     *uint32
+	keyFingerprint   string
+
+	compressionDecisions []adaptive.Decision
 }
 
 func NewPrevBackupInfo(name string, sentinel BackupSentinelDto, filesMeta FilesMetadataDto) PrevBackupInfo {
@@ -158,6 +163,7 @@ func (bh *BackupHandler) createAndPushBackup(ctx context.Context) {
 
 	arguments := bh.Arguments
 	crypter := internal.ConfigureCrypter()
+	bh.CurBackupInfo.keyFingerprint = keyFingerprint(crypter)
 	bh.Workers.Bundle = NewBundle(bh.PgInfo.PgDataDirectory, crypter, bh.prevBackupInfo.name,
 		bh.prevBackupInfo.sentinelDto.BackupStartLSN, bh.prevBackupInfo.filesMetadataDto.Files, arguments.forceIncremental,
 		viper.GetInt64(conf.TarSizeThresholdSetting))
@@ -265,7 +271,20 @@ func (bh *BackupHandler) handleDeltaBackup(ctx context.Context, folder storage.F
 		useWalDelta, _, err := configureWalDeltaUsage()
 		tracelog.ErrorLogger.FatalOnError(err)
 
-		if useWalDelta {
+		if viper.GetBool(conf.UseWalSummariesSetting) {
+			ForceWalDetal, _ := conf.GetBoolSettingDefault(conf.ForceWalDetal, false)
+			err := bh.Workers.Bundle.LoadWalSummariesDeltaMap(ctx, bh.Workers.QueryRunner,
+				folder.GetSubFolder(utility.WalPath), bh.CurBackupInfo.startLSN)
+			if err == nil {
+				tracelog.InfoLogger.Println("Successfully loaded delta map from WAL summaries, delta backup will be " +
+					"made with provided delta map")
+			} else if ForceWalDetal {
+				return errors.Wrapf(err, "Failed to load delta map from WAL summaries")
+			} else {
+				tracelog.WarningLogger.Printf("Error during loading delta map from WAL summaries: '%v'. "+
+					"Fallback to full scan delta backup\n", err)
+			}
+		} else if useWalDelta {
 			ForceWalDetal, _ := conf.GetBoolSettingDefault(conf.ForceWalDetal, false)
 			err := bh.Workers.Bundle.DownloadDeltaMap(ctx, internal.NewFolderReader(folder.GetSubFolder(utility.WalPath)), bh.CurBackupInfo.startLSN)
 			if err == nil {
@@ -361,6 +380,7 @@ func (bh *BackupHandler) uploadBackup(ctx context.Context) internal.TarFileSets
 	bh.CurBackupInfo.compressedSize, err = bh.Arguments.Uploader.UploadedDataSize()
 	bh.CurBackupInfo.dataCatalogSize = bundle.DataCatalogSize.Load()
 	tracelog.ErrorLogger.FatalOnError(err)
+	bh.CurBackupInfo.compressionDecisions = adaptive.Decisions(bh.Arguments.Uploader.Compression())
 	tarFileSets.AddFiles(labelFilesTarBallName, labelFilesList)
 	timelineChanged := bundle.checkTimelineChanged(ctx, bh.Workers.QueryRunner)
 	tracelog.DebugLogger.Printf("Labelfiles tarball name: %s", labelFilesTarBallName)
@@ -460,6 +480,7 @@ func (bh *BackupHandler) createAndPushRemoteBackup(ctx context.Context) {
 	bh.CurBackupInfo.uncompressedSize = baseBackup.UncompressedSize
 	bh.CurBackupInfo.compressedSize, err = bh.Arguments.Uploader.UploadedDataSize()
 	tracelog.ErrorLogger.FatalOnError(err)
+	bh.CurBackupInfo.keyFingerprint = keyFingerprint(internal.ConfigureCrypter())
 	sentinelDto := NewBackupSentinelDto(bh, baseBackup.GetTablespaceSpec())
 	filesMetadataDto := NewFilesMetadataDto(baseBackup.Files, tarFileSets)
 	bh.CurBackupInfo.Name = baseBackup.BackupName()
@@ -469,6 +490,15 @@ func (bh *BackupHandler) createAndPushRemoteBackup(ctx context.Context) {
 	tracelog.InfoLogger.Printf("Wrote backup with name %s", bh.CurBackupInfo.Name)
 }
 
+// keyFingerprint identifies the key the backup is encrypted with. Not being able to identify it doesn't fail the backup.
+func keyFingerprint(crypter crypto.Crypter) string {
+	fingerprint, err := crypto.KeyFingerprint(crypter)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to get the encryption key fingerprint, it will not be recorded: %v", err)
+	}
+	return fingerprint
+}
+
 func (bh *BackupHandler) uploadMetadata(ctx context.Context, sentinelDto BackupSentinelDto, filesMetaDto FilesMetadataDto) {
 	curBackupName := bh.CurBackupInfo.Name
 	meta := NewExtendedMetadataDto(bh.Arguments.isPermanent, bh.PgInfo.PgDataDirectory,
@@ -8,6 +8,7 @@ import (
 
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/adaptive"
 	"github.com/wal-g/wal-g/utility"
 )
 
@@ -36,6 +37,12 @@ type BackupSentinelDto struct {
 	FilesMetadataDisabled bool    `json:"FilesMetadataDisabled,omitempty"`
 	BackupStartChkpNum    *uint32 `json:"ChkpNum"`
 	IncrementFromChkpNum  *uint32 `json:"DeltaChkpNum,omitempty"`
+
+	// KeyFingerprint identifies the key the backup is encrypted with, see crypto.KeyFingerprinter
+	KeyFingerprint string `json:"KeyFingerprint,omitempty"`
+
+	// CompressionDecisions describe the compression chosen for every tar part by the adaptive compression
+	CompressionDecisions []adaptive.Decision `json:"CompressionDecisions,omitempty"`
 }
 
 func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSentinelDto {
@@ -64,6 +71,8 @@ func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSent
 	sentinel.CompressedSize = bh.CurBackupInfo.compressedSize
 	sentinel.DataCatalogSize = bh.CurBackupInfo.dataCatalogSize
 	sentinel.FilesMetadataDisabled = bh.Arguments.withoutFilesMetadata
+	sentinel.KeyFingerprint = bh.CurBackupInfo.keyFingerprint
+	sentinel.CompressionDecisions = bh.CurBackupInfo.compressionDecisions
 	return sentinel
 }
 
@@ -84,6 +93,8 @@ type ExtendedMetadataDto struct {
 	CompressedSize   int64 `json:"compressed_size"`
 
 	UserData interface{} `json:"user_data,omitempty"`
+
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
 }
 
 func NewExtendedMetadataDto(isPermanent bool, dataDir string, startTime time.Time,
@@ -107,6 +118,7 @@ func NewExtendedMetadataDto(isPermanent bool, dataDir string, startTime time.Tim
 	meta.UserData = sentinelDto.UserData
 	meta.UncompressedSize = sentinelDto.UncompressedSize
 	meta.CompressedSize = sentinelDto.CompressedSize
+	meta.KeyFingerprint = sentinelDto.KeyFingerprint
 	return meta
 }
 
@@ -0,0 +1,306 @@
+package postgres
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// BackupVerifyReport is the result of the offline verification of a backup. Nothing is written to a data directory
+// while the backup is verified.
+type BackupVerifyReport struct {
+	BackupName     string                     `json:"backup_name"`
+	Status         WalVerifyCheckStatus       `json:"status"`
+	TarCount       int                        `json:"tar_count"`
+	FilesChecked   int                        `json:"files_checked"`
+	MissingFiles   []string                   `json:"missing_files,omitempty"`
+	SizeMismatches []BackupVerifySizeMismatch `json:"size_mismatches,omitempty"`
+	CorruptFiles   []BackupVerifyCorruptFile  `json:"corrupt_files,omitempty"`
+	Wal            *BackupVerifyWalRange      `json:"wal,omitempty"`
+	DeltaBases     []BackupVerifyReport       `json:"delta_bases,omitempty"`
+	Errors         []string                   `json:"errors,omitempty"`
+	Warnings       []string                   `json:"warnings,omitempty"`
+}
+
+// BackupVerifySizeMismatch is a file which size in the tar differs from the one recorded in the files metadata
+type BackupVerifySizeMismatch struct {
+	Path         string `json:"path"`
+	ExpectedSize int64  `json:"expected_size"`
+	ActualSize   int64  `json:"actual_size"`
+}
+
+// BackupVerifyCorruptFile is a file with the pages which header or checksum is wrong
+type BackupVerifyCorruptFile struct {
+	Path          string   `json:"path"`
+	CorruptBlocks []uint32 `json:"corrupt_blocks,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// BackupVerifyWalRange describes the WAL segments required to make the backup consistent
+type BackupVerifyWalRange struct {
+	Timeline        uint32   `json:"timeline"`
+	StartSegment    string   `json:"start_segment"`
+	EndSegment      string   `json:"end_segment"`
+	MissingSegments []string `json:"missing_segments,omitempty"`
+}
+
+func (report *BackupVerifyReport) addError(format string, args ...interface{}) {
+	report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+}
+
+func (report *BackupVerifyReport) addWarning(format string, args ...interface{}) {
+	report.Warnings = append(report.Warnings, fmt.Sprintf(format, args...))
+}
+
+// updateStatus sets the status of the report, which is the worst of its own and the delta bases' statuses
+func (report *BackupVerifyReport) updateStatus() {
+	switch {
+	case len(report.Errors) > 0 || len(report.MissingFiles) > 0 || len(report.SizeMismatches) > 0 ||
+		len(report.CorruptFiles) > 0 || (report.Wal != nil && len(report.Wal.MissingSegments) > 0):
+		report.Status = StatusFailure
+	case len(report.Warnings) > 0:
+		report.Status = StatusWarning
+	default:
+		report.Status = StatusOk
+	}
+	for _, base := range report.DeltaBases {
+		report.Status = max(report.Status, base.Status)
+	}
+}
+
+// HandleBackupVerify verifies the selected backup without restoring it, see VerifyBackup, and writes the report
+// to the output in JSON
+func HandleBackupVerify(ctx context.Context, rootFolder storage.Folder, backupSelector internal.BackupSelector,
+	output io.Writer) (WalVerifyCheckStatus, error) {
+	selected, err := backupSelector.Select(ctx, rootFolder)
+	if err != nil {
+		return 0, err
+	}
+	report, err := VerifyBackup(ctx, rootFolder, ToPgBackup(selected))
+	if err != nil {
+		return 0, err
+	}
+
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "    ")
+	return report.Status, encoder.Encode(report)
+}
+
+// VerifyBackup streams every tar part of the backup and checks that each file from the files metadata
+// is present with the recorded size, that the page headers and checksums are valid, and that the WAL
+// from the backup start to the backup finish is archived. The tars of every delta base the backup is restored
+// from are checked the same way.
+func VerifyBackup(ctx context.Context, rootFolder storage.Folder, backup Backup) (BackupVerifyReport, error) {
+	report := BackupVerifyReport{BackupName: backup.Name}
+	sentinel, filesMetadata, err := backup.GetSentinelAndFilesMetadata(ctx)
+	if err != nil {
+		return BackupVerifyReport{}, err
+	}
+	if len(filesMetadata.Files) == 0 {
+		report.addWarning("files metadata is not available, only the tars and the pages are checked")
+	}
+
+	backupStartLSN := maxLSN
+	if sentinel.BackupStartLSN != nil {
+		backupStartLSN = *sentinel.BackupStartLSN
+	}
+	verifyBackupTars(ctx, backup, filesMetadata, backupStartLSN, &report)
+	if sentinel.IncrementFrom != nil {
+		verifyDeltaBases(ctx, backup, *sentinel.IncrementFrom, &report)
+	}
+
+	if sentinel.BackupStartLSN != nil && sentinel.BackupFinishLSN != nil {
+		err = verifyBackupWal(ctx, rootFolder, backup.Name, *sentinel.BackupStartLSN, *sentinel.BackupFinishLSN, &report)
+		if err != nil {
+			return BackupVerifyReport{}, err
+		}
+	} else {
+		report.addWarning("backup start or finish LSN is unknown, WAL is not checked")
+	}
+
+	report.updateStatus()
+	return report, nil
+}
+
+func verifyBackupTars(ctx context.Context, backup Backup, filesMetadata FilesMetadataDto, backupStartLSN LSN,
+	report *BackupVerifyReport) {
+	tarNames, err := backup.GetTarNames(ctx)
+	if err != nil {
+		report.addError("failed to list the tars: %v", err)
+		return
+	}
+	report.TarCount = len(tarNames)
+	if len(tarNames) == 0 {
+		report.addError("backup has no tars")
+		return
+	}
+
+	readerMakers := make([]internal.ReaderMaker, 0, len(tarNames))
+	for _, tarName := range tarNames {
+		readerMakers = append(readerMakers, internal.NewStorageReaderMaker(backup.GetTarPartitionFolder(), tarName))
+	}
+	interpreter := newVerifyTarInterpreter(filesMetadata.Files, backupStartLSN)
+	err = internal.ExtractAll(ctx, interpreter, readerMakers)
+	if err != nil {
+		report.addError("failed to read the tars: %v", err)
+	}
+
+	report.FilesChecked = len(interpreter.sizes)
+	for name, description := range filesMetadata.Files {
+		if description.IsSkipped {
+			continue
+		}
+		size, found := interpreter.sizes[name]
+		switch {
+		case !found:
+			report.MissingFiles = append(report.MissingFiles, name)
+		case description.Size != 0 && description.Size != size:
+			report.SizeMismatches = append(report.SizeMismatches,
+				BackupVerifySizeMismatch{Path: name, ExpectedSize: description.Size, ActualSize: size})
+		}
+	}
+	sort.Strings(report.MissingFiles)
+	sort.Slice(report.SizeMismatches, func(i, j int) bool {
+		return report.SizeMismatches[i].Path < report.SizeMismatches[j].Path
+	})
+
+	for _, corruptFile := range interpreter.corruptFiles {
+		report.CorruptFiles = append(report.CorruptFiles, corruptFile)
+	}
+	sort.Slice(report.CorruptFiles, func(i, j int) bool {
+		return report.CorruptFiles[i].Path < report.CorruptFiles[j].Path
+	})
+}
+
+// verifyDeltaBases walks the chain of the delta bases starting from the named one down to the full backup, and
+// checks the tars of each of them. The WAL of the bases is not required to restore the delta backup, so it's not
+// checked. If some base can't be read, the rest of the chain is reported as unverified.
+func verifyDeltaBases(ctx context.Context, backup Backup, baseName string, report *BackupVerifyReport) {
+	visited := map[string]bool{backup.Name: true}
+	for {
+		if visited[baseName] {
+			report.addError("delta chain is unverified: delta base %s is referenced twice", baseName)
+			return
+		}
+		visited[baseName] = true
+
+		base, err := NewBackup(backup.Folder, baseName)
+		if err != nil {
+			report.addError("delta chain is unverified from %s: %v", baseName, err)
+			return
+		}
+		sentinel, filesMetadata, err := base.GetSentinelAndFilesMetadata(ctx)
+		if err != nil {
+			report.addError("delta chain is unverified from %s: %v", baseName, err)
+			return
+		}
+
+		baseReport := BackupVerifyReport{BackupName: baseName}
+		if len(filesMetadata.Files) == 0 {
+			baseReport.addWarning("files metadata is not available, only the tars and the pages are checked")
+		}
+		baseStartLSN := maxLSN
+		if sentinel.BackupStartLSN != nil {
+			baseStartLSN = *sentinel.BackupStartLSN
+		}
+		verifyBackupTars(ctx, base, filesMetadata, baseStartLSN, &baseReport)
+		baseReport.updateStatus()
+		report.DeltaBases = append(report.DeltaBases, baseReport)
+
+		if sentinel.IncrementFrom == nil {
+			return
+		}
+		baseName = *sentinel.IncrementFrom
+	}
+}
+
+// verifyBackupWal checks that every WAL segment from the backup start to the backup finish is in storage
+func verifyBackupWal(ctx context.Context, rootFolder storage.Folder, backupName string, startLSN, finishLSN LSN,
+	report *BackupVerifyReport) error {
+	timeline, err := ParseTimelineFromBackupName(backupName)
+	if err != nil {
+		return err
+	}
+	walFolderFilenames, err := getFolderFilenames(ctx, rootFolder.GetSubFolder(utility.WalPath))
+	if err != nil {
+		return errors.Wrap(err, "failed to list WAL folder")
+	}
+	storageSegments := getSegmentsFromFiles(walFolderFilenames)
+
+	startSegmentNo := NewWalSegmentNo(startLSN)
+	endSegmentNo := startSegmentNo
+	if finishLSN > startLSN {
+		endSegmentNo = NewWalSegmentNo(finishLSN - 1)
+	}
+	report.Wal = &BackupVerifyWalRange{
+		Timeline:     timeline,
+		StartSegment: startSegmentNo.GetFilename(timeline),
+		EndSegment:   endSegmentNo.GetFilename(timeline),
+	}
+	for segmentNo := startSegmentNo; segmentNo <= endSegmentNo; segmentNo = segmentNo.Next() {
+		if !storageSegments[WalSegmentDescription{Number: segmentNo, Timeline: timeline}] {
+			report.Wal.MissingSegments = append(report.Wal.MissingSegments, segmentNo.GetFilename(timeline))
+		}
+	}
+	return nil
+}
+
+// verifyTarInterpreter reads the files from the tars instead of extracting them. It's safe to interpret
+// the same tar again, as ExtractAll does on retries. The pages modified since the backup start are not checked,
+// since they might be torn in an online backup.
+type verifyTarInterpreter struct {
+	files          internal.BackupFileList
+	backupStartLSN LSN
+
+	mutex        sync.Mutex
+	sizes        map[string]int64
+	corruptFiles map[string]BackupVerifyCorruptFile
+}
+
+func newVerifyTarInterpreter(files internal.BackupFileList, backupStartLSN LSN) *verifyTarInterpreter {
+	return &verifyTarInterpreter{
+		files:          files,
+		backupStartLSN: backupStartLSN,
+		sizes:          make(map[string]int64),
+		corruptFiles:   make(map[string]BackupVerifyCorruptFile),
+	}
+}
+
+func (interpreter *verifyTarInterpreter) Interpret(reader io.Reader, header *tar.Header) error {
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+	name := strings.TrimPrefix(header.Name, "./")
+	tracelog.DebugLogger.Printf("Verifying %s", name)
+
+	corruptBlocks, err := VerifyBackupPagedFile(name, header.FileInfo(), reader, interpreter.files[name].IsIncremented,
+		interpreter.backupStartLSN)
+	// the rest of the file is read anyway to check the tar to the end
+	if _, copyErr := io.Copy(io.Discard, reader); copyErr != nil {
+		return copyErr
+	}
+
+	interpreter.mutex.Lock()
+	defer interpreter.mutex.Unlock()
+	interpreter.sizes[name] = header.Size
+	delete(interpreter.corruptFiles, name)
+	if err != nil || len(corruptBlocks) > 0 {
+		corruptFile := BackupVerifyCorruptFile{Path: name, CorruptBlocks: corruptBlocks}
+		if err != nil {
+			corruptFile.Error = err.Error()
+		}
+		interpreter.corruptFiles[name] = corruptFile
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	verifyTestBackupName = "base_000000010000000000000002"
+	verifyTestPagedFile  = "base/1/16384"
+)
+
+// makeVerifyTestPagedFile provides the test paged file with the page checksums set, and the pages modified
+// before the test backup start
+func makeVerifyTestPagedFile(t *testing.T) []byte {
+	data, err := os.ReadFile(pagedFileName)
+	require.NoError(t, err)
+	for blockNo := 0; blockNo*int(DatabasePageSize) < len(data); blockNo++ {
+		var page PgDatabasePage
+		pageBytes := data[blockNo*int(DatabasePageSize) : (blockNo+1)*int(DatabasePageSize)]
+		binary.LittleEndian.PutUint32(pageBytes[0:4], 0)
+		binary.LittleEndian.PutUint32(pageBytes[4:8], 0x1000028)
+		copy(page[:], pageBytes)
+		checksum := pgChecksumPage(uint32(blockNo), &page)
+		binary.LittleEndian.PutUint16(pageBytes[PdChecksumOffset:], checksum)
+	}
+	return data
+}
+
+func putVerifyTestBackup(t *testing.T, folder storage.Folder, files map[string][]byte,
+	filesMetadata FilesMetadataDto, walSegments ...string) {
+	startLSN, finishLSN := LSN(0x2000028), LSN(0x3000100)
+	putVerifyTestBackupAs(t, folder, verifyTestBackupName,
+		BackupSentinelDto{BackupStartLSN: &startLSN, BackupFinishLSN: &finishLSN, PgVersion: 170000}, files, filesMetadata)
+
+	for _, segment := range walSegments {
+		require.NoError(t, folder.GetSubFolder(utility.WalPath).PutObject(t.Context(), segment+".lz4",
+			bytes.NewReader([]byte("wal"))))
+	}
+}
+
+func putVerifyTestBackupAs(t *testing.T, folder storage.Folder, backupName string, sentinel BackupSentinelDto,
+	files map[string][]byte, filesMetadata FilesMetadataDto) {
+	var tarBuffer bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBuffer)
+	for name, content := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)),
+			Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+
+	ctx := t.Context()
+	backupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	require.NoError(t, backupFolder.PutObject(ctx, backupName+internal.TarPartitionFolderName+"part_1.tar", &tarBuffer))
+
+	sentinelBytes, err := json.Marshal(sentinel)
+	require.NoError(t, err)
+	require.NoError(t, backupFolder.PutObject(ctx, backupName+utility.SentinelSuffix, bytes.NewReader(sentinelBytes)))
+	metadata, err := json.Marshal(filesMetadata)
+	require.NoError(t, err)
+	require.NoError(t, backupFolder.PutObject(ctx, getFilesMetadataPath(backupName), bytes.NewReader(metadata)))
+}
+
+func verifyTestBackup(t *testing.T, folder storage.Folder) BackupVerifyReport {
+	backup, err := NewBackup(folder.GetSubFolder(utility.BaseBackupPath), verifyTestBackupName)
+	require.NoError(t, err)
+	report, err := VerifyBackup(t.Context(), folder, backup)
+	require.NoError(t, err)
+	return report
+}
+
+func TestVerifyBackup_OK(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	pagedFile := makeVerifyTestPagedFile(t)
+	putVerifyTestBackup(t, folder,
+		map[string][]byte{verifyTestPagedFile: pagedFile, "PG_VERSION": []byte("17\n")},
+		FilesMetadataDto{Files: internal.BackupFileList{
+			verifyTestPagedFile: {Size: int64(len(pagedFile))},
+			"PG_VERSION":        {Size: 3},
+			"base/1/16390":      {IsSkipped: true},
+		}},
+		"000000010000000000000002", "000000010000000000000003")
+
+	report := verifyTestBackup(t, folder)
+	assert.Equal(t, StatusOk, report.Status, report)
+	assert.Equal(t, 1, report.TarCount)
+	assert.Equal(t, 2, report.FilesChecked)
+	assert.Equal(t, &BackupVerifyWalRange{
+		Timeline:     1,
+		StartSegment: "000000010000000000000002",
+		EndSegment:   "000000010000000000000003",
+	}, report.Wal)
+}
+
+func TestVerifyBackup_Failures(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	pagedFile := makeVerifyTestPagedFile(t)
+	pagedFile[int(DatabasePageSize)+5000] ^= 0xff
+	putVerifyTestBackup(t, folder,
+		map[string][]byte{verifyTestPagedFile: pagedFile, "PG_VERSION": []byte("17\n")},
+		FilesMetadataDto{Files: internal.BackupFileList{
+			verifyTestPagedFile: {Size: int64(len(pagedFile))},
+			"PG_VERSION":        {Size: 4},
+			"base/1/16390":      {},
+		}},
+		"000000010000000000000002")
+
+	report := verifyTestBackup(t, folder)
+	assert.Equal(t, StatusFailure, report.Status)
+	assert.Equal(t, []string{"base/1/16390"}, report.MissingFiles)
+	assert.Equal(t, []BackupVerifySizeMismatch{{Path: "PG_VERSION", ExpectedSize: 4, ActualSize: 3}},
+		report.SizeMismatches)
+	assert.Equal(t, []BackupVerifyCorruptFile{{Path: verifyTestPagedFile, CorruptBlocks: []uint32{1}}},
+		report.CorruptFiles)
+	assert.Equal(t, []string{"000000010000000000000003"}, report.Wal.MissingSegments)
+}
+
+func TestVerifyBackup_TornPages(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	pagedFile := makeVerifyTestPagedFile(t)
+	// the page modified after the backup start at 0x2000028 was copied while being written
+	tornPage := pagedFile[int(DatabasePageSize) : 2*int(DatabasePageSize)]
+	binary.LittleEndian.PutUint32(tornPage[0:4], 0)
+	binary.LittleEndian.PutUint32(tornPage[4:8], 0x2000100)
+	tornPage[5000] ^= 0xff
+	putVerifyTestBackup(t, folder,
+		map[string][]byte{verifyTestPagedFile: pagedFile},
+		FilesMetadataDto{Files: internal.BackupFileList{verifyTestPagedFile: {Size: int64(len(pagedFile))}}},
+		"000000010000000000000002", "000000010000000000000003")
+
+	report := verifyTestBackup(t, folder)
+	assert.Equal(t, StatusOk, report.Status, report)
+	assert.Empty(t, report.CorruptFiles)
+}
+
+func TestVerifyBackup_NoFilesMetadata(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	putVerifyTestBackup(t, folder, map[string][]byte{"PG_VERSION": []byte("17\n")}, FilesMetadataDto{},
+		"000000010000000000000002", "000000010000000000000003")
+
+	report := verifyTestBackup(t, folder)
+	assert.Equal(t, StatusWarning, report.Status)
+	assert.Len(t, report.Warnings, 1)
+}
+
+func TestVerifyBackup_DeltaChain(t *testing.T) {
+	const (
+		fullBackupName  = "base_000000010000000000000001"
+		deltaBackupName = "base_000000010000000000000001_D_000000010000000000000001"
+	)
+	folder := memory.NewFolder("", memory.NewKVS())
+	pagedFile := makeVerifyTestPagedFile(t)
+	putVerifyTestBackup(t, folder,
+		map[string][]byte{verifyTestPagedFile: pagedFile},
+		FilesMetadataDto{Files: internal.BackupFileList{verifyTestPagedFile: {Size: int64(len(pagedFile))}}},
+		"000000010000000000000002", "000000010000000000000003")
+	// the test backup is the second delta of the chain
+	startLSN, finishLSN := LSN(0x2000028), LSN(0x3000100)
+	deltaName, deltaLSN, fullName, count := deltaBackupName, LSN(0x1800028), fullBackupName, 2
+	sentinelBytes, err := json.Marshal(BackupSentinelDto{BackupStartLSN: &startLSN, BackupFinishLSN: &finishLSN,
+		IncrementFrom: &deltaName, IncrementFromLSN: &deltaLSN, IncrementFullName: &fullName, IncrementCount: &count})
+	require.NoError(t, err)
+	require.NoError(t, folder.GetSubFolder(utility.BaseBackupPath).PutObject(t.Context(),
+		verifyTestBackupName+utility.SentinelSuffix, bytes.NewReader(sentinelBytes)))
+
+	fullLSN, deltaCount := LSN(0x1000028), 1
+	putVerifyTestBackupAs(t, folder, deltaBackupName,
+		BackupSentinelDto{BackupStartLSN: &deltaLSN, IncrementFrom: &fullName, IncrementFromLSN: &fullLSN,
+			IncrementFullName: &fullName, IncrementCount: &deltaCount},
+		map[string][]byte{"PG_VERSION": []byte("17\n")},
+		FilesMetadataDto{Files: internal.BackupFileList{"PG_VERSION": {Size: 3}}})
+	putVerifyTestBackupAs(t, folder, fullBackupName, BackupSentinelDto{BackupStartLSN: &fullLSN},
+		map[string][]byte{"PG_VERSION": []byte("17\n")},
+		FilesMetadataDto{Files: internal.BackupFileList{"PG_VERSION": {Size: 3}, "base/1/16390": {}}})
+
+	report := verifyTestBackup(t, folder)
+	assert.Equal(t, StatusFailure, report.Status)
+	require.Len(t, report.DeltaBases, 2)
+	assert.Equal(t, deltaBackupName, report.DeltaBases[0].BackupName)
+	assert.Equal(t, StatusOk, report.DeltaBases[0].Status)
+	assert.Equal(t, fullBackupName, report.DeltaBases[1].BackupName)
+	assert.Equal(t, StatusFailure, report.DeltaBases[1].Status)
+	assert.Equal(t, []string{"base/1/16390"}, report.DeltaBases[1].MissingFiles)
+	assert.Empty(t, report.Errors)
+	assert.Empty(t, report.Wal.MissingSegments)
+}
+
+func TestVerifyBackup_MissingDeltaBase(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	putVerifyTestBackup(t, folder, map[string][]byte{"PG_VERSION": []byte("17\n")},
+		FilesMetadataDto{Files: internal.BackupFileList{"PG_VERSION": {Size: 3}}},
+		"000000010000000000000002", "000000010000000000000003")
+	startLSN, finishLSN := LSN(0x2000028), LSN(0x3000100)
+	baseName, baseLSN, count := "base_000000010000000000000001", LSN(0x1000028), 1
+	sentinelBytes, err := json.Marshal(BackupSentinelDto{BackupStartLSN: &startLSN, BackupFinishLSN: &finishLSN,
+		IncrementFrom: &baseName, IncrementFromLSN: &baseLSN, IncrementFullName: &baseName, IncrementCount: &count})
+	require.NoError(t, err)
+	require.NoError(t, folder.GetSubFolder(utility.BaseBackupPath).PutObject(t.Context(),
+		verifyTestBackupName+utility.SentinelSuffix, bytes.NewReader(sentinelBytes)))
+
+	report := verifyTestBackup(t, folder)
+	assert.Equal(t, StatusFailure, report.Status)
+	assert.Empty(t, report.DeltaBases)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0], "delta chain is unverified from "+baseName)
+}
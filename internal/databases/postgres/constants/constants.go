@@ -5,3 +5,7 @@ package constants
 // Looking at sysexits.h, EX_IOERR (74) is defined as a generic exit code for input/output errors
 // It is used in wal-fetch (and daemon-client wal-fetch) to signal that the requested file does not exist.
 const ExIoError = 74
+
+// EX_DATAERR (65) is used in wal-fetch to signal that the file was found, but failed the integrity check, e.g.
+// because it's truncated in storage.
+const ExDataError = 65
@@ -232,6 +232,8 @@ func HandleDaemon(ctx context.Context, options DaemonOptions) {
 	}
 	defer utility.LoggedClose(multiSt, "close multi-storage")
 
+	startWALRepair(ctx, multiSt)
+
 	serve(ctx, l, multiSt)
 }
 
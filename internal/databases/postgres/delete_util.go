@@ -84,6 +84,10 @@ func IsPermanent(objectName, storageName string, permanentBackups, permanentWals
 		}
 		return permanentBackups[backup]
 	}
+	if strings.HasPrefix(objectName, utility.WalDictionaryPath) {
+		// WAL segments can't be decompressed without the dictionaries, so they're deleted only with everything else
+		return true
+	}
 	// should not reach here, default to false
 	return false
 }
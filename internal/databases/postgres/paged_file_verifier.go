@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"io"
+	"math"
 	"os"
 	"unsafe"
 
@@ -25,6 +26,9 @@ const (
 	// page header checksum length (in bytes)
 	PdChecksumLen       = 2
 	MaxDatabasePageSize = 32768
+
+	// maxLSN makes verifyPageBlocks check all the pages
+	maxLSN = LSN(math.MaxUint64)
 )
 
 // There is an unsafe pointer logic with PgDatabasePage and PgChecksummablePage.
@@ -179,38 +183,52 @@ func isPageCorrupted(path string, blockNo uint32, page *PgDatabasePage) (bool, e
 
 // VerifyPagedFileIncrement verifies pages of an increment
 func VerifyPagedFileIncrement(path string, fileInfo os.FileInfo, increment io.Reader) ([]uint32, error) {
-	_, diffBlockCount, diffMap, err := GetIncrementHeaderFields(increment)
+	return VerifyBackupPagedFile(path, fileInfo, increment, true, maxLSN)
+}
+
+// VerifyPagedFileBase verifies pages of a standard paged file
+func VerifyPagedFileBase(path string, fileInfo os.FileInfo, pagedFile io.Reader) ([]uint32, error) {
+	return VerifyBackupPagedFile(path, fileInfo, pagedFile, false, maxLSN)
+}
+
+// VerifyBackupPagedFile verifies pages of a paged file or an increment stored in a backup. The pages modified
+// since the backup start are skipped: they might have been torn while the backup was copying them, which is fine
+// because the WAL replay overwrites them with full page images.
+func VerifyBackupPagedFile(path string, fileInfo os.FileInfo, reader io.Reader, isIncremented bool,
+	backupStartLSN LSN) ([]uint32, error) {
+	if !isIncremented {
+		return verifyPageBlocks(path, fileInfo, reader, allBlockNumbers(fileInfo), backupStartLSN)
+	}
+	_, diffBlockCount, diffMap, err := GetIncrementHeaderFields(reader)
 	if err != nil {
 		return nil, err
 	}
 	blockNumbers := make([]uint32, 0, diffBlockCount)
 	for i := uint32(0); i < diffBlockCount; i++ {
-		blockNo := binary.LittleEndian.Uint32(diffMap[i*sizeofInt32 : (i+1)*sizeofInt32])
-		blockNumbers = append(blockNumbers, blockNo)
+		blockNumbers = append(blockNumbers, binary.LittleEndian.Uint32(diffMap[i*sizeofInt32:(i+1)*sizeofInt32]))
 	}
-	return verifyPageBlocks(path, fileInfo, increment, blockNumbers)
+	return verifyPageBlocks(path, fileInfo, reader, blockNumbers, backupStartLSN)
 }
 
-// VerifyPagedFileBase verifies pages of a standard paged file
-func VerifyPagedFileBase(path string, fileInfo os.FileInfo, pagedFile io.Reader) ([]uint32, error) {
-	size := fileInfo.Size()
-	filePageCount := uint32((size + DatabasePageSize - 1) / DatabasePageSize)
+func allBlockNumbers(fileInfo os.FileInfo) []uint32 {
+	filePageCount := uint32((fileInfo.Size() + DatabasePageSize - 1) / DatabasePageSize)
 	blockNumbers := make([]uint32, 0, filePageCount)
 	for i := uint32(0); i < filePageCount; i++ {
 		blockNumbers = append(blockNumbers, i)
 	}
-	return verifyPageBlocks(path, fileInfo, pagedFile, blockNumbers)
+	return blockNumbers
 }
 
-// verifyPageBlocks verifies provided page blocks from the pagedBlocks reader
+// verifyPageBlocks verifies provided page blocks from the pagedBlocks reader, except for the pages with
+// pd_lsn >= skipFromLSN
 func verifyPageBlocks(path string, fileInfo os.FileInfo, pageBlocks io.Reader,
-	blockNumbers []uint32) (corruptBlockNumbers []uint32, err error) {
+	blockNumbers []uint32, skipFromLSN LSN) (corruptBlockNumbers []uint32, err error) {
 	if _, ignored := ignoredFileNames[fileInfo.Name()]; ignored || !isChecksumValidatableFile(fileInfo, path) {
 		_, err = io.Copy(io.Discard, pageBlocks)
 		return nil, err
 	}
 	for _, blockNo := range blockNumbers {
-		corrupted, err := verifySinglePage(path, blockNo, pageBlocks)
+		corrupted, err := verifySinglePage(path, blockNo, pageBlocks, skipFromLSN)
 		if corrupted {
 			corruptBlockNumbers = append(corruptBlockNumbers, blockNo)
 		}
@@ -236,11 +254,19 @@ func verifyPageBlocks(path string, fileInfo os.FileInfo, pageBlocks io.Reader,
 }
 
 // verifySinglePage reads and verifies single paged file block
-func verifySinglePage(path string, blockNo uint32, pageBlocks io.Reader) (bool, error) {
+func verifySinglePage(path string, blockNo uint32, pageBlocks io.Reader, skipFromLSN LSN) (bool, error) {
 	page := PgDatabasePage{}
 	_, err := io.ReadFull(pageBlocks, page[:DatabasePageSize])
 	if err != nil {
 		return false, err
 	}
+	pageHeader, err := parsePostgresPageHeader(bytes.NewReader(page[:]))
+	if err != nil {
+		return false, err
+	}
+	if pageHeader.lsn() >= skipFromLSN {
+		tracelog.DebugLogger.Printf("Skipping page modified at %s: blockNo %d, path %s", pageHeader.lsn(), blockNo, path)
+		return false, nil
+	}
 	return isPageCorrupted(path, blockNo, &page)
 }
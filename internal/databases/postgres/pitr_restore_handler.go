@@ -0,0 +1,393 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	RecoveryConfFileName   = "recovery.conf"
+	RecoverySignalFileName = "recovery.signal"
+	AutoConfFileName       = "postgresql.auto.conf"
+
+	// recoverySignalVersion is the first version that reads the recovery settings from postgresql.conf
+	recoverySignalVersion = 120000
+	// recoveryTargetActionVersion is the first version that supports recovery_target_action
+	recoveryTargetActionVersion = 90500
+)
+
+type RecoveryTargetType int
+
+const (
+	RecoveryTargetTime RecoveryTargetType = iota
+	RecoveryTargetLSN
+	RecoveryTargetXid
+	RecoveryTargetName
+)
+
+// String returns the name of the recovery parameter for the target type
+func (targetType RecoveryTargetType) String() string {
+	return [...]string{"recovery_target_time", "recovery_target_lsn", "recovery_target_xid", "recovery_target_name"}[targetType]
+}
+
+// RecoveryTarget is the point Postgres recovers up to after the backup is fetched
+type RecoveryTarget struct {
+	Type  RecoveryTargetType
+	Value string
+
+	time time.Time
+	lsn  LSN
+}
+
+// NewRecoveryTarget parses the target value, the time is expected in RFC 3339 format
+func NewRecoveryTarget(targetType RecoveryTargetType, value string) (RecoveryTarget, error) {
+	target := RecoveryTarget{Type: targetType, Value: value}
+	var err error
+	switch targetType {
+	case RecoveryTargetTime:
+		target.time, err = time.Parse(time.RFC3339, value)
+	case RecoveryTargetLSN:
+		target.lsn, err = ParseLSN(value)
+	case RecoveryTargetXid:
+		_, err = strconv.ParseUint(value, 10, 64)
+	case RecoveryTargetName:
+		if value == "" {
+			err = errors.New("empty restore point name")
+		}
+	}
+	if err != nil {
+		return RecoveryTarget{}, errors.Wrapf(err, "invalid %s '%s'", targetType, value)
+	}
+	return target, nil
+}
+
+// HandlePitrRestore selects the newest backup finished before the recovery target, checks that the WAL
+// from the backup start to the target is archived, fetches the backup and writes the recovery config
+func HandlePitrRestore(ctx context.Context, rootFolder storage.Folder, dbDataDirectory string,
+	target RecoveryTarget, targetAction string, fetcher internal.Fetcher) error {
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	backupTimes, err := internal.GetBackups(ctx, baseBackupFolder)
+	if err != nil {
+		return err
+	}
+	backupDetails, err := GetBackupsDetails(ctx, baseBackupFolder, backupTimes)
+	if err != nil {
+		return err
+	}
+	selected, err := selectPitrBackup(backupDetails, target)
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Selected backup %s finished at %s (LSN %s) for the %s '%s'\n",
+		selected.BackupName, internal.FormatTime(selected.FinishTime), selected.FinishLsn, target.Type, target.Value)
+
+	err = checkPitrWalCoverage(ctx, rootFolder.GetSubFolder(utility.WalPath), selected, target)
+	if err != nil {
+		return err
+	}
+
+	backup, err := internal.NewBackupInStorage(ctx, baseBackupFolder, selected.BackupName, selected.StorageName)
+	if err != nil {
+		return err
+	}
+	internal.HandleBackupFetch(ctx, rootFolder, pitrBackupSelector{backup: backup}, fetcher)
+
+	restoreCommand, err := makeRestoreCommand()
+	if err != nil {
+		return err
+	}
+	config := makePitrRecoveryConfig(restoreCommand, target, targetAction, selected.PgVersion)
+	return writeRecoveryConfig(dbDataDirectory, config, selected.PgVersion)
+}
+
+// pitrBackupSelector returns the backup that is already selected by HandlePitrRestore
+type pitrBackupSelector struct {
+	backup internal.Backup
+}
+
+func (s pitrBackupSelector) Select(_ context.Context, _ storage.Folder) (internal.Backup, error) {
+	return s.backup, nil
+}
+
+// selectPitrBackup returns the newest backup which is consistent before the target. The backups can't be compared
+// with the transaction ID and the restore point targets, so the newest backup is returned for them.
+func selectPitrBackup(backups []BackupDetail, target RecoveryTarget) (BackupDetail, error) {
+	var selected *BackupDetail
+	for i := range backups {
+		backup := &backups[i]
+		switch target.Type {
+		case RecoveryTargetTime:
+			if backup.FinishTime.IsZero() || backup.FinishTime.After(target.time) {
+				continue
+			}
+		case RecoveryTargetLSN:
+			if backup.FinishLsn == 0 || backup.FinishLsn > target.lsn {
+				continue
+			}
+		}
+		if selected == nil || backup.FinishLsn > selected.FinishLsn ||
+			(backup.FinishLsn == selected.FinishLsn && backup.FinishTime.After(selected.FinishTime)) {
+			selected = backup
+		}
+	}
+	if selected == nil {
+		return BackupDetail{}, errors.Errorf("no backup finished before the %s '%s'", target.Type, target.Value)
+	}
+	if target.Type == RecoveryTargetXid || target.Type == RecoveryTargetName {
+		tracelog.WarningLogger.Printf("Can't check that the backup is older than the %s, the newest backup is used\n",
+			target.Type)
+	}
+	return *selected, nil
+}
+
+// checkPitrWalCoverage checks that every WAL segment from the backup start to the target segment is archived.
+// The target segment is the segment of the target LSN, or the first segment archived after the target time.
+// The transaction ID and the restore point targets can't be located, so for them the WAL is checked up to the first
+// gap after the backup. The segments are looked for on the history of the newest timeline, since the recovery
+// follows the latest timeline.
+func checkPitrWalCoverage(ctx context.Context, walFolder storage.Folder, backup BackupDetail,
+	target RecoveryTarget) error {
+	backupTimeline, err := ParseTimelineFromBackupName(backup.BackupName)
+	if err != nil {
+		return err
+	}
+	objects, _, err := walFolder.ListFolder(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list WAL folder")
+	}
+	walSegments := make(map[WalSegmentDescription]bool, len(objects))
+	segmentTimes := make(map[WalSegmentDescription]time.Time, len(objects))
+	for _, object := range objects {
+		segment, err := NewWalSegmentDescription(utility.TrimFileExtension(object.GetName()))
+		if _, ok := err.(NotWalFilenameError); ok {
+			continue
+		}
+		walSegments[segment] = true
+		if object.GetLastModified().After(segmentTimes[segment]) {
+			segmentTimes[segment] = object.GetLastModified()
+		}
+	}
+
+	latestTimeline := backupTimeline
+	for segment := range walSegments {
+		latestTimeline = max(latestTimeline, segment.Timeline)
+	}
+	historyRecords, err := GetTimeLineHistoryRecords(ctx, latestTimeline, walFolder)
+	if _, ok := err.(HistoryFileNotFoundError); err != nil && !ok {
+		return err
+	}
+	timelineSwitchMap := make(map[WalSegmentNo]*TimelineHistoryRecord, len(historyRecords))
+	for _, record := range historyRecords {
+		timelineSwitchMap[NewWalSegmentNo(record.lsn)] = record
+	}
+
+	missingSegments, err := findPitrMissingSegments(walSegments, segmentTimes, timelineSwitchMap, historyRecords,
+		backupTimeline, latestTimeline, backup, target)
+	if err != nil {
+		return err
+	}
+	if len(missingSegments) > 0 {
+		return errors.Errorf("WAL from the backup %s to the %s '%s' is not archived, missing segments: %s",
+			backup.BackupName, target.Type, target.Value, strings.Join(missingSegments, ", "))
+	}
+	return nil
+}
+
+func findPitrMissingSegments(walSegments map[WalSegmentDescription]bool,
+	segmentTimes map[WalSegmentDescription]time.Time, timelineSwitchMap map[WalSegmentNo]*TimelineHistoryRecord,
+	historyRecords []*TimelineHistoryRecord, backupTimeline, latestTimeline uint32, backup BackupDetail,
+	target RecoveryTarget) ([]string, error) {
+	startSegmentNo := NewWalSegmentNo(backup.StartLsn)
+	finishSegmentNo := startSegmentNo
+	if backup.FinishLsn > backup.StartLsn {
+		finishSegmentNo = NewWalSegmentNo(backup.FinishLsn - 1)
+	}
+
+	segmentOnHistory := func(segmentNo WalSegmentNo) WalSegmentDescription {
+		return WalSegmentDescription{
+			Number:   segmentNo,
+			Timeline: getTimelineByLSN(historyRecords, segmentNo.firstLsn(), latestTimeline),
+		}
+	}
+	newestSegmentNo := finishSegmentNo
+	for segment := range walSegments {
+		if segment.Timeline == latestTimeline && segment.Number > newestSegmentNo {
+			newestSegmentNo = segment.Number
+		}
+	}
+
+	var endSegment WalSegmentDescription
+	switch target.Type {
+	case RecoveryTargetLSN:
+		endSegment = WalSegmentDescription{
+			Number:   max(NewWalSegmentNo(target.lsn), finishSegmentNo),
+			Timeline: getTimelineByLSN(historyRecords, target.lsn, latestTimeline),
+		}
+	case RecoveryTargetTime:
+		endSegment = segmentOnHistory(newestSegmentNo)
+		for segmentNo := finishSegmentNo; segmentNo < newestSegmentNo; segmentNo++ {
+			segment := segmentOnHistory(segmentNo)
+			// the first segment archived after the target time contains the WAL up to the target
+			if archiveTime, ok := segmentTimes[segment]; ok && archiveTime.After(target.time) {
+				endSegment = segment
+				break
+			}
+		}
+	default:
+		endSegment = segmentOnHistory(finishSegmentNo)
+		for segmentNo := finishSegmentNo + 1; segmentNo <= newestSegmentNo; segmentNo++ {
+			segment := segmentOnHistory(segmentNo)
+			if !walSegments[segment] {
+				break
+			}
+			endSegment = segment
+		}
+		tracelog.WarningLogger.Printf("Can't check the WAL up to the %s, the continuous WAL is archived up to %s\n",
+			target.Type, endSegment.GetFileName())
+	}
+
+	var missingSegments []string
+	if !walSegments[endSegment] {
+		missingSegments = append(missingSegments, endSegment.GetFileName())
+	}
+	walSegmentRunner := NewWalSegmentRunner(endSegment, walSegments, startSegmentNo, timelineSwitchMap)
+	walSegmentScanner := NewWalSegmentScanner(walSegmentRunner)
+	err := walSegmentScanner.Scan(SegmentScanConfig{
+		UnlimitedScan:        true,
+		MissingSegmentStatus: Lost,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if walSegmentRunner.Current().Timeline != backupTimeline {
+		return nil, errors.Errorf("timeline %d of the backup %s is not in the history of timeline %d",
+			backupTimeline, backup.BackupName, endSegment.Timeline)
+	}
+	for _, segment := range walSegmentScanner.GetMissingSegmentsDescriptions() {
+		missingSegments = append(missingSegments, segment.GetFileName())
+	}
+	return missingSegments, nil
+}
+
+// getTimelineByLSN returns the timeline the LSN belongs to according to the .history records of the latest timeline
+func getTimelineByLSN(historyRecords []*TimelineHistoryRecord, lsn LSN, latestTimeline uint32) uint32 {
+	for _, record := range historyRecords {
+		if lsn < record.lsn {
+			return record.timeline
+		}
+	}
+	return latestTimeline
+}
+
+// makeRestoreCommand returns the restore_command running wal-fetch with the current binary and config. Postgres runs
+// the command without the environment of pitr-restore, so all the settings have to be in the config file.
+func makeRestoreCommand() (string, error) {
+	walgBinaryPath, err := os.Executable()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the wal-g binary path")
+	}
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		return "", errors.New("pitr-restore requires a config file, since restore_command doesn't get " +
+			"the settings from the current environment")
+	}
+	configFile, err = filepath.Abs(configFile)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the config file path")
+	}
+	fileConfig := viper.New()
+	conf.ReadConfigFromFile(fileConfig, configFile)
+	envOnlySettings := findEnvOnlySettings(conf.AllowedSettings, fileConfig, os.LookupEnv)
+	if len(envOnlySettings) > 0 {
+		return "", errors.Errorf("%s are set only in the environment, which restore_command doesn't get, "+
+			"add them to the config file %s", strings.Join(envOnlySettings, ", "), configFile)
+	}
+	return formatRestoreCommand(walgBinaryPath, configFile), nil
+}
+
+// findEnvOnlySettings returns the settings which are set in the environment, but not in the config file.
+// The Postgres connection settings are skipped, since wal-fetch doesn't connect to Postgres.
+func findEnvOnlySettings(allowedSettings map[string]bool, fileConfig *viper.Viper,
+	lookupEnv func(string) (string, bool)) []string {
+	var settings []string
+	for setting := range allowedSettings {
+		if strings.HasPrefix(setting, "PG") {
+			continue
+		}
+		if _, ok := lookupEnv(setting); ok && !fileConfig.IsSet(setting) {
+			settings = append(settings, setting)
+		}
+	}
+	sort.Strings(settings)
+	return settings
+}
+
+func formatRestoreCommand(walgBinaryPath, configFile string) string {
+	return fmt.Sprintf("%s wal-fetch \"%%f\" \"%%p\" --config %s", quoteShellArg(walgBinaryPath), quoteShellArg(configFile))
+}
+
+// quoteShellArg quotes the value as a single argument of the shell command
+func quoteShellArg(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func makePitrRecoveryConfig(restoreCommand string, target RecoveryTarget, targetAction string, pgVersion int) string {
+	lines := []string{
+		fmt.Sprintf("restore_command = %s", quoteConfigValue(restoreCommand)),
+		fmt.Sprintf("%s = %s", target.Type, quoteConfigValue(target.Value)),
+		"recovery_target_timeline = 'latest'",
+	}
+	if targetAction != "" {
+		if pgVersion >= recoveryTargetActionVersion {
+			lines = append(lines, fmt.Sprintf("recovery_target_action = %s", quoteConfigValue(targetAction)))
+		} else {
+			tracelog.WarningLogger.Printf("recovery_target_action is not supported by Postgres %d, skipping it\n",
+				pgVersion)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// quoteConfigValue quotes the value as a string literal of the Postgres config
+func quoteConfigValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// writeRecoveryConfig writes recovery.conf before Postgres 12. Since Postgres 12 the config is appended
+// to postgresql.auto.conf and recovery.signal is created to start the targeted recovery.
+func writeRecoveryConfig(dbDataDirectory, config string, pgVersion int) error {
+	if pgVersion < recoverySignalVersion {
+		path := filepath.Join(dbDataDirectory, RecoveryConfFileName)
+		tracelog.InfoLogger.Printf("Writing recovery config to %s\n", path)
+		return os.WriteFile(path, []byte(config), 0600)
+	}
+
+	path := filepath.Join(dbDataDirectory, AutoConfFileName)
+	tracelog.InfoLogger.Printf("Writing recovery config to %s\n", path)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString("# recovery settings added by wal-g pitr-restore\n" + config)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dbDataDirectory, RecoverySignalFileName), nil, 0600)
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+)
+
+const pitrTestRestoreCommand = `/usr/bin/wal-g wal-fetch "%f" "%p"`
+
+func makePitrTestBackup(name string, startLSN, finishLSN LSN, finishTime string) BackupDetail {
+	finish, _ := time.Parse(time.RFC3339, finishTime)
+	return BackupDetail{
+		BackupTime:          internal.BackupTime{BackupName: name},
+		ExtendedMetadataDto: ExtendedMetadataDto{StartLsn: startLSN, FinishLsn: finishLSN, FinishTime: finish},
+	}
+}
+
+func makePitrTestSegments(timeline uint32, first, last WalSegmentNo) map[WalSegmentDescription]bool {
+	segments := make(map[WalSegmentDescription]bool)
+	for segmentNo := first; segmentNo <= last; segmentNo++ {
+		segments[WalSegmentDescription{Number: segmentNo, Timeline: timeline}] = true
+	}
+	return segments
+}
+
+func TestNewRecoveryTarget(t *testing.T) {
+	target, err := NewRecoveryTarget(RecoveryTargetLSN, "0/3000028")
+	require.NoError(t, err)
+	assert.Equal(t, LSN(0x3000028), target.lsn)
+
+	_, err = NewRecoveryTarget(RecoveryTargetTime, "2024-01-02T15:04:05Z")
+	assert.NoError(t, err)
+	_, err = NewRecoveryTarget(RecoveryTargetTime, "yesterday")
+	assert.Error(t, err)
+	_, err = NewRecoveryTarget(RecoveryTargetXid, "-1")
+	assert.Error(t, err)
+}
+
+func TestSelectPitrBackup(t *testing.T) {
+	backups := []BackupDetail{
+		makePitrTestBackup("base_000000010000000000000002", 0x2000028, 0x2000100, "2024-01-01T10:00:00Z"),
+		makePitrTestBackup("base_000000010000000000000006", 0x6000028, 0x6000100, "2024-01-02T10:00:00Z"),
+		makePitrTestBackup("base_000000010000000000000004", 0x4000028, 0x4000100, "2024-01-01T20:00:00Z"),
+	}
+
+	target, _ := NewRecoveryTarget(RecoveryTargetTime, "2024-01-02T09:00:00Z")
+	selected, err := selectPitrBackup(backups, target)
+	require.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000004", selected.BackupName)
+
+	target, _ = NewRecoveryTarget(RecoveryTargetLSN, "0/4000100")
+	selected, err = selectPitrBackup(backups, target)
+	require.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000004", selected.BackupName)
+
+	target, _ = NewRecoveryTarget(RecoveryTargetName, "before_migration")
+	selected, err = selectPitrBackup(backups, target)
+	require.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000006", selected.BackupName)
+
+	target, _ = NewRecoveryTarget(RecoveryTargetTime, "2023-12-31T00:00:00Z")
+	_, err = selectPitrBackup(backups, target)
+	assert.Error(t, err)
+}
+
+func TestFindPitrMissingSegments_NoMissingSegments(t *testing.T) {
+	backup := makePitrTestBackup("base_000000010000000000000002", 0x2000028, 0x3000100, "2024-01-01T10:00:00Z")
+	segments := makePitrTestSegments(1, 1, 8)
+
+	target, _ := NewRecoveryTarget(RecoveryTargetLSN, "0/6000000")
+	missing, err := findPitrMissingSegments(segments, nil, nil, nil, 1, 1, backup, target)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	target, _ = NewRecoveryTarget(RecoveryTargetTime, "2024-01-02T10:00:00Z")
+	missing, err = findPitrMissingSegments(segments, nil, nil, nil, 1, 1, backup, target)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+}
+
+func TestFindPitrMissingSegments_MissingSegments(t *testing.T) {
+	backup := makePitrTestBackup("base_000000010000000000000002", 0x2000028, 0x3000100, "2024-01-01T10:00:00Z")
+	segments := makePitrTestSegments(1, 2, 8)
+	delete(segments, WalSegmentDescription{Number: 5, Timeline: 1})
+
+	target, _ := NewRecoveryTarget(RecoveryTargetLSN, "0/9000000")
+	missing, err := findPitrMissingSegments(segments, nil, nil, nil, 1, 1, backup, target)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"000000010000000000000009", "000000010000000000000005"}, missing)
+}
+
+func TestFindPitrMissingSegments_StopsAtTarget(t *testing.T) {
+	backup := makePitrTestBackup("base_000000010000000000000002", 0x2000028, 0x3000100, "2024-01-01T10:00:00Z")
+	segments := makePitrTestSegments(1, 2, 8)
+	delete(segments, WalSegmentDescription{Number: 6, Timeline: 1})
+	archiveTimes := make(map[WalSegmentDescription]time.Time)
+	for segment := range segments {
+		archiveTimes[segment] = time.Date(2024, 1, 1, 10, int(segment.Number), 0, 0, time.UTC)
+	}
+
+	target, _ := NewRecoveryTarget(RecoveryTargetTime, "2024-01-01T10:04:30Z")
+	missing, err := findPitrMissingSegments(segments, archiveTimes, nil, nil, 1, 1, backup, target)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	target, _ = NewRecoveryTarget(RecoveryTargetTime, "2024-01-01T10:06:30Z")
+	missing, err = findPitrMissingSegments(segments, archiveTimes, nil, nil, 1, 1, backup, target)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"000000010000000000000006"}, missing)
+
+	target, _ = NewRecoveryTarget(RecoveryTargetXid, "1234")
+	missing, err = findPitrMissingSegments(segments, archiveTimes, nil, nil, 1, 1, backup, target)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+}
+
+func TestFindPitrMissingSegments_TimelineSwitch(t *testing.T) {
+	backup := makePitrTestBackup("base_000000010000000000000002", 0x2000028, 0x3000100, "2024-01-01T10:00:00Z")
+	segments := makePitrTestSegments(1, 2, 5)
+	for segment := range makePitrTestSegments(2, 5, 8) {
+		segments[segment] = true
+	}
+	records := []*TimelineHistoryRecord{NewTimelineHistoryRecord(1, 0x5000100, "no recovery target specified")}
+	switchMap := map[WalSegmentNo]*TimelineHistoryRecord{5: records[0]}
+
+	target, _ := NewRecoveryTarget(RecoveryTargetLSN, "0/7000000")
+	missing, err := findPitrMissingSegments(segments, nil, switchMap, records, 1, 2, backup, target)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	target, _ = NewRecoveryTarget(RecoveryTargetLSN, "0/4000000")
+	missing, err = findPitrMissingSegments(segments, nil, switchMap, records, 1, 2, backup, target)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	otherBackup := makePitrTestBackup("base_000000030000000000000002", 0x2000028, 0x3000100, "2024-01-01T10:00:00Z")
+	_, err = findPitrMissingSegments(segments, nil, switchMap, records, 3, 2, otherBackup, target)
+	assert.Error(t, err)
+}
+
+func TestMakePitrRecoveryConfig(t *testing.T) {
+	target, _ := NewRecoveryTarget(RecoveryTargetName, "before 'migration'")
+
+	config := makePitrRecoveryConfig(pitrTestRestoreCommand, target, "promote", 160000)
+	assert.Equal(t, `restore_command = '/usr/bin/wal-g wal-fetch "%f" "%p"'
+recovery_target_name = 'before ''migration'''
+recovery_target_timeline = 'latest'
+recovery_target_action = 'promote'
+`, config)
+
+	config = makePitrRecoveryConfig(pitrTestRestoreCommand, target, "promote", 90400)
+	assert.NotContains(t, config, "recovery_target_action")
+}
+
+func TestFormatRestoreCommand(t *testing.T) {
+	assert.Equal(t, `'/opt/wal g/wal-g' wal-fetch "%f" "%p" --config '/etc/it'\''s/walg.json'`,
+		formatRestoreCommand("/opt/wal g/wal-g", "/etc/it's/walg.json"))
+}
+
+func TestFindEnvOnlySettings(t *testing.T) {
+	allowedSettings := map[string]bool{
+		conf.StoragePrefixSetting:     true,
+		conf.CompressionMethodSetting: true,
+		conf.PgHostSetting:            true,
+		conf.NetworkRateLimitSetting:  true,
+	}
+	fileConfig := viper.New()
+	fileConfig.Set(conf.StoragePrefixSetting, "s3://bucket/path")
+	env := map[string]string{
+		conf.StoragePrefixSetting:     "s3://bucket/other",
+		conf.CompressionMethodSetting: "zstd",
+		conf.PgHostSetting:            "localhost",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	assert.Equal(t, []string{conf.CompressionMethodSetting}, findEnvOnlySettings(allowedSettings, fileConfig, lookupEnv))
+}
+
+func TestWriteRecoveryConfig(t *testing.T) {
+	dataDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, AutoConfFileName), []byte("work_mem = '8MB'\n"), 0600))
+
+	require.NoError(t, writeRecoveryConfig(dataDir, "recovery_target_lsn = '0/3000028'\n", 170000))
+	autoConf, err := os.ReadFile(filepath.Join(dataDir, AutoConfFileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(autoConf), "work_mem = '8MB'\n")
+	assert.Contains(t, string(autoConf), "recovery_target_lsn = '0/3000028'\n")
+	assert.FileExists(t, filepath.Join(dataDir, RecoverySignalFileName))
+	assert.NoFileExists(t, filepath.Join(dataDir, RecoveryConfFileName))
+
+	dataDir = t.TempDir()
+	require.NoError(t, writeRecoveryConfig(dataDir, "recovery_target_lsn = '0/3000028'\n", 110000))
+	recoveryConf, err := os.ReadFile(filepath.Join(dataDir, RecoveryConfFileName))
+	require.NoError(t, err)
+	assert.Equal(t, "recovery_target_lsn = '0/3000028'\n", string(recoveryConf))
+	assert.NoFileExists(t, filepath.Join(dataDir, RecoverySignalFileName))
+}
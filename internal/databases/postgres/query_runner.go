@@ -176,6 +176,16 @@ func (queryRunner *PgQueryRunner) buildGetPhysicalSlotInfo() string {
 	return "select active, restart_lsn from pg_catalog.pg_replication_slots where slot_name OPERATOR(pg_catalog.=) $1"
 }
 
+// buildCreatePhysicalSlot formats a query to create a Physical Replication Slot which reserves WAL immediately
+func (queryRunner *PgQueryRunner) buildCreatePhysicalSlot() string {
+	return "select pg_catalog.pg_create_physical_replication_slot($1, true)"
+}
+
+// buildAdvancePhysicalSlot formats a query to advance a Physical Replication Slot
+func (queryRunner *PgQueryRunner) buildAdvancePhysicalSlot() string {
+	return "select pg_catalog.pg_replication_slot_advance($1, $2::pg_catalog.pg_lsn)"
+}
+
 // Retrieve PostgreSQL numeric version
 func (queryRunner *PgQueryRunner) getVersion(ctx context.Context) (err error) {
 	queryRunner.Mu.Lock()
@@ -467,6 +477,31 @@ func (queryRunner *PgQueryRunner) GetPhysicalSlotInfo(ctx context.Context, slotN
 	return NewPhysicalSlot(slotName, true, active, restartLSN)
 }
 
+// CreatePhysicalSlot creates a physical replication slot, it can be created on a standby too
+func (queryRunner *PgQueryRunner) CreatePhysicalSlot(ctx context.Context, slotName string) error {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	conn := queryRunner.Connection
+	_, err := conn.Exec(ctx, queryRunner.buildCreatePhysicalSlot(), slotName)
+	return errors.Wrapf(err, "CreatePhysicalSlot: creating slot '%s' failed", slotName)
+}
+
+// AdvancePhysicalSlot moves the restart LSN of a physical replication slot forward. On a standby
+// the slot is advanced no further than the replayed LSN.
+func (queryRunner *PgQueryRunner) AdvancePhysicalSlot(ctx context.Context, slotName string, lsn LSN) error {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	if queryRunner.Version < 110000 {
+		return errors.Errorf("advancing replication slots is not supported by Postgres %d, 11 or newer is required",
+			queryRunner.Version)
+	}
+	conn := queryRunner.Connection
+	_, err := conn.Exec(ctx, queryRunner.buildAdvancePhysicalSlot(), slotName, lsn.String())
+	return errors.Wrapf(err, "AdvancePhysicalSlot: advancing slot '%s' to %s failed", slotName, lsn)
+}
+
 // tablespace map does not exist in < 9.6
 func (queryRunner *PgQueryRunner) IsTablespaceMapExists() bool {
 	return queryRunner.Version >= 90600
@@ -0,0 +1,277 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	zstdcompression "github.com/wal-g/wal-g/internal/compression/zstd"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	WalDictionaryDefaultSegments = 8
+	WalDictionaryDefaultMaxSize  = 112 << 10
+
+	walDictionaryCurrentName = "current.json"
+	// walDictionaryHashBytes is the minimum length of the matches the dictionary is built of
+	walDictionaryHashBytes = 6
+
+	walDictionaryCacheFileName = ".walg_wal_dictionary_cache"
+	// walDictionaryCacheLifetime is how long wal-push uses the cached dictionary before it checks the current one
+	walDictionaryCacheLifetime = time.Hour
+)
+
+// WalDictionaryInfo describes the dictionary WAL segments are compressed with by wal-push
+type WalDictionaryInfo struct {
+	ID        uint32    `json:"ID"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	Segments  []string  `json:"Segments"`
+	Size      int       `json:"Size"`
+}
+
+// HandleWalDictionaryTrain trains the zstd dictionary on the most recent WAL segments in storage, uploads it
+// and makes it the current one, so that wal-push uses it if WALG_ZSTD_DICTIONARY is enabled.
+// Dictionaries are never deleted by the retention, since they're required to decompress WAL.
+func HandleWalDictionaryTrain(ctx context.Context, uploader internal.Uploader, segmentCount, maxSize int) error {
+	rootFolder := uploader.Folder()
+	rootReader := internal.NewFolderReader(rootFolder)
+
+	segments, err := getRecentWalSegments(ctx, rootFolder.GetSubFolder(utility.WalPath), segmentCount)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return errors.New("no WAL segments to train the dictionary on")
+	}
+
+	var samples [][]byte
+	for _, segment := range segments {
+		tracelog.InfoLogger.Printf("Sampling WAL segment %s", segment)
+		segmentSamples, err := sampleWalSegment(ctx, rootReader.SubFolder(utility.WalPath), segment)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sample WAL segment %s", segment)
+		}
+		samples = append(samples, segmentSamples...)
+	}
+
+	dictionary, err := dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: maxSize,
+		HashBytes:   walDictionaryHashBytes,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to train the dictionary")
+	}
+	inspected, err := zstd.InspectDictionary(dictionary)
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect the trained dictionary")
+	}
+
+	info := WalDictionaryInfo{
+		ID:        inspected.ID(),
+		CreatedAt: time.Now().UTC(),
+		Segments:  segments,
+		Size:      len(dictionary),
+	}
+	dictionaryName := internal.ZstdDictionaryName(info.ID)
+	exists, err := rootFolder.GetSubFolder(utility.WalDictionaryPath).Exists(ctx, dictionaryName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.Errorf("dictionary %08x already exists, please run the training again", info.ID)
+	}
+
+	content := internal.CompressAndEncrypt(bytes.NewReader(dictionary), nil, internal.ConfigureCrypter())
+	err = uploader.Upload(ctx, utility.WalDictionaryPath+dictionaryName, content)
+	if err != nil {
+		return errors.Wrapf(err, "failed to upload dictionary %08x", info.ID)
+	}
+	// the dictionary becomes current only after it's uploaded
+	err = uploader.UploadJSON(ctx, utility.WalDictionaryPath+walDictionaryCurrentName, info)
+	if err != nil {
+		return errors.Wrap(err, "failed to make the dictionary current")
+	}
+
+	tracelog.InfoLogger.Printf("Dictionary %08x of %d bytes is trained on %d WAL segments and is current now",
+		info.ID, info.Size, len(segments))
+	return nil
+}
+
+// getRecentWalSegments provides the names of the most recent WAL segments in the folder, the newest first
+func getRecentWalSegments(ctx context.Context, walFolder storage.Folder, count int) ([]string, error) {
+	objects, _, err := walFolder.ListFolder(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list WAL folder")
+	}
+
+	unique := make(map[string]bool)
+	var segments []string
+	for _, object := range objects {
+		name := utility.TrimFileExtension(object.GetName())
+		if !isWalFilename(name) || unique[name] {
+			continue
+		}
+		unique[name] = true
+		segments = append(segments, name)
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(segments)))
+	if len(segments) > count {
+		segments = segments[:count]
+	}
+	return segments, nil
+}
+
+// sampleWalSegment splits the segment into pages, skipping the zeroed ones, e.g. of the segment switched early
+func sampleWalSegment(ctx context.Context, walReader internal.StorageFolderReader, segment string) ([][]byte, error) {
+	reader, err := internal.DownloadAndDecompressStorageFile(ctx, walReader, segment)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(reader, "")
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples [][]byte
+	zeroPage := make([]byte, walparser.WalPageSize)
+	for start := 0; start < len(data); start += int(walparser.WalPageSize) {
+		page := data[start:min(start+int(walparser.WalPageSize), len(data))]
+		if bytes.Equal(page, zeroPage[:len(page)]) {
+			continue
+		}
+		samples = append(samples, page)
+	}
+	return samples, nil
+}
+
+// FetchCurrentWalDictionary provides the current dictionary, or nil if no dictionary has been trained yet
+func FetchCurrentWalDictionary(ctx context.Context,
+	rootReader internal.StorageFolderReader) (*WalDictionaryInfo, []byte, error) {
+	dictionaryReader := rootReader.SubFolder(utility.WalDictionaryPath)
+	infoReader, err := dictionaryReader.ReadObject(ctx, walDictionaryCurrentName)
+	if _, ok := err.(storage.ObjectNotFoundError); ok {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer utility.LoggedClose(infoReader, "")
+
+	var info WalDictionaryInfo
+	if err = json.NewDecoder(infoReader).Decode(&info); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse the current dictionary info")
+	}
+	dictionary, err := internal.ReadZstdDictionary(ctx, dictionaryReader, info.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &info, dictionary, nil
+}
+
+// cachedWalDictionary is the current dictionary of the storage kept on disk, so that wal-push
+// doesn't download it every time
+type cachedWalDictionary struct {
+	StoragePath string            `json:"StoragePath"`
+	FetchedAt   time.Time         `json:"FetchedAt"`
+	Info        WalDictionaryInfo `json:"Info"`
+	Dictionary  []byte            `json:"Dictionary"`
+}
+
+func getWalDictionaryCacheFilename() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, walDictionaryCacheFileName), nil
+}
+
+// readCachedWalDictionary provides the cached dictionary of the storage, or nil if it's missing or outdated
+func readCachedWalDictionary(storagePath string) (*WalDictionaryInfo, []byte) {
+	cacheFilename, err := getWalDictionaryCacheFilename()
+	if err != nil {
+		return nil, nil
+	}
+	file, err := os.ReadFile(cacheFilename)
+	if err != nil {
+		return nil, nil
+	}
+	var cache cachedWalDictionary
+	if err = json.Unmarshal(file, &cache); err != nil {
+		tracelog.WarningLogger.Printf("Failed to parse the cached dictionary: %v", err)
+		return nil, nil
+	}
+	if cache.StoragePath != storagePath || time.Since(cache.FetchedAt) > walDictionaryCacheLifetime ||
+		len(cache.Dictionary) == 0 {
+		return nil, nil
+	}
+	return &cache.Info, cache.Dictionary
+}
+
+func writeCachedWalDictionary(storagePath string, info *WalDictionaryInfo, dictionary []byte) {
+	cacheFilename, err := getWalDictionaryCacheFilename()
+	if err != nil {
+		return
+	}
+	marshal, err := json.Marshal(cachedWalDictionary{
+		StoragePath: storagePath,
+		FetchedAt:   time.Now(),
+		Info:        *info,
+		Dictionary:  dictionary,
+	})
+	if err == nil {
+		// the dictionary is made of WAL content, so it's readable by the owner only
+		err = os.WriteFile(cacheFilename, marshal, 0600)
+	}
+	tracelog.WarningLogger.PrintOnError(err)
+}
+
+// configureWalDictionary makes the uploader compress WAL with the current dictionary if WALG_ZSTD_DICTIONARY is enabled
+func configureWalDictionary(ctx context.Context, uploader *internal.RegularUploader) error {
+	if !viper.GetBool(conf.ZstdDictionarySetting) {
+		return nil
+	}
+	compressor, ok := uploader.Compressor.(zstdcompression.Compressor)
+	if !ok {
+		return errors.Errorf("%s requires the '%s' compression method", conf.ZstdDictionarySetting,
+			zstdcompression.AlgorithmName)
+	}
+
+	storagePath := uploader.Folder().GetPath()
+	info, dictionary := readCachedWalDictionary(storagePath)
+	if info == nil {
+		var err error
+		info, dictionary, err = FetchCurrentWalDictionary(ctx, internal.NewFolderReader(uploader.Folder()))
+		if err != nil {
+			return errors.Wrap(err, "failed to fetch the current dictionary")
+		}
+		if info == nil {
+			tracelog.WarningLogger.Printf("%s is enabled, but no dictionary is trained yet, see 'wal-g wal-dict train'",
+				conf.ZstdDictionarySetting)
+			return nil
+		}
+		writeCachedWalDictionary(storagePath, info, dictionary)
+	}
+
+	tracelog.DebugLogger.Printf("Compressing WAL with dictionary %08x", info.ID)
+	compressor.Dictionary = dictionary
+	uploader.Compressor = compressor
+	return nil
+}
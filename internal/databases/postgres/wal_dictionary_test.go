@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	zstdcompression "github.com/wal-g/wal-g/internal/compression/zstd"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// makeTestWalSegment generates pages of similar records with a zeroed tail, like the segment switched early
+func makeTestWalSegment(random *rand.Rand, pages int) []byte {
+	var segment bytes.Buffer
+	for segment.Len() < (pages-1)*int(walparser.WalPageSize) {
+		id := random.Intn(1 << 20)
+		_, _ = fmt.Fprintf(&segment, "\x00\x01heap insert rel 1663/16384/%d blk %d: id=%d name=user_%d balance=%d;",
+			16385+random.Intn(4), random.Intn(1000), id, id, random.Intn(100000))
+	}
+	segment.Truncate((pages - 1) * int(walparser.WalPageSize))
+	segment.Write(make([]byte, walparser.WalPageSize))
+	return segment.Bytes()
+}
+
+func putTestWalSegment(t *testing.T, folder storage.Folder, name string, data []byte) {
+	compressed, err := io.ReadAll(internal.CompressAndEncrypt(bytes.NewReader(data), zstdcompression.Compressor{}, nil))
+	require.NoError(t, err)
+	require.NoError(t, folder.PutObject(t.Context(), name+"."+zstdcompression.FileExtension, bytes.NewReader(compressed)))
+}
+
+func TestHandleWalDictionaryTrain(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	rootFolder := memory.NewFolder("", memory.NewKVS())
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	random := rand.New(rand.NewSource(0x1337c0deb357beef))
+	for _, name := range []string{
+		"000000010000000000000001", "000000010000000000000002", "000000010000000000000003",
+		"000000010000000000000004", "000000010000000000000005", "000000010000000000000005.partial",
+	} {
+		putTestWalSegment(t, walFolder, name, makeTestWalSegment(random, 32))
+	}
+	putTestWalSegment(t, walFolder, "00000002.history", []byte("1\t0/5000000\tno recovery target specified\n"))
+
+	uploader := internal.NewRegularUploader(nil, rootFolder)
+	require.NoError(t, HandleWalDictionaryTrain(t.Context(), uploader, 3, WalDictionaryDefaultMaxSize))
+
+	info, dictionary, err := FetchCurrentWalDictionary(t.Context(), internal.NewFolderReader(rootFolder))
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, []string{"000000010000000000000005", "000000010000000000000004", "000000010000000000000003"},
+		info.Segments)
+	assert.Equal(t, len(dictionary), info.Size)
+	assert.LessOrEqual(t, info.Size, WalDictionaryDefaultMaxSize)
+
+	// wal-push compresses with the current dictionary and wal-fetch finds it by the ID in the frame header
+	viper.Set(conf.ZstdDictionarySetting, true)
+	defer viper.Set(conf.ZstdDictionarySetting, nil)
+	walUploader := internal.NewRegularUploader(zstdcompression.Compressor{}, rootFolder)
+	require.NoError(t, configureWalDictionary(t.Context(), walUploader))
+	walUploader.ChangeDirectory(utility.WalPath)
+
+	segment := makeTestWalSegment(random, 32)
+	segmentReader := ioextensions.NewNamedReaderImpl(bytes.NewReader(segment), "000000010000000000000006")
+	require.NoError(t, walUploader.UploadFile(t.Context(), segmentReader))
+
+	object, err := walFolder.ReadObject(t.Context(), "000000010000000000000006."+zstdcompression.FileExtension)
+	require.NoError(t, err)
+	compressed, err := io.ReadAll(object)
+	require.NoError(t, err)
+	var header zstd.Header
+	require.NoError(t, header.Decode(compressed))
+	assert.Equal(t, info.ID, header.DictionaryID)
+
+	zstdcompression.SetDictionaryLoader(nil)
+	reader, err := internal.DownloadAndDecompressStorageFile(t.Context(), internal.NewFolderReader(walFolder),
+		"000000010000000000000006")
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.ErrorContains(t, err, "dictionaries are not available")
+
+	internal.ConfigureZstdDictionaryLoader(t.Context(), func() (internal.StorageFolderReader, error) {
+		return internal.NewFolderReader(rootFolder), nil
+	})
+	reader, err = internal.DownloadAndDecompressStorageFile(t.Context(), internal.NewFolderReader(walFolder),
+		"000000010000000000000006")
+	require.NoError(t, err)
+	fetched, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, segment, fetched)
+
+	// the next wal-push takes the dictionary from the cache
+	dictionaryFolder := rootFolder.GetSubFolder(utility.WalDictionaryPath)
+	require.NoError(t, dictionaryFolder.DeleteObjects(t.Context(), []storage.Object{
+		storage.NewLocalObject(walDictionaryCurrentName, time.Time{}, 0),
+	}))
+	walUploader = internal.NewRegularUploader(zstdcompression.Compressor{}, rootFolder)
+	require.NoError(t, configureWalDictionary(t.Context(), walUploader))
+	assert.Equal(t, dictionary, walUploader.Compressor.(zstdcompression.Compressor).Dictionary)
+}
+
+func TestConfigureWalDictionary_NotTrained(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	viper.Set(conf.ZstdDictionarySetting, true)
+	defer viper.Set(conf.ZstdDictionarySetting, nil)
+
+	folder := memory.NewFolder("", memory.NewKVS())
+	uploader := internal.NewRegularUploader(zstdcompression.Compressor{}, folder)
+	require.NoError(t, configureWalDictionary(t.Context(), uploader))
+	assert.Equal(t, zstdcompression.Compressor{}, uploader.Compressor)
+}
+
+func TestIsPermanent_WalDictionary(t *testing.T) {
+	assert.True(t, IsPermanent(utility.WalDictionaryPath+internal.ZstdDictionaryName(0xabcd), "default", nil, nil))
+	assert.True(t, IsPermanent(utility.WalDictionaryPath+walDictionaryCurrentName, "default", nil, nil))
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/utility"
 )
 
@@ -94,7 +98,71 @@ func HandleWALFetch(ctx context.Context,
 	}
 
 	tracelog.DebugLogger.Printf("Statring external storage download for file %s at %v", walFileName, time.Now())
-	return internal.DownloadFileTo(ctx, reader, walFileName, location)
+	err := internal.DownloadFileTo(ctx, reader, walFileName, location)
+	if _, ok := err.(internal.ArchiveNonExistenceError); ok && viper.GetBool(conf.PgFetchPartialWal) && isWalFilename(walFileName) {
+		// The newest segment is uploaded only in the .partial parts by wal-receive in the sync mode
+		tracelog.InfoLogger.Printf("WAL file %s does not exist, fetching %s.partial\n", walFileName, walFileName)
+		return fetchPartialWAL(ctx, reader, walFileName, location)
+	}
+	return err
+}
+
+// fetchPartialWAL restores the .partial file of the segment, which wal-receive uploads on the timeline switch,
+// or assembles it from the .partial parts uploaded in the sync mode. The parts are read in order until the next one
+// is not found, and the rest of the segment is filled with zeros like in the .partial files of pg_receivewal.
+func fetchPartialWAL(ctx context.Context, reader internal.StorageFolderReader, walFileName string, location string) error {
+	notFoundErr := internal.DownloadFileTo(ctx, reader, walFileName+".partial", location)
+	if _, ok := notFoundErr.(internal.ArchiveNonExistenceError); !ok {
+		return notFoundErr
+	}
+
+	file, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(file, "")
+
+	size, err := copyPartialParts(ctx, reader, walFileName, file)
+	if err == nil && size == 0 {
+		err = notFoundErr
+	}
+	if err == nil {
+		err = file.Truncate(int64(WalSegmentSize))
+	}
+	if err != nil {
+		_ = os.Remove(location)
+		return err
+	}
+	tracelog.InfoLogger.Printf("Assembled %s.partial with %d bytes of WAL\n", walFileName, size)
+	return nil
+}
+
+// copyPartialParts writes the contiguous .partial parts of the segment starting from the beginning of the segment
+func copyPartialParts(ctx context.Context, reader internal.StorageFolderReader, walFileName string, file io.Writer) (int64, error) {
+	size := int64(0)
+	for size < int64(WalSegmentSize) {
+		partName := partialPartName(walFileName, int(size))
+		part, err := internal.DownloadAndDecompressStorageFile(ctx, reader, partName)
+		if _, ok := err.(internal.ArchiveNonExistenceError); ok {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		n, err := utility.FastCopy(file, part)
+		utility.LoggedClose(part, "")
+		if crypto.IsIntegrityError(err) {
+			return 0, errors.Wrapf(err, "file '%s' failed the integrity check", partName)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
+		size += n
+	}
+	return size, nil
 }
 
 // TODO : unit tests
@@ -3,13 +3,22 @@ package postgres_test
 import (
 	"bytes"
 	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/compression"
 	"github.com/wal-g/wal-g/internal/compression/lz4"
 	"github.com/wal-g/wal-g/internal/compression/lzma"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/testtools"
 	"github.com/wal-g/wal-g/utility"
 )
@@ -79,3 +88,93 @@ func TestTryDownloadWALFile_NotExist(t *testing.T) {
 	assert.False(t, exist)
 	assert.NoError(t, err)
 }
+
+func TestDownloadFileTo_RemovesTruncatedFile(t *testing.T) {
+	viper.Set(conf.AeadKeySetting, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	defer viper.Set(conf.AeadKeySetting, nil)
+	folder := testtools.MakeDefaultInMemoryStorageFolder().GetSubFolder(utility.WalPath)
+
+	data, err := io.ReadAll(internal.CompressAndEncrypt(bytes.NewReader(make([]byte, 1024*1024)), lz4.Compressor{},
+		internal.ConfigureCrypter()))
+	assert.NoError(t, err)
+	assert.NoError(t, folder.PutObject(t.Context(), WalFilename+"."+lz4.FileExtension, bytes.NewReader(data[:len(data)/2])))
+
+	location := filepath.Join(t.TempDir(), WalFilename)
+	err = internal.DownloadFileTo(t.Context(), internal.NewFolderReader(folder), WalFilename, location)
+	assert.True(t, crypto.IsIntegrityError(err), "%v", err)
+	assert.NoFileExists(t, location)
+}
+
+func putCompressedWAL(t *testing.T, folder storage.Folder, name string, data []byte) {
+	compressed := bytes.Buffer{}
+	writer := lz4.Compressor{}.NewWriter(&compressed)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, folder.PutObject(t.Context(), name+"."+lz4.FileExtension, &compressed))
+}
+
+func TestHandleWALFetch_FetchesPartialWAL(t *testing.T) {
+	data := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(data)
+	expected := make([]byte, postgres.WalSegmentSize)
+	copy(expected, data)
+
+	t.Run("assembles the parts", func(t *testing.T) {
+		viper.Set(conf.PgFetchPartialWal, true)
+		defer viper.Set(conf.PgFetchPartialWal, nil)
+		folder := testtools.MakeDefaultInMemoryStorageFolder()
+		walFolder := folder.GetSubFolder(utility.WalPath)
+		putCompressedWAL(t, walFolder, WalFilename+".partial.00000000", data[:1000])
+		putCompressedWAL(t, walFolder, WalFilename+".partial.000003E8", data[1000:4000])
+		putCompressedWAL(t, walFolder, WalFilename+".partial.00000FA0", data[4000:])
+		// the part which doesn't follow the previous one is not used
+		putCompressedWAL(t, walFolder, WalFilename+".partial.00002000", data[:100])
+
+		location := filepath.Join(t.TempDir(), WalFilename)
+		require.NoError(t, postgres.HandleWALFetch(t.Context(), internal.NewFolderReader(folder), WalFilename, location,
+			postgres.NopPrefetcher{}))
+		restored, err := os.ReadFile(location)
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(expected, restored))
+	})
+
+	t.Run("prefers the partial file", func(t *testing.T) {
+		viper.Set(conf.PgFetchPartialWal, true)
+		defer viper.Set(conf.PgFetchPartialWal, nil)
+		folder := testtools.MakeDefaultInMemoryStorageFolder()
+		walFolder := folder.GetSubFolder(utility.WalPath)
+		putCompressedWAL(t, walFolder, WalFilename+".partial", expected)
+		putCompressedWAL(t, walFolder, WalFilename+".partial.00000000", data[:1000])
+
+		location := filepath.Join(t.TempDir(), WalFilename)
+		require.NoError(t, postgres.HandleWALFetch(t.Context(), internal.NewFolderReader(folder), WalFilename, location,
+			postgres.NopPrefetcher{}))
+		restored, err := os.ReadFile(location)
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(expected, restored))
+	})
+
+	t.Run("fails without the partial WAL", func(t *testing.T) {
+		viper.Set(conf.PgFetchPartialWal, true)
+		defer viper.Set(conf.PgFetchPartialWal, nil)
+		folder := testtools.MakeDefaultInMemoryStorageFolder()
+
+		location := filepath.Join(t.TempDir(), WalFilename)
+		err := postgres.HandleWALFetch(t.Context(), internal.NewFolderReader(folder), WalFilename, location,
+			postgres.NopPrefetcher{})
+		assert.IsType(t, internal.ArchiveNonExistenceError{}, err)
+		assert.NoFileExists(t, location)
+	})
+
+	t.Run("ignores the parts if disabled", func(t *testing.T) {
+		folder := testtools.MakeDefaultInMemoryStorageFolder()
+		putCompressedWAL(t, folder.GetSubFolder(utility.WalPath), WalFilename+".partial.00000000", data)
+
+		location := filepath.Join(t.TempDir(), WalFilename)
+		err := postgres.HandleWALFetch(t.Context(), internal.NewFolderReader(folder), WalFilename, location,
+			postgres.NopPrefetcher{})
+		assert.IsType(t, internal.ArchiveNonExistenceError{}, err)
+		assert.NoFileExists(t, location)
+	})
+}
@@ -0,0 +1,253 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+)
+
+const (
+	defaultClusterMemberPort = 5432
+	clusterPollInterval      = time.Second
+)
+
+// ClusterMember is a Postgres instance of the HA cluster. It's connected with the PG* settings
+// except for the host and the port.
+type ClusterMember struct {
+	Host string
+	Port uint16
+}
+
+func (member ClusterMember) String() string {
+	return fmt.Sprintf("%s:%d", member.Host, member.Port)
+}
+
+// ParseClusterMembers parses the comma separated list of host:port, the port defaults to 5432.
+// The host may be a unix socket directory.
+func ParseClusterMembers(hosts string) ([]ClusterMember, error) {
+	var members []ClusterMember
+	for _, hostPort := range strings.Split(hosts, ",") {
+		hostPort = strings.TrimSpace(hostPort)
+		if hostPort == "" {
+			continue
+		}
+		member := ClusterMember{Host: hostPort, Port: defaultClusterMemberPort}
+		if idx := strings.LastIndex(hostPort, ":"); idx >= 0 {
+			port, err := strconv.ParseUint(hostPort[idx+1:], 10, 16)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid port of cluster member '%s'", hostPort)
+			}
+			member = ClusterMember{Host: hostPort[:idx], Port: uint16(port)}
+		}
+		if member.Host == "" {
+			return nil, errors.Errorf("empty host of cluster member '%s'", hostPort)
+		}
+		members = append(members, member)
+	}
+	if len(members) == 0 {
+		return nil, errors.New("no cluster members specified")
+	}
+	return members, nil
+}
+
+func (member ClusterMember) connect(ctx context.Context) (*pgx.Conn, error) {
+	config, err := pgx.ParseConfig("")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read environment variables")
+	}
+	config.Host = member.Host
+	config.Port = member.Port
+	config.Fallbacks = nil
+	conn, err := pgx.ConnectConfig(ctx, config)
+	return conn, errors.Wrapf(err, "failed to connect to %s", member)
+}
+
+func (member ClusterMember) connectReplication(ctx context.Context) (*pgconn.PgConn, error) {
+	config, err := pgconn.ParseConfig("replication=yes")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read environment variables")
+	}
+	config.Host = member.Host
+	config.Port = member.Port
+	config.Fallbacks = nil
+	conn, err := pgconn.ConnectConfig(ctx, config)
+	return conn, errors.Wrapf(err, "failed to open replication connection to %s", member)
+}
+
+// WalReceiveCluster keeps the wal-receive replication slot on every member of the HA cluster,
+// so that the WAL which is not archived yet survives the promotion of any standby
+type WalReceiveCluster struct {
+	members          []ClusterMember
+	slotName         string
+	reconnectTimeout time.Duration
+	queryRunners     map[ClusterMember]*PgQueryRunner
+}
+
+func NewWalReceiveCluster(members []ClusterMember, slotName string, reconnectTimeout time.Duration) *WalReceiveCluster {
+	return &WalReceiveCluster{
+		members:          members,
+		slotName:         slotName,
+		reconnectTimeout: reconnectTimeout,
+		queryRunners:     make(map[ClusterMember]*PgQueryRunner),
+	}
+}
+
+// ConfigureWalReceiveCluster returns nil if WALG_RECEIVE_CLUSTER_HOSTS is not set
+func ConfigureWalReceiveCluster() (*WalReceiveCluster, error) {
+	hosts := viper.GetString(conf.PgReceiveClusterHosts)
+	if hosts == "" {
+		return nil, nil
+	}
+	members, err := ParseClusterMembers(hosts)
+	if err != nil {
+		return nil, err
+	}
+	reconnectTimeout, err := conf.GetDurationSetting(conf.PgReceiveReconnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewWalReceiveCluster(members, internal.GetPgSlotName(), reconnectTimeout), nil
+}
+
+func (cluster *WalReceiveCluster) getQueryRunner(ctx context.Context, member ClusterMember) (*PgQueryRunner, error) {
+	if queryRunner, ok := cluster.queryRunners[member]; ok {
+		return queryRunner, nil
+	}
+	conn, err := member.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queryRunner, err := NewPgQueryRunner(ctx, conn)
+	if err != nil {
+		_ = conn.Close(context.Background())
+		return nil, err
+	}
+	cluster.queryRunners[member] = queryRunner
+	return queryRunner, nil
+}
+
+// dropQueryRunner closes the connection to the member, it's opened again on the next use
+func (cluster *WalReceiveCluster) dropQueryRunner(member ClusterMember) {
+	if queryRunner, ok := cluster.queryRunners[member]; ok {
+		_ = queryRunner.Connection.Close(context.Background())
+		delete(cluster.queryRunners, member)
+	}
+}
+
+func (cluster *WalReceiveCluster) Close() {
+	for member := range cluster.queryRunners {
+		cluster.dropQueryRunner(member)
+	}
+}
+
+// FindPrimary polls the members until one of them is not in recovery or the reconnect timeout expires
+func (cluster *WalReceiveCluster) FindPrimary(ctx context.Context) (ClusterMember, error) {
+	deadline := time.Now().Add(cluster.reconnectTimeout)
+	for {
+		for _, member := range cluster.members {
+			queryRunner, err := cluster.getQueryRunner(ctx, member)
+			if err != nil {
+				tracelog.DebugLogger.Printf("Cluster member %s is not available: %v\n", member, err)
+				continue
+			}
+			standby, err := queryRunner.IsStandby(ctx)
+			if err != nil {
+				tracelog.DebugLogger.Printf("Failed to check cluster member %s: %v\n", member, err)
+				cluster.dropQueryRunner(member)
+				continue
+			}
+			if !standby {
+				return member, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return ClusterMember{}, errors.Errorf("no primary found among the cluster members %v in %s",
+				cluster.members, cluster.reconnectTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ClusterMember{}, ctx.Err()
+		case <-time.After(clusterPollInterval):
+		}
+	}
+}
+
+// EnsureSlots creates the replication slot on the members that don't have it. The unavailable members
+// are skipped, the slot is created on them when they come back and the slots are advanced.
+func (cluster *WalReceiveCluster) EnsureSlots(ctx context.Context) {
+	for _, member := range cluster.members {
+		if _, err := cluster.ensureSlot(ctx, member); err != nil {
+			tracelog.WarningLogger.Printf("Failed to ensure replication slot '%s' on %s: %v\n",
+				cluster.slotName, member, err)
+			cluster.dropQueryRunner(member)
+		}
+	}
+}
+
+func (cluster *WalReceiveCluster) ensureSlot(ctx context.Context, member ClusterMember) (PhysicalSlot, error) {
+	queryRunner, err := cluster.getQueryRunner(ctx, member)
+	if err != nil {
+		return PhysicalSlot{}, err
+	}
+	slot, err := queryRunner.GetPhysicalSlotInfo(ctx, cluster.slotName)
+	if err != nil || slot.Exists {
+		return slot, err
+	}
+	tracelog.InfoLogger.Printf("Creating replication slot '%s' on %s\n", cluster.slotName, member)
+	err = queryRunner.CreatePhysicalSlot(ctx, cluster.slotName)
+	if err != nil {
+		return PhysicalSlot{}, err
+	}
+	return queryRunner.GetPhysicalSlotInfo(ctx, cluster.slotName)
+}
+
+// OnSegmentArchived checks that the member WAL is received from is still the primary and advances the slots
+// on the other members to the archived LSN. The slot on the primary is advanced by the replication feedback.
+func (cluster *WalReceiveCluster) OnSegmentArchived(ctx context.Context, primary ClusterMember, archivedLSN LSN) error {
+	queryRunner, err := cluster.getQueryRunner(ctx, primary)
+	if err != nil {
+		return err
+	}
+	standby, err := queryRunner.IsStandby(ctx)
+	if err != nil {
+		return err
+	}
+	if standby {
+		return errors.Errorf("cluster member %s is not the primary anymore", primary)
+	}
+
+	for _, member := range cluster.members {
+		if member == primary {
+			continue
+		}
+		if err := cluster.advanceSlot(ctx, member, archivedLSN); err != nil {
+			tracelog.WarningLogger.Printf("Failed to advance replication slot '%s' on %s: %v\n",
+				cluster.slotName, member, err)
+			cluster.dropQueryRunner(member)
+		}
+	}
+	return nil
+}
+
+func (cluster *WalReceiveCluster) advanceSlot(ctx context.Context, member ClusterMember, lsn LSN) error {
+	slot, err := cluster.ensureSlot(ctx, member)
+	if err != nil {
+		return err
+	}
+	if LSN(slot.RestartLSN) >= lsn {
+		return nil
+	}
+	tracelog.DebugLogger.Printf("Advancing replication slot '%s' on %s from %s to %s\n",
+		cluster.slotName, member, slot.RestartLSN, lsn)
+	return cluster.queryRunners[member].AdvancePhysicalSlot(ctx, cluster.slotName, lsn)
+}
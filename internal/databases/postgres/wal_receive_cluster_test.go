@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClusterMembers(t *testing.T) {
+	members, err := ParseClusterMembers("pg1:5433, pg2 ,/var/run/postgresql:5434,")
+	require.NoError(t, err)
+	assert.Equal(t, []ClusterMember{
+		{Host: "pg1", Port: 5433},
+		{Host: "pg2", Port: 5432},
+		{Host: "/var/run/postgresql", Port: 5434},
+	}, members)
+}
+
+func TestParseClusterMembers_Invalid(t *testing.T) {
+	_, err := ParseClusterMembers("pg1:port")
+	assert.Error(t, err)
+	_, err = ParseClusterMembers(":5432")
+	assert.Error(t, err)
+	_, err = ParseClusterMembers(" , ")
+	assert.Error(t, err)
+}
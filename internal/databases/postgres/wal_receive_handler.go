@@ -3,9 +3,11 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
@@ -23,19 +25,18 @@ const (
 NOTE: Preventing a WAL gap is a complex one (also not 100% fixed with arch_command).
 * Using replication slot helps, but that should be created and maintained
   by wal-g on standby's too (making sure unconsumed wals are preserved on
-  potential new masters too)
-* Using sync replication is another option, but non-promotable, and we
-  should locally cache to disconnect S3 performance from database performance
+  potential new masters too). This is done when WALG_RECEIVE_CLUSTER_HOSTS is set:
+  the slot is created on all cluster members and advanced to the last archived LSN,
+  and WAL is received from the new primary after the failover.
+* Using sync replication is another option, but non-promotable. It's done when
+  WALG_RECEIVE_SYNC_MODE is set: the segment being received is uploaded in .partial parts
+  and the flush position is reported after the upload, so commits wait for the storage.
 * Making something that checks 'what is in wal-g s repo' vs 'where postgres is
   is another option, but when wal-g is no longer running there would be nothing
   preventing postgres from advancing and cleaning, which is what slots are for.
-Cleanest would probably be to create the slot on all postgres instances and advance all of them.
-Can be done, but first, lets focus on creating wal files from repl msg...
 
 Things to do (future):
 * unittests for queryrunner code
-* upgrade to pgx/v4
-* we might want to add a feature to have wal-g advance multiple slots to support HA setups natively
 * Test with different wal size (>=pg11)
 */
 
@@ -47,79 +48,214 @@ func (err genericWalReceiveError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+// walReceiveState is the position of wal-receive which is kept across the reconnects to the cluster members
+type walReceiveState struct {
+	// xLogPos is the start of the first segment which is not archived yet, it's taken from the slot if zero
+	xLogPos  pglogrepl.LSN
+	timeline uint32
+	// partialName, partialUploadedIndex and partialParts are the segment uploaded as the .partial parts in the sync
+	// mode, the size of the uploaded data and the offsets of the parts, so that the upload continues after the reconnect
+	partialName          string
+	partialUploadedIndex int
+	partialParts         []int
+}
+
 // HandleWALReceive is invoked to receive wal with a replication connection and push
 func HandleWALReceive(ctx context.Context, uploader *WalUploader) {
-	// Connect to postgres.
-	var XLogPos pglogrepl.LSN
-	var segment *WalSegment
-
 	uploader.ChangeDirectory(utility.WalPath)
 
-	slot, walSegmentBytes, err := getCurrentWalInfo(ctx)
+	partialUploader, err := ConfigurePartialSegmentUploader(uploader)
 	tracelog.ErrorLogger.FatalOnError(err)
+	cluster, err := ConfigureWalReceiveCluster()
+	tracelog.ErrorLogger.FatalOnError(err)
+	if cluster == nil {
+		err = receiveWAL(ctx, uploader, partialUploader, nil, nil, &walReceiveState{})
+		tracelog.ErrorLogger.FatalOnError(err)
+		return
+	}
+	defer cluster.Close()
+	handleClusterWALReceive(ctx, uploader, partialUploader, cluster)
+}
+
+// handleClusterWALReceive receives WAL from the primary of the cluster and reconnects to the new primary
+// when the stream is lost. It gives up if there is no progress for the reconnect timeout.
+func handleClusterWALReceive(ctx context.Context,
+	uploader *WalUploader,
+	partialUploader *PartialSegmentUploader,
+	cluster *WalReceiveCluster) {
+	state := &walReceiveState{}
+	lastProgress := time.Now()
+	lastXLogPos := state.xLogPos
+	for {
+		primary, err := cluster.FindPrimary(ctx)
+		tracelog.ErrorLogger.FatalOnError(err)
+		tracelog.InfoLogger.Printf("Receiving WAL from the primary %s\n", primary)
+		cluster.EnsureSlots(ctx)
+
+		err = receiveWAL(ctx, uploader, partialUploader, cluster, &primary, state)
+		if ctx.Err() != nil {
+			tracelog.ErrorLogger.FatalOnError(err)
+		}
+		tracelog.WarningLogger.Printf("WAL stream from %s is lost: %v\n", primary, err)
+		cluster.dropQueryRunner(primary)
+
+		if state.xLogPos != lastXLogPos {
+			lastXLogPos = state.xLogPos
+			lastProgress = time.Now()
+		} else if time.Since(lastProgress) > cluster.reconnectTimeout {
+			tracelog.ErrorLogger.Fatalf("No WAL received for %s, last error: %v\n", cluster.reconnectTimeout, err)
+		}
+		select {
+		case <-ctx.Done():
+			tracelog.ErrorLogger.FatalOnError(ctx.Err())
+		case <-time.After(clusterPollInterval):
+		}
+	}
+}
+
+// receiveWAL streams WAL from the primary, which is the one from the PG* settings if it's nil, and uploads it
+// until an error occurs. The partial segments are uploaded too if partialUploader is not nil.
+func receiveWAL(ctx context.Context,
+	uploader *WalUploader,
+	partialUploader *PartialSegmentUploader,
+	cluster *WalReceiveCluster,
+	primary *ClusterMember,
+	state *walReceiveState) error {
+	slot, walSegmentBytes, err := getCurrentWalInfo(ctx, primary)
+	if err != nil {
+		return err
+	}
 	tracelog.DebugLogger.Printf("WAL segment bytes: %d", walSegmentBytes)
 
-	conn, err := pgconn.Connect(ctx, "replication=yes")
-	tracelog.ErrorLogger.FatalOnError(err)
+	conn, err := connectReplication(ctx, primary)
+	if err != nil {
+		return err
+	}
 	defer conn.Close(context.Background())
 
 	sysident, err := pglogrepl.IdentifySystem(ctx, conn)
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return err
+	}
 
-	if slot.Exists {
-		XLogPos = slot.RestartLSN
-	} else {
+	if !slot.Exists {
 		tracelog.InfoLogger.Println("Trying to create the replication slot")
 		_, err = pglogrepl.CreateReplicationSlot(ctx, conn, slot.Name, "",
 			pglogrepl.CreateReplicationSlotOptions{Mode: pglogrepl.PhysicalReplication})
-		tracelog.ErrorLogger.FatalOnError(err)
-		XLogPos = sysident.XLogPos
+		if err != nil {
+			return err
+		}
+	}
+	if state.xLogPos == 0 {
+		if slot.Exists {
+			state.xLogPos = slot.RestartLSN
+		} else {
+			state.xLogPos = sysident.XLogPos
+		}
+	}
+
+	// Upload the history of the timelines that were skipped while there was no connection
+	if state.timeline != 0 {
+		for timeline := state.timeline + 1; timeline < uint32(sysident.Timeline); timeline++ {
+			err = uploadTimelineHistory(ctx, conn, uploader, timeline)
+			if err != nil {
+				tracelog.WarningLogger.Printf("Failed to upload history of timeline %d: %v\n", timeline, err)
+			}
+		}
 	}
 
 	// Get timeline for XLogPos from historyfile with helper function
-	timeline, err := getStartTimeline(ctx, conn, uploader, uint32(sysident.Timeline), XLogPos)
-	tracelog.ErrorLogger.FatalOnError(err)
+	timeline, err := getStartTimeline(ctx, conn, uploader, uint32(sysident.Timeline), state.xLogPos)
+	if err != nil {
+		return err
+	}
+	state.timeline = timeline
 
-	segment = NewWalSegment(timeline, XLogPos, walSegmentBytes)
-	startReplication(ctx, conn, segment, slot.Name)
+	segment := NewWalSegment(timeline, state.xLogPos, walSegmentBytes)
+	if segment.Name() == state.partialName {
+		segment.uploadedIndex, segment.uploadedParts = state.partialUploadedIndex, state.partialParts
+	}
+	defer func() {
+		state.partialName, state.partialUploadedIndex, state.partialParts = segment.Name(), segment.uploadedIndex, segment.uploadedParts
+	}()
+	err = startReplication(ctx, conn, segment, slot.Name)
+	if err != nil {
+		return err
+	}
 	for {
-		streamResult, err := segment.Stream(ctx, conn, StandbyMessageTimeout)
-		tracelog.ErrorLogger.FatalOnError(err)
+		streamResult, err := segment.Stream(ctx, conn, StandbyMessageTimeout, partialUploader)
+		if err != nil {
+			return err
+		}
 		tracelog.DebugLogger.Printf("Successfully received wal segment %s: ", segment.Name())
 
 		switch streamResult {
 		case ProcessMessageOK:
 			// segment is a regular segemnt. Write, and create a new for this timeline.
-			err = uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(segment, segment.Name()))
-			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploadRemoteWalMetadata(ctx, segment.Name(), uploader.Uploader)
-			tracelog.ErrorLogger.FatalOnError(err)
-			XLogPos = segment.endLSN
+			err = uploadReceivedFile(ctx, uploader, segment, segment.Name())
+			if err != nil {
+				return err
+			}
+			state.xLogPos = segment.endLSN
+			if partialUploader != nil {
+				partialUploader.DeletePartial(ctx, segment)
+			}
+			if cluster != nil {
+				err = cluster.OnSegmentArchived(ctx, *primary, LSN(state.xLogPos))
+				if err != nil {
+					return err
+				}
+			}
 			segment, err = segment.NextWalSegment()
-			tracelog.ErrorLogger.FatalOnError(err)
+			if err != nil {
+				return err
+			}
 		case ProcessMessageCopyDone:
 			// segment is a partial. Write, and create a new for the next timeline.
-			err = uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(segment, segment.Name()))
-			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploadRemoteWalMetadata(ctx, segment.Name(), uploader.Uploader)
-			tracelog.ErrorLogger.FatalOnError(err)
+			err = uploadReceivedFile(ctx, uploader, segment, segment.Name())
+			if err != nil {
+				return err
+			}
+			if partialUploader != nil {
+				partialUploader.DeletePartial(ctx, segment)
+			}
 			timeline++
-			timelinehistfile, err := pglogrepl.TimelineHistory(ctx, conn, int32(timeline))
-			tracelog.ErrorLogger.FatalOnError(err)
-			tlh, err := NewTimeLineHistFile(timeline, timelinehistfile.FileName, timelinehistfile.Content)
-			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(tlh, tlh.Name()))
-			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploadRemoteWalMetadata(ctx, tlh.Name(), uploader.Uploader)
-			tracelog.ErrorLogger.FatalOnError(err)
-			segment = NewWalSegment(timeline, XLogPos, walSegmentBytes)
-			startReplication(ctx, conn, segment, slot.Name)
+			err = uploadTimelineHistory(ctx, conn, uploader, timeline)
+			if err != nil {
+				return err
+			}
+			state.timeline = timeline
+			segment = NewWalSegment(timeline, state.xLogPos, walSegmentBytes)
+			err = startReplication(ctx, conn, segment, slot.Name)
+			if err != nil {
+				return err
+			}
 		default:
-			tracelog.ErrorLogger.FatalOnError(errors.Errorf("Unexpected result from WalSegment.Stream() %v", streamResult))
+			return errors.Errorf("Unexpected result from WalSegment.Stream() %v", streamResult)
 		}
 	}
 }
 
+func uploadReceivedFile(ctx context.Context, uploader *WalUploader, reader io.Reader, name string) error {
+	err := uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(reader, name))
+	if err != nil {
+		return err
+	}
+	return uploadRemoteWalMetadata(ctx, name, uploader.Uploader)
+}
+
+func uploadTimelineHistory(ctx context.Context, conn *pgconn.PgConn, uploader *WalUploader, timeline uint32) error {
+	timelinehistfile, err := pglogrepl.TimelineHistory(ctx, conn, int32(timeline))
+	if err != nil {
+		return err
+	}
+	tlh, err := NewTimeLineHistFile(timeline, timelinehistfile.FileName, timelinehistfile.Content)
+	if err != nil {
+		return err
+	}
+	return uploadReceivedFile(ctx, uploader, tlh, tlh.Name())
+}
+
 func getStartTimeline(ctx context.Context,
 	conn *pgconn.PgConn,
 	uploader *WalUploader,
@@ -131,9 +267,13 @@ func getStartTimeline(ctx context.Context,
 	timelinehistfile, err := pglogrepl.TimelineHistory(ctx, conn, int32(systemTimeline))
 	if err == nil {
 		tlh, err := NewTimeLineHistFile(systemTimeline, timelinehistfile.FileName, timelinehistfile.Content)
-		tracelog.ErrorLogger.FatalOnError(err)
+		if err != nil {
+			return 0, err
+		}
 		err = uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(tlh, tlh.Name()))
-		tracelog.ErrorLogger.FatalOnError(err)
+		if err != nil {
+			return 0, err
+		}
 		return tlh.LSNToTimeLine(xLogPos)
 	}
 	if pgErr, ok := err.(*pgconn.PgError); ok {
@@ -144,19 +284,35 @@ func getStartTimeline(ctx context.Context,
 	return 0, nil
 }
 
-func startReplication(ctx context.Context, conn *pgconn.PgConn, segment *WalSegment, slotName string) {
+func startReplication(ctx context.Context, conn *pgconn.PgConn, segment *WalSegment, slotName string) error {
 	tracelog.DebugLogger.Printf("Starting replication from %s: ", segment.StartLSN)
 	err := pglogrepl.StartReplication(ctx, conn, slotName, segment.StartLSN,
 		pglogrepl.StartReplicationOptions{Timeline: int32(segment.TimeLine), Mode: pglogrepl.PhysicalReplication})
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return err
+	}
 	tracelog.DebugLogger.Println("Started replication")
+	return nil
 }
 
-func getCurrentWalInfo(ctx context.Context) (slot PhysicalSlot, walSegmentBytes uint64, err error) {
+// connectReplication opens the replication connection to the primary, or with the PG* settings if it's nil
+func connectReplication(ctx context.Context, primary *ClusterMember) (*pgconn.PgConn, error) {
+	if primary != nil {
+		return primary.connectReplication(ctx)
+	}
+	return pgconn.Connect(ctx, "replication=yes")
+}
+
+func getCurrentWalInfo(ctx context.Context, primary *ClusterMember) (slot PhysicalSlot, walSegmentBytes uint64, err error) {
 	slotName := internal.GetPgSlotName()
 
 	// Creating a temporary connection to read slot info and wal_segment_size
-	tmpConn, err := Connect(ctx)
+	var tmpConn *pgx.Conn
+	if primary != nil {
+		tmpConn, err = primary.connect(ctx)
+	} else {
+		tmpConn, err = Connect(ctx)
+	}
 	if err != nil {
 		return
 	}
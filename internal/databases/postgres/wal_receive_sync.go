@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// PartialSegmentUploader uploads the incomplete WAL segment in .partial parts, so that the received WAL
+// is in the storage before its flush position is reported to Postgres. This lets wal-receive be
+// a synchronous standby: a commit waits until its WAL is uploaded.
+//
+// Each part holds only the WAL received since the previous upload, and is named by its offset in the segment,
+// see partialPartName. wal-fetch assembles the parts into the .partial segment.
+type PartialSegmentUploader struct {
+	uploader *WalUploader
+	// interval is the maximum time the received WAL waits for the upload
+	interval time.Duration
+	// size is the amount of the received WAL which is uploaded without waiting for the interval
+	size int
+}
+
+func NewPartialSegmentUploader(uploader *WalUploader, interval time.Duration, size int) *PartialSegmentUploader {
+	return &PartialSegmentUploader{uploader: uploader, interval: interval, size: size}
+}
+
+// ConfigurePartialSegmentUploader returns nil if WALG_RECEIVE_SYNC_MODE is not set
+func ConfigurePartialSegmentUploader(uploader *WalUploader) (*PartialSegmentUploader, error) {
+	if !viper.GetBool(conf.PgReceiveSyncMode) {
+		return nil, nil
+	}
+	interval, err := conf.GetDurationSetting(conf.PgReceivePartialUploadInterval)
+	if err != nil {
+		return nil, err
+	}
+	size := int(viper.GetSizeInBytes(conf.PgReceivePartialUploadSize))
+	return NewPartialSegmentUploader(uploader, interval, size), nil
+}
+
+// isDue returns true if the received WAL that is not uploaded yet is large or old enough.
+// The complete segment is uploaded by wal-receive itself.
+func (partialUploader *PartialSegmentUploader) isDue(seg *WalSegment, now time.Time) bool {
+	if !seg.hasPendingData() {
+		return false
+	}
+	return seg.writeIndex-seg.uploadedIndex >= partialUploader.size || !now.Before(partialUploader.deadline(seg))
+}
+
+// deadline returns the time by which the received WAL should be uploaded
+func (partialUploader *PartialSegmentUploader) deadline(seg *WalSegment) time.Time {
+	return seg.pendingSince.Add(partialUploader.interval)
+}
+
+// Upload uploads the WAL received into the segment since the previous upload as the next .partial part.
+func (partialUploader *PartialSegmentUploader) Upload(ctx context.Context, seg *WalSegment) error {
+	uploadedIndex, writeIndex := seg.uploadedIndex, seg.writeIndex
+	name := partialPartName(seg.walFileName(), uploadedIndex)
+	tracelog.DebugLogger.Printf("Uploading %s up to %s\n", name, seg.StartLSN+pglogrepl.LSN(writeIndex))
+	part := bytes.NewReader(seg.data[uploadedIndex:writeIndex])
+	err := partialUploader.uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(part, name))
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(seg.uploadedParts, uploadedIndex) {
+		seg.uploadedParts = append(seg.uploadedParts, uploadedIndex)
+	}
+	seg.uploadedIndex = writeIndex
+	seg.pendingSince = time.Time{}
+	return nil
+}
+
+// DeletePartial deletes the .partial parts of the segment after the complete one is uploaded.
+// The failure is not fatal: wal-fetch prefers the complete file anyway.
+func (partialUploader *PartialSegmentUploader) DeletePartial(ctx context.Context, seg *WalSegment) {
+	if len(seg.uploadedParts) == 0 {
+		return
+	}
+	ext := partialUploader.uploader.Compression().FileExtension()
+	parts := make([]storage.Object, 0, len(seg.uploadedParts))
+	for _, offset := range seg.uploadedParts {
+		name := utility.AddFileExtension(partialPartName(seg.walFileName(), offset), ext)
+		parts = append(parts, storage.NewLocalObject(name, time.Time{}, 0))
+	}
+	err := partialUploader.uploader.Folder().DeleteObjects(ctx, parts)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to delete the .partial parts of %s: %v\n", seg.walFileName(), err)
+	}
+}
+
+// partialPartName provides the name of the .partial part which starts at the offset in the segment. The parts are
+// contiguous, so the next part is named by the end of the previous one.
+func partialPartName(walFileName string, offset int) string {
+	return fmt.Sprintf("%s.partial.%08X", walFileName, offset)
+}
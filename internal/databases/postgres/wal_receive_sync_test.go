@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const syncTestSegmentBytes = 16 * 1024 * 1024
+
+func TestPartialSegmentUploader_IsDue(t *testing.T) {
+	partialUploader := NewPartialSegmentUploader(nil, time.Second, 1024)
+	now := time.Now()
+	seg := NewWalSegment(1, 0x3000000, syncTestSegmentBytes)
+	assert.False(t, partialUploader.isDue(seg, now))
+
+	seg.writeIndex = 100
+	seg.pendingSince = now
+	assert.False(t, partialUploader.isDue(seg, now))
+	assert.True(t, partialUploader.isDue(seg, now.Add(time.Second)))
+
+	seg.writeIndex = 1024
+	assert.True(t, partialUploader.isDue(seg, now))
+
+	seg.uploadedIndex = 1024
+	assert.False(t, partialUploader.isDue(seg, now.Add(time.Second)))
+
+	seg.writeIndex = syncTestSegmentBytes
+	assert.False(t, partialUploader.isDue(seg, now.Add(time.Second)))
+}
+
+func TestWalSegment_StandbyStatus(t *testing.T) {
+	seg := NewWalSegment(1, 0x3000100, syncTestSegmentBytes)
+	seg.writeIndex = 0x200
+	seg.uploadedIndex = 0x100
+
+	assert.Equal(t, pglogrepl.StandbyStatusUpdate{WALWritePosition: 0x3000000}, seg.standbyStatus(false))
+	assert.Equal(t, pglogrepl.StandbyStatusUpdate{
+		WALWritePosition: 0x3000200,
+		WALFlushPosition: 0x3000100,
+		WALApplyPosition: 0x3000100,
+	}, seg.standbyStatus(true))
+
+	seg.writeIndex = 0
+	assert.Equal(t, pglogrepl.LSN(0x3000100), seg.standbyStatus(true).WALWritePosition)
+}
+
+func TestPartialSegmentUploader_UploadsOnlyNewWAL(t *testing.T) {
+	viper.Set(conf.PgFetchPartialWal, true)
+	defer viper.Set(conf.PgFetchPartialWal, nil)
+	rootFolder := memory.NewFolder("in_memory/", memory.NewKVS())
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	partialUploader := NewPartialSegmentUploader(
+		NewWalUploader(internal.NewRegularUploader(lz4.Compressor{}, walFolder), nil), time.Second, 1024)
+	seg := NewWalSegment(1, 0x3000000, WalSegmentSize)
+	rand.New(rand.NewSource(1)).Read(seg.data[:3000])
+
+	seg.writeIndex = 1000
+	require.NoError(t, partialUploader.Upload(t.Context(), seg))
+	seg.writeIndex = 3000
+	require.NoError(t, partialUploader.Upload(t.Context(), seg))
+
+	objects, _, err := walFolder.ListFolder(t.Context())
+	require.NoError(t, err)
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		names = append(names, object.GetName())
+	}
+	assert.ElementsMatch(t, []string{
+		"000000010000000000000003.partial.00000000.lz4",
+		"000000010000000000000003.partial.000003E8.lz4",
+	}, names)
+	assert.Equal(t, []int{0, 1000}, seg.uploadedParts)
+	assert.Equal(t, 3000, seg.uploadedIndex)
+
+	location := filepath.Join(t.TempDir(), "000000010000000000000003")
+	err = HandleWALFetch(t.Context(), internal.NewFolderReader(rootFolder), "000000010000000000000003", location, NopPrefetcher{})
+	require.NoError(t, err)
+	restored, err := os.ReadFile(location)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(seg.data, restored))
+
+	partialUploader.DeletePartial(t.Context(), seg)
+	objects, _, err = walFolder.ListFolder(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestPartialSegmentUploader_DeletePartialWithoutParts(t *testing.T) {
+	walFolder := memory.NewFolder("in_memory/", memory.NewKVS())
+	require.NoError(t, walFolder.PutObject(t.Context(), "000000010000000000000003.lz4", bytes.NewReader(nil)))
+	partialUploader := NewPartialSegmentUploader(
+		NewWalUploader(internal.NewRegularUploader(lz4.Compressor{}, walFolder), nil), time.Second, 1024)
+
+	partialUploader.DeletePartial(t.Context(), NewWalSegment(1, 0x3000000, WalSegmentSize))
+
+	objects, err := storage.ListFolderRecursively(t.Context(), walFolder)
+	require.NoError(t, err)
+	assert.Len(t, objects, 1)
+}
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/multistorage"
+)
+
+// HandleWALRepair runs a single pass of copying the WAL segments recorded to the repair journal by wal-push to the
+// storages they are missing from.
+func HandleWALRepair(ctx context.Context, multiSt *multistorage.Storage) error {
+	window, err := conf.GetDurationSetting(conf.FailoverStoragesRepairWindow)
+	if err != nil {
+		return fmt.Errorf("get the WAL repair window: %w", err)
+	}
+	journal := multistorage.NewRepairJournal(multistorage.DefaultRepairJournalPath())
+	report, err := multistorage.RepairMissingObjects(ctx, multiSt.RootFolder(), journal, window)
+	if err != nil {
+		return fmt.Errorf("repair WAL segments: %w", err)
+	}
+	tracelog.InfoLogger.Printf("WAL segments repaired: %s", report)
+	return nil
+}
+
+// startWALRepair starts catching up the storages that miss some WAL segments if WAL is written to several storages
+// with a quorum.
+func startWALRepair(ctx context.Context, multiSt *multistorage.Storage) {
+	if viper.GetInt(conf.FailoverStoragesPutQuorum) <= 0 {
+		return
+	}
+	interval, err := conf.GetDurationSetting(conf.FailoverStoragesRepairInterval)
+	if err != nil {
+		tracelog.ErrorLogger.Fatalf("Failed to get the WAL repair interval: %v", err)
+	}
+	window, err := conf.GetDurationSetting(conf.FailoverStoragesRepairWindow)
+	if err != nil {
+		tracelog.ErrorLogger.Fatalf("Failed to get the WAL repair window: %v", err)
+	}
+	if interval <= 0 {
+		return
+	}
+	journal := multistorage.NewRepairJournal(multistorage.DefaultRepairJournalPath())
+	go multistorage.RunRepairLoop(ctx, multiSt.RootFolder(), journal, interval, window)
+}
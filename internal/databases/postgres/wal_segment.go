@@ -32,6 +32,12 @@ type WalSegment struct {
 	readIndex       int
 	writeIndex      int
 	lastMsg         *pgproto3.BackendMessage
+	// uploadedIndex is the end of the data uploaded as the .partial parts in the sync mode
+	uploadedIndex int
+	// uploadedParts are the offsets of the .partial parts uploaded in the sync mode
+	uploadedParts []int
+	// pendingSince is the time of the first data received after the last upload of a .partial part
+	pendingSince time.Time
 }
 
 // The ProcessMessageResult is an enum representing possible results from the methods
@@ -95,11 +101,15 @@ func (seg *WalSegment) NextWalSegment() (*WalSegment, error) {
 func (seg *WalSegment) Name() string {
 	// Example LSN -> Name:
 	// '0/2A33FE00' -> '00000001000000000000002A'
-	segID := uint64(seg.StartLSN) / seg.walSegmentBytes
 	if seg.isComplete() {
-		return formatWALFileName(seg.TimeLine, segID)
+		return seg.walFileName()
 	}
-	return formatWALFileName(seg.TimeLine, segID) + ".partial"
+	return seg.walFileName() + ".partial"
+}
+
+// walFileName returns the filename of the complete wal segment, even if the segment is not complete yet.
+func (seg *WalSegment) walFileName() string {
+	return formatWALFileName(seg.TimeLine, uint64(seg.StartLSN)/seg.walSegmentBytes)
 }
 
 // processMessage is a method that processes a message from Postgres and copies its data
@@ -146,6 +156,9 @@ func (seg *WalSegment) processMessage(message pgproto3.BackendMessage) (ProcessM
 			}
 			copiedBytes := copy(seg.data[seg.writeIndex:], xld.WALData[messageOffset:])
 			seg.writeIndex += copiedBytes
+			if copiedBytes > 0 && seg.pendingSince.IsZero() {
+				seg.pendingSince = time.Now()
+			}
 			if copiedBytes < len(xld.WALData[messageOffset:]) {
 				seg.lastMsg = &message
 			}
@@ -159,7 +172,12 @@ func (seg *WalSegment) processMessage(message pgproto3.BackendMessage) (ProcessM
 }
 
 // Stream is a helper function to retrieve messages from Postgres and have them processed by processMessage().
-func (seg *WalSegment) Stream(ctx context.Context, conn *pgconn.PgConn, standbyMessageTimeout time.Duration) (ProcessMessageResult, error) {
+// If partialUploader is not nil, the received data is uploaded as the .partial parts and the flush position
+// is reported only after the upload.
+func (seg *WalSegment) Stream(ctx context.Context,
+	conn *pgconn.PgConn,
+	standbyMessageTimeout time.Duration,
+	partialUploader *PartialSegmentUploader) (ProcessMessageResult, error) {
 	// Inspired by https://github.com/jackc/pglogrepl/blob/master/example/pglogrepl_demo/main.go
 	// And https://www.postgresql.org/docs/12/protocol-replication.html
 
@@ -167,22 +185,36 @@ func (seg *WalSegment) Stream(ctx context.Context, conn *pgconn.PgConn, standbyM
 	var msg pgproto3.BackendMessage
 	nextStandbyMessageDeadline := time.Now()
 	for {
+		if partialUploader != nil && partialUploader.isDue(seg, time.Now()) {
+			err = partialUploader.Upload(ctx, seg)
+			if err != nil {
+				return ProcessMessageUnknown, err
+			}
+			// Report the new flush position right away, the commits on the primary wait for it
+			nextStandbyMessageDeadline = time.Time{}
+		}
 		if time.Now().After(nextStandbyMessageDeadline) {
-			err = pglogrepl.SendStandbyStatusUpdate(ctx,
-				conn,
-				pglogrepl.StandbyStatusUpdate{WALWritePosition: seg.StartLSN})
-			tracelog.ErrorLogger.FatalOnError(err)
+			err = pglogrepl.SendStandbyStatusUpdate(ctx, conn, seg.standbyStatus(partialUploader != nil))
+			if err != nil {
+				return ProcessMessageUnknown, err
+			}
 			tracelog.DebugLogger.Println("Sent Standby status message")
 			nextStandbyMessageDeadline = time.Now().Add(standbyMessageTimeout)
 		}
 
-		deadlineCtx, cancel := context.WithDeadline(ctx, nextStandbyMessageDeadline)
+		receiveDeadline := nextStandbyMessageDeadline
+		if partialUploader != nil && seg.hasPendingData() && partialUploader.deadline(seg).Before(receiveDeadline) {
+			receiveDeadline = partialUploader.deadline(seg)
+		}
+		deadlineCtx, cancel := context.WithDeadline(ctx, receiveDeadline)
 		msg, err = conn.ReceiveMessage(deadlineCtx)
 		cancel()
 		if pgconn.Timeout(err) {
 			continue
 		}
-		tracelog.ErrorLogger.FatalOnError(err)
+		if err != nil {
+			return ProcessMessageUnknown, err
+		}
 
 		result, err := seg.processMessage(msg)
 		switch result {
@@ -194,7 +226,9 @@ func (seg *WalSegment) Stream(ctx context.Context, conn *pgconn.PgConn, standbyM
 			return result, err
 		case ProcessMessageCopyDone:
 			cdr, err := pglogrepl.SendStandbyCopyDone(ctx, conn)
-			tracelog.ErrorLogger.FatalOnError(err)
+			if err != nil {
+				return result, err
+			}
 			tracelog.DebugLogger.Printf("CopyDoneResult => %v", cdr)
 			return result, nil
 		case ProcessMessageReplyRequested:
@@ -213,6 +247,31 @@ func (seg *WalSegment) Stream(ctx context.Context, conn *pgconn.PgConn, standbyM
 	}
 }
 
+// standbyStatus returns the positions reported to Postgres. Without the sync mode the segment is reported
+// only when it's complete and uploaded. In the sync mode the flush position is the end of the uploaded
+// uploaded .partial parts, so a synchronous commit waits until its WAL is in the storage.
+func (seg *WalSegment) standbyStatus(sync bool) pglogrepl.StandbyStatusUpdate {
+	if !sync {
+		return pglogrepl.StandbyStatusUpdate{WALWritePosition: seg.StartLSN}
+	}
+	flushPosition := seg.StartLSN + pglogrepl.LSN(seg.uploadedIndex)
+	writePosition := seg.StartLSN + pglogrepl.LSN(seg.writeIndex)
+	if writePosition < flushPosition {
+		// The .partial parts were uploaded before the reconnect
+		writePosition = flushPosition
+	}
+	return pglogrepl.StandbyStatusUpdate{
+		WALWritePosition: writePosition,
+		WALFlushPosition: flushPosition,
+		WALApplyPosition: flushPosition,
+	}
+}
+
+// hasPendingData returns true if the incomplete segment has data which is not uploaded as a .partial part
+func (seg *WalSegment) hasPendingData() bool {
+	return !seg.isComplete() && seg.writeIndex > seg.uploadedIndex
+}
+
 // isComplete is a helper function which returns true when all data is added
 func (seg *WalSegment) isComplete() bool {
 	return seg.StartLSN+pglogrepl.LSN(seg.writeIndex) >= seg.endLSN
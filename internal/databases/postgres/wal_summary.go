@@ -0,0 +1,303 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	// walSummarizationTimeout is how long the backup waits for the WAL summarizer to reach the backup start LSN
+	walSummarizationTimeout      = time.Minute
+	walSummarizationPollInterval = time.Second
+
+	mainForkNumber = 0
+)
+
+// WalSummary describes a WAL summary file from pg_wal/summaries, it lists the blocks modified by the WAL
+// in the [StartLSN, EndLSN) range of the timeline
+type WalSummary struct {
+	Timeline uint32
+	StartLSN LSN
+	EndLSN   LSN
+}
+
+// WalSummaryBlock is a block listed in a WAL summary. The limit block means that the relation fork was truncated
+// to this block or created, so every block from it on may have changed.
+type WalSummaryBlock struct {
+	Location     walparser.BlockLocation
+	ForkNumber   int16
+	IsLimitBlock bool
+}
+
+type WalSummariesNotCoverRangeError struct {
+	error
+}
+
+func newWalSummariesNotCoverRangeError(from, to LSN) WalSummariesNotCoverRangeError {
+	return WalSummariesNotCoverRangeError{
+		errors.Errorf("available WAL summaries do not cover the range from %s to %s", from, to)}
+}
+
+func (err WalSummariesNotCoverRangeError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// timelineRange is the part [StartLSN, EndLSN) of the WAL history where the timeline is current
+type timelineRange struct {
+	Timeline uint32
+	StartLSN LSN
+	EndLSN   LSN
+}
+
+// newTimelineRanges builds the ranges of the current timeline and its ancestors from the .history records of the
+// current timeline. Each record is the ancestor timeline with the LSN where it was switched from.
+func newTimelineRanges(historyRecords []*TimelineHistoryRecord, currentTimeline uint32) []timelineRange {
+	ranges := make([]timelineRange, 0, len(historyRecords)+1)
+	start := LSN(0)
+	for _, record := range historyRecords {
+		ranges = append(ranges, timelineRange{Timeline: record.timeline, StartLSN: start, EndLSN: record.lsn})
+		start = record.lsn
+	}
+	return append(ranges, timelineRange{Timeline: currentTimeline, StartLSN: start, EndLSN: math.MaxUint64})
+}
+
+// selectWalSummaries returns the summaries overlapping the [from, to) range. Only the summaries of the timelines
+// in the history of the current one count, and only within the LSN range where these timelines were current:
+// the WAL of abandoned timeline branches doesn't describe the changes of the cluster. It's not a problem if
+// the summaries overlap each other or go beyond the range: the delta map can only get bigger than needed.
+func selectWalSummaries(summaries []WalSummary, timelines []timelineRange, from, to LSN) ([]WalSummary, error) {
+	type coveringSummary struct {
+		WalSummary
+		// start and end limit the summary to the range of its timeline
+		start, end LSN
+	}
+	var candidates []coveringSummary
+	for _, summary := range summaries {
+		for _, timeline := range timelines {
+			if summary.Timeline != timeline.Timeline {
+				continue
+			}
+			start, end := max(summary.StartLSN, timeline.StartLSN), min(summary.EndLSN, timeline.EndLSN)
+			if start < end {
+				candidates = append(candidates, coveringSummary{WalSummary: summary, start: start, end: end})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].start < candidates[j].start
+	})
+
+	var selected []WalSummary
+	covered := from
+	for _, summary := range candidates {
+		if summary.end <= from || summary.start >= to {
+			continue
+		}
+		if summary.start > covered {
+			break
+		}
+		selected = append(selected, summary.WalSummary)
+		if summary.end > covered {
+			covered = summary.end
+		}
+	}
+	if covered < to {
+		return nil, newWalSummariesNotCoverRangeError(from, to)
+	}
+	return selected, nil
+}
+
+// addWalSummaryBlocks adds the main fork blocks to the delta map, the other forks are not paged files
+func (deltaMap *PagedFileDeltaMap) addWalSummaryBlocks(blocks []WalSummaryBlock) {
+	for _, block := range blocks {
+		if block.ForkNumber != mainForkNumber {
+			continue
+		}
+		if block.IsLimitBlock {
+			deltaMap.addBlocksFrom(block.Location)
+		} else {
+			deltaMap.AddLocationToDelta(block.Location)
+		}
+	}
+}
+
+// addBlocksFrom adds every block starting from the location, since the pages in the base backup are obsolete
+func (deltaMap *PagedFileDeltaMap) addBlocksFrom(location walparser.BlockLocation) {
+	deltaMap.AddLocationToDelta(location)
+	(*deltaMap)[location.RelationFileNode].AddRange(uint64(location.BlockNo), math.MaxUint32+1)
+}
+
+// LoadWalSummariesDeltaMap builds the delta map from the WAL summaries made by the WAL summarizer of Postgres 17+
+// between the previous backup start and the backup start, so that the unchanged pages are not read at all.
+// The timeline history is read from the WAL folder.
+func (bundle *Bundle) LoadWalSummariesDeltaMap(ctx context.Context, queryRunner *PgQueryRunner, walFolder storage.Folder,
+	backupStartLSN LSN) error {
+	err := queryRunner.waitForWalSummarization(ctx, backupStartLSN)
+	if err != nil {
+		return err
+	}
+	summaries, err := queryRunner.GetWalSummaries(ctx)
+	if err != nil {
+		return err
+	}
+	historyRecords, err := GetTimeLineHistoryRecords(ctx, bundle.Timeline, walFolder)
+	if _, ok := err.(HistoryFileNotFoundError); ok {
+		// the first timeline has no history, and the summaries of the other ones won't be accepted
+		historyRecords, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	summaries, err = selectWalSummaries(summaries, newTimelineRanges(historyRecords, bundle.Timeline),
+		*bundle.IncrementFromLsn, backupStartLSN)
+	if err != nil {
+		return err
+	}
+
+	deltaMap := NewPagedFileDeltaMap()
+	for _, summary := range summaries {
+		blocks, err := queryRunner.GetWalSummaryContents(ctx, summary)
+		if err != nil {
+			return err
+		}
+		tracelog.InfoLogger.Printf("Read WAL summary from %s to %s on timeline %d\n",
+			summary.StartLSN, summary.EndLSN, summary.Timeline)
+		deltaMap.addWalSummaryBlocks(blocks)
+	}
+	bundle.DeltaMap = deltaMap
+	return nil
+}
+
+// waitForWalSummarization waits for the WAL summarizer to summarize the WAL up to the LSN
+func (queryRunner *PgQueryRunner) waitForWalSummarization(ctx context.Context, lsn LSN) error {
+	if queryRunner.Version < 170000 {
+		return errors.Errorf("WAL summaries are not supported by Postgres %d, 17 or newer is required",
+			queryRunner.Version)
+	}
+	summarizeWal, err := queryRunner.GetParameter(ctx, "summarize_wal")
+	if err != nil {
+		return err
+	}
+	if summarizeWal != "on" {
+		return errors.New("WAL summarization is disabled, set summarize_wal = on")
+	}
+
+	deadline := time.Now().Add(walSummarizationTimeout)
+	for {
+		summarizedLSN, err := queryRunner.GetWalSummarizedLSN(ctx)
+		if err != nil {
+			return err
+		}
+		if summarizedLSN >= lsn {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("WAL summarizer has not reached %s in %s, summarized up to %s",
+				lsn, walSummarizationTimeout, summarizedLSN)
+		}
+		tracelog.DebugLogger.Printf("Waiting for WAL summarizer to reach %s, summarized up to %s", lsn, summarizedLSN)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(walSummarizationPollInterval):
+		}
+	}
+}
+
+// buildGetWalSummarizedLSN formats a query to get the LSN the WAL is summarized up to
+func (queryRunner *PgQueryRunner) buildGetWalSummarizedLSN() string {
+	return "select summarized_lsn from pg_catalog.pg_get_wal_summarizer_state()"
+}
+
+// buildGetWalSummaries formats a query to list the WAL summary files
+func (queryRunner *PgQueryRunner) buildGetWalSummaries() string {
+	return "select tli, start_lsn, end_lsn from pg_catalog.pg_available_wal_summaries()"
+}
+
+// buildGetWalSummaryContents formats a query to list the blocks from a WAL summary file
+func (queryRunner *PgQueryRunner) buildGetWalSummaryContents() string {
+	return "select relfilenode, reltablespace, reldatabase, relforknumber, relblocknumber, is_limit_block " +
+		"from pg_catalog.pg_wal_summary_contents($1, $2::pg_catalog.pg_lsn, $3::pg_catalog.pg_lsn)"
+}
+
+// GetWalSummarizedLSN returns the LSN the WAL is summarized up to
+func (queryRunner *PgQueryRunner) GetWalSummarizedLSN(ctx context.Context) (LSN, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	var summarizedLSN string
+	conn := queryRunner.Connection
+	err := conn.QueryRow(ctx, queryRunner.buildGetWalSummarizedLSN()).Scan(&summarizedLSN)
+	if err != nil {
+		return 0, errors.Wrap(err, "GetWalSummarizedLSN: getting WAL summarizer state failed")
+	}
+	return ParseLSN(summarizedLSN)
+}
+
+// GetWalSummaries lists the WAL summary files available in pg_wal/summaries
+func (queryRunner *PgQueryRunner) GetWalSummaries(ctx context.Context) ([]WalSummary, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	conn := queryRunner.Connection
+	rows, err := conn.Query(ctx, queryRunner.buildGetWalSummaries())
+	if err != nil {
+		return nil, errors.Wrap(err, "GetWalSummaries: listing WAL summaries failed")
+	}
+	defer rows.Close()
+
+	var summaries []WalSummary
+	for rows.Next() {
+		var timeline int64
+		var startLSN, endLSN string
+		if err = rows.Scan(&timeline, &startLSN, &endLSN); err != nil {
+			return nil, errors.Wrap(err, "GetWalSummaries: scanning WAL summary failed")
+		}
+		summary := WalSummary{Timeline: uint32(timeline)}
+		if summary.StartLSN, err = ParseLSN(startLSN); err != nil {
+			return nil, err
+		}
+		if summary.EndLSN, err = ParseLSN(endLSN); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// GetWalSummaryContents lists the blocks from the WAL summary file
+func (queryRunner *PgQueryRunner) GetWalSummaryContents(ctx context.Context, summary WalSummary) ([]WalSummaryBlock, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	conn := queryRunner.Connection
+	rows, err := conn.Query(ctx, queryRunner.buildGetWalSummaryContents(),
+		int64(summary.Timeline), summary.StartLSN.String(), summary.EndLSN.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "GetWalSummaryContents: reading WAL summary failed")
+	}
+	defer rows.Close()
+
+	var blocks []WalSummaryBlock
+	for rows.Next() {
+		var relNode, spcNode, dbNode uint32
+		var blockNo int64
+		var block WalSummaryBlock
+		err = rows.Scan(&relNode, &spcNode, &dbNode, &block.ForkNumber, &blockNo, &block.IsLimitBlock)
+		if err != nil {
+			return nil, errors.Wrap(err, "GetWalSummaryContents: scanning WAL summary block failed")
+		}
+		block.Location = *walparser.NewBlockLocation(walparser.Oid(spcNode), walparser.Oid(dbNode),
+			walparser.Oid(relNode), uint32(blockNo))
+		blocks = append(blocks, block)
+	}
+	return blocks, rows.Err()
+}
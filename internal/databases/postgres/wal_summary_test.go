@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/walparser"
+)
+
+var firstTimeline = newTimelineRanges(nil, 1)
+
+func TestSelectWalSummaries_CoverRange(t *testing.T) {
+	summaries := []WalSummary{
+		{Timeline: 1, StartLSN: 0x3000000, EndLSN: 0x4000000},
+		{Timeline: 1, StartLSN: 0x1000000, EndLSN: 0x2000000},
+		{Timeline: 1, StartLSN: 0x2000000, EndLSN: 0x3000000},
+		{Timeline: 1, StartLSN: 0x4000000, EndLSN: 0x5000000},
+	}
+
+	selected, err := selectWalSummaries(summaries, firstTimeline, 0x1800000, 0x3000028)
+	require.NoError(t, err)
+	assert.Equal(t, []WalSummary{
+		{Timeline: 1, StartLSN: 0x1000000, EndLSN: 0x2000000},
+		{Timeline: 1, StartLSN: 0x2000000, EndLSN: 0x3000000},
+		{Timeline: 1, StartLSN: 0x3000000, EndLSN: 0x4000000},
+	}, selected)
+}
+
+func TestSelectWalSummaries_TimelineSwitch(t *testing.T) {
+	timelines := newTimelineRanges([]*TimelineHistoryRecord{NewTimelineHistoryRecord(1, 0x2000100, "")}, 2)
+	summaries := []WalSummary{
+		{Timeline: 1, StartLSN: 0x1000000, EndLSN: 0x2000100},
+		{Timeline: 2, StartLSN: 0x2000100, EndLSN: 0x3000000},
+	}
+
+	selected, err := selectWalSummaries(summaries, timelines, 0x1000000, 0x3000000)
+	require.NoError(t, err)
+	assert.Len(t, selected, 2)
+}
+
+func TestSelectWalSummaries_OtherTimelines(t *testing.T) {
+	timelines := newTimelineRanges([]*TimelineHistoryRecord{NewTimelineHistoryRecord(1, 0x2000100, "")}, 2)
+
+	t.Run("summary of the parent timeline after the switch", func(t *testing.T) {
+		summaries := []WalSummary{
+			{Timeline: 1, StartLSN: 0x1000000, EndLSN: 0x3000000},
+		}
+		_, err := selectWalSummaries(summaries, timelines, 0x1000000, 0x3000000)
+		assert.IsType(t, WalSummariesNotCoverRangeError{}, err)
+	})
+
+	t.Run("summary of a timeline out of the history", func(t *testing.T) {
+		summaries := []WalSummary{
+			{Timeline: 1, StartLSN: 0x1000000, EndLSN: 0x2000100},
+			{Timeline: 3, StartLSN: 0x2000100, EndLSN: 0x3000000},
+		}
+		_, err := selectWalSummaries(summaries, timelines, 0x1000000, 0x3000000)
+		assert.IsType(t, WalSummariesNotCoverRangeError{}, err)
+	})
+
+	t.Run("summary of the current timeline before it started", func(t *testing.T) {
+		summaries := []WalSummary{
+			{Timeline: 2, StartLSN: 0x1000000, EndLSN: 0x3000000},
+		}
+		_, err := selectWalSummaries(summaries, timelines, 0x1000000, 0x3000000)
+		assert.IsType(t, WalSummariesNotCoverRangeError{}, err)
+	})
+}
+
+func TestSelectWalSummaries_Gap(t *testing.T) {
+	summaries := []WalSummary{
+		{Timeline: 1, StartLSN: 0x1000000, EndLSN: 0x2000000},
+		{Timeline: 1, StartLSN: 0x2100000, EndLSN: 0x3000000},
+	}
+
+	_, err := selectWalSummaries(summaries, firstTimeline, 0x1000000, 0x3000000)
+	assert.IsType(t, WalSummariesNotCoverRangeError{}, err)
+}
+
+func TestSelectWalSummaries_NotSummarizedYet(t *testing.T) {
+	summaries := []WalSummary{
+		{Timeline: 1, StartLSN: 0x1000000, EndLSN: 0x2000000},
+	}
+
+	_, err := selectWalSummaries(summaries, firstTimeline, 0x1000000, 0x3000000)
+	assert.IsType(t, WalSummariesNotCoverRangeError{}, err)
+	_, err = selectWalSummaries(nil, firstTimeline, 0x1000000, 0x3000000)
+	assert.IsType(t, WalSummariesNotCoverRangeError{}, err)
+}
+
+func TestAddWalSummaryBlocks(t *testing.T) {
+	changed := *walparser.NewBlockLocation(DefaultSpcNode, 5, 16384, 3)
+	truncated := *walparser.NewBlockLocation(DefaultSpcNode, 5, 16390, 2)
+	otherFork := *walparser.NewBlockLocation(DefaultSpcNode, 5, 16395, 7)
+
+	deltaMap := NewPagedFileDeltaMap()
+	deltaMap.addWalSummaryBlocks([]WalSummaryBlock{
+		{Location: changed},
+		{Location: truncated, IsLimitBlock: true},
+		{Location: otherFork, ForkNumber: 1},
+	})
+
+	bitmap, err := deltaMap.GetDeltaBitmapFor("base/5/16384")
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{3}, bitmap.ToArray())
+
+	bitmap, err = deltaMap.GetDeltaBitmapFor("base/5/16390")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(BlocksInRelFile-2), bitmap.GetCardinality())
+	assert.False(t, bitmap.Contains(1))
+	assert.True(t, bitmap.Contains(2))
+	bitmap, err = deltaMap.GetDeltaBitmapFor("base/5/16390.1")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(BlocksInRelFile), bitmap.GetCardinality())
+
+	_, err = deltaMap.GetDeltaBitmapFor("base/5/16395")
+	assert.IsType(t, NoBitmapFoundError{}, err)
+}
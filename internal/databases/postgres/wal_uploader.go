@@ -6,9 +6,11 @@ import (
 	"io"
 	"path"
 
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/asm"
+	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/ioextensions"
 	"github.com/wal-g/wal-g/internal/multistorage"
 	"github.com/wal-g/wal-g/internal/multistorage/policies"
@@ -73,22 +75,38 @@ func (walUploader *WalUploader) FlushFiles(ctx context.Context) {
 }
 
 func PrepareMultiStorageWalUploader(ctx context.Context, folder storage.Folder, targetStorage string) (*WalUploader, error) {
-	folder = multistorage.SetPolicies(folder, policies.TakeFirstStorage)
 	var err error
-	if targetStorage == "" {
-		folder, err = multistorage.UseFirstAliveStorage(ctx, folder)
-	} else {
+	putQuorum := viper.GetInt(conf.FailoverStoragesPutQuorum)
+	switch {
+	case targetStorage != "":
+		folder = multistorage.SetPolicies(folder, policies.TakeFirstStorage)
 		folder, err = multistorage.UseSpecificStorage(ctx, targetStorage, folder)
+	case putQuorum > 0:
+		folder = multistorage.SetPolicies(folder, policies.ReplicateToAllStorages(putQuorum))
+		folder = multistorage.SetRepairJournal(folder, multistorage.NewRepairJournal(multistorage.DefaultRepairJournalPath()))
+		folder, err = multistorage.UseAllAliveStorages(ctx, folder)
+	default:
+		folder = multistorage.SetPolicies(folder, policies.TakeFirstStorage)
+		folder, err = multistorage.UseFirstAliveStorage(ctx, folder)
 	}
 	if err != nil {
 		return nil, err
 	}
-	tracelog.InfoLogger.Printf("Files will be uploaded to storage: %v", multistorage.UsedStorages(folder)[0])
+	if targetStorage == "" && putQuorum > 0 {
+		tracelog.InfoLogger.Printf("Files will be uploaded to storages: %v, quorum: %d",
+			multistorage.UsedStorages(folder), putQuorum)
+	} else {
+		tracelog.InfoLogger.Printf("Files will be uploaded to storage: %v", multistorage.UsedStorages(folder)[0])
+	}
 
 	baseUploader, err := internal.ConfigureUploaderToFolder(folder)
 	if err != nil {
 		return nil, fmt.Errorf("configure base uploader: %w", err)
 	}
+	err = configureWalDictionary(ctx, baseUploader)
+	if err != nil {
+		return nil, fmt.Errorf("configure wal dictionary: %w", err)
+	}
 
 	walUploader, err := ConfigureWalUploader(baseUploader)
 	if err != nil {
@@ -0,0 +1,124 @@
+package dedup
+
+import (
+	"io"
+	"math/bits"
+)
+
+// MinAvgChunkSize is the smallest supported average chunk size. Smaller chunks make manifests and the number of
+// stored objects grow too much to be worth it.
+const MinAvgChunkSize = 64 * 1024
+
+// gearTable maps every byte value to a pseudo-random 64-bit number for the gear rolling hash. It is generated from
+// a fixed seed, so chunk boundaries stay the same between WAL-G versions and chunks keep deduplicating.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x5741_4c47_4445_4455) // "WALGDEDU"
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Chunker splits a stream into content-defined chunks using the FastCDC algorithm with normalized chunking:
+// a boundary is placed where the gear hash of the preceding bytes matches a mask, so inserting or removing data
+// only changes the chunks around the modification. Chunk sizes are between avgSize/4 and avgSize*4.
+type Chunker struct {
+	reader io.Reader
+	buf    []byte
+	start  int
+	end    int
+	eof    bool
+
+	minSize int
+	avgSize int
+	maxSize int
+	// maskSmall is harder to match and is used before avgSize is reached, maskLarge is easier to match and is used
+	// after that. Together they concentrate chunk sizes around avgSize.
+	maskSmall uint64
+	maskLarge uint64
+}
+
+func NewChunker(reader io.Reader, avgSize int) *Chunker {
+	avgBits := bits.Len(uint(avgSize)) - 1
+	return &Chunker{
+		reader:    reader,
+		buf:       make([]byte, avgSize*4),
+		minSize:   avgSize / 4,
+		avgSize:   avgSize,
+		maxSize:   avgSize * 4,
+		maskSmall: highBitsMask(avgBits + 1),
+		maskLarge: highBitsMask(avgBits - 1),
+	}
+}
+
+func highBitsMask(count int) uint64 {
+	return ((uint64(1) << count) - 1) << (64 - count)
+}
+
+// Next returns the next chunk of the stream. The returned slice is only valid until the next call.
+// Returns io.EOF when the stream is over.
+func (chunker *Chunker) Next() ([]byte, error) {
+	err := chunker.fill()
+	if err != nil {
+		return nil, err
+	}
+	if chunker.start == chunker.end {
+		return nil, io.EOF
+	}
+	data := chunker.buf[chunker.start:chunker.end]
+	cut := chunker.findBoundary(data)
+	chunker.start += cut
+	return data[:cut], nil
+}
+
+// fill makes sure that at least maxSize bytes are buffered, unless the stream is over.
+func (chunker *Chunker) fill() error {
+	if chunker.eof || chunker.end-chunker.start >= chunker.maxSize {
+		return nil
+	}
+	copy(chunker.buf, chunker.buf[chunker.start:chunker.end])
+	chunker.end -= chunker.start
+	chunker.start = 0
+	for chunker.end < chunker.maxSize {
+		n, err := chunker.reader.Read(chunker.buf[chunker.end:])
+		chunker.end += n
+		if err == io.EOF {
+			chunker.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (chunker *Chunker) findBoundary(data []byte) int {
+	if len(data) <= chunker.minSize {
+		return len(data)
+	}
+	normalSize := min(chunker.avgSize, len(data))
+	maxSize := min(chunker.maxSize, len(data))
+
+	var hash uint64
+	i := chunker.minSize
+	for ; i < normalSize; i++ {
+		hash = (hash << 1) + gearTable[data[i]]
+		if hash&chunker.maskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < maxSize; i++ {
+		hash = (hash << 1) + gearTable[data[i]]
+		if hash&chunker.maskLarge == 0 {
+			return i + 1
+		}
+	}
+	return maxSize
+}
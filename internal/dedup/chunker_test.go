@@ -0,0 +1,71 @@
+package dedup_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/dedup"
+)
+
+const testAvgChunkSize = dedup.MinAvgChunkSize
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func splitIntoChunks(t *testing.T, data []byte) [][]byte {
+	chunker := dedup.NewChunker(bytes.NewReader(data), testAvgChunkSize)
+	var chunks [][]byte
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		require.NoError(t, err)
+		chunks = append(chunks, bytes.Clone(chunk))
+	}
+}
+
+func TestChunker_ReassemblesInput(t *testing.T) {
+	data := randomData(1, 3*1024*1024+17)
+
+	chunks := splitIntoChunks(t, data)
+
+	assert.Equal(t, data, bytes.Join(chunks, nil))
+	for i, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), testAvgChunkSize*4)
+		if i < len(chunks)-1 {
+			assert.Greater(t, len(chunk), testAvgChunkSize/4)
+		}
+	}
+}
+
+func TestChunker_EmptyInput(t *testing.T) {
+	assert.Empty(t, splitIntoChunks(t, nil))
+}
+
+func TestChunker_BoundariesSurviveInsertion(t *testing.T) {
+	data := randomData(2, 2*1024*1024)
+	modified := append(append(bytes.Clone(data[:1000]), []byte("inserted bytes")...), data[1000:]...)
+
+	original := splitIntoChunks(t, data)
+	shifted := splitIntoChunks(t, modified)
+
+	known := make(map[string]bool)
+	for _, chunk := range original {
+		known[string(chunk)] = true
+	}
+	shared := 0
+	for _, chunk := range shifted {
+		if known[string(chunk)] {
+			shared++
+		}
+	}
+	assert.GreaterOrEqual(t, shared, len(original)-2)
+}
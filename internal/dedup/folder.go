@@ -0,0 +1,428 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+var _ storage.Folder = &Folder{}
+
+type Config struct {
+	// AvgChunkSize is the average size of content-defined chunks in bytes.
+	AvgChunkSize int
+
+	// GCDelay is the minimal age of an unreferenced chunk before the garbage collection deletes it. It protects chunks
+	// uploaded by backups that are still in progress and haven't written their manifests yet. Stored chunks are reused
+	// without uploading them again while they are younger than GCDelay/2, so the delay must be at least twice as long
+	// as the longest backup.
+	GCDelay time.Duration
+
+	// ShouldDeduplicate tells if an object is stored as chunks. The path is relative to the deduplication root.
+	ShouldDeduplicate func(objectPath string) bool
+}
+
+// root keeps the state shared by the deduplication root folder and all its subfolders.
+type root struct {
+	folder       storage.Folder
+	chunksFolder storage.Folder
+	config       Config
+
+	// knownChunks caches the time until which the garbage collection keeps chunks that are known to exist, to not
+	// check them every time.
+	knownChunks sync.Map
+}
+
+// Folder stores objects selected by Config.ShouldDeduplicate as content-defined chunks under the shared "chunks/"
+// folder, and writes a small manifest named "<object>.dedup" in place of each of them. Reading, listing and copying
+// such objects is transparent: manifest names are reported without the suffix, and the content is reassembled
+// from chunks. Objects stored without deduplication are still readable.
+//
+// Deleting deduplicated objects removes only their manifests. The chunks which are no longer referenced are removed
+// by CollectGarbage, which counts references from all manifests, so it's run on demand rather than on every deletion.
+type Folder struct {
+	storage.Folder
+	root *root
+
+	// path of the folder relative to the deduplication root, either empty or ending with "/".
+	path string
+}
+
+func NewFolder(folder storage.Folder, config Config) *Folder {
+	return &Folder{
+		Folder: folder,
+		root: &root{
+			folder:       folder,
+			chunksFolder: folder.GetSubFolder(ChunksFolderName),
+			config:       config,
+		},
+	}
+}
+
+func (folder *Folder) shouldDeduplicate(objectRelativePath string) bool {
+	return folder.root.config.ShouldDeduplicate(folder.path + strings.TrimPrefix(objectRelativePath, "/"))
+}
+
+func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	subPath := strings.Trim(subFolderRelativePath, "/")
+	if subPath != "" {
+		subPath += "/"
+	}
+	return &Folder{
+		Folder: folder.Folder.GetSubFolder(subFolderRelativePath),
+		root:   folder.root,
+		path:   folder.path + subPath,
+	}
+}
+
+// ListFolder reports the size of the original object for deduplicated objects, like StatObject does, which requires
+// reading their manifests.
+func (folder *Folder) ListFolder(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	rawObjects, rawSubFolders, err := folder.Folder.ListFolder(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objects = make([]storage.Object, len(rawObjects))
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(manifestReadConcurrency)
+	for i, object := range rawObjects {
+		name, isManifest := strings.CutSuffix(object.GetName(), ManifestSuffix)
+		if !isManifest {
+			objects[i] = object
+			continue
+		}
+		errGroup.Go(func() error {
+			size := object.GetSize()
+			manifest, err := readManifest(groupCtx, folder.Folder, object.GetName())
+			switch err.(type) {
+			case nil:
+				size = manifest.Size
+			case storage.ObjectNotFoundError:
+				// The manifest has been deleted concurrently, or it's a noncurrent version.
+			default:
+				return err
+			}
+			objects[i] = storage.NewLocalObjectWithVersion(
+				name, object.GetLastModified(), size, object.GetVersionID(), object.GetAdditionalInfo())
+			return nil
+		})
+	}
+	err = errGroup.Wait()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, subFolder := range rawSubFolders {
+		name := path.Base(strings.TrimSuffix(subFolder.GetPath(), "/"))
+		if folder.path == "" && name == ChunksFolderName {
+			continue
+		}
+		subFolders = append(subFolders, &Folder{
+			Folder: subFolder,
+			root:   folder.root,
+			path:   folder.path + name + "/",
+		})
+	}
+	return objects, subFolders, nil
+}
+
+func (folder *Folder) Exists(ctx context.Context, objectRelativePath string) (bool, error) {
+	if folder.shouldDeduplicate(objectRelativePath) {
+		exists, err := folder.Folder.Exists(ctx, objectRelativePath+ManifestSuffix)
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	return folder.Folder.Exists(ctx, objectRelativePath)
+}
+
+// StatObject reports the size of the original object for deduplicated objects, which requires reading the manifest.
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (storage.Object, error) {
+	if folder.shouldDeduplicate(objectRelativePath) {
+		manifestObject, err := folder.Folder.StatObject(ctx, objectRelativePath+ManifestSuffix)
+		if err == nil {
+			manifest, err := readManifest(ctx, folder.Folder, objectRelativePath+ManifestSuffix)
+			if err != nil {
+				return nil, err
+			}
+			return storage.NewLocalObject(objectRelativePath, manifestObject.GetLastModified(), manifest.Size), nil
+		}
+		if _, ok := err.(storage.ObjectNotFoundError); !ok {
+			return nil, err
+		}
+	}
+	return folder.Folder.StatObject(ctx, objectRelativePath)
+}
+
+func (folder *Folder) ReadObject(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	if folder.shouldDeduplicate(objectRelativePath) {
+		manifest, err := readManifest(ctx, folder.Folder, objectRelativePath+ManifestSuffix)
+		if err == nil {
+			return newChunkedReader(ctx, folder.root.chunksFolder, manifest), nil
+		}
+		if _, ok := err.(storage.ObjectNotFoundError); !ok {
+			return nil, err
+		}
+	}
+	return folder.Folder.ReadObject(ctx, objectRelativePath)
+}
+
+// ReadObjectRange reads a part of the original object. For deduplicated objects, the chunks covering the range are
+// read whole, so they can be verified, and the bytes outside the range are skipped.
+func (folder *Folder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	if folder.shouldDeduplicate(objectRelativePath) {
+		manifest, err := readManifest(ctx, folder.Folder, objectRelativePath+ManifestSuffix)
+		if err == nil {
+			return newChunkedRangeReader(ctx, folder.root.chunksFolder, manifest, offset, length)
+		}
+		if _, ok := err.(storage.ObjectNotFoundError); !ok {
+			return nil, err
+		}
+	}
+	return storage.ReadObjectRange(ctx, folder.Folder, objectRelativePath, offset, length)
+}
+
+func (folder *Folder) PutObject(ctx context.Context, name string, content io.Reader) error {
+	if !folder.shouldDeduplicate(name) {
+		return folder.Folder.PutObject(ctx, name, content)
+	}
+
+	manifest := &Manifest{Version: manifestVersion}
+	uploadedBytes := int64(0)
+	chunker := NewChunker(content, folder.root.config.AvgChunkSize)
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("split %q into chunks: %w", name, err)
+		}
+		hash := hashChunk(chunk)
+		uploaded, err := folder.root.putChunk(ctx, hash, chunk)
+		if err != nil {
+			return err
+		}
+		if uploaded {
+			uploadedBytes += int64(len(chunk))
+		}
+		manifest.Size += int64(len(chunk))
+		manifest.Chunks = append(manifest.Chunks, ChunkRef{Hash: hash, Size: int64(len(chunk))})
+	}
+
+	tracelog.DebugLogger.Printf("Deduplicated %s: %d chunks, %d of %d bytes uploaded",
+		folder.path+name, len(manifest.Chunks), uploadedBytes, manifest.Size)
+	return writeManifest(ctx, folder.Folder, name+ManifestSuffix, manifest)
+}
+
+// putChunk uploads the chunk unless it's already stored. Chunks which the garbage collection may delete in less than
+// half of the GC delay are uploaded again to refresh their modification time, so they aren't deleted before the
+// manifest referencing them is written. Locked chunks are kept by the garbage collection until their retention ends.
+func (root *root) putChunk(ctx context.Context, hash string, chunk []byte) (bool, error) {
+	if keptUntil, ok := root.knownChunks.Load(hash); ok && root.isFresh(keptUntil.(time.Time)) {
+		return false, nil
+	}
+	chunkPath := ChunkPath(hash)
+	object, err := root.chunksFolder.StatObject(ctx, chunkPath)
+	if _, ok := err.(storage.ObjectNotFoundError); err != nil && !ok {
+		return false, fmt.Errorf("check deduplicated chunk %s: %w", hash, err)
+	}
+	if err == nil {
+		keptUntil, err := root.chunkKeptUntil(ctx, chunkPath, object.GetLastModified())
+		if err != nil {
+			return false, fmt.Errorf("check deduplicated chunk %s: %w", hash, err)
+		}
+		if root.isFresh(keptUntil) {
+			root.knownChunks.Store(hash, keptUntil)
+			return false, nil
+		}
+	}
+
+	uploadTime := time.Now()
+	err = root.chunksFolder.PutObject(ctx, chunkPath, bytes.NewReader(chunk))
+	if err != nil {
+		return false, fmt.Errorf("upload deduplicated chunk %s: %w", hash, err)
+	}
+	root.knownChunks.Store(hash, uploadTime.Add(root.config.GCDelay))
+	return true, nil
+}
+
+// chunkKeptUntil provides the time until which the garbage collection doesn't delete the chunk even if it isn't
+// referenced: the end of the GC delay or of the retention, whichever is later.
+func (root *root) chunkKeptUntil(ctx context.Context, chunkPath string, lastModified time.Time) (time.Time, error) {
+	keptUntil := lastModified.Add(root.config.GCDelay)
+	if !storage.IsRetentionEnabled(root.chunksFolder) || root.isFresh(keptUntil) {
+		return keptUntil, nil
+	}
+	lockedUntil, err := storage.GetRetention(ctx, root.chunksFolder, chunkPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if lockedUntil.After(keptUntil) {
+		return lockedUntil, nil
+	}
+	return keptUntil, nil
+}
+
+func (root *root) isFresh(keptUntil time.Time) bool {
+	return time.Until(keptUntil) > root.config.GCDelay/2
+}
+
+func (folder *Folder) CopyObject(ctx context.Context, srcPath string, dstPath string) error {
+	if folder.shouldDeduplicate(srcPath) {
+		exists, err := folder.Folder.Exists(ctx, srcPath+ManifestSuffix)
+		if err != nil {
+			return err
+		}
+		if exists && folder.shouldDeduplicate(dstPath) {
+			return folder.Folder.CopyObject(ctx, srcPath+ManifestSuffix, dstPath+ManifestSuffix)
+		}
+		if exists {
+			content, err := folder.ReadObject(ctx, srcPath)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+			return folder.Folder.PutObject(ctx, dstPath, content)
+		}
+	}
+	return folder.Folder.CopyObject(ctx, srcPath, dstPath)
+}
+
+// DeleteObjects deletes the objects along with their manifests. The chunks are left for CollectGarbage.
+func (folder *Folder) DeleteObjects(ctx context.Context, objects []storage.Object) error {
+	toDelete := make([]storage.Object, 0, len(objects))
+	for _, object := range objects {
+		toDelete = append(toDelete, object)
+		if folder.shouldDeduplicate(object.GetName()) {
+			toDelete = append(toDelete, storage.NewLocalObjectWithVersion(
+				object.GetName()+ManifestSuffix, object.GetLastModified(), 0, object.GetVersionID(), ""))
+		}
+	}
+
+	return folder.Folder.DeleteObjects(ctx, toDelete)
+}
+
+// CollectGarbage deletes chunks which are not referenced by any manifest and are older than Config.GCDelay.
+func (folder *Folder) CollectGarbage(ctx context.Context) error {
+	return folder.root.collectGarbage(ctx)
+}
+
+// SetShowAllVersions delegates the "show all versions" toggle to the underlying folder (if supported).
+func (folder *Folder) SetShowAllVersions(show bool) {
+	storage.SetShowAllVersions(folder.Folder, show)
+}
+
+func (folder *Folder) IsRetentionEnabled() bool {
+	return storage.IsRetentionEnabled(folder.Folder)
+}
+
+// SetRetention locks the manifest of deduplicated objects along with all chunks it references. Chunks are shared
+// between objects, so the retention of a chunk is only extended, and the chunks locked by other objects until a later
+// time are left as is.
+func (folder *Folder) SetRetention(ctx context.Context, objectRelativePath string, until time.Time) error {
+	storedPath, err := folder.storedPath(ctx, objectRelativePath)
+	if err != nil {
+		return err
+	}
+	if storedPath != objectRelativePath {
+		manifest, err := readManifest(ctx, folder.Folder, storedPath)
+		if err != nil {
+			return err
+		}
+		err = folder.root.lockChunks(ctx, manifest, until)
+		if err != nil {
+			return fmt.Errorf("lock chunks of %q: %w", folder.path+objectRelativePath, err)
+		}
+	}
+	return storage.SetRetention(ctx, folder.Folder, storedPath, until)
+}
+
+func (root *root) lockChunks(ctx context.Context, manifest *Manifest, until time.Time) error {
+	hashes := make(map[string]struct{}, len(manifest.Chunks))
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(manifestReadConcurrency)
+	for _, chunk := range manifest.Chunks {
+		if _, ok := hashes[chunk.Hash]; ok {
+			continue
+		}
+		hashes[chunk.Hash] = struct{}{}
+		errGroup.Go(func() error {
+			chunkPath := ChunkPath(chunk.Hash)
+			lockedUntil, err := storage.GetRetention(groupCtx, root.chunksFolder, chunkPath)
+			if err != nil {
+				return err
+			}
+			if !lockedUntil.Before(until) {
+				return nil
+			}
+			return storage.SetRetention(groupCtx, root.chunksFolder, chunkPath, until)
+		})
+	}
+	return errGroup.Wait()
+}
+
+func (folder *Folder) GetRetention(ctx context.Context, objectRelativePath string) (time.Time, error) {
+	storedPath, err := folder.storedPath(ctx, objectRelativePath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return storage.GetRetention(ctx, folder.Folder, storedPath)
+}
+
+func (folder *Folder) GetStorageClass(ctx context.Context, objectRelativePath string) (string, error) {
+	storedPath, err := folder.storedPath(ctx, objectRelativePath)
+	if err != nil {
+		return "", err
+	}
+	return storage.GetStorageClass(ctx, folder.Folder, storedPath)
+}
+
+// SetStorageClass is supported only for objects stored as is, since chunks of deduplicated objects are shared.
+func (folder *Folder) SetStorageClass(ctx context.Context, objectRelativePath string, storageClass string) error {
+	storedPath, err := folder.storedPath(ctx, objectRelativePath)
+	if err != nil {
+		return err
+	}
+	if storedPath != objectRelativePath {
+		return fmt.Errorf("%w: %q is deduplicated", storage.ErrStorageClassNotSupported, folder.path+objectRelativePath)
+	}
+	return storage.SetStorageClass(ctx, folder.Folder, objectRelativePath, storageClass)
+}
+
+func (folder *Folder) RestoreObject(ctx context.Context, objectRelativePath string, days int) (bool, error) {
+	storedPath, err := folder.storedPath(ctx, objectRelativePath)
+	if err != nil {
+		return false, err
+	}
+	return storage.RestoreObject(ctx, folder.Folder, storedPath, days)
+}
+
+// storedPath provides the path of the manifest for deduplicated objects, and the path of the object itself otherwise.
+func (folder *Folder) storedPath(ctx context.Context, objectRelativePath string) (string, error) {
+	if !folder.shouldDeduplicate(objectRelativePath) {
+		return objectRelativePath, nil
+	}
+	exists, err := folder.Folder.Exists(ctx, objectRelativePath+ManifestSuffix)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return objectRelativePath + ManifestSuffix, nil
+	}
+	return objectRelativePath, nil
+}
@@ -0,0 +1,244 @@
+package dedup_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/dedup"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func newTestFolder(kvs *memory.KVS) (*dedup.Folder, storage.Folder) {
+	raw := memory.NewFolder("in_memory/", kvs)
+	return dedup.NewFolder(raw, dedup.Config{
+		AvgChunkSize: testAvgChunkSize,
+		GCDelay:      time.Hour,
+		ShouldDeduplicate: func(objectPath string) bool {
+			return strings.HasPrefix(objectPath, "data/")
+		},
+	}), raw
+}
+
+func readAll(t *testing.T, folder storage.Folder, path string) []byte {
+	reader, err := folder.ReadObject(context.Background(), path)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return data
+}
+
+func countChunks(t *testing.T, raw storage.Folder) int {
+	chunks, err := storage.ListFolderRecursively(context.Background(), raw.GetSubFolder(dedup.ChunksFolderName))
+	require.NoError(t, err)
+	return len(chunks)
+}
+
+func TestFolder(t *testing.T) {
+	folder, _ := newTestFolder(memory.NewKVS())
+	storage.RunFolderTest(folder, t)
+}
+
+func TestFolder_PutAndRead(t *testing.T) {
+	ctx := context.Background()
+	folder, raw := newTestFolder(memory.NewKVS())
+	data := randomData(3, 1024*1024)
+
+	require.NoError(t, folder.PutObject(ctx, "data/object", bytes.NewReader(data)))
+
+	assert.Equal(t, data, readAll(t, folder, "data/object"))
+	exists, err := raw.Exists(ctx, "data/object"+dedup.ManifestSuffix)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = folder.Exists(ctx, "data/object")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	object, err := folder.GetSubFolder("data").StatObject(ctx, "object")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), object.GetSize())
+}
+
+func TestFolder_SharesChunks(t *testing.T) {
+	ctx := context.Background()
+	folder, raw := newTestFolder(memory.NewKVS())
+	data := randomData(4, 2*1024*1024)
+	modified := bytes.Clone(data)
+	copy(modified[len(modified)/2:], "modified")
+
+	require.NoError(t, folder.PutObject(ctx, "data/first", bytes.NewReader(data)))
+	firstChunks := countChunks(t, raw)
+	require.NoError(t, folder.PutObject(ctx, "data/second", bytes.NewReader(modified)))
+
+	assert.LessOrEqual(t, countChunks(t, raw)-firstChunks, 2)
+	assert.Equal(t, modified, readAll(t, folder, "data/second"))
+}
+
+func TestFolder_ListHidesInternals(t *testing.T) {
+	ctx := context.Background()
+	folder, _ := newTestFolder(memory.NewKVS())
+	require.NoError(t, folder.PutObject(ctx, "data/object", bytes.NewReader(randomData(5, 1000))))
+	require.NoError(t, folder.PutObject(ctx, "meta.json", strings.NewReader("{}")))
+
+	objects, subFolders, err := folder.ListFolder(ctx)
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "meta.json", objects[0].GetName())
+	require.Len(t, subFolders, 1)
+
+	objects, _, err = subFolders[0].ListFolder(ctx)
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "object", objects[0].GetName())
+	object, err := subFolders[0].StatObject(ctx, "object")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), objects[0].GetSize())
+	assert.Equal(t, object.GetSize(), objects[0].GetSize())
+}
+
+func TestFolder_ReadsRawObjects(t *testing.T) {
+	ctx := context.Background()
+	folder, raw := newTestFolder(memory.NewKVS())
+	require.NoError(t, raw.PutObject(ctx, "data/legacy", strings.NewReader("legacy content")))
+
+	assert.Equal(t, []byte("legacy content"), readAll(t, folder, "data/legacy"))
+}
+
+func TestFolder_ReadObjectRange(t *testing.T) {
+	ctx := context.Background()
+	folder, raw := newTestFolder(memory.NewKVS())
+	data := randomData(7, 1024*1024)
+	require.NoError(t, folder.PutObject(ctx, "data/object", bytes.NewReader(data)))
+	require.NoError(t, raw.PutObject(ctx, "data/legacy", bytes.NewReader(data)))
+	require.Greater(t, countChunks(t, raw), 2)
+
+	for _, objectPath := range []string{"data/object", "data/legacy"} {
+		for _, r := range [][2]int64{{0, 10}, {1000, 300 * 1024}, {int64(len(data)) - 5, 5}, {0, int64(len(data))}} {
+			reader, err := storage.ReadObjectRange(ctx, folder, objectPath, r[0], r[1])
+			require.NoError(t, err)
+			part, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.NoError(t, reader.Close())
+			assert.Equal(t, data[r[0]:r[0]+r[1]], part, "%s %v", objectPath, r)
+		}
+	}
+
+	parallel, err := storage.ReadObjectParallel(ctx, folder, "data/object",
+		storage.ParallelReadConfig{PartSize: 100 * 1024, Concurrency: 4})
+	require.NoError(t, err)
+	defer parallel.Close()
+	content, err := io.ReadAll(parallel)
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+}
+
+func TestFolder_DetectsCorruptedChunk(t *testing.T) {
+	ctx := context.Background()
+	folder, raw := newTestFolder(memory.NewKVS())
+	require.NoError(t, folder.PutObject(ctx, "data/object", bytes.NewReader(randomData(6, 1000))))
+	chunks, err := storage.ListFolderRecursively(ctx, raw.GetSubFolder(dedup.ChunksFolderName))
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	require.NoError(t, raw.GetSubFolder(dedup.ChunksFolderName).PutObject(ctx, chunks[0].GetName(),
+		strings.NewReader("garbage")))
+
+	reader, err := folder.ReadObject(ctx, "data/object")
+	require.NoError(t, err)
+	defer reader.Close()
+	_, err = io.ReadAll(reader)
+	assert.ErrorContains(t, err, "corrupted")
+}
+
+func TestFolder_CollectGarbage(t *testing.T) {
+	ctx := context.Background()
+	uploadTime := time.Now().Add(-2 * time.Hour)
+	kvs := memory.NewKVS(memory.WithCustomTime(func() time.Time { return uploadTime }))
+	folder, raw := newTestFolder(kvs)
+	shared := randomData(7, 1000)
+	require.NoError(t, folder.PutObject(ctx, "data/first", bytes.NewReader(shared)))
+	require.NoError(t, folder.PutObject(ctx, "data/second", bytes.NewReader(shared)))
+	require.NoError(t, folder.PutObject(ctx, "data/unique", bytes.NewReader(randomData(8, 1000))))
+	require.Equal(t, 2, countChunks(t, raw))
+
+	require.NoError(t, folder.DeleteObjects(ctx, []storage.Object{
+		storage.NewLocalObject("data/first", uploadTime, 0),
+		storage.NewLocalObject("data/unique", uploadTime, 0),
+	}))
+	// the chunks are left until the garbage collection is run
+	assert.Equal(t, 2, countChunks(t, raw))
+
+	require.NoError(t, folder.CollectGarbage(ctx))
+	assert.Equal(t, 1, countChunks(t, raw))
+	assert.Equal(t, shared, readAll(t, folder, "data/second"))
+	exists, err := folder.Exists(ctx, "data/unique")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestFolder_GarbageCollectionKeepsRecentChunks(t *testing.T) {
+	ctx := context.Background()
+	folder, raw := newTestFolder(memory.NewKVS())
+	require.NoError(t, folder.PutObject(ctx, "data/object", bytes.NewReader(randomData(9, 1000))))
+
+	require.NoError(t, folder.DeleteObjects(ctx, []storage.Object{storage.NewLocalObject("data/object", time.Now(), 0)}))
+	require.NoError(t, folder.CollectGarbage(ctx))
+
+	assert.Equal(t, 1, countChunks(t, raw))
+}
+
+func TestFolder_SetRetentionLocksChunks(t *testing.T) {
+	ctx := context.Background()
+	uploadTime := time.Now().Add(-2 * time.Hour)
+	kvs := memory.NewKVS(memory.WithCustomTime(func() time.Time { return uploadTime }))
+	folder, raw := newTestFolder(kvs)
+	shared := randomData(10, 1000)
+	require.NoError(t, folder.PutObject(ctx, "data/first", bytes.NewReader(shared)))
+	require.NoError(t, folder.PutObject(ctx, "data/second", bytes.NewReader(shared)))
+	chunks, err := storage.ListFolderRecursively(ctx, raw.GetSubFolder(dedup.ChunksFolderName))
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	chunkRetention := func() time.Time {
+		until, err := storage.GetRetention(ctx, raw.GetSubFolder(dedup.ChunksFolderName), chunks[0].GetName())
+		require.NoError(t, err)
+		return until
+	}
+
+	until := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	require.NoError(t, storage.SetRetention(ctx, folder, "data/first", until))
+	assert.True(t, until.Equal(chunkRetention()))
+
+	// the chunk stays locked until the later time when the other object is locked for less time
+	require.NoError(t, storage.SetRetention(ctx, folder, "data/second", until.Add(-time.Hour)))
+	assert.True(t, until.Equal(chunkRetention()))
+	require.NoError(t, storage.SetRetention(ctx, folder, "data/second", until.Add(time.Hour)))
+	assert.True(t, until.Add(time.Hour).Equal(chunkRetention()))
+
+	// the locked chunk is reused without uploading it again, although it's older than the GC delay
+	reopened, _ := newTestFolder(kvs)
+	require.NoError(t, reopened.PutObject(ctx, "data/third", bytes.NewReader(shared)))
+	assert.Equal(t, shared, readAll(t, reopened, "data/third"))
+}
+
+func TestFolder_GarbageCollectionKeepsLockedChunks(t *testing.T) {
+	ctx := context.Background()
+	uploadTime := time.Now().Add(-2 * time.Hour)
+	kvs := memory.NewKVS(memory.WithCustomTime(func() time.Time { return uploadTime }))
+	folder, raw := newTestFolder(kvs)
+	require.NoError(t, folder.PutObject(ctx, "data/object", bytes.NewReader(randomData(11, 1000))))
+	chunksFolder := raw.GetSubFolder(dedup.ChunksFolderName)
+	chunks, err := storage.ListFolderRecursively(ctx, chunksFolder)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	require.NoError(t, storage.SetRetention(ctx, chunksFolder, chunks[0].GetName(), time.Now().Add(time.Hour)))
+
+	require.NoError(t, folder.DeleteObjects(ctx, []storage.Object{storage.NewLocalObject("data/object", uploadTime, 0)}))
+	require.NoError(t, folder.CollectGarbage(ctx))
+
+	assert.Equal(t, 1, countChunks(t, raw))
+}
@@ -0,0 +1,136 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+const manifestReadConcurrency = 16
+
+// collectGarbage is a mark-and-sweep pass: it counts references to chunks from all manifests and deletes the chunks
+// which are not referenced anymore. Chunks younger than GCDelay are kept even if unreferenced, because they may
+// belong to an upload that hasn't written its manifest yet, and so are the chunks whose retention hasn't ended.
+func (root *root) collectGarbage(ctx context.Context) error {
+	startTime := time.Now()
+	refCounts, err := root.countChunkReferences(ctx)
+	if err != nil {
+		return fmt.Errorf("count deduplicated chunk references: %w", err)
+	}
+
+	chunks, err := storage.ListFolderRecursively(ctx, root.chunksFolder)
+	if err != nil {
+		return fmt.Errorf("list deduplicated chunks: %w", err)
+	}
+
+	var unreferenced []storage.Object
+	for _, chunk := range chunks {
+		hash := path.Base(chunk.GetName())
+		if refCounts[hash] > 0 || startTime.Sub(chunk.GetLastModified()) < root.config.GCDelay {
+			continue
+		}
+		unreferenced = append(unreferenced, chunk)
+	}
+	garbage, err := root.skipLockedChunks(ctx, unreferenced, startTime)
+	if err != nil {
+		return fmt.Errorf("check retention of deduplicated chunks: %w", err)
+	}
+	for _, chunk := range garbage {
+		root.knownChunks.Delete(path.Base(chunk.GetName()))
+	}
+
+	tracelog.InfoLogger.Printf("Deduplication garbage collection: %d chunks are referenced, %d of %d stored chunks will be deleted",
+		len(refCounts), len(garbage), len(chunks))
+	if len(garbage) == 0 {
+		return nil
+	}
+	err = root.chunksFolder.DeleteObjects(ctx, garbage)
+	if err != nil {
+		return fmt.Errorf("delete unreferenced deduplicated chunks: %w", err)
+	}
+	return nil
+}
+
+// skipLockedChunks filters out the chunks locked after the time.
+func (root *root) skipLockedChunks(ctx context.Context, chunks []storage.Object, after time.Time) ([]storage.Object, error) {
+	if !storage.IsRetentionEnabled(root.chunksFolder) {
+		return chunks, nil
+	}
+	locked := make([]bool, len(chunks))
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(manifestReadConcurrency)
+	for i, chunk := range chunks {
+		errGroup.Go(func() error {
+			lockedUntil, err := storage.GetRetention(groupCtx, root.chunksFolder, chunk.GetName())
+			if _, ok := err.(storage.ObjectNotFoundError); ok {
+				// The chunk has been deleted concurrently.
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			locked[i] = lockedUntil.After(after)
+			return nil
+		})
+	}
+	err := errGroup.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	unlocked := make([]storage.Object, 0, len(chunks))
+	for i, chunk := range chunks {
+		if !locked[i] {
+			unlocked = append(unlocked, chunk)
+		}
+	}
+	return unlocked, nil
+}
+
+func (root *root) countChunkReferences(ctx context.Context) (map[string]int, error) {
+	objects, err := storage.ListFolderRecursivelyWithFilter(ctx, root.folder, func(folderPath string) bool {
+		return strings.Trim(folderPath, "/") != ChunksFolderName
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refCounts := make(map[string]int)
+	mutex := sync.Mutex{}
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(manifestReadConcurrency)
+	for _, object := range objects {
+		if !strings.HasSuffix(object.GetName(), ManifestSuffix) {
+			continue
+		}
+		manifestPath := object.GetName()
+		errGroup.Go(func() error {
+			manifest, err := readManifest(groupCtx, root.folder, manifestPath)
+			if _, ok := err.(storage.ObjectNotFoundError); ok {
+				// The manifest has been deleted concurrently, so it doesn't reference anything.
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			for _, chunk := range manifest.Chunks {
+				refCounts[chunk.Hash]++
+			}
+			return nil
+		})
+	}
+	err = errGroup.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return refCounts, nil
+}
@@ -0,0 +1,214 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	// ManifestSuffix is appended to the name of an object that was stored as a list of chunks.
+	ManifestSuffix = ".dedup"
+
+	// ChunksFolderName is the folder under the deduplication root where chunks are shared by all objects.
+	ChunksFolderName = "chunks"
+
+	manifestVersion = 1
+)
+
+// Manifest lists the chunks which the original object consists of, in order.
+type Manifest struct {
+	Version int        `json:"version"`
+	Size    int64      `json:"size"`
+	Chunks  []ChunkRef `json:"chunks"`
+}
+
+type ChunkRef struct {
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// ChunkPath provides the path of the chunk relative to the chunks folder. Chunks are spread over 256 subfolders to
+// keep listings small on file system based storages.
+func ChunkPath(hash string) string {
+	return hash[:2] + "/" + hash
+}
+
+func hashChunk(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func readManifest(ctx context.Context, folder storage.Folder, manifestPath string) (*Manifest, error) {
+	reader, err := folder.ReadObject(ctx, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	manifest := &Manifest{}
+	err = json.NewDecoder(reader).Decode(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("decode deduplication manifest %q: %w", manifestPath, err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported deduplication manifest %q version: %d", manifestPath, manifest.Version)
+	}
+	return manifest, nil
+}
+
+func writeManifest(ctx context.Context, folder storage.Folder, manifestPath string, manifest *Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("encode deduplication manifest %q: %w", manifestPath, err)
+	}
+	return folder.PutObject(ctx, manifestPath, bytes.NewReader(data))
+}
+
+// chunkedReader reassembles the original object from the chunks listed in the manifest, verifying each of them.
+type chunkedReader struct {
+	openChunk func(hash string) (io.ReadCloser, error)
+	chunks    []ChunkRef
+
+	current     io.ReadCloser
+	currentRef  ChunkRef
+	currentRead int64
+	hasher      hash.Hash
+}
+
+func newChunkedReader(ctx context.Context, chunksFolder storage.Folder, manifest *Manifest) *chunkedReader {
+	return &chunkedReader{
+		openChunk: func(hash string) (io.ReadCloser, error) {
+			return chunksFolder.ReadObject(ctx, ChunkPath(hash))
+		},
+		chunks: manifest.Chunks,
+		hasher: sha256.New(),
+	}
+}
+
+// newChunkedRangeReader provides length bytes of the original object starting from offset, reading only the chunks
+// which cover them.
+func newChunkedRangeReader(
+	ctx context.Context,
+	chunksFolder storage.Folder,
+	manifest *Manifest,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	var chunks []ChunkRef
+	skip := int64(0)
+	chunkStart := int64(0)
+	for _, chunk := range manifest.Chunks {
+		chunkEnd := chunkStart + chunk.Size
+		if chunkEnd > offset && chunkStart < offset+length {
+			if len(chunks) == 0 {
+				skip = offset - chunkStart
+			}
+			chunks = append(chunks, chunk)
+		}
+		chunkStart = chunkEnd
+	}
+
+	reader := newChunkedReader(ctx, chunksFolder, &Manifest{Version: manifest.Version, Chunks: chunks})
+	_, err := io.CopyN(io.Discard, reader, skip)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return &chunkedRangeReader{chunkedReader: reader, remaining: length}, nil
+}
+
+// chunkedRangeReader provides the bytes of the chunks up to the end of the range. Once the range is read, it reads
+// the rest of the last chunk, so that the chunk is verified before the last bytes of the range are provided.
+type chunkedRangeReader struct {
+	*chunkedReader
+	remaining int64
+}
+
+func (reader *chunkedRangeReader) Read(p []byte) (int, error) {
+	if reader.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > reader.remaining {
+		p = p[:reader.remaining]
+	}
+	n, err := reader.chunkedReader.Read(p)
+	reader.remaining -= int64(n)
+	if err == io.EOF && reader.remaining > 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+	if err == nil && reader.remaining == 0 {
+		_, err = io.Copy(io.Discard, reader.chunkedReader)
+	}
+	return n, err
+}
+
+func (reader *chunkedReader) Read(p []byte) (int, error) {
+	for {
+		if reader.current == nil {
+			if len(reader.chunks) == 0 {
+				return 0, io.EOF
+			}
+			err := reader.openNext()
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := reader.current.Read(p)
+		reader.currentRead += int64(n)
+		reader.hasher.Write(p[:n])
+		if err == io.EOF {
+			err = reader.finishCurrent()
+			if err != nil {
+				return n, err
+			}
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+func (reader *chunkedReader) openNext() error {
+	reader.currentRef, reader.chunks = reader.chunks[0], reader.chunks[1:]
+	chunk, err := reader.openChunk(reader.currentRef.Hash)
+	if err != nil {
+		return fmt.Errorf("read deduplicated chunk %s: %w", reader.currentRef.Hash, err)
+	}
+	reader.current = chunk
+	reader.currentRead = 0
+	reader.hasher.Reset()
+	return nil
+}
+
+func (reader *chunkedReader) finishCurrent() error {
+	closeErr := reader.current.Close()
+	reader.current = nil
+	if reader.currentRead != reader.currentRef.Size {
+		return fmt.Errorf("deduplicated chunk %s is corrupted: expected size %d, got %d",
+			reader.currentRef.Hash, reader.currentRef.Size, reader.currentRead)
+	}
+	actualHash := hex.EncodeToString(reader.hasher.Sum(nil))
+	if actualHash != reader.currentRef.Hash {
+		return fmt.Errorf("deduplicated chunk %s is corrupted: content hash is %s", reader.currentRef.Hash, actualHash)
+	}
+	return closeErr
+}
+
+func (reader *chunkedReader) Close() error {
+	if reader.current != nil {
+		err := reader.current.Close()
+		reader.current = nil
+		return err
+	}
+	return nil
+}
@@ -12,9 +12,11 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/integrity"
 	"github.com/wal-g/wal-g/internal/multistorage"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -158,6 +160,83 @@ func (h *DeleteHandler) HandleDeleteRetainAfter(ctx context.Context, args []stri
 	tracelog.ErrorLogger.FatalOnError(err)
 }
 
+// HandleTierRetained moves the backups that are kept by 'delete retain', except for the tierRetainCount newest ones,
+// to the storage class. It's meant to run before the deletion, so the backups that will be deleted aren't moved.
+func (h *DeleteHandler) HandleTierRetained(ctx context.Context, args []string, tierRetainCount int,
+	storageClass string, confirmed bool) error {
+	modifier, retentionStr := ExtractDeleteModifierFromArgs(args)
+	retentionCount, err := strconv.Atoi(retentionStr)
+	if err != nil {
+		return err
+	}
+	target, err := h.FindTargetRetain(retentionCount, modifier)
+	if err != nil {
+		return err
+	}
+
+	backupNames := h.selectBackupsToTier(target, tierRetainCount)
+	if len(backupNames) == 0 {
+		tracelog.InfoLogger.Printf("No backup found for moving to %s", storageClass)
+		return nil
+	}
+	tracelog.InfoLogger.Printf("Backups to move to %s: %v", storageClass, backupNames)
+	return TierBackups(ctx, h.Folder, backupNames, storageClass, confirmed)
+}
+
+// selectBackupsToTier provides the names of backups that aren't older than the deletion target, except for the
+// retainCount newest ones and the backups they are incremented from, since restoring a delta backup requires its
+// whole increment chain.
+func (h *DeleteHandler) selectBackupsToTier(deletionTarget BackupObject, retainCount int) []string {
+	backups := slices.Clone(h.backups)
+	slices.SortFunc(backups, func(a, b BackupObject) int {
+		if h.greater(a, b) {
+			return -1
+		}
+		if h.greater(b, a) {
+			return 1
+		}
+		return 0
+	})
+
+	incrementFrom := make(map[string]string)
+	for _, backup := range backups {
+		if !backup.IsFullBackup() {
+			incrementFrom[backup.GetBackupName()] = backup.GetIncrementFromName()
+		}
+	}
+
+	candidates := make([]string, 0)
+	retainedChains := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, backup := range backups {
+		if deletionTarget != nil && h.less(backup, deletionTarget) {
+			break
+		}
+		// The same backup may be stored in several storages
+		if seen[backup.GetBackupName()] {
+			continue
+		}
+		seen[backup.GetBackupName()] = true
+		if len(seen) > retainCount {
+			candidates = append(candidates, backup.GetBackupName())
+			continue
+		}
+		for name := backup.GetBackupName(); name != "" && !retainedChains[name]; name = incrementFrom[name] {
+			retainedChains[name] = true
+		}
+	}
+
+	backupNames := make([]string, 0, len(candidates))
+	for _, name := range candidates {
+		if retainedChains[name] {
+			tracelog.InfoLogger.Printf("Backup %s is the base of a retained backup, it won't be moved", name)
+			continue
+		}
+		backupNames = append(backupNames, name)
+	}
+	return backupNames
+}
+
 func (h *DeleteHandler) HandleDeleteTarget(ctx context.Context, targetSelector BackupSelector, confirmed, findFull bool) {
 	target, err := h.FindTargetBySelector(ctx, targetSelector)
 	tracelog.ErrorLogger.FatalOnError(err)
@@ -417,7 +496,7 @@ func (h *DeleteHandler) DeleteTarget(ctx context.Context, target BackupObject, c
 		backupNamesToDelete[bTarget.GetBackupName()] = true
 	}
 
-	return DeleteObjectsWhere(ctx, h.Folder.GetSubFolder(utility.BaseBackupPath),
+	return deleteObjectsWhere(ctx, h.Folder, utility.BaseBackupPath,
 		confirmed, func(object storage.Object) bool {
 			return backupNamesToDelete[utility.StripLeftmostBackupName(object.GetName())] && !h.isPermanent(object)
 		}, folderFilter)
@@ -488,6 +567,23 @@ func DeleteObjectsWhere(
 	objFilter func(object1 storage.Object) bool,
 	folderFilter func(name string) bool,
 ) error {
+	return deleteObjectsWhere(ctx, folder, "", confirm, objFilter, folderFilter)
+}
+
+// deleteObjectsWhere deletes the matching objects from the subfolder of the storage root at the relative path,
+// together with the integrity manifests that describe only the deleted objects.
+func deleteObjectsWhere(
+	ctx context.Context,
+	rootFolder storage.Folder,
+	relativePath string,
+	confirm bool,
+	objFilter func(object1 storage.Object) bool,
+	folderFilter func(name string) bool,
+) error {
+	folder := rootFolder
+	if relativePath != "" {
+		folder = rootFolder.GetSubFolder(relativePath)
+	}
 	// if folder has uncurrent versions we need to clean them as well
 	storage.SetShowAllVersions(folder, true)
 	relativePathObjects, err := multistorage.ListFolderRecursivelyWithFilter(ctx, folder, folderFilter)
@@ -504,22 +600,108 @@ func DeleteObjectsWhere(
 			tracelog.DebugLogger.Printf("Object skipped: %s storage=%s\n", object.GetName(), multistorage.GetStorage(object))
 		}
 	}
+	if len(markedForDeletion) == 0 {
+		tracelog.InfoLogger.Println("No objects matched the deletion criteria.")
+		return nil
+	}
+	markedForDeletion, err = skipLockedObjects(ctx, folder, relativePath, markedForDeletion)
+	if err != nil {
+		return err
+	}
 	deletionCount := len(markedForDeletion)
 	if deletionCount == 0 {
-		tracelog.InfoLogger.Println("No objects matched the deletion criteria.")
+		tracelog.InfoLogger.Println("All objects matching the deletion criteria are locked.")
 		return nil
 	}
 	if confirm {
 		err := folder.DeleteObjects(ctx, markedForDeletion)
-		if err == nil {
-			tracelog.InfoLogger.Printf("Objects deleted successfully: count=%d\n", deletionCount)
+		if err != nil {
+			return err
 		}
-		return err
+		tracelog.InfoLogger.Printf("Objects deleted successfully: count=%d\n", deletionCount)
+		deletedPaths := make([]string, 0, deletionCount)
+		for _, object := range markedForDeletion {
+			deletedPaths = append(deletedPaths, storage.JoinPath(relativePath, object.GetName()))
+		}
+		err = integrity.DeleteManifests(ctx, rootFolder, deletedPaths)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to delete the integrity manifests of the deleted objects: %v", err)
+		}
+		return nil
 	}
 	tracelog.InfoLogger.Printf("Dry run: objects would be deleted count=%d, Run with --confirm to execute\n", deletionCount)
 	return nil
 }
 
+// retentionCheckConcurrency limits the number of concurrent requests checking the retention of objects.
+const retentionCheckConcurrency = 16
+
+// skipLockedObjects excludes the objects that are still locked by the storage retention, since they can't be deleted.
+// If any object of a backup is locked, all objects of the backup are kept, so that the backup isn't left partially
+// deleted. The folder is the subfolder of the storage root at the relative path.
+func skipLockedObjects(ctx context.Context, folder storage.Folder, relativePath string,
+	objects []storage.Object) ([]storage.Object, error) {
+	if !storage.IsRetentionEnabled(folder) {
+		return objects, nil
+	}
+	tracelog.InfoLogger.Println("Checking the retention of objects marked for deletion...")
+	lockedUntil := make([]time.Time, len(objects))
+	errGroup, ctx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(retentionCheckConcurrency)
+	for i, object := range objects {
+		errGroup.Go(func() error {
+			until, err := storage.GetRetention(ctx, folder, object.GetName())
+			if _, ok := err.(storage.ObjectNotFoundError); ok {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("check retention of %q: %w", object.GetName(), err)
+			}
+			lockedUntil[i] = until
+			return nil
+		})
+	}
+	if err := errGroup.Wait(); err != nil {
+		return nil, err
+	}
+
+	lockedBackups := make(map[string]bool)
+	for i, object := range objects {
+		if backup := backupPathOf(relativePath, object.GetName()); backup != "" && !lockedUntil[i].IsZero() {
+			lockedBackups[backup] = true
+		}
+	}
+
+	unlocked := make([]storage.Object, 0, len(objects))
+	for i, object := range objects {
+		backup := backupPathOf(relativePath, object.GetName())
+		switch {
+		case !lockedUntil[i].IsZero():
+			tracelog.InfoLogger.Printf("Object is locked until %s, skipping: %s storage=%s\n",
+				lockedUntil[i].Format(time.RFC3339), object.GetName(), multistorage.GetStorage(object))
+		case lockedBackups[backup]:
+			tracelog.InfoLogger.Printf("Object belongs to a backup with locked objects, skipping: %s storage=%s\n",
+				object.GetName(), multistorage.GetStorage(object))
+		default:
+			unlocked = append(unlocked, object)
+		}
+	}
+	if lockedCount := len(objects) - len(unlocked); lockedCount > 0 {
+		tracelog.WarningLogger.Printf("Locked objects skipped: count=%d\n", lockedCount)
+	}
+	return unlocked, nil
+}
+
+// backupPathOf provides the path of the backup, relative to the storage root, which the object in the subfolder at
+// the relative path belongs to. Provides an empty string for the objects which aren't parts of backups, e.g. WAL.
+func backupPathOf(relativePath, objectName string) string {
+	objectPath, isBackupObject := strings.CutPrefix(storage.JoinPath(relativePath, objectName), utility.BaseBackupPath)
+	if !isBackupObject || objectPath == "" {
+		return ""
+	}
+	return utility.BaseBackupPath + utility.StripLeftmostBackupName(objectPath)
+}
+
 func findTarget(objects []BackupObject,
 	compare func(object1, object2 storage.Object) bool,
 	isTarget func(object BackupObject) bool) (BackupObject, error) {
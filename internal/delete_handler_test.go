@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
 )
 
 func CreateMockStorageFolder(ctx context.Context) storage.Folder {
@@ -117,3 +119,88 @@ func TestFindTargetByNameEmpty(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, nil, actual)
 }
+
+func TestDeleteOldObjectsSkipsLocked(t *testing.T) {
+	folder := CreateMockStorageFolder(t.Context())
+	lockedObjectName := "basebackups_005/base_456/tar_partitions/1"
+	err := storage.SetRetention(t.Context(), folder, lockedObjectName, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	filter := func(object storage.Object) bool { return true }
+	folderFilter := func(path string) bool { return true }
+	err = DeleteObjectsWhere(t.Context(), folder, true, filter, folderFilter)
+	assert.NoError(t, err)
+	savedObjects, err := storage.ListFolderRecursively(t.Context(), folder)
+	assert.NoError(t, err)
+	// the other objects of the backup with the locked object are kept too
+	var savedNames []string
+	for _, object := range savedObjects {
+		savedNames = append(savedNames, object.GetName())
+	}
+	assert.ElementsMatch(t, []string{
+		"basebackups_005/base_456_backup_stop_sentinel.json",
+		"basebackups_005/base_456/some_folder/3",
+		"basebackups_005/base_456/tar_partitions/1",
+		"basebackups_005/base_456/tar_partitions/2",
+		"basebackups_005/base_456/tar_partitions/3",
+	}, savedNames)
+}
+
+func TestDeleteBackupObjectsSkipsLockedBackup(t *testing.T) {
+	folder := CreateMockStorageFolder(t.Context())
+	err := storage.SetRetention(t.Context(), folder, "basebackups_005/base_456/tar_partitions/2", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	filter := func(object storage.Object) bool { return true }
+	folderFilter := func(path string) bool { return true }
+	err = deleteObjectsWhere(t.Context(), folder, "basebackups_005", true, filter, folderFilter)
+	assert.NoError(t, err)
+	savedObjects, err := storage.ListFolderRecursively(t.Context(), folder.GetSubFolder("basebackups_005"))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(savedObjects))
+	for _, object := range savedObjects {
+		assert.Equal(t, "base_456", utility.StripLeftmostBackupName(object.GetName()))
+	}
+}
+
+func TestBackupPathOf(t *testing.T) {
+	assert.Equal(t, "basebackups_005/base_1", backupPathOf("", "basebackups_005/base_1_backup_stop_sentinel.json"))
+	assert.Equal(t, "basebackups_005/base_1", backupPathOf("basebackups_005", "base_1/tar_partitions/part_1.tar"))
+	assert.Equal(t, "basebackups_005/base_1", backupPathOf("basebackups_005/", "base_1/metadata.json"))
+	assert.Equal(t, "", backupPathOf("", "wal_005/000000010000000000000001.lz4"))
+	assert.Equal(t, "", backupPathOf("wal_005", "000000010000000000000001.lz4"))
+}
+
+type deltaBackupObject struct {
+	DefaultBackupObject
+	incrementFrom string
+}
+
+func (o deltaBackupObject) GetIncrementFromName() string {
+	return o.incrementFrom
+}
+
+func (o deltaBackupObject) IsFullBackup() bool {
+	return o.incrementFrom == ""
+}
+
+func TestSelectBackupsToTierKeepsDeltaBases(t *testing.T) {
+	newBackup := func(name, incrementFrom string) BackupObject {
+		object := storage.NewLocalObject(name+"_backup_stop_sentinel.json", time.Time{}, 0)
+		return deltaBackupObject{DefaultBackupObject{object}, incrementFrom}
+	}
+	backups := []BackupObject{
+		newBackup("base_0", ""),
+		newBackup("base_1", ""),
+		newBackup("base_2", "base_1"),
+		newBackup("base_3", "base_2"),
+		newBackup("base_4", ""),
+		newBackup("base_5", "base_4"),
+	}
+	deleteHandler := CreateMockDeleteHandler(backups, memory.NewFolder("in_memory/", memory.NewKVS()))
+
+	// base_3 is retained, so its chain base_2 and base_1 stays in the current class
+	assert.Equal(t, []string{"base_0"}, deleteHandler.selectBackupsToTier(nil, 3))
+	assert.Equal(t, []string{"base_3", "base_2", "base_1", "base_0"}, deleteHandler.selectBackupsToTier(nil, 2))
+	assert.Equal(t, []string{"base_3", "base_2", "base_1"}, deleteHandler.selectBackupsToTier(backups[1], 2))
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/wal-g/wal-g/internal/compression"
 	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/objectheader"
 	"github.com/wal-g/wal-g/utility"
 	"golang.org/x/sync/semaphore"
 )
@@ -114,7 +115,20 @@ func extractNonTar(tarInterpreter TarInterpreter, source io.Reader, path string,
 // If it's tar, a decompression is not needed.
 // Otherwise it uses corresponding decompressor. If none found an error will be returned.
 func DecryptAndDecompressTar(reader io.Reader, filePath string, crypter crypto.Crypter) (io.ReadCloser, error) {
-	var err error
+	fileExtension := utility.GetFileExtension(filePath)
+
+	header, reader, err := objectheader.Read(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "DecryptAndDecompressTar: read header failed")
+	}
+	if header != nil {
+		var decompressor compression.Decompressor
+		if fileExtension != "tar" && fileExtension != "" {
+			decompressor = compression.FindDecompressor(fileExtension)
+		}
+		extractingReader, err := decryptAndDecompressWithHeader(reader, header, crypter, decompressor)
+		return extractingReader, errors.Wrap(err, "DecryptAndDecompressTar")
+	}
 
 	if crypter != nil {
 		reader, err = crypter.Decrypt(reader)
@@ -123,8 +137,6 @@ func DecryptAndDecompressTar(reader io.Reader, filePath string, crypter crypto.C
 		}
 	}
 
-	fileExtension := utility.GetFileExtension(filePath)
-
 	if fileExtension == "tar" || fileExtension == "" {
 		return io.NopCloser(reader), nil
 	}
@@ -234,6 +246,11 @@ func tryExtractFiles(downloadingContext context.Context,
 
 			if err != nil {
 				isFailed.Store(fileClosure, true)
+				if crypto.IsIntegrityError(err) {
+					tracelog.ErrorLogger.Printf("%s failed the integrity check, it may be truncated or corrupted in storage: %v",
+						fileClosure.StoragePath(), err)
+					return
+				}
 				tracelog.ErrorLogger.Println(err)
 			}
 		}()
@@ -12,7 +12,9 @@ import (
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/internal/objectheader"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
@@ -71,7 +73,15 @@ func TryDownloadFile(ctx context.Context, reader StorageFolderReader, path strin
 }
 
 func DecompressDecryptBytes(archiveReader io.Reader, decompressor compression.Decompressor) (io.ReadCloser, error) {
-	decryptReader, err := DecryptBytes(archiveReader)
+	header, archiveReader, err := objectheader.Read(archiveReader)
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		return decryptAndDecompressWithHeader(archiveReader, header, ConfigureCrypter(), decompressor)
+	}
+
+	decryptReader, err := DecryptWithHeader(archiveReader, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -82,21 +92,14 @@ func DecompressDecryptBytes(archiveReader io.Reader, decompressor compression.De
 	return decompressor.Decompress(decryptReader)
 }
 
+// DecryptBytes decrypts the object with the crypter selected by its header, or with the configured crypter if the
+// object has no header. The header is not provided in the output.
 func DecryptBytes(archiveReader io.Reader) (io.Reader, error) {
-	crypter := ConfigureCrypter()
-	if crypter == nil {
-		tracelog.DebugLogger.Printf("No crypter has been selected")
-		return archiveReader, nil
-	}
-
-	tracelog.DebugLogger.Printf("Selected crypter: %s", crypter.Name())
-
-	decryptReader, err := crypter.Decrypt(archiveReader)
+	header, archiveReader, err := objectheader.Read(archiveReader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to init decrypt reader: %w", err)
+		return nil, err
 	}
-
-	return decryptReader, nil
+	return DecryptWithHeader(archiveReader, header)
 }
 
 // CachedDecompressor is the file extension describing decompressor
@@ -231,6 +234,11 @@ func DownloadFileTo(ctx context.Context, folderReader StorageFolderReader, fileN
 	defer utility.LoggedClose(reader, "")
 
 	_, err = utility.FastCopy(file, reader)
+	if crypto.IsIntegrityError(err) {
+		// The content is known to be damaged, so it must not be used.
+		_ = os.Remove(dstPath)
+		return errors.Wrapf(err, "file '%s' failed the integrity check", fileName)
+	}
 	// In case of error we may have some content within file. Leave it alone.
 	return err
 }
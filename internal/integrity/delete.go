@@ -0,0 +1,132 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// DeleteManifests removes the manifests which have no objects left after the objects at the paths were deleted.
+// The paths are relative to the storage root. A manifest is kept while any of its objects still exists.
+func DeleteManifests(ctx context.Context, rootFolder storage.Folder, deletedPaths []string) error {
+	manifestsFolder := rootFolder.GetSubFolder(FolderName)
+	rootObjects, rootSubFolders, err := manifestsFolder.ListFolder(ctx)
+	if err != nil {
+		return fmt.Errorf("list integrity manifests: %w", err)
+	}
+	if len(rootObjects) == 0 && len(rootSubFolders) == 0 {
+		return nil
+	}
+
+	deleted := make(map[string]bool, len(deletedPaths))
+	groups := make(map[string]bool)
+	for _, deletedPath := range deletedPaths {
+		deletedPath = strings.TrimPrefix(deletedPath, "/")
+		if strings.HasPrefix(deletedPath, FolderName+"/") {
+			continue
+		}
+		deleted[deletedPath] = true
+		for _, group := range possibleGroups(deletedPath) {
+			groups[group] = true
+		}
+	}
+
+	var obsolete []storage.Object
+	for group := range groups {
+		manifests, err := obsoleteManifests(ctx, rootFolder, manifestsFolder, group, deleted)
+		if err != nil {
+			return err
+		}
+		obsolete = append(obsolete, manifests...)
+	}
+	if len(obsolete) == 0 {
+		return nil
+	}
+	err = manifestsFolder.DeleteObjects(ctx, obsolete)
+	if err != nil {
+		return fmt.Errorf("delete integrity manifests: %w", err)
+	}
+	tracelog.InfoLogger.Printf("Integrity manifests deleted: count=%d\n", len(obsolete))
+	return nil
+}
+
+// possibleGroups provides the groups the manifest of the object may belong to. The directory the object was
+// uploaded to isn't known, so every parent folder is considered.
+func possibleGroups(objectPath string) []string {
+	groups := []string{""}
+	directory, name := path.Split(objectPath)
+	directory = strings.TrimSuffix(directory, "/")
+	for i, char := range directory {
+		if char == '/' {
+			groups = append(groups, directory[:i])
+		}
+	}
+	if directory != "" {
+		groups = append(groups, directory)
+	}
+	if isSentinel(name) || isWalSegmentName(name) {
+		groups = append(groups, GroupOf(directory, name))
+	}
+	return groups
+}
+
+func obsoleteManifests(ctx context.Context, rootFolder, manifestsFolder storage.Folder, group string,
+	deleted map[string]bool) ([]storage.Object, error) {
+	groupFolder := manifestsFolder
+	if group != "" {
+		groupFolder = manifestsFolder.GetSubFolder(group)
+	}
+	objects, _, err := groupFolder.ListFolder(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list integrity manifests in %q: %w", group, err)
+	}
+
+	var obsolete []storage.Object
+	for _, object := range objects {
+		if !strings.HasSuffix(object.GetName(), ManifestSuffix) {
+			continue
+		}
+		manifest, err := readManifest(ctx, groupFolder, object.GetName())
+		if err != nil {
+			return nil, err
+		}
+		isObsolete, err := allEntriesDeleted(ctx, rootFolder, manifest, deleted)
+		if err != nil {
+			return nil, err
+		}
+		if isObsolete {
+			obsolete = append(obsolete, storage.NewLocalObject(storage.JoinPath(group, object.GetName()),
+				object.GetLastModified(), object.GetSize()))
+		}
+	}
+	return obsolete, nil
+}
+
+// allEntriesDeleted checks if none of the objects of the manifest exists anymore. Manifests which have no entries
+// among the deleted ones are left untouched.
+func allEntriesDeleted(ctx context.Context, rootFolder storage.Folder, manifest *Manifest,
+	deleted map[string]bool) (bool, error) {
+	var remaining []Entry
+	for _, entry := range manifest.Entries {
+		if !deleted[entry.Path] {
+			remaining = append(remaining, entry)
+		}
+	}
+	if len(remaining) == len(manifest.Entries) {
+		return false, nil
+	}
+	for _, entry := range remaining {
+		exists, err := rootFolder.Exists(ctx, entry.Path)
+		if err != nil {
+			return false, fmt.Errorf("check if %q exists: %w", entry.Path, err)
+		}
+		if exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
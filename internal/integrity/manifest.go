@@ -0,0 +1,184 @@
+package integrity
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	// FolderName is the folder under the storage root where integrity manifests are stored. Its layout mirrors the
+	// storage: manifests of objects uploaded to "<dir>/<group>/..." are stored in "integrity_005/<dir>/<group>/",
+	// where the group is the backup name. The backup sentinels belong to the group of their backup, manifests of WAL
+	// segments stored right in "<dir>/" are grouped by the WAL range, i.e. the timeline and the log ID, and manifests
+	// of the other objects stored right in "<dir>/" are stored in "integrity_005/<dir>/" itself.
+	FolderName = "integrity_005"
+
+	ManifestSuffix = ".json"
+
+	manifestTimeLayout  = "20060102T150405.000000Z"
+	manifestRandomBytes = 4
+
+	walSegmentNameLength = 24
+	walRangeNameLength   = 16
+)
+
+// Entry describes an uploaded object as it was sent to the storage, i.e. compressed and encrypted.
+type Entry struct {
+	// Path of the object relative to the storage root.
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type Manifest struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Recorder collects entries of the uploaded objects and writes them as manifests, one per group. The objects stored
+// right in a directory, like WAL segments, are usually uploaded one by one by separate processes, so their entries
+// are batched in the local spool, if it's set. The other entries, including the backup sentinels, are kept in memory
+// until Flush. The Recorder is safe for concurrent use.
+type Recorder struct {
+	folder storage.Folder
+	spool  *Spool
+
+	mutex   sync.Mutex
+	pending map[string][]Entry
+}
+
+// NewRecorder creates a Recorder that writes manifests into the FolderName subfolder of the storage root folder.
+// The spool may be nil.
+func NewRecorder(rootFolder storage.Folder, spool *Spool) *Recorder {
+	return &Recorder{
+		folder:  rootFolder.GetSubFolder(FolderName),
+		spool:   spool,
+		pending: make(map[string][]Entry),
+	}
+}
+
+// Record adds the object to the manifest of its group. The directory is the folder the object was uploaded to,
+// relative to the storage root, and the name is the object path relative to that directory.
+func (recorder *Recorder) Record(ctx context.Context, directory, name string, size int64, sha256 string) error {
+	group := GroupOf(directory, name)
+	entry := Entry{
+		Path:   storage.JoinPath(directory, name),
+		Size:   size,
+		SHA256: sha256,
+	}
+	if recorder.spool != nil && !isNested(name) && !isSentinel(name) {
+		return recorder.spool.add(ctx, spoolEntry{Directory: directory, Group: group, Time: time.Now(), Entry: entry},
+			recorder.writeManifest)
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.pending[group] = append(recorder.pending[group], entry)
+	return nil
+}
+
+// GroupOf provides the folder of the manifest for the object, relative to FolderName.
+func GroupOf(directory, name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if group, _, nested := strings.Cut(name, "/"); nested {
+		return storage.JoinPath(directory, group)
+	}
+	if isSentinel(name) {
+		return storage.JoinPath(directory, strings.TrimSuffix(name, utility.SentinelSuffix))
+	}
+	if isWalSegmentName(name) {
+		return storage.JoinPath(directory, name[:walRangeNameLength])
+	}
+	return directory
+}
+
+func isNested(name string) bool {
+	return strings.Contains(strings.Trim(name, "/"), "/")
+}
+
+func isSentinel(name string) bool {
+	return strings.HasSuffix(name, utility.SentinelSuffix)
+}
+
+// isWalSegmentName checks if the name starts with a WAL segment name, like the segments, their .partial files
+// and backup history files do.
+func isWalSegmentName(name string) bool {
+	if len(name) < walSegmentNameLength || len(name) > walSegmentNameLength && name[walSegmentNameLength] != '.' {
+		return false
+	}
+	_, err := hex.DecodeString(name[:walSegmentNameLength])
+	return err == nil
+}
+
+// Flush writes a manifest for every group which has entries recorded in memory since the previous flush, and for
+// every group in the spool whose oldest entry is older than SpoolMaxAge.
+func (recorder *Recorder) Flush(ctx context.Context) error {
+	recorder.mutex.Lock()
+	pending := recorder.pending
+	recorder.pending = make(map[string][]Entry)
+	recorder.mutex.Unlock()
+
+	for group, entries := range pending {
+		err := recorder.writeManifest(ctx, group, entries)
+		if err != nil {
+			recorder.restore(group, entries)
+			return err
+		}
+	}
+	if recorder.spool != nil {
+		return recorder.spool.flush(ctx, false, recorder.writeManifest)
+	}
+	return nil
+}
+
+// FlushSpool writes the manifests of all the groups in the spool, without waiting for them to fill up.
+func (recorder *Recorder) FlushSpool(ctx context.Context) error {
+	if recorder.spool == nil {
+		return nil
+	}
+	return recorder.spool.flush(ctx, true, recorder.writeManifest)
+}
+
+// restore returns the entries of a manifest that failed to be written back to the pending ones.
+func (recorder *Recorder) restore(group string, entries []Entry) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.pending[group] = append(entries, recorder.pending[group]...)
+}
+
+func (recorder *Recorder) writeManifest(ctx context.Context, group string, entries []Entry) error {
+	name, err := newManifestName()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&Manifest{Entries: entries})
+	if err != nil {
+		return fmt.Errorf("encode integrity manifest: %w", err)
+	}
+	manifestPath := storage.JoinPath(group, name)
+	err = recorder.folder.PutObject(ctx, manifestPath, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("upload integrity manifest %q: %w", manifestPath, err)
+	}
+	return nil
+}
+
+// newManifestName provides a unique name, so concurrent processes uploading to the same group never overwrite
+// manifests of each other.
+func newManifestName() (string, error) {
+	random := make([]byte, manifestRandomBytes)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", fmt.Errorf("generate integrity manifest name: %w", err)
+	}
+	return time.Now().UTC().Format(manifestTimeLayout) + "_" + hex.EncodeToString(random) + ManifestSuffix, nil
+}
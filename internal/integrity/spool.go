@@ -0,0 +1,176 @@
+package integrity
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	spoolFileName = ".walg_integrity_spool"
+	spoolKeyBytes = 8
+
+	// SpoolBatchSize is the number of entries of a group collected in the spool before the manifest is written.
+	SpoolBatchSize = 64
+	// SpoolMaxAge is how long the entries wait in the spool for the batch to fill up.
+	SpoolMaxAge = time.Hour
+)
+
+// DefaultSpoolPath provides the location of the spool shared by the WAL-G processes of the user which upload to
+// the storage identified by the key. The entries don't record the storage, so each storage has its own spool.
+func DefaultSpoolPath(storageKey string) string {
+	sum := sha256.Sum256([]byte(storageKey))
+	fileName := spoolFileName + "_" + hex.EncodeToString(sum[:spoolKeyBytes])
+	homeDir, err := os.UserHomeDir()
+	if err == nil {
+		return filepath.Join(homeDir, fileName)
+	}
+	tmpDir := os.TempDir()
+	tracelog.DebugLogger.Printf("Failed to get user HOME dir, will use %q instead: %q", tmpDir, err)
+	return filepath.Join(tmpDir, fileName)
+}
+
+// Spool is a local file collecting the entries of the objects stored right in a directory, such as WAL segments,
+// which are uploaded one by one by separate processes. The manifest of a group is written when the group has
+// SpoolBatchSize entries, when an entry of another group of the same directory comes (e.g. the WAL range has
+// switched), or when the oldest entry of the group is older than SpoolMaxAge, which is checked on every upload
+// and when the uploading command exits. The spool is locked while it's accessed.
+type Spool struct {
+	path string
+	lock *flock.Flock
+}
+
+func NewSpool(path string) *Spool {
+	return &Spool{path: path, lock: flock.New(path + ".lock")}
+}
+
+type spoolEntry struct {
+	Directory string    `json:"directory"`
+	Group     string    `json:"group"`
+	Time      time.Time `json:"time"`
+	Entry     Entry     `json:"entry"`
+}
+
+// add appends the entry to the spool and writes the manifests of the groups which are due.
+func (spool *Spool) add(ctx context.Context, newEntry spoolEntry,
+	writeManifest func(ctx context.Context, group string, entries []Entry) error) error {
+	return spool.update(ctx, &newEntry, false, writeManifest)
+}
+
+// flush writes the manifests of the groups which are due, or of all the groups if force is set.
+func (spool *Spool) flush(ctx context.Context, force bool,
+	writeManifest func(ctx context.Context, group string, entries []Entry) error) error {
+	return spool.update(ctx, nil, force, writeManifest)
+}
+
+func (spool *Spool) update(ctx context.Context, newEntry *spoolEntry, force bool,
+	writeManifest func(ctx context.Context, group string, entries []Entry) error) error {
+	if err := spool.lock.Lock(); err != nil {
+		return fmt.Errorf("lock integrity spool: %w", err)
+	}
+	defer func() { _ = spool.lock.Unlock() }()
+
+	spooled, err := spool.read()
+	if err != nil {
+		return err
+	}
+	if newEntry == nil && len(spooled) == 0 {
+		return nil
+	}
+	if newEntry != nil {
+		spooled = append(spooled, *newEntry)
+	}
+
+	groups := make(map[string][]spoolEntry)
+	for _, entry := range spooled {
+		groups[entry.Group] = append(groups[entry.Group], entry)
+	}
+	now := time.Now()
+	var remaining []spoolEntry
+	failed := 0
+	for _, entry := range spooled {
+		group := groups[entry.Group]
+		if group == nil {
+			// already written or kept
+			continue
+		}
+		delete(groups, entry.Group)
+		isDue := force || len(group) >= SpoolBatchSize ||
+			newEntry != nil && group[0].Directory == newEntry.Directory && group[0].Group != newEntry.Group ||
+			now.Sub(group[0].Time) >= SpoolMaxAge
+		if !isDue {
+			remaining = append(remaining, group...)
+			continue
+		}
+		entries := make([]Entry, 0, len(group))
+		for _, spooledEntry := range group {
+			entries = append(entries, spooledEntry.Entry)
+		}
+		if err := writeManifest(ctx, entry.Group, entries); err != nil {
+			tracelog.WarningLogger.Printf("Failed to upload the integrity manifest, will retry later: %v", err)
+			remaining = append(remaining, group...)
+			failed++
+		}
+	}
+	err = spool.write(remaining)
+	if err == nil && force && failed > 0 {
+		err = fmt.Errorf("failed to upload %d integrity manifests", failed)
+	}
+	return err
+}
+
+func (spool *Spool) read() ([]spoolEntry, error) {
+	content, err := os.ReadFile(spool.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read integrity spool: %w", err)
+	}
+
+	var entries []spoolEntry
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		var entry spoolEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a line may be torn if a writer crashed
+			tracelog.WarningLogger.Printf("Skipping invalid integrity spool entry %q: %v", scanner.Text(), err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("parse integrity spool: %w", err)
+	}
+	return entries, nil
+}
+
+func (spool *Spool) write(entries []spoolEntry) error {
+	var lines bytes.Buffer
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("marshal integrity spool entry: %w", err)
+		}
+		lines.Write(line)
+		lines.WriteByte('\n')
+	}
+	tmpPath := spool.path + ".tmp"
+	if err := os.WriteFile(tmpPath, lines.Bytes(), 0600); err != nil {
+		return fmt.Errorf("write integrity spool: %w", err)
+	}
+	if err := os.Rename(tmpPath, spool.path); err != nil {
+		return fmt.Errorf("write integrity spool: %w", err)
+	}
+	return nil
+}
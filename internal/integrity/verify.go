@@ -0,0 +1,160 @@
+package integrity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+type Mismatch struct {
+	Path   string
+	Reason string
+}
+
+type Report struct {
+	Verified  int
+	Missing   []string
+	Corrupted []Mismatch
+}
+
+// Verify streams back every object under the prefix that has an entry in the integrity manifests and compares its
+// size and SHA-256 with the recorded ones. If an object was uploaded several times, the latest entry is used.
+// The prefix is relative to the storage root.
+func Verify(ctx context.Context, rootFolder storage.Folder, prefix string, concurrency int) (*Report, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	entries, err := loadEntries(ctx, rootFolder, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	mutex := sync.Mutex{}
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(max(concurrency, 1))
+	for _, entry := range entries {
+		errGroup.Go(func() error {
+			reason, err := verifyObject(groupCtx, rootFolder, entry)
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch {
+			case isNotFound(err):
+				report.Missing = append(report.Missing, entry.Path)
+			case err != nil:
+				return err
+			case reason != "":
+				report.Corrupted = append(report.Corrupted, Mismatch{Path: entry.Path, Reason: reason})
+			default:
+				report.Verified++
+			}
+			return nil
+		})
+	}
+	err = errGroup.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(report.Missing)
+	sort.Slice(report.Corrupted, func(i, j int) bool {
+		return report.Corrupted[i].Path < report.Corrupted[j].Path
+	})
+	return report, nil
+}
+
+func verifyObject(ctx context.Context, rootFolder storage.Folder, entry Entry) (string, error) {
+	reader, err := rootFolder.ReadObject(ctx, entry.Path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, reader)
+	if err != nil {
+		return "", fmt.Errorf("read object %q: %w", entry.Path, err)
+	}
+	if size != entry.Size {
+		return fmt.Sprintf("size is %d, expected %d", size, entry.Size), nil
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if checksum != entry.SHA256 {
+		return fmt.Sprintf("SHA-256 is %s, expected %s", checksum, entry.SHA256), nil
+	}
+	return "", nil
+}
+
+// loadEntries reads the manifests which may contain entries for the prefix, from the oldest to the newest. The order
+// is taken from the manifest names, which start with the upload time, because the modification time changes when
+// the storage is copied.
+func loadEntries(ctx context.Context, rootFolder storage.Folder, prefix string) ([]Entry, error) {
+	manifestsFolder := rootFolder.GetSubFolder(FolderName)
+	if dir := path.Dir(strings.TrimSuffix(prefix, "/")); dir != "." {
+		manifestsFolder = manifestsFolder.GetSubFolder(dir)
+	}
+	manifestObjects, err := storage.ListFolderRecursively(ctx, manifestsFolder)
+	if err != nil {
+		return nil, fmt.Errorf("list integrity manifests: %w", err)
+	}
+	sort.Slice(manifestObjects, func(i, j int) bool {
+		iName, jName := path.Base(manifestObjects[i].GetName()), path.Base(manifestObjects[j].GetName())
+		if iName != jName {
+			return iName < jName
+		}
+		return manifestObjects[i].GetName() < manifestObjects[j].GetName()
+	})
+
+	latest := make(map[string]Entry)
+	for _, object := range manifestObjects {
+		if !strings.HasSuffix(object.GetName(), ManifestSuffix) {
+			continue
+		}
+		manifest, err := readManifest(ctx, manifestsFolder, object.GetName())
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range manifest.Entries {
+			if strings.HasPrefix(entry.Path, prefix) {
+				latest[entry.Path] = entry
+			}
+		}
+	}
+
+	entries := make([]Entry, 0, len(latest))
+	for _, entry := range latest {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+func readManifest(ctx context.Context, folder storage.Folder, manifestPath string) (*Manifest, error) {
+	reader, err := folder.ReadObject(ctx, manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("read integrity manifest %q: %w", manifestPath, err)
+	}
+	defer reader.Close()
+
+	manifest := &Manifest{}
+	err = json.NewDecoder(reader).Decode(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("decode integrity manifest %q: %w", manifestPath, err)
+	}
+	return manifest, nil
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(storage.ObjectNotFoundError)
+	return ok
+}
@@ -0,0 +1,170 @@
+package integrity_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/integrity"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func putAndRecord(t *testing.T, folder storage.Folder, recorder *integrity.Recorder, directory, name, content string) {
+	require.NoError(t, folder.GetSubFolder(directory).PutObject(context.Background(), name, strings.NewReader(content)))
+	sum := sha256.Sum256([]byte(content))
+	require.NoError(t, recorder.Record(context.Background(), directory, name, int64(len(content)), hex.EncodeToString(sum[:])))
+}
+
+func listManifests(t *testing.T, folder storage.Folder) []string {
+	objects, err := storage.ListFolderRecursively(context.Background(), folder.GetSubFolder(integrity.FolderName))
+	require.NoError(t, err)
+	var names []string
+	for _, object := range objects {
+		names = append(names, path.Dir(object.GetName()))
+	}
+	return names
+}
+
+func TestGroupOf(t *testing.T) {
+	assert.Equal(t, "basebackups_005/base_000000010000000000000002",
+		integrity.GroupOf("basebackups_005", "base_000000010000000000000002/tar_partitions/part_1.tar.lz4"))
+	assert.Equal(t, "basebackups_005/base_000000010000000000000002",
+		integrity.GroupOf("basebackups_005", "base_000000010000000000000002_backup_stop_sentinel.json"))
+	assert.Equal(t, "wal_005/0000000100000000",
+		integrity.GroupOf("wal_005", "000000010000000000000002.lz4"))
+	assert.Equal(t, "wal_005", integrity.GroupOf("wal_005", "00000002.history.lz4"))
+	assert.Equal(t, "", integrity.GroupOf("", "object"))
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	recorder := integrity.NewRecorder(folder, nil)
+	putAndRecord(t, folder, recorder, "basebackups_005", "base_1/part_1", "first part")
+	putAndRecord(t, folder, recorder, "basebackups_005", "base_1/part_2", "second part")
+	putAndRecord(t, folder, recorder, "basebackups_005", "base_1/part_3", "third part")
+	putAndRecord(t, folder, recorder, "wal_005", "000000010000000000000001", "segment")
+	require.NoError(t, recorder.Flush(ctx))
+
+	report, err := integrity.Verify(ctx, folder, "basebackups_005/base_1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Verified)
+	assert.Empty(t, report.Missing)
+	assert.Empty(t, report.Corrupted)
+
+	require.NoError(t, folder.PutObject(ctx, "basebackups_005/base_1/part_2", strings.NewReader("second pari")))
+	require.NoError(t, folder.DeleteObjects(ctx, []storage.Object{
+		storage.NewLocalObject("basebackups_005/base_1/part_3", time.Time{}, 0),
+	}))
+
+	report, err = integrity.Verify(ctx, folder, "/", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Verified)
+	assert.Equal(t, []string{"basebackups_005/base_1/part_3"}, report.Missing)
+	require.Len(t, report.Corrupted, 1)
+	assert.Equal(t, "basebackups_005/base_1/part_2", report.Corrupted[0].Path)
+}
+
+func TestVerify_UsesLatestEntry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	folder := memory.NewFolder("in_memory/", memory.NewKVS(memory.WithCustomTime(func() time.Time { return now })))
+	recorder := integrity.NewRecorder(folder, nil)
+	putAndRecord(t, folder, recorder, "wal_005", "segment", "old content")
+	require.NoError(t, recorder.Flush(ctx))
+	now = now.Add(time.Minute)
+	putAndRecord(t, folder, recorder, "wal_005", "segment", "new content")
+	require.NoError(t, recorder.Flush(ctx))
+
+	report, err := integrity.Verify(ctx, folder, "wal_005/", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Verified)
+	assert.Empty(t, report.Corrupted)
+}
+
+func TestRecorder_BatchesFlatObjectsInSpool(t *testing.T) {
+	ctx := context.Background()
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	spool := integrity.NewSpool(filepath.Join(t.TempDir(), "spool"))
+	recorder := integrity.NewRecorder(folder, spool)
+	putAndRecord(t, folder, recorder, "wal_005", "000000010000000000000001.lz4", "first segment")
+	putAndRecord(t, folder, recorder, "wal_005", "000000010000000000000002.lz4", "second segment")
+	assert.Empty(t, listManifests(t, folder))
+
+	// the WAL range has switched, so the batch of the previous range is complete
+	putAndRecord(t, folder, recorder, "wal_005", "000000010000000100000000.lz4", "third segment")
+	assert.Equal(t, []string{"wal_005/0000000100000000"}, listManifests(t, folder))
+	report, err := integrity.Verify(ctx, folder, "wal_005/", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Verified)
+
+	for i := 1; i < integrity.SpoolBatchSize; i++ {
+		putAndRecord(t, folder, recorder, "wal_005", fmt.Sprintf("0000000100000001%08X.lz4", i), "segment")
+	}
+	assert.ElementsMatch(t, []string{"wal_005/0000000100000000", "wal_005/0000000100000001"}, listManifests(t, folder))
+	report, err = integrity.Verify(ctx, folder, "wal_005/", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2+integrity.SpoolBatchSize, report.Verified)
+}
+
+func TestDeleteManifests(t *testing.T) {
+	ctx := context.Background()
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	recorder := integrity.NewRecorder(folder, nil)
+	putAndRecord(t, folder, recorder, "basebackups_005", "base_1/part_1", "first part")
+	putAndRecord(t, folder, recorder, "basebackups_005", "base_1_backup_stop_sentinel.json", "{}")
+	putAndRecord(t, folder, recorder, "basebackups_005", "base_2/part_1", "first part")
+	putAndRecord(t, folder, recorder, "basebackups_005", "base_2/part_2", "second part")
+	require.NoError(t, recorder.Flush(ctx))
+
+	deleted := []string{"basebackups_005/base_1/part_1", "basebackups_005/base_1_backup_stop_sentinel.json",
+		"basebackups_005/base_2/part_1"}
+	objects := make([]storage.Object, 0, len(deleted))
+	for _, name := range deleted {
+		objects = append(objects, storage.NewLocalObject(name, time.Time{}, 0))
+	}
+	require.NoError(t, folder.DeleteObjects(ctx, objects))
+	require.NoError(t, integrity.DeleteManifests(ctx, folder, deleted))
+
+	// base_2/part_2 still exists, so its manifest is kept
+	assert.Equal(t, []string{"basebackups_005/base_2"}, listManifests(t, folder))
+}
+
+func TestRecorder_FlushesSpool(t *testing.T) {
+	ctx := context.Background()
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	spoolPath := filepath.Join(t.TempDir(), "spool")
+	// the entry was spooled long ago, and no segment has been uploaded since then
+	staleEntry := fmt.Sprintf(`{"directory":"wal_005","group":"wal_005/0000000100000000","time":%q,`+
+		`"entry":{"path":"wal_005/000000010000000000000001.lz4","size":1,"sha256":"00"}}`,
+		time.Now().Add(-integrity.SpoolMaxAge).Format(time.RFC3339Nano))
+	require.NoError(t, os.WriteFile(spoolPath, []byte(staleEntry+"\n"), 0600))
+	recorder := integrity.NewRecorder(folder, integrity.NewSpool(spoolPath))
+	putAndRecord(t, folder, recorder, "basebackups_005", "base_1/part_1", "first part")
+	putAndRecord(t, folder, recorder, "wal_005", "000000010000000100000001.lz4", "segment")
+
+	require.NoError(t, recorder.Flush(ctx))
+	assert.ElementsMatch(t, []string{"basebackups_005/base_1", "wal_005/0000000100000000"}, listManifests(t, folder))
+
+	require.NoError(t, recorder.FlushSpool(ctx))
+	assert.ElementsMatch(t, []string{"basebackups_005/base_1", "wal_005/0000000100000000", "wal_005/0000000100000001"},
+		listManifests(t, folder))
+	content, err := os.ReadFile(spoolPath)
+	require.NoError(t, err)
+	assert.Empty(t, content)
+}
+
+func TestDefaultSpoolPath(t *testing.T) {
+	assert.Equal(t, integrity.DefaultSpoolPath("default#1234"), integrity.DefaultSpoolPath("default#1234"))
+	assert.NotEqual(t, integrity.DefaultSpoolPath("default#1234"), integrity.DefaultSpoolPath("default#5678"))
+}
@@ -52,6 +52,24 @@ func (cf OnCloseFlusher) Close() error {
 	return cf.Flush()
 }
 
+// FailedWriter reports the error of the writer creation, e.g. of a compressor with invalid settings, from every call,
+// so the upload fails instead of the whole process
+type FailedWriter struct {
+	Err error
+}
+
+func (writer FailedWriter) Write([]byte) (int, error) {
+	return 0, writer.Err
+}
+
+func (writer FailedWriter) Flush() error {
+	return writer.Err
+}
+
+func (writer FailedWriter) Close() error {
+	return writer.Err
+}
+
 // ZeroReader generates a slice of zeroes. Used to pad
 // tar in cases where length of file changes.
 type ZeroReader struct{}
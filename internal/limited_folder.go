@@ -3,6 +3,7 @@ package internal
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/wal-g/wal-g/internal/ioextensions"
 	"github.com/wal-g/wal-g/internal/limiters"
@@ -15,8 +16,14 @@ type LimitedFolder struct {
 	limiter *rate.Limiter
 }
 
-func NewLimitedFolder(folder storage.Folder, limiter *rate.Limiter) *LimitedFolder {
-	return &LimitedFolder{Folder: folder, limiter: limiter}
+// NewLimitedFolder wraps the folder to limit its traffic. The result implements storage.RangeReader
+// only if the folder does, so that ranged reads are not attempted on the storages that don't support them.
+func NewLimitedFolder(folder storage.Folder, limiter *rate.Limiter) storage.Folder {
+	limitedFolder := &LimitedFolder{Folder: folder, limiter: limiter}
+	if _, ok := folder.(storage.RangeReader); ok {
+		return &rangeLimitedFolder{limitedFolder}
+	}
+	return limitedFolder
 }
 
 func (lf *LimitedFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
@@ -35,6 +42,26 @@ func (lf *LimitedFolder) ReadObject(ctx context.Context, objectRelativePath stri
 	}, nil
 }
 
+// rangeLimitedFolder is the LimitedFolder of a folder that supports ranged reads
+type rangeLimitedFolder struct {
+	*LimitedFolder
+}
+
+func (lf *rangeLimitedFolder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	readCloser, err := storage.ReadObjectRange(ctx, lf.Folder, objectRelativePath, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return ioextensions.ReadCascadeCloser{
+		Reader: limiters.NewReader(ctx, readCloser, lf.limiter),
+		Closer: readCloser,
+	}, nil
+}
+
 func (lf *LimitedFolder) PutObject(ctx context.Context, name string, content io.Reader) error {
 	limitedReader := limiters.NewReader(ctx, content, lf.limiter)
 	return lf.Folder.PutObject(ctx, name, limitedReader)
@@ -45,3 +72,27 @@ func (lf *LimitedFolder) PutObject(ctx context.Context, name string, content io.
 func (lf *LimitedFolder) SetShowAllVersions(show bool) {
 	storage.SetShowAllVersions(lf.Folder, show)
 }
+
+func (lf *LimitedFolder) IsRetentionEnabled() bool {
+	return storage.IsRetentionEnabled(lf.Folder)
+}
+
+func (lf *LimitedFolder) SetRetention(ctx context.Context, objectRelativePath string, until time.Time) error {
+	return storage.SetRetention(ctx, lf.Folder, objectRelativePath, until)
+}
+
+func (lf *LimitedFolder) GetRetention(ctx context.Context, objectRelativePath string) (time.Time, error) {
+	return storage.GetRetention(ctx, lf.Folder, objectRelativePath)
+}
+
+func (lf *LimitedFolder) GetStorageClass(ctx context.Context, objectRelativePath string) (string, error) {
+	return storage.GetStorageClass(ctx, lf.Folder, objectRelativePath)
+}
+
+func (lf *LimitedFolder) SetStorageClass(ctx context.Context, objectRelativePath string, storageClass string) error {
+	return storage.SetStorageClass(ctx, lf.Folder, objectRelativePath, storageClass)
+}
+
+func (lf *LimitedFolder) RestoreObject(ctx context.Context, objectRelativePath string, days int) (bool, error) {
+	return storage.RestoreObject(ctx, lf.Folder, objectRelativePath, days)
+}
@@ -0,0 +1,44 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/fs"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"golang.org/x/time/rate"
+)
+
+func TestLimitedFolder_FsFolderIsNotRangeReader(t *testing.T) {
+	content := bytes.Repeat([]byte("wal-g"), 200)
+	folder := fs.NewFolder(t.TempDir(), "")
+	require.NoError(t, folder.PutObject(context.Background(), "object", bytes.NewReader(content)))
+
+	limitedFolder := internal.NewLimitedFolder(folder, rate.NewLimiter(rate.Inf, 1024))
+	_, isRangeReader := limitedFolder.(storage.RangeReader)
+	assert.False(t, isRangeReader)
+	_, isRangeReader = limitedFolder.GetSubFolder("").(storage.RangeReader)
+	assert.False(t, isRangeReader)
+
+	config := storage.ParallelReadConfig{PartSize: 64, Concurrency: 4, PartRetries: 1}
+	reader, err := storage.ReadObjectParallel(context.Background(), limitedFolder, "object", config)
+	require.NoError(t, err)
+	actual, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, content, actual)
+}
+
+func TestLimitedFolder_MemoryFolderIsRangeReader(t *testing.T) {
+	limitedFolder := internal.NewLimitedFolder(memory.NewFolder("test/", memory.NewKVS()), rate.NewLimiter(rate.Inf, 1024))
+	_, isRangeReader := limitedFolder.(storage.RangeReader)
+	assert.True(t, isRangeReader)
+	_, isRangeReader = limitedFolder.GetSubFolder("sub").(storage.RangeReader)
+	assert.True(t, isRangeReader)
+}
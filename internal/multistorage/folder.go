@@ -8,11 +8,13 @@ import (
 	"io"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/multistorage/consts"
 	"github.com/wal-g/wal-g/internal/multistorage/policies"
 	"github.com/wal-g/wal-g/internal/multistorage/stats"
+	"github.com/wal-g/wal-g/internal/multistorage/stats/cache"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
 
@@ -107,6 +109,30 @@ func UsedStorages(folder storage.Folder) []string {
 	return storageNames
 }
 
+// StorageKey provides the key of the storage with the name, like UsedStorageKeys does.
+func StorageKey(name string, st storage.HashableStorage) string {
+	return cache.Key{Name: name, Hash: st.ConfigHash()}.String()
+}
+
+// UsedStorageKeys provides the keys of the used storages, which identify them among all WAL-G configurations. The key
+// has no config hash if it's unknown.
+func UsedStorageKeys(folder storage.Folder) []string {
+	mf, ok := folder.(Folder)
+	if !ok {
+		return []string{consts.DefaultStorage}
+	}
+
+	var keys []string
+	for _, s := range mf.usedFolders {
+		key, ok := mf.storageKeys[s.StorageName]
+		if !ok {
+			key = cache.Key{Name: s.StorageName}
+		}
+		keys = append(keys, key.String())
+	}
+	return keys
+}
+
 func EnsureSingleStorageIsUsed(folder storage.Folder) error {
 	storages := UsedStorages(folder)
 	if len(storages) != 1 {
@@ -134,6 +160,16 @@ func SetPolicies(folder storage.Folder, policies policies.Policies) storage.Fold
 	return folder
 }
 
+// SetRepairJournal makes a copy of the Folder that records the objects put to a quorum of storages, but not to all
+// configured storages, to the journal, so that they can be repaired later.
+func SetRepairJournal(folder storage.Folder, journal *RepairJournal) storage.Folder {
+	if mf, ok := folder.(Folder); ok {
+		mf.repairJournal = journal
+		return mf
+	}
+	return folder
+}
+
 func NewFolder(specificFolders map[string]storage.Folder, statsCollector stats.Collector) storage.Folder {
 	return Folder{
 		statsCollector:        statsCollector,
@@ -152,6 +188,9 @@ type Folder struct {
 	usedFolders           []NamedFolder
 	path                  string
 	policies              policies.Policies
+	repairJournal         *RepairJournal
+	// storageKeys identify the configured storages among all WAL-G configurations, they're unknown in tests
+	storageKeys map[string]cache.Key
 }
 
 // GetPath provides the base path that is common for all the storages.
@@ -170,6 +209,8 @@ func (mf Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
 		configuredRootFolders: mf.configuredRootFolders,
 		path:                  newPath,
 		policies:              mf.policies,
+		repairJournal:         mf.repairJournal,
+		storageKeys:           mf.storageKeys,
 	}
 	multiSubfolder.usedFolders = make([]NamedFolder, len(mf.usedFolders))
 	for i := range mf.usedFolders {
@@ -380,6 +421,57 @@ func (mf Folder) ReadObjectFoundFirst(ctx context.Context, objectRelativePath st
 	return nil, consts.AllStorages, storage.NewObjectNotFoundError(objectRelativePath)
 }
 
+// ReadObjectRange reads a part of the object from the storage selected by the same policy as ReadObject does. Returns
+// storage.ErrRangeReadNotSupported if the selected storage doesn't support ranged reads.
+func (mf Folder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	if len(mf.usedFolders) == 0 {
+		return nil, ErrNoUsedStorages
+	}
+	switch mf.policies.Read {
+	case policies.ReadPolicyFirst:
+		return mf.readObjectRange(ctx, mf.usedFolders[0], objectRelativePath, offset, length)
+	case policies.ReadPolicyFoundFirst:
+		for _, f := range mf.usedFolders {
+			exists, err := f.Exists(ctx, objectRelativePath)
+			if err != nil {
+				mf.statsCollector.ReportOperationResult(f.StorageName, stats.OperationExists, false)
+				return nil, fmt.Errorf("check file for existence in %q: %w", f.StorageName, err)
+			}
+			if exists {
+				return mf.readObjectRange(ctx, f, objectRelativePath, offset, length)
+			}
+		}
+		return nil, storage.NewObjectNotFoundError(objectRelativePath)
+	default:
+		panic(fmt.Sprintf("unknown read object policy %d", mf.policies.Read))
+	}
+}
+
+func (mf Folder) readObjectRange(
+	ctx context.Context,
+	folder NamedFolder,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	file, err := storage.ReadObjectRange(ctx, folder.Folder, objectRelativePath, offset, length)
+	if errors.Is(err, storage.ErrRangeReadNotSupported) {
+		return nil, err
+	}
+	if err != nil {
+		if _, ok := err.(storage.ObjectNotFoundError); ok {
+			mf.statsCollector.ReportOperationResult(folder.StorageName, stats.OperationRead(0), true)
+			return nil, err
+		}
+		mf.statsCollector.ReportOperationResult(folder.StorageName, stats.OperationRead(0), false)
+		return nil, fmt.Errorf("read object range from %q: %w", folder.StorageName, err)
+	}
+	return newReportReadCloser(file, mf.statsCollector, folder.StorageName), nil
+}
+
 // ListFolder lists the folder in multiple storages. A specific implementation is selected using policies.Policies
 func (mf Folder) ListFolder(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
 	switch mf.policies.List {
@@ -505,6 +597,8 @@ func (mf Folder) PutObject(ctx context.Context, name string, content io.Reader)
 		return mf.PutObjectToAll(ctx, name, content)
 	case policies.PutPolicyUpdateAllFound:
 		return mf.PutObjectOrUpdateAllFound(ctx, name, content)
+	case policies.PutPolicyAllQuorum:
+		return mf.PutObjectToAllWithQuorum(ctx, name, content, mf.policies.PutQuorum)
 	default:
 		panic(fmt.Sprintf("unknown put policy %d", mf.policies.Put))
 	}
@@ -575,6 +669,66 @@ func (mf Folder) PutObjectToAll(ctx context.Context, name string, content io.Rea
 	return nil
 }
 
+// PutObjectToAllWithQuorum puts the object to all used storages in parallel, and succeeds as soon as quorum of them
+// have acknowledged it. If quorum is not positive, all storages must acknowledge the object. The remaining uploads
+// are finished in the background. Unless all configured storages have acknowledged the object by the time the result
+// is known, it's recorded to the repair journal, so that RepairMissingObjects catches up the other storages.
+func (mf Folder) PutObjectToAllWithQuorum(ctx context.Context, name string, content io.Reader, quorum int) error {
+	if len(mf.usedFolders) == 0 {
+		return ErrNoUsedStorages
+	}
+	if quorum <= 0 {
+		quorum = len(mf.usedFolders)
+	}
+	if quorum > len(mf.usedFolders) {
+		return fmt.Errorf("put quorum is %d, but only %d storages are used: %v", quorum, len(mf.usedFolders), UsedStorages(mf))
+	}
+
+	buffer, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("read file content to save in a temporary buffer: %w", err)
+	}
+	bufferSize := int64(len(buffer))
+	objectPath := path.Join(mf.path, name)
+
+	// The uploads that are left in the background must not be canceled when the caller is done
+	putCtx := context.WithoutCancel(ctx)
+	results := make(chan error, len(mf.usedFolders))
+	for _, f := range mf.usedFolders {
+		go func() {
+			err := f.PutObject(putCtx, name, bytes.NewReader(buffer))
+			mf.statsCollector.ReportOperationResult(f.StorageName, stats.OperationPut(bufferSize), err == nil)
+			if err != nil {
+				tracelog.WarningLogger.Printf("Object %q is not uploaded to storage %q: %v", objectPath, f.StorageName, err)
+				err = fmt.Errorf("put object to storage %q: %w", f.StorageName, err)
+			}
+			results <- err
+		}()
+	}
+
+	acknowledged := 0
+	var errs []error
+	for acknowledged < quorum && len(mf.usedFolders)-len(errs) >= quorum {
+		if err := <-results; err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		acknowledged++
+	}
+	if acknowledged < quorum {
+		return fmt.Errorf("object is uploaded to %d storages, but the quorum is %d: %w",
+			acknowledged, quorum, errors.Join(errs...))
+	}
+
+	if acknowledged < len(mf.configuredRootFolders) && mf.repairJournal != nil {
+		err = mf.repairJournal.Add(RepairJournalEntry{Path: objectPath, Time: time.Now()})
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to record object %q to the repair journal: %v", objectPath, err)
+		}
+	}
+	return nil
+}
+
 // PutObjectOrUpdateAllFound updates the object in all storages where it is found. If it's not found anywhere, uploads a
 // new object to the first storage.
 func (mf Folder) PutObjectOrUpdateAllFound(ctx context.Context, name string, content io.Reader) error {
@@ -753,6 +907,128 @@ func (mf Folder) SetShowAllVersions(show bool) {
 	}
 }
 
+// IsRetentionEnabled checks if objects may be locked in any of the used storages.
+func (mf Folder) IsRetentionEnabled() bool {
+	for _, f := range mf.usedFolders {
+		if storage.IsRetentionEnabled(f.Folder) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRetention locks the object in all used storages where it exists.
+func (mf Folder) SetRetention(ctx context.Context, objectRelativePath string, until time.Time) error {
+	if len(mf.usedFolders) == 0 {
+		return ErrNoUsedStorages
+	}
+	found := false
+	for _, f := range mf.usedFolders {
+		err := storage.SetRetention(ctx, f.Folder, objectRelativePath, until)
+		if _, ok := err.(storage.ObjectNotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("set retention in storage %q: %w", f.StorageName, err)
+		}
+		found = true
+	}
+	if !found {
+		return storage.NewObjectNotFoundError(objectRelativePath)
+	}
+	return nil
+}
+
+// GetRetention provides the latest time until which the object is locked in any of the used storages.
+func (mf Folder) GetRetention(ctx context.Context, objectRelativePath string) (time.Time, error) {
+	if len(mf.usedFolders) == 0 {
+		return time.Time{}, ErrNoUsedStorages
+	}
+	found := false
+	var latest time.Time
+	for _, f := range mf.usedFolders {
+		until, err := storage.GetRetention(ctx, f.Folder, objectRelativePath)
+		if _, ok := err.(storage.ObjectNotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("get retention in storage %q: %w", f.StorageName, err)
+		}
+		found = true
+		if until.After(latest) {
+			latest = until
+		}
+	}
+	if !found {
+		return time.Time{}, storage.NewObjectNotFoundError(objectRelativePath)
+	}
+	return latest, nil
+}
+
+// GetStorageClass provides the storage class of the object in the first used storage where it's found.
+func (mf Folder) GetStorageClass(ctx context.Context, objectRelativePath string) (string, error) {
+	if len(mf.usedFolders) == 0 {
+		return "", ErrNoUsedStorages
+	}
+	for _, f := range mf.usedFolders {
+		storageClass, err := storage.GetStorageClass(ctx, f.Folder, objectRelativePath)
+		if _, ok := err.(storage.ObjectNotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("get storage class in storage %q: %w", f.StorageName, err)
+		}
+		return storageClass, nil
+	}
+	return "", storage.NewObjectNotFoundError(objectRelativePath)
+}
+
+// SetStorageClass moves the object to the storage class in all used storages where it exists.
+func (mf Folder) SetStorageClass(ctx context.Context, objectRelativePath string, storageClass string) error {
+	if len(mf.usedFolders) == 0 {
+		return ErrNoUsedStorages
+	}
+	found := false
+	for _, f := range mf.usedFolders {
+		err := storage.SetStorageClass(ctx, f.Folder, objectRelativePath, storageClass)
+		if _, ok := err.(storage.ObjectNotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("set storage class in storage %q: %w", f.StorageName, err)
+		}
+		found = true
+	}
+	if !found {
+		return storage.NewObjectNotFoundError(objectRelativePath)
+	}
+	return nil
+}
+
+// RestoreObject restores the object in all used storages where it exists. It's ready when it's ready everywhere.
+func (mf Folder) RestoreObject(ctx context.Context, objectRelativePath string, days int) (bool, error) {
+	if len(mf.usedFolders) == 0 {
+		return false, ErrNoUsedStorages
+	}
+	found := false
+	allReady := true
+	for _, f := range mf.usedFolders {
+		ready, err := storage.RestoreObject(ctx, f.Folder, objectRelativePath, days)
+		if _, ok := err.(storage.ObjectNotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("restore object in storage %q: %w", f.StorageName, err)
+		}
+		found = true
+		allReady = allReady && ready
+	}
+	if !found {
+		return false, storage.NewObjectNotFoundError(objectRelativePath)
+	}
+	return allReady, nil
+}
+
 var (
 	ErrNoUsedStorages  = fmt.Errorf("no storages are used")
 	ErrNoAliveStorages = fmt.Errorf("no alive storages")
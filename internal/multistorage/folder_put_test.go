@@ -2,7 +2,10 @@ package multistorage
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -121,4 +124,116 @@ func TestPutObject(t *testing.T) {
 		content, _ = io.ReadAll(reader)
 		assert.Equal(t, "new_content", string(content))
 	})
+	t.Run("put to all storages with quorum", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies = policies.ReplicateToAllStorages(2)
+		folder.usedFolders[1].Folder = failingPutFolder{folder.usedFolders[1].Folder}
+
+		err := folder.PutObject(t.Context(), "a/b/c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+
+		for _, i := range []int{0, 2} {
+			reader, err := folder.usedFolders[i].ReadObject(t.Context(), "a/b/c/file")
+			require.NoError(t, err)
+			content, _ := io.ReadAll(reader)
+			assert.Equal(t, "abc", string(content))
+		}
+		_, err = folder.usedFolders[1].ReadObject(t.Context(), "a/b/c/file")
+		assert.ErrorAs(t, err, &storage.ObjectNotFoundError{})
+	})
+
+	t.Run("do not wait for slow storages after quorum is reached", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies = policies.ReplicateToAllStorages(2)
+		folder.repairJournal = NewRepairJournal(filepath.Join(t.TempDir(), "journal"))
+		unblock := make(chan struct{})
+		folder.usedFolders[1].Folder = blockingPutFolder{folder.usedFolders[1].Folder, unblock}
+
+		err := folder.PutObject(t.Context(), "a/b/c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+		close(unblock)
+
+		entries, err := folder.repairJournal.Take()
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "a/b/c/file", entries[0].Path)
+	})
+
+	t.Run("journal objects put to subfolders", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies = policies.ReplicateToAllStorages(2)
+		folder.repairJournal = NewRepairJournal(filepath.Join(t.TempDir(), "journal"))
+		folder.usedFolders[1].Folder = failingPutFolder{folder.usedFolders[1].Folder}
+
+		err := folder.GetSubFolder("a/b").PutObject(t.Context(), "c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+
+		entries, err := folder.repairJournal.Take()
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "a/b/c/file", entries[0].Path)
+	})
+
+	t.Run("do not journal objects put to all storages", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies = policies.ReplicateToAllStorages(3)
+		folder.repairJournal = NewRepairJournal(filepath.Join(t.TempDir(), "journal"))
+
+		err := folder.PutObject(t.Context(), "a/b/c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+
+		entries, err := folder.repairJournal.Take()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("fail if quorum is not reached", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies = policies.ReplicateToAllStorages(3)
+		folder.usedFolders[1].Folder = failingPutFolder{folder.usedFolders[1].Folder}
+
+		err := folder.PutObject(t.Context(), "a/b/c/file", bytes.NewBufferString("abc"))
+		assert.ErrorIs(t, err, errPutFailed)
+	})
+
+	t.Run("require all storages if quorum is not set", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2")
+		folder.policies = policies.ReplicateToAllStorages(0)
+		folder.usedFolders[0].Folder = failingPutFolder{folder.usedFolders[0].Folder}
+
+		err := folder.PutObject(t.Context(), "a/b/c/file", bytes.NewBufferString("abc"))
+		assert.ErrorIs(t, err, errPutFailed)
+	})
+
+	t.Run("fail if fewer storages than quorum are used", func(t *testing.T) {
+		folder := newTestFolder(t, "s1")
+		folder.policies = policies.ReplicateToAllStorages(2)
+
+		err := folder.PutObject(t.Context(), "a/b/c/file", bytes.NewBufferString("abc"))
+		assert.Error(t, err)
+	})
+}
+
+var errPutFailed = errors.New("put failed")
+
+type failingPutFolder struct {
+	storage.Folder
+}
+
+func (f failingPutFolder) PutObject(context.Context, string, io.Reader) error {
+	return errPutFailed
+}
+
+func (f failingPutFolder) GetSubFolder(path string) storage.Folder {
+	return failingPutFolder{f.Folder.GetSubFolder(path)}
+}
+
+type blockingPutFolder struct {
+	storage.Folder
+	unblock <-chan struct{}
+}
+
+func (f blockingPutFolder) PutObject(ctx context.Context, name string, content io.Reader) error {
+	<-f.unblock
+	return f.Folder.PutObject(ctx, name, content)
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/wal-g/wal-g/internal/multistorage/policies"
 	"github.com/wal-g/wal-g/internal/multistorage/stats"
+	"github.com/wal-g/wal-g/internal/multistorage/stats/cache"
 	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"go.uber.org/mock/gomock"
@@ -137,6 +138,24 @@ func TestUsedStorages(t *testing.T) {
 	})
 }
 
+func TestUsedStorageKeys(t *testing.T) {
+	t.Run("provide keys of storages", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2")
+		folder.storageKeys = map[string]cache.Key{"s1": {Name: "s1", Hash: "hash1"}}
+		keys := UsedStorageKeys(folder.GetSubFolder("wal_005"))
+		assert.Equal(t, []string{"s1#hash1", "s2#"}, keys)
+	})
+
+	t.Run("match the key of the storage", func(t *testing.T) {
+		st := &testStorage{hash: "primary_hash", rootFolder: memory.NewFolder("test/", memory.NewKVS())}
+		ms, err := NewStorage(&Config{}, st, nil)
+		require.NoError(t, err)
+		folder, err := UseFirstAliveStorage(t.Context(), ms.RootFolder())
+		require.NoError(t, err)
+		assert.Equal(t, []string{StorageKey("default", st)}, UsedStorageKeys(folder))
+	})
+}
+
 func TestEnsureSingleStorageIsUsed(t *testing.T) {
 	t.Run("no error if storage is single", func(t *testing.T) {
 		folder := newTestFolder(t, "s1")
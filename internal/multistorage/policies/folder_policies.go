@@ -33,6 +33,20 @@ var TakeFirstStorage = Policies{
 	Copy:   CopyPolicyFirst,
 }
 
+// ReplicateToAllStorages implies writing new files to all storages, so that losing any of them doesn't lose the files.
+// A write succeeds once at least quorum storages have acknowledged it, or all of them if quorum is not positive.
+func ReplicateToAllStorages(quorum int) Policies {
+	return Policies{
+		Exists:    ExistsPolicyAny,
+		Read:      ReadPolicyFoundFirst,
+		List:      ListPolicyFoundFirst,
+		Put:       PutPolicyAllQuorum,
+		PutQuorum: quorum,
+		Delete:    DeletePolicyAll,
+		Copy:      CopyPolicyAll,
+	}
+}
+
 // Policies define the behavior of the multi-storage folder in terms of selecting which underlying storages should be
 // used to perform different operations.
 type Policies struct {
@@ -42,6 +56,9 @@ type Policies struct {
 	Put    PutPolicy
 	Delete DeletePolicy
 	Copy   CopyPolicy
+
+	// PutQuorum is the number of storages that must acknowledge a write with PutPolicyAllQuorum.
+	PutQuorum int
 }
 
 type ExistsPolicy int
@@ -74,6 +91,7 @@ const (
 	PutPolicyUpdateFirstFound
 	PutPolicyAll
 	PutPolicyUpdateAllFound
+	PutPolicyAllQuorum
 )
 
 type DeletePolicy int
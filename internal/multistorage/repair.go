@@ -0,0 +1,193 @@
+package multistorage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/stats"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// RepairReport describes the result of a single RepairMissingObjects pass.
+type RepairReport struct {
+	// Checked is the number of distinct objects taken from the repair journal.
+	Checked int
+	// Repaired is the number of object copies uploaded to the storages they were missing from.
+	Repaired int
+	// Failed is the number of object copies that couldn't be uploaded.
+	Failed int
+	// Dropped is the number of objects removed from the repair journal without being copied to all storages.
+	Dropped int
+}
+
+func (report RepairReport) String() string {
+	return fmt.Sprintf("checked=%d, repaired=%d, failed=%d, dropped=%d",
+		report.Checked, report.Repaired, report.Failed, report.Dropped)
+}
+
+// RepairMissingObjects copies the objects listed in the repair journal to the configured storages they are missing
+// from. These are the objects written with policies.PutPolicyAllQuorum while some storages were lagging or dead, so
+// only they are checked, and the storages aren't listed. The objects that can't be copied to some storage yet, e.g.
+// because it's still dead, are left in the journal, unless they were journaled more than window ago. Such objects, as
+// well as the objects that aren't found in any storage, are dropped from the journal with a warning. Storages are
+// selected by the alive checker, and the results of operations are reported to the storage stats.
+func RepairMissingObjects(
+	ctx context.Context,
+	folder storage.Folder,
+	journal *RepairJournal,
+	window time.Duration,
+) (RepairReport, error) {
+	mf, ok := folder.(Folder)
+	if !ok {
+		return RepairReport{}, nil
+	}
+
+	entries, err := journal.Take()
+	if err != nil {
+		return RepairReport{}, fmt.Errorf("take repair journal entries: %w", err)
+	}
+	if len(entries) == 0 {
+		return RepairReport{}, nil
+	}
+
+	var postponed []RepairJournalEntry
+	defer func() {
+		if err := journal.Add(postponed...); err != nil {
+			tracelog.WarningLogger.Printf("Failed to return %d entries to the repair journal: %v", len(postponed), err)
+		}
+	}()
+
+	storageNames, err := mf.statsCollector.AllAliveStorages(ctx)
+	if err != nil {
+		postponed = entries
+		return RepairReport{}, fmt.Errorf("select all alive storages in multistorage folder: %w", err)
+	}
+	folders := make([]NamedFolder, len(storageNames))
+	for i, name := range storageNames {
+		folders[i] = NamedFolder{Folder: mf.configuredRootFolders[name], StorageName: name}
+	}
+	someStoragesDead := len(folders) < len(mf.configuredRootFolders)
+
+	report := RepairReport{Checked: len(entries)}
+	expiredBefore := time.Now().Add(-window)
+	for _, entry := range entries {
+		repaired, failed, found := repairJournaledObject(ctx, mf.statsCollector, folders, entry.Path)
+		report.Repaired += repaired
+		report.Failed += failed
+		switch {
+		case !found && !someStoragesDead:
+			tracelog.WarningLogger.Printf("Object %q to repair isn't found in any storage, dropping it from the repair journal",
+				entry.Path)
+			report.Dropped++
+		case failed == 0 && !someStoragesDead:
+		case entry.Time.After(expiredBefore):
+			postponed = append(postponed, entry)
+		default:
+			tracelog.WarningLogger.Printf("Object %q isn't repaired within %v since %v, dropping it from the repair journal",
+				entry.Path, window, entry.Time.Format(time.RFC3339))
+			report.Dropped++
+		}
+	}
+	return report, nil
+}
+
+// repairJournaledObject copies the object from one of the folders having it to the others, and provides the numbers
+// of uploaded and failed copies, and whether the object is found in any folder.
+func repairJournaledObject(
+	ctx context.Context,
+	statsCollector stats.Collector,
+	folders []NamedFolder,
+	name string,
+) (repaired, failed int, found bool) {
+	var having []int
+	for i, f := range folders {
+		exists, err := f.Exists(ctx, name)
+		statsCollector.ReportOperationResult(f.StorageName, stats.OperationExists, err == nil)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to check object %q in storage %q: %v", name, f.StorageName, err)
+			return 0, 1, true
+		}
+		if exists {
+			having = append(having, i)
+		}
+	}
+	if len(having) == 0 {
+		return 0, 0, false
+	}
+
+	source := folders[having[0]]
+	for i, target := range folders {
+		if slices.Contains(having, i) {
+			continue
+		}
+		err := repairObject(ctx, statsCollector, source, target, name)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to repair object %q in storage %q: %v", name, target.StorageName, err)
+			failed++
+			continue
+		}
+		tracelog.DebugLogger.Printf("Object %q is copied from storage %q to %q", name, source.StorageName, target.StorageName)
+		repaired++
+	}
+	return repaired, failed, true
+}
+
+func repairObject(
+	ctx context.Context,
+	statsCollector stats.Collector,
+	source, target NamedFolder,
+	name string,
+) error {
+	// The object might have been uploaded since it was checked
+	exists, err := target.Exists(ctx, name)
+	statsCollector.ReportOperationResult(target.StorageName, stats.OperationExists, err == nil)
+	if err != nil {
+		return fmt.Errorf("check for existence: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	reader, err := source.ReadObject(ctx, name)
+	if err != nil {
+		statsCollector.ReportOperationResult(source.StorageName, stats.OperationRead(0), false)
+		return fmt.Errorf("read object from storage %q: %w", source.StorageName, err)
+	}
+	defer utility.LoggedClose(reader, "close object reader")
+	countReader := newCountReader(reader)
+
+	err = target.PutObject(ctx, name, countReader)
+	statsCollector.ReportOperationResult(source.StorageName, stats.OperationRead(countReader.ReadBytes()), true)
+	statsCollector.ReportOperationResult(target.StorageName, stats.OperationPut(countReader.ReadBytes()), err == nil)
+	if err != nil {
+		return fmt.Errorf("put object: %w", err)
+	}
+	return nil
+}
+
+// RunRepairLoop runs RepairMissingObjects every interval until the context is done. The journal entries that can't
+// be repaired are kept for the window.
+func RunRepairLoop(ctx context.Context, folder storage.Folder, journal *RepairJournal, interval, window time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := RepairMissingObjects(ctx, folder, journal, window)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to repair multi-storage folder %q: %v", folder.GetPath(), err)
+			continue
+		}
+		if report.Repaired > 0 || report.Failed > 0 || report.Dropped > 0 {
+			tracelog.InfoLogger.Printf("Multi-storage folder %q repaired: %s", folder.GetPath(), report)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package multistorage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/wal-g/tracelog"
+)
+
+const repairJournalFileName = ".walg_multistorage_repair_journal"
+
+// DefaultRepairJournalPath provides the location of the journal shared by wal-push and the daemon that repairs
+// the storages.
+func DefaultRepairJournalPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err == nil {
+		return filepath.Join(homeDir, repairJournalFileName)
+	}
+	tmpDir := os.TempDir()
+	tracelog.DebugLogger.Printf("Failed to get user HOME dir, will use %q instead: %q", tmpDir, err)
+	return filepath.Join(tmpDir, repairJournalFileName)
+}
+
+// RepairJournal is a local file listing the objects that were put with policies.PutPolicyAllQuorum, but are not known
+// to be in all the configured storages. RepairMissingObjects checks only these objects, so it doesn't have to list
+// the storages. The journal is shared by several processes, so it's locked while it's accessed.
+type RepairJournal struct {
+	path string
+	lock *flock.Flock
+}
+
+func NewRepairJournal(path string) *RepairJournal {
+	return &RepairJournal{path: path, lock: flock.New(path + ".lock")}
+}
+
+// RepairJournalEntry is an object that may be missing from some storages.
+type RepairJournalEntry struct {
+	// Path is the path of the object relative to the root of the storages.
+	Path string    `json:"path"`
+	Time time.Time `json:"time"`
+}
+
+// Add appends the entries to the journal.
+func (j *RepairJournal) Add(entries ...RepairJournalEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var lines bytes.Buffer
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("marshal repair journal entry: %w", err)
+		}
+		lines.Write(line)
+		lines.WriteByte('\n')
+	}
+
+	if err := j.lock.Lock(); err != nil {
+		return fmt.Errorf("lock repair journal: %w", err)
+	}
+	defer func() { _ = j.lock.Unlock() }()
+
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open repair journal: %w", err)
+	}
+	_, err = file.Write(lines.Bytes())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write repair journal: %w", err)
+	}
+	return nil
+}
+
+// Take removes all the entries from the journal and provides them. The entries that aren't repaired should be added
+// back.
+func (j *RepairJournal) Take() ([]RepairJournalEntry, error) {
+	if err := j.lock.Lock(); err != nil {
+		return nil, fmt.Errorf("lock repair journal: %w", err)
+	}
+	defer func() { _ = j.lock.Unlock() }()
+
+	content, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read repair journal: %w", err)
+	}
+
+	var entries []RepairJournalEntry
+	unique := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		var entry RepairJournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a line may be torn if a writer crashed
+			tracelog.WarningLogger.Printf("Skipping invalid repair journal entry %q: %v", scanner.Text(), err)
+			continue
+		}
+		if unique[entry.Path] {
+			continue
+		}
+		unique[entry.Path] = true
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("parse repair journal: %w", err)
+	}
+
+	if err := os.Truncate(j.path, 0); err != nil {
+		return nil, fmt.Errorf("truncate repair journal: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,109 @@
+package multistorage
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/multistorage/stats"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func TestRepairMissingObjects(t *testing.T) {
+	newJournal := func(t *testing.T, paths ...string) *RepairJournal {
+		journal := NewRepairJournal(filepath.Join(t.TempDir(), "journal"))
+		for _, p := range paths {
+			require.NoError(t, journal.Add(RepairJournalEntry{Path: p, Time: time.Now()}))
+		}
+		return journal
+	}
+
+	t.Run("copy journaled objects to lagging storages", func(t *testing.T) {
+		folder := newTestFolder(t)
+		folder.statsCollector = stats.NewNopCollector([]string{"s1", "s2", "s3"})
+		s1, s2, s3 := folder.configuredRootFolders["s1"], folder.configuredRootFolders["s2"], folder.configuredRootFolders["s3"]
+
+		require.NoError(t, s1.PutObject(t.Context(), "wal/a", bytes.NewBufferString("a")))
+		require.NoError(t, s2.PutObject(t.Context(), "wal/a", bytes.NewBufferString("a")))
+		require.NoError(t, s2.PutObject(t.Context(), "wal/b", bytes.NewBufferString("b")))
+		journal := newJournal(t, "wal/a", "wal/b")
+
+		report, err := RepairMissingObjects(t.Context(), folder, journal, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, RepairReport{Checked: 2, Repaired: 3}, report)
+
+		for _, f := range []storage.Folder{s1, s2, s3} {
+			for _, name := range []string{"a", "b"} {
+				reader, err := f.ReadObject(t.Context(), "wal/"+name)
+				require.NoError(t, err)
+				content, _ := io.ReadAll(reader)
+				assert.Equal(t, name, string(content))
+			}
+		}
+		entries, err := journal.Take()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("skip objects that are not journaled", func(t *testing.T) {
+		folder := newTestFolder(t)
+		folder.statsCollector = stats.NewNopCollector([]string{"s1", "s2"})
+		s1, s2 := folder.configuredRootFolders["s1"], folder.configuredRootFolders["s2"]
+
+		require.NoError(t, s1.PutObject(t.Context(), "wal/a", bytes.NewBufferString("a")))
+
+		report, err := RepairMissingObjects(t.Context(), folder, newJournal(t), time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, RepairReport{}, report)
+
+		exists, err := s2.Exists(t.Context(), "wal/a")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("keep failed copies in the journal within the window", func(t *testing.T) {
+		folder := newTestFolder(t)
+		folder.statsCollector = stats.NewNopCollector([]string{"s1", "s2"})
+		s1 := folder.configuredRootFolders["s1"]
+		folder.configuredRootFolders["s2"] = failingPutFolder{folder.configuredRootFolders["s2"]}
+
+		require.NoError(t, s1.PutObject(t.Context(), "wal/a", bytes.NewBufferString("a")))
+		journal := newJournal(t, "wal/a")
+
+		report, err := RepairMissingObjects(t.Context(), folder, journal, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, RepairReport{Checked: 1, Failed: 1}, report)
+
+		entries, err := journal.Take()
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "wal/a", entries[0].Path)
+
+		require.NoError(t, journal.Add(RepairJournalEntry{Path: "wal/a", Time: time.Now().Add(-2 * time.Hour)}))
+		report, err = RepairMissingObjects(t.Context(), folder, journal, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, RepairReport{Checked: 1, Failed: 1, Dropped: 1}, report)
+
+		entries, err = journal.Take()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("drop objects missing in all storages", func(t *testing.T) {
+		folder := newTestFolder(t)
+		folder.statsCollector = stats.NewNopCollector([]string{"s1", "s2", "s3"})
+		journal := newJournal(t, "wal/a")
+
+		report, err := RepairMissingObjects(t.Context(), folder, journal, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, RepairReport{Checked: 1, Dropped: 1}, report)
+
+		entries, err := journal.Take()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
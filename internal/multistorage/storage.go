@@ -36,7 +36,8 @@ func NewStorage(config *Config, primary storage.HashableStorage, failovers map[s
 	if err != nil {
 		return nil, fmt.Errorf("configure stats collector: %w", err)
 	}
-	rootFolder := NewFolder(specificStorages.RootFolders(), statsCollector)
+	rootFolder := NewFolder(specificStorages.RootFolders(), statsCollector).(Folder)
+	rootFolder.storageKeys = specificStorages.Keys()
 
 	return &Storage{
 		statsCollector:   statsCollector,
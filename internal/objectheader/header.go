@@ -0,0 +1,98 @@
+package objectheader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	// Magic starts every object with the header. Its first byte is not ASCII, so that it doesn't look like the
+	// beginning of a plain tar or a WAL segment, and the whole value is unlikely to start a compressed or encrypted
+	// stream.
+	Magic = "\x89WALGHDR"
+
+	// NoCompression is recorded for objects stored without compression. An empty Compression means that the
+	// compression is unknown, and should be detected by the file extension.
+	NoCompression = "none"
+
+	version     byte = 1
+	maxJSONSize      = 4096
+	sizeofInt16      = 2
+)
+
+// Header describes how the object that follows it was compressed and encrypted. It is written in plain text before
+// the encrypted data, so that the object can be read even if the configuration has changed since it was uploaded.
+type Header struct {
+	// Compression is the file extension of the compression method, like "lz4" or "zst", or NoCompression.
+	Compression      string `json:"compression,omitempty"`
+	CompressionLevel string `json:"compression_level,omitempty"`
+
+	// Crypter is the name of the crypter the object is encrypted with. The object is not encrypted if it is empty.
+	Crypter string `json:"crypter,omitempty"`
+	// KeyID is the fingerprint of the key, see crypto.KeyFingerprinter.
+	KeyID string `json:"key_id,omitempty"`
+}
+
+func (header Header) IsEncrypted() bool {
+	return header.Crypter != ""
+}
+
+// Marshal provides the header as it is written in the object:
+//
+//	magic value "\x89WALGHDR"
+//	version (current version is 1)
+//	uint16 - JSON length
+//	JSON ...
+func (header Header) Marshal() ([]byte, error) {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxJSONSize {
+		return nil, fmt.Errorf("object header is too long: %d bytes", len(data))
+	}
+	result := append([]byte(Magic), version)
+	result = binary.LittleEndian.AppendUint16(result, uint16(len(data)))
+	return append(result, data...), nil
+}
+
+// Read reads the header if the object starts with one. Otherwise, it returns nil header. In both cases the returned
+// reader provides the rest of the object.
+func Read(reader io.Reader) (*Header, io.Reader, error) {
+	bufferedReader := bufio.NewReaderSize(reader, len(Magic))
+	magic, err := bufferedReader.Peek(len(Magic))
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	if string(magic) != Magic {
+		return nil, bufferedReader, nil
+	}
+	_, err = bufferedReader.Discard(len(Magic))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prefix := make([]byte, 1+sizeofInt16)
+	_, err = io.ReadFull(bufferedReader, prefix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read object header: %w", err)
+	}
+	if prefix[0] != version {
+		return nil, nil, fmt.Errorf("object header version %d is not supported", prefix[0])
+	}
+	data := make([]byte, binary.LittleEndian.Uint16(prefix[1:]))
+	_, err = io.ReadFull(bufferedReader, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read object header: %w", err)
+	}
+
+	header := &Header{}
+	err = json.Unmarshal(data, header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse object header: %w", err)
+	}
+	return header, bufferedReader, nil
+}
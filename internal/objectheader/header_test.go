@@ -0,0 +1,46 @@
+package objectheader
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadHeader(t *testing.T) {
+	expected := Header{Compression: "zst", CompressionLevel: "better", Crypter: "Age/Crypter", KeyID: "age:age1xyz"}
+	data, err := expected.Marshal()
+	require.NoError(t, err)
+
+	header, reader, err := Read(io.MultiReader(bytes.NewReader(data), bytes.NewBufferString("payload")))
+	require.NoError(t, err)
+	require.NotNil(t, header)
+	assert.Equal(t, expected, *header)
+	assert.True(t, header.IsEncrypted())
+
+	payload, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(payload))
+}
+
+func TestReadHeader_NoHeader(t *testing.T) {
+	for _, content := range []string{"", "short", "a longer object without any header"} {
+		header, reader, err := Read(bytes.NewBufferString(content))
+		require.NoError(t, err)
+		assert.Nil(t, header)
+
+		payload, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(payload))
+	}
+}
+
+func TestReadHeader_Invalid(t *testing.T) {
+	_, _, err := Read(bytes.NewBufferString(Magic + "\x02"))
+	assert.Error(t, err)
+
+	_, _, err = Read(bytes.NewBufferString(Magic + "\x01\x05\x00{"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,315 @@
+package readcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	tmpFilePrefix = ".tmp-"
+	// refsDirName is the subdirectory where the keys of the recently validated objects are recorded by their paths.
+	refsDirName = "refs"
+	// staleTmpFileAge is the age of temporary files after which they are considered left by crashed processes.
+	staleTmpFileAge = time.Hour
+)
+
+// Cache keeps recently read storage objects in a local directory, evicting the least recently used ones when the
+// total size exceeds the budget. The directory can be shared by several processes: files are written atomically, and
+// each process tracks the use of entries by their modification times. The keys of the objects validated against the
+// storage are recorded by their paths, so the objects are read from the cache without checking the storage for the TTL.
+type Cache struct {
+	dir    string
+	budget int64
+	ttl    time.Duration
+
+	mutex     sync.Mutex
+	totalSize int64
+	// lru holds *entry values, the most recently used entries go first.
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key  string
+	size int64
+}
+
+// New opens the cache in the directory, creating it if needed, and picks up the entries left by previous runs. Objects
+// are validated against the storage on every read if the TTL is not positive.
+func New(dir string, budget int64, ttl time.Duration) (*Cache, error) {
+	err := os.MkdirAll(filepath.Join(dir, refsDirName), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("create read cache directory: %w", err)
+	}
+	cache := &Cache{
+		dir:     dir,
+		budget:  budget,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+	err = cache.load()
+	if err != nil {
+		return nil, fmt.Errorf("load read cache: %w", err)
+	}
+	return cache, nil
+}
+
+func (c *Cache) load() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	type fileEntry struct {
+		entry
+		modTime time.Time
+	}
+	var files []fileEntry
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if strings.HasPrefix(dirEntry.Name(), tmpFilePrefix) {
+			if time.Since(info.ModTime()) > staleTmpFileAge {
+				_ = os.Remove(filepath.Join(c.dir, dirEntry.Name()))
+			}
+			continue
+		}
+		files = append(files, fileEntry{entry{key: dirEntry.Name(), size: info.Size()}, info.ModTime()})
+	}
+	slices.SortFunc(files, func(a, b fileEntry) int { return b.modTime.Compare(a.modTime) })
+
+	c.pruneRefs()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, file := range files {
+		c.entries[file.key] = c.lru.PushBack(&entry{key: file.key, size: file.size})
+		c.totalSize += file.size
+	}
+	c.evict()
+	return nil
+}
+
+// pruneRefs removes the expired records of validated objects, so they don't pile up for the objects not read anymore.
+func (c *Cache) pruneRefs() {
+	refsDir := filepath.Join(c.dir, refsDirName)
+	dirEntries, err := os.ReadDir(refsDir)
+	if err != nil {
+		return
+	}
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err == nil && time.Since(info.ModTime()) > max(c.ttl, staleTmpFileAge) {
+			_ = os.Remove(filepath.Join(refsDir, dirEntry.Name()))
+		}
+	}
+}
+
+// Lookup provides the key and the size of the object content, if the object was validated against the storage by any
+// process less than the TTL ago.
+func (c *Cache) Lookup(objectPath string) (key string, size int64, ok bool) {
+	if c.ttl <= 0 {
+		return "", 0, false
+	}
+	file, err := os.Open(c.refPath(objectPath))
+	if err != nil {
+		return "", 0, false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || time.Since(info.ModTime()) >= c.ttl {
+		return "", 0, false
+	}
+	_, err = fmt.Fscanf(file, "%s %d", &key, &size)
+	if err != nil {
+		return "", 0, false
+	}
+	return key, size, true
+}
+
+// Validated records the key and the size of the object content just checked in the storage.
+func (c *Cache) Validated(objectPath string, key string, size int64) {
+	if c.ttl <= 0 {
+		return
+	}
+	file, err := os.CreateTemp(c.dir, tmpFilePrefix+"*")
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to record read cache key of %q: %v", objectPath, err)
+		return
+	}
+	_, err = fmt.Fprintf(file, "%s %d", key, size)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), c.refPath(objectPath))
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		tracelog.WarningLogger.Printf("Failed to record read cache key of %q: %v", objectPath, err)
+	}
+}
+
+func (c *Cache) refPath(objectPath string) string {
+	hash := sha256.Sum256([]byte(objectPath))
+	return filepath.Join(c.dir, refsDirName, hex.EncodeToString(hash[:]))
+}
+
+// Key identifies the content of the object by its full path in the storage, size, modification time and version.
+// If the object is overwritten, its new content gets a different key.
+func Key(objectPath string, object storage.Object) string {
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "%s\x00%d\x00%d\x00%s",
+		objectPath, object.GetSize(), object.GetLastModified().UnixNano(), object.GetVersionID())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Get opens the cached content by the key. Returns false if there is no such entry, or it has a different size.
+func (c *Cache) Get(key string, size int64) (io.ReadCloser, bool) {
+	filePath := filepath.Join(c.dir, key)
+	file, err := os.Open(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			tracelog.WarningLogger.Printf("Failed to open read cache entry %q: %v", filePath, err)
+		}
+		c.forget(key)
+		return nil, false
+	}
+	info, err := file.Stat()
+	if err != nil || info.Size() != size {
+		_ = file.Close()
+		c.remove(key)
+		return nil, false
+	}
+
+	// Let other processes know that the entry is used
+	now := time.Now()
+	_ = os.Chtimes(filePath, now, now)
+	c.touch(key, size)
+	return file, true
+}
+
+// Fits checks if an object of the size can be cached at all.
+func (c *Cache) Fits(size int64) bool {
+	return size <= c.budget
+}
+
+// NewWriter starts writing the content of an entry. The entry appears in the cache only after it's committed.
+func (c *Cache) NewWriter(key string) (*EntryWriter, error) {
+	file, err := os.CreateTemp(c.dir, tmpFilePrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("create read cache temporary file: %w", err)
+	}
+	return &EntryWriter{cache: c, key: key, file: file}, nil
+}
+
+func (c *Cache) commit(key string, tmpPath string, size int64) error {
+	err := os.Rename(tmpPath, filepath.Join(c.dir, key))
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	c.touch(key, size)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.evict()
+	return nil
+}
+
+func (c *Cache) touch(key string, size int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&entry{key: key, size: size})
+	c.totalSize += size
+}
+
+// evict removes the least recently used entries until the cache fits the budget. Must be called with the mutex held.
+func (c *Cache) evict() {
+	for c.totalSize > c.budget && c.lru.Len() > 0 {
+		oldest := c.lru.Back()
+		evicted := oldest.Value.(*entry)
+		c.lru.Remove(oldest)
+		delete(c.entries, evicted.key)
+		c.totalSize -= evicted.size
+
+		err := os.Remove(filepath.Join(c.dir, evicted.key))
+		if err != nil && !os.IsNotExist(err) {
+			tracelog.WarningLogger.Printf("Failed to evict read cache entry %q: %v", evicted.key, err)
+		}
+	}
+}
+
+func (c *Cache) remove(key string) {
+	c.forget(key)
+	err := os.Remove(filepath.Join(c.dir, key))
+	if err != nil && !os.IsNotExist(err) {
+		tracelog.WarningLogger.Printf("Failed to remove read cache entry %q: %v", key, err)
+	}
+}
+
+// forget removes the entry from the in-memory index, e.g. after another process has evicted it.
+func (c *Cache) forget(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		c.totalSize -= element.Value.(*entry).size
+	}
+}
+
+// EntryWriter writes the content of a new cache entry to a temporary file.
+type EntryWriter struct {
+	cache   *Cache
+	key     string
+	file    *os.File
+	written int64
+}
+
+func (w *EntryWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Commit adds the written content to the cache if it has the expected size.
+func (w *EntryWriter) Commit(expectedSize int64) error {
+	err := w.file.Close()
+	if err != nil {
+		_ = os.Remove(w.file.Name())
+		return err
+	}
+	if w.written != expectedSize {
+		_ = os.Remove(w.file.Name())
+		return fmt.Errorf("expected %d bytes, but %d are written", expectedSize, w.written)
+	}
+	return w.cache.commit(w.key, w.file.Name(), w.written)
+}
+
+// Abort removes the written content.
+func (w *EntryWriter) Abort() {
+	_ = w.file.Close()
+	err := os.Remove(w.file.Name())
+	if err != nil && !os.IsNotExist(err) {
+		tracelog.WarningLogger.Printf("Failed to remove read cache temporary file %q: %v", w.file.Name(), err)
+	}
+}
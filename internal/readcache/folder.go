@@ -0,0 +1,132 @@
+package readcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/statistics"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.Folder = &Folder{}
+
+// Folder is a storage.Folder decorator that reads objects through the local Cache. Objects are looked up by their
+// path and the metadata returned by StatObject, so overwritten objects are read from the storage again. Storages don't
+// provide ETags through the storage.Object interface, so size, modification time and version ID are used instead.
+// Objects validated by StatObject less than the cache TTL ago are read from the cache without checking them again,
+// so an overwritten object might be read stale within the TTL.
+type Folder struct {
+	storage.Folder
+	cache *Cache
+}
+
+func NewFolder(folder storage.Folder, cache *Cache) *Folder {
+	return &Folder{
+		Folder: folder,
+		cache:  cache,
+	}
+}
+
+func (f *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return NewFolder(f.Folder.GetSubFolder(subFolderRelativePath), f.cache)
+}
+
+func (f *Folder) ReadObject(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	objectPath := path.Join(f.GetPath(), objectRelativePath)
+	if key, size, ok := f.cache.Lookup(objectPath); ok {
+		if cached, ok := f.cache.Get(key, size); ok {
+			statistics.WalgMetrics.ReadCacheHitsTotal.Inc()
+			tracelog.DebugLogger.Printf("Object %q is read from the read cache without validation", objectRelativePath)
+			return cached, nil
+		}
+	}
+
+	object, err := f.Folder.StatObject(ctx, objectRelativePath)
+	if err != nil {
+		var notFoundErr storage.ObjectNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return nil, err
+		}
+		tracelog.WarningLogger.Printf("Failed to stat object %q, reading it bypassing the read cache: %v",
+			objectRelativePath, err)
+		return f.Folder.ReadObject(ctx, objectRelativePath)
+	}
+
+	key := Key(objectPath, object)
+	size := object.GetSize()
+	f.cache.Validated(objectPath, key, size)
+	if cached, ok := f.cache.Get(key, size); ok {
+		statistics.WalgMetrics.ReadCacheHitsTotal.Inc()
+		tracelog.DebugLogger.Printf("Object %q is read from the read cache", objectRelativePath)
+		return cached, nil
+	}
+	statistics.WalgMetrics.ReadCacheMissesTotal.Inc()
+
+	reader, err := f.Folder.ReadObject(ctx, objectRelativePath)
+	if err != nil || !f.cache.Fits(size) {
+		return reader, err
+	}
+	writer, err := f.cache.NewWriter(key)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to cache object %q: %v", objectRelativePath, err)
+		return reader, nil
+	}
+	return &cachingReader{
+		ReadCloser:   reader,
+		writer:       writer,
+		name:         objectRelativePath,
+		expectedSize: size,
+	}, nil
+}
+
+// ReadObjectRange reads the part of the object from the storage bypassing the cache. Ranged reads are used for
+// objects larger than the cache is meant for, and a part can't be cached as the whole object.
+func (f *Folder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	return storage.ReadObjectRange(ctx, f.Folder, objectRelativePath, offset, length)
+}
+
+// cachingReader copies the object content to the cache entry as it's read. The entry is committed only if the whole
+// object is read, otherwise it's dropped.
+type cachingReader struct {
+	io.ReadCloser
+	writer       *EntryWriter
+	name         string
+	expectedSize int64
+	eof          bool
+	failed       bool
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && !r.failed {
+		_, writeErr := r.writer.Write(p[:n])
+		if writeErr != nil {
+			tracelog.WarningLogger.Printf("Failed to write object %q to the read cache: %v", r.name, writeErr)
+			r.failed = true
+		}
+	}
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	err := r.ReadCloser.Close()
+	if err != nil || !r.eof || r.failed {
+		r.writer.Abort()
+		return err
+	}
+	commitErr := r.writer.Commit(r.expectedSize)
+	if commitErr != nil {
+		tracelog.WarningLogger.Printf("Failed to add object %q to the read cache: %v", r.name, commitErr)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package readcache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/statistics"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+type countingFolder struct {
+	storage.Folder
+	reads *int
+	stats *int
+}
+
+func (f countingFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return countingFolder{f.Folder.GetSubFolder(subFolderRelativePath), f.reads, f.stats}
+}
+
+func (f countingFolder) StatObject(ctx context.Context, objectRelativePath string) (storage.Object, error) {
+	*f.stats++
+	return f.Folder.StatObject(ctx, objectRelativePath)
+}
+
+func (f countingFolder) ReadObject(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	*f.reads++
+	return f.Folder.ReadObject(ctx, objectRelativePath)
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestFolder(t *testing.T, budget int64) (*Folder, storage.Folder, *testClock, *int) {
+	folder, base, clock, reads, _ := newTestFolderWithTTL(t, budget, 0)
+	return folder, base, clock, reads
+}
+
+func newTestFolderWithTTL(t *testing.T, budget int64, ttl time.Duration) (*Folder, storage.Folder, *testClock, *int, *int) {
+	cache, err := New(t.TempDir(), budget, ttl)
+	require.NoError(t, err)
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	reads, stats := new(int), new(int)
+	base := countingFolder{memory.NewFolder("test/", memory.NewKVS(memory.WithCustomTime(clock.Now))), reads, stats}
+	return NewFolder(base, cache), base, clock, reads, stats
+}
+
+func readAll(t *testing.T, folder storage.Folder, name string) string {
+	reader, err := folder.ReadObject(context.Background(), name)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	return string(content)
+}
+
+func TestFolder_ReadObject(t *testing.T) {
+	t.Run("second read is served from cache", func(t *testing.T) {
+		folder, base, _, reads := newTestFolder(t, 1024)
+		require.NoError(t, base.PutObject(context.Background(), "a", strings.NewReader("content")))
+		hits := testutil.ToFloat64(statistics.WalgMetrics.ReadCacheHitsTotal)
+		misses := testutil.ToFloat64(statistics.WalgMetrics.ReadCacheMissesTotal)
+
+		assert.Equal(t, "content", readAll(t, folder, "a"))
+		assert.Equal(t, "content", readAll(t, folder, "a"))
+		assert.Equal(t, 1, *reads)
+		assert.Equal(t, hits+1, testutil.ToFloat64(statistics.WalgMetrics.ReadCacheHitsTotal))
+		assert.Equal(t, misses+1, testutil.ToFloat64(statistics.WalgMetrics.ReadCacheMissesTotal))
+	})
+
+	t.Run("subfolders share the cache", func(t *testing.T) {
+		folder, base, _, reads := newTestFolder(t, 1024)
+		require.NoError(t, base.PutObject(context.Background(), "sub/a", strings.NewReader("content")))
+
+		assert.Equal(t, "content", readAll(t, folder, "sub/a"))
+		assert.Equal(t, "content", readAll(t, folder.GetSubFolder("sub"), "a"))
+		assert.Equal(t, 1, *reads)
+	})
+
+	t.Run("overwritten object is read again", func(t *testing.T) {
+		folder, base, clock, reads := newTestFolder(t, 1024)
+		require.NoError(t, base.PutObject(context.Background(), "a", strings.NewReader("old")))
+		assert.Equal(t, "old", readAll(t, folder, "a"))
+
+		clock.now = clock.now.Add(time.Second)
+		require.NoError(t, base.PutObject(context.Background(), "a", strings.NewReader("new")))
+		assert.Equal(t, "new", readAll(t, folder, "a"))
+		assert.Equal(t, 2, *reads)
+	})
+
+	t.Run("partially read object is not cached", func(t *testing.T) {
+		folder, base, _, reads := newTestFolder(t, 1024)
+		require.NoError(t, base.PutObject(context.Background(), "a", strings.NewReader("content")))
+
+		reader, err := folder.ReadObject(context.Background(), "a")
+		require.NoError(t, err)
+		_, err = reader.Read(make([]byte, 3))
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+
+		assert.Equal(t, "content", readAll(t, folder, "a"))
+		assert.Equal(t, 2, *reads)
+	})
+
+	t.Run("objects larger than budget are not cached", func(t *testing.T) {
+		folder, base, _, reads := newTestFolder(t, 4)
+		require.NoError(t, base.PutObject(context.Background(), "a", strings.NewReader("content")))
+
+		assert.Equal(t, "content", readAll(t, folder, "a"))
+		assert.Equal(t, "content", readAll(t, folder, "a"))
+		assert.Equal(t, 2, *reads)
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		folder, _, _, _ := newTestFolder(t, 1024)
+		_, err := folder.ReadObject(context.Background(), "a")
+		assert.IsType(t, storage.ObjectNotFoundError{}, err)
+	})
+
+	t.Run("least recently used objects are evicted", func(t *testing.T) {
+		folder, base, _, reads := newTestFolder(t, 10)
+		for _, name := range []string{"a", "b", "c"} {
+			require.NoError(t, base.PutObject(context.Background(), name, bytes.NewReader(make([]byte, 4))))
+		}
+
+		readAll(t, folder, "a")
+		readAll(t, folder, "b")
+		readAll(t, folder, "a")
+		readAll(t, folder, "c")
+		assert.Equal(t, 3, *reads)
+
+		// b is evicted to make room for c
+		readAll(t, folder, "a")
+		assert.Equal(t, 3, *reads)
+		readAll(t, folder, "b")
+		assert.Equal(t, 4, *reads)
+	})
+}
+
+func TestFolder_ReadObjectWithTTL(t *testing.T) {
+	t.Run("validated object is read without checking the storage", func(t *testing.T) {
+		folder, base, _, reads, stats := newTestFolderWithTTL(t, 1024, time.Hour)
+		require.NoError(t, base.PutObject(context.Background(), "sub/a", strings.NewReader("content")))
+
+		assert.Equal(t, "content", readAll(t, folder, "sub/a"))
+		assert.Equal(t, "content", readAll(t, folder, "sub/a"))
+		assert.Equal(t, "content", readAll(t, folder.GetSubFolder("sub"), "a"))
+		assert.Equal(t, 1, *reads)
+		assert.Equal(t, 1, *stats)
+	})
+
+	t.Run("object is validated again once the TTL expires", func(t *testing.T) {
+		folder, base, clock, reads, stats := newTestFolderWithTTL(t, 1024, time.Nanosecond)
+		require.NoError(t, base.PutObject(context.Background(), "a", strings.NewReader("old")))
+		assert.Equal(t, "old", readAll(t, folder, "a"))
+
+		clock.now = clock.now.Add(time.Second)
+		require.NoError(t, base.PutObject(context.Background(), "a", strings.NewReader("new")))
+		assert.Equal(t, "new", readAll(t, folder, "a"))
+		assert.Equal(t, 2, *reads)
+		assert.Equal(t, 2, *stats)
+	})
+
+	t.Run("evicted object is read again", func(t *testing.T) {
+		folder, base, _, reads, _ := newTestFolderWithTTL(t, 4, time.Hour)
+		for _, name := range []string{"a", "b"} {
+			require.NoError(t, base.PutObject(context.Background(), name, bytes.NewReader(make([]byte, 4))))
+		}
+
+		readAll(t, folder, "a")
+		readAll(t, folder, "b")
+		readAll(t, folder, "a")
+		assert.Equal(t, 3, *reads)
+	})
+}
+
+func TestNew_LoadsExistingEntries(t *testing.T) {
+	dir := t.TempDir()
+	oldTime := time.Now().Add(-time.Minute)
+	for _, name := range []string{"old", "new"} {
+		require.NoError(t, os.WriteFile(dir+"/"+name, make([]byte, 4), 0600))
+	}
+	require.NoError(t, os.Chtimes(dir+"/old", oldTime, oldTime))
+	require.NoError(t, os.WriteFile(dir+"/"+tmpFilePrefix+"stale", make([]byte, 4), 0600))
+	staleTime := time.Now().Add(-2 * staleTmpFileAge)
+	require.NoError(t, os.Chtimes(dir+"/"+tmpFilePrefix+"stale", staleTime, staleTime))
+
+	cache, err := New(dir, 6, 0)
+	require.NoError(t, err)
+
+	_, ok := cache.Get("old", 4)
+	assert.False(t, ok)
+	reader, ok := cache.Get("new", 4)
+	require.True(t, ok)
+	require.NoError(t, reader.Close())
+	_, err = os.Stat(dir + "/" + tmpFilePrefix + "stale")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -2,7 +2,18 @@ package internal
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/compression"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/objectheader"
 )
 
 type FileType string
@@ -29,3 +40,119 @@ func readerMakersToFilePaths(readerMakers []ReaderMaker) []string {
 	}
 	return paths
 }
+
+var decryptionKeyring struct {
+	once    sync.Once
+	keyring *crypto.Keyring
+}
+
+// getDecryptionKeyring provides the crypters configured in the WALG_DECRYPTION_KEYRING config files.
+func getDecryptionKeyring() *crypto.Keyring {
+	decryptionKeyring.once.Do(func() {
+		crypters := make([]crypto.Crypter, 0)
+		for _, configFile := range strings.Split(viper.GetString(conf.DecryptionKeyringSetting), ",") {
+			configFile = strings.TrimSpace(configFile)
+			if configFile != "" {
+				crypters = append(crypters, CrypterFromConfig(configFile))
+			}
+		}
+		decryptionKeyring.keyring = crypto.NewKeyring(crypters...)
+	})
+	return decryptionKeyring.keyring
+}
+
+type KeyNotConfiguredError struct {
+	error
+}
+
+func newKeyNotConfiguredError(header *objectheader.Header) KeyNotConfiguredError {
+	return KeyNotConfiguredError{errors.Errorf("object is encrypted by %s with key %q, which is not configured: "+
+		"configure it as the current crypter or add its config to %s",
+		header.Crypter, header.KeyID, conf.DecryptionKeyringSetting)}
+}
+
+func (err KeyNotConfiguredError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// SelectCrypter chooses the crypter to decrypt the object with the header: the provided crypter if its key matches
+// the one recorded in the header, or one of the crypters from WALG_DECRYPTION_KEYRING. Returns nil if the object is
+// not encrypted.
+func SelectCrypter(header *objectheader.Header, crypter crypto.Crypter) (crypto.Crypter, error) {
+	if !header.IsEncrypted() {
+		return nil, nil
+	}
+	if found := crypto.NewKeyring(crypter).Find(header.Crypter, header.KeyID); found != nil {
+		return found, nil
+	}
+	if found := getDecryptionKeyring().Find(header.Crypter, header.KeyID); found != nil {
+		tracelog.DebugLogger.Printf("Selected crypter %s with key %q from the keyring", found.Name(), header.KeyID)
+		return found, nil
+	}
+	return nil, newKeyNotConfiguredError(header)
+}
+
+// SelectDecompressor chooses the decompressor recorded in the header. The fallback decompressor, usually found by the
+// file extension, is used if the header doesn't record the compression.
+func SelectDecompressor(header *objectheader.Header, fallback compression.Decompressor) (compression.Decompressor, error) {
+	switch header.Compression {
+	case "":
+		return fallback, nil
+	case objectheader.NoCompression:
+		return nil, nil
+	}
+	decompressor := compression.FindDecompressor(header.Compression)
+	if decompressor == nil {
+		return nil, errors.Errorf("decompressor for %q recorded in the object header was not found", header.Compression)
+	}
+	return decompressor, nil
+}
+
+// DecryptWithHeader decrypts the object with the crypter selected by its header, or with the configured crypter if
+// the object has no header.
+func DecryptWithHeader(reader io.Reader, header *objectheader.Header) (io.Reader, error) {
+	crypter := ConfigureCrypter()
+	if header != nil {
+		var err error
+		crypter, err = SelectCrypter(header, crypter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if crypter == nil {
+		tracelog.DebugLogger.Printf("No crypter has been selected")
+		return reader, nil
+	}
+	tracelog.DebugLogger.Printf("Selected crypter: %s", crypter.Name())
+
+	decryptReader, err := crypter.Decrypt(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init decrypt reader: %w", err)
+	}
+	return decryptReader, nil
+}
+
+// decryptAndDecompressWithHeader decrypts and decompresses the object as its header describes. The crypter and the
+// decompressor are used if the header doesn't tell otherwise, nil decompressor means no compression.
+func decryptAndDecompressWithHeader(reader io.Reader, header *objectheader.Header, crypter crypto.Crypter,
+	decompressor compression.Decompressor) (io.ReadCloser, error) {
+	crypter, err := SelectCrypter(header, crypter)
+	if err != nil {
+		return nil, err
+	}
+	decompressor, err = SelectDecompressor(header, decompressor)
+	if err != nil {
+		return nil, err
+	}
+
+	if crypter != nil {
+		reader, err = crypter.Decrypt(reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "decrypt failed")
+		}
+	}
+	if decompressor == nil {
+		return io.NopCloser(reader), nil
+	}
+	return decompressor.Decompress(reader)
+}
@@ -0,0 +1,108 @@
+package internal_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/compression/none"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/crypto/openpgp"
+	"github.com/wal-g/wal-g/internal/objectheader"
+)
+
+func compressAndEncryptWithHeader(t *testing.T, data []byte, compressor compression.Compressor,
+	crypter crypto.Crypter) []byte {
+	viper.Set(conf.ObjectHeaderSetting, true)
+	defer viper.Set(conf.ObjectHeaderSetting, false)
+
+	result, err := io.ReadAll(internal.CompressAndEncrypt(bytes.NewReader(data), compressor, crypter))
+	require.NoError(t, err)
+	return result
+}
+
+func TestCompressAndEncrypt_ObjectHeader(t *testing.T) {
+	crypter := openpgp.CrypterFromKeyPath(PrivateKeyFilePath, noPassphrase)
+	keyID, err := crypto.KeyFingerprint(crypter)
+	require.NoError(t, err)
+
+	object := compressAndEncryptWithHeader(t, generateRandomBytes(), compression.Compressors[lz4.AlgorithmName], crypter)
+
+	header, _, err := objectheader.Read(bytes.NewReader(object))
+	require.NoError(t, err)
+	require.NotNil(t, header)
+	assert.Equal(t, objectheader.Header{Compression: lz4.FileExtension, Crypter: crypter.Name(), KeyID: keyID}, *header)
+}
+
+type longExtensionCompressor struct {
+	none.Compressor
+}
+
+func (longExtensionCompressor) FileExtension() string {
+	return strings.Repeat("x", 5000)
+}
+
+func TestCompressAndEncrypt_ObjectHeaderError(t *testing.T) {
+	viper.Set(conf.ObjectHeaderSetting, true)
+	defer viper.Set(conf.ObjectHeaderSetting, false)
+
+	_, err := io.ReadAll(internal.CompressAndEncrypt(bytes.NewReader(generateRandomBytes()), longExtensionCompressor{}, nil))
+	assert.ErrorContains(t, err, "object header is too long")
+}
+
+func TestDecryptAndDecompressTar_ObjectHeader(t *testing.T) {
+	data := generateRandomBytes()
+	crypter := openpgp.CrypterFromKeyPath(PrivateKeyFilePath, noPassphrase)
+
+	t.Run("compression from the header", func(t *testing.T) {
+		object := compressAndEncryptWithHeader(t, data, compression.Compressors[lz4.AlgorithmName], crypter)
+
+		reader, err := internal.DecryptAndDecompressTar(bytes.NewReader(object), "/usr/local/test.tar.lzma", crypter)
+		require.NoError(t, err)
+		decompressed, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, data, decompressed)
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		object := compressAndEncryptWithHeader(t, data, none.Compressor{}, nil)
+
+		reader, err := internal.DecryptAndDecompressTar(bytes.NewReader(object), "/usr/local/test.tar.lz4", crypter)
+		require.NoError(t, err)
+		decompressed, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, data, decompressed)
+	})
+
+	t.Run("key is not configured", func(t *testing.T) {
+		object := compressAndEncryptWithHeader(t, data, compression.Compressors[lz4.AlgorithmName], crypter)
+
+		_, err := internal.DecryptAndDecompressTar(bytes.NewReader(object), "/usr/local/test.tar.lz4", nil)
+		assert.ErrorAs(t, err, &internal.KeyNotConfiguredError{})
+	})
+}
+
+func TestSelectDecompressor(t *testing.T) {
+	fallback := compression.Compressors[lz4.AlgorithmName]
+	fallbackDecompressor := compression.GetDecompressorByCompressor(fallback)
+
+	decompressor, err := internal.SelectDecompressor(&objectheader.Header{}, fallbackDecompressor)
+	require.NoError(t, err)
+	assert.Equal(t, fallbackDecompressor, decompressor)
+
+	decompressor, err = internal.SelectDecompressor(&objectheader.Header{Compression: objectheader.NoCompression},
+		fallbackDecompressor)
+	require.NoError(t, err)
+	assert.Nil(t, decompressor)
+
+	_, err = internal.SelectDecompressor(&objectheader.Header{Compression: "unknown"}, fallbackDecompressor)
+	assert.Error(t, err)
+}
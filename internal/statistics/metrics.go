@@ -22,6 +22,9 @@ type metrics struct {
 	S3BytesWritten prometheus.Gauge
 	S3BytesRead    prometheus.Gauge
 	S3UploadTime   prometheus.Gauge
+
+	ReadCacheHitsTotal   prometheus.Counter
+	ReadCacheMissesTotal prometheus.Counter
 }
 
 var (
@@ -67,6 +70,18 @@ var (
 				Help: "WAL upload time to S3.",
 			},
 		),
+		ReadCacheHitsTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: WalgMetricsPrefix + "read_cache_hits_total",
+				Help: "Number of storage objects read from the local read cache.",
+			},
+		),
+		ReadCacheMissesTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: WalgMetricsPrefix + "read_cache_misses_total",
+				Help: "Number of storage objects missing in the local read cache.",
+			},
+		),
 	}
 )
 
@@ -82,6 +97,8 @@ func init() {
 	prometheus.MustRegister(WalgMetrics.S3BytesWritten)
 	prometheus.MustRegister(WalgMetrics.S3BytesRead)
 	prometheus.MustRegister(WalgMetrics.S3UploadTime)
+	prometheus.MustRegister(WalgMetrics.ReadCacheHitsTotal)
+	prometheus.MustRegister(WalgMetrics.ReadCacheMissesTotal)
 }
 
 func PushMetrics() {
@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/viper"
 	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/pkg/storages/azure"
+	"github.com/wal-g/wal-g/pkg/storages/external"
 	"github.com/wal-g/wal-g/pkg/storages/fs"
 	"github.com/wal-g/wal-g/pkg/storages/gcs"
 	"github.com/wal-g/wal-g/pkg/storages/oss"
@@ -13,6 +14,7 @@ import (
 	"github.com/wal-g/wal-g/pkg/storages/sh"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/pkg/storages/swift"
+	"github.com/wal-g/wal-g/pkg/storages/webdav"
 )
 
 type StorageAdapter struct {
@@ -62,4 +64,6 @@ var StorageAdapters = []StorageAdapter{
 	{"AZ", azure.SettingList, azure.ConfigureStorage},
 	{"SWIFT", swift.SettingList, swift.ConfigureStorage},
 	{"SSH", sh.SettingList, sh.ConfigureStorage},
+	{"WEBDAV", webdav.SettingList, webdav.ConfigureStorage},
+	{"EXTERNAL", external.SettingList, external.ConfigureStorage},
 }
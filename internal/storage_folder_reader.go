@@ -3,10 +3,14 @@ package internal
 import (
 	"context"
 	"io"
+	"sync"
 
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
+	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/multistorage"
 	"github.com/wal-g/wal-g/internal/multistorage/policies"
+	"github.com/wal-g/wal-g/internal/readcache"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
 
@@ -15,10 +19,43 @@ type StorageFolderReader interface {
 	SubFolder(subFolderRelativePath string) StorageFolderReader
 }
 
+// NewFolderReader provides a reader of the folder objects. If the read cache is configured, objects are read through it.
 func NewFolderReader(folder storage.Folder) StorageFolderReader {
+	if cache := getReadCache(); cache != nil {
+		if _, cached := folder.(*readcache.Folder); !cached {
+			folder = readcache.NewFolder(folder, cache)
+		}
+	}
 	return &FolderReaderImpl{folder}
 }
 
+var (
+	readCache     *readcache.Cache
+	readCacheOnce sync.Once
+)
+
+func getReadCache() *readcache.Cache {
+	readCacheOnce.Do(func() {
+		cachePath := viper.GetString(conf.ReadCachePathSetting)
+		if cachePath == "" {
+			return
+		}
+		ttl, err := conf.GetDurationSetting(conf.ReadCacheTTLSetting)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to get the read cache TTL, reading without the cache: %v", err)
+			return
+		}
+		cache, err := readcache.New(cachePath, int64(viper.GetSizeInBytes(conf.ReadCacheSizeSetting)), ttl)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to open the read cache, reading without it: %v", err)
+			return
+		}
+		tracelog.DebugLogger.Printf("Objects will be read through the read cache in %q", cachePath)
+		readCache = cache
+	})
+	return readCache
+}
+
 type FolderReaderImpl struct {
 	storage.Folder
 }
@@ -4,9 +4,15 @@ import (
 	"context"
 	"io"
 
+	"github.com/spf13/viper"
+	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
 
+// rangePartRetries is the number of times a range of a backup file is requested again before the whole file download
+// is considered failed and retried by ExtractAll.
+const rangePartRetries = 3
+
 // StorageReaderMaker creates readers for downloading from storage
 type StorageReaderMaker struct {
 	Folder          storage.Folder
@@ -29,9 +35,18 @@ func (readerMaker *StorageReaderMaker) StoragePath() string { return readerMaker
 func (readerMaker *StorageReaderMaker) LocalPath() string { return readerMaker.localPath }
 
 func (readerMaker *StorageReaderMaker) Reader(ctx context.Context) (io.ReadCloser, error) {
-	return readerMaker.Folder.ReadObject(ctx, readerMaker.storagePath)
+	return storage.ReadObjectParallel(ctx, readerMaker.Folder, readerMaker.storagePath, GetParallelReadConfig())
 }
 
 func (readerMaker *StorageReaderMaker) FileType() FileType { return readerMaker.StorageFileType }
 
 func (readerMaker *StorageReaderMaker) Mode() int64 { return readerMaker.FileMode }
+
+// GetParallelReadConfig provides the settings of downloading large backup files with concurrent ranged requests.
+func GetParallelReadConfig() storage.ParallelReadConfig {
+	return storage.ParallelReadConfig{
+		PartSize:    int64(viper.GetSizeInBytes(conf.DownloadRangePartSizeSetting)),
+		Concurrency: viper.GetInt(conf.DownloadRangeConcurrencySetting),
+		PartRetries: rangePartRetries,
+	}
+}
@@ -0,0 +1,78 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"golang.org/x/time/rate"
+)
+
+// rangeCountingFolder counts the ranged reads of the objects in the memory folder.
+type rangeCountingFolder struct {
+	*memory.Folder
+	ranges *atomic.Int32
+}
+
+func (folder rangeCountingFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return rangeCountingFolder{folder.Folder.GetSubFolder(subFolderRelativePath).(*memory.Folder), folder.ranges}
+}
+
+func (folder rangeCountingFolder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	folder.ranges.Add(1)
+	return folder.Folder.ReadObjectRange(ctx, objectRelativePath, offset, length)
+}
+
+type rangeCountingStorage struct {
+	*memory.Storage
+	rootFolder storage.Folder
+}
+
+func (s rangeCountingStorage) RootFolder() storage.Folder {
+	return s.rootFolder
+}
+
+func TestStorageReaderMaker_ReadsMultiStorageInRanges(t *testing.T) {
+	viper.Set(conf.DownloadRangeConcurrencySetting, 4)
+	viper.Set(conf.DownloadRangePartSizeSetting, 1024)
+	defer viper.Set(conf.DownloadRangeConcurrencySetting, nil)
+	defer viper.Set(conf.DownloadRangePartSizeSetting, nil)
+
+	ranges := &atomic.Int32{}
+	memoryStorage := memory.NewStorage("memory://ranged-read-test", memory.NewKVS())
+	rootFolder := rangeCountingFolder{memoryStorage.RootFolder().(*memory.Folder), ranges}
+	// The storage is wrapped like ConfigureStorage does when the network is limited
+	primary := rangeCountingStorage{memoryStorage, internal.NewLimitedFolder(rootFolder, rate.NewLimiter(rate.Inf, 1024*1024))}
+	multiStorage, err := multistorage.NewStorage(&multistorage.Config{}, primary, nil)
+	require.NoError(t, err)
+	folder, err := multistorage.UseFirstAliveStorage(t.Context(), multiStorage.RootFolder())
+	require.NoError(t, err)
+	folder = folder.GetSubFolder("basebackups_005")
+
+	data := make([]byte, 10*1024+7)
+	rand.New(rand.NewSource(1)).Read(data)
+	require.NoError(t, folder.PutObject(t.Context(), "part_001.tar", bytes.NewReader(data)))
+
+	reader, err := internal.NewStorageReaderMaker(folder, "part_001.tar").Reader(t.Context())
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+	assert.Equal(t, int32(11), ranges.Load())
+}
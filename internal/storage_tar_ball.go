@@ -9,6 +9,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/compression"
 	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/utility"
 )
@@ -117,7 +118,7 @@ func (tarBall *StorageTarBall) startUpload(ctx context.Context, name string, cry
 		writerToCompress = &utility.CascadeWriteCloser{WriteCloser: encryptedWriter, Underlying: pipeWriter}
 	}
 
-	return &utility.CascadeWriteCloser{WriteCloser: uploader.Compression().NewWriter(writerToCompress),
+	return &utility.CascadeWriteCloser{WriteCloser: compression.NewWriter(uploader.Compression(), writerToCompress, name),
 		Underlying: writerToCompress}
 }
 
@@ -0,0 +1,479 @@
+package storagetools
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/internal/multistorage/consts"
+	"github.com/wal-g/wal-g/internal/storagetools/transfer"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+var (
+	tarPartNameRegexp   = regexp.MustCompile(`^part_(\d+)\.tar`)
+	walSegmentNameRegex = regexp.MustCompile(`^([0-9A-F]{8})([0-9A-F]{8})([0-9A-F]{8})(\.[a-z0-9]+)?$`)
+)
+
+// StorageAudit describes which backups and WAL segments are stored in which storages of a multi-storage.
+type StorageAudit struct {
+	// Storages are the names of the audited storages, the primary one goes first.
+	Storages []string
+	Backups  []BackupAudit
+	// WALSegments maps the paths of WAL segment objects, relative to the WAL folder, to the storages they are found in.
+	WALSegments map[string][]string
+}
+
+// BackupAudit describes where the pieces of a backup are stored.
+type BackupAudit struct {
+	Name string
+	// Pieces maps the paths of the backup objects, relative to the storage root, to the storages they are found in.
+	Pieces map[string][]string
+	// TarPartsCount is the number of tar partitions the backup has according to its sentinel or files metadata,
+	// 0 if it isn't known.
+	TarPartsCount int
+}
+
+// tarPartsDto is the part of the backup sentinel and the files metadata naming the tar partitions of the backup.
+// Sentinels of old backups hold the files metadata themselves.
+type tarPartsDto struct {
+	CompressionDecisions []struct {
+		Name string `json:"Name"`
+	} `json:"CompressionDecisions"`
+	FilesMetadataDisabled bool                `json:"FilesMetadataDisabled"`
+	TarFileSets           map[string][]string `json:"TarFileSets"`
+}
+
+// filesMetadataName is the name of the files metadata object in the backup folder, see postgres.FilesMetadataName.
+const filesMetadataName = "files_metadata.json"
+
+// AuditStorages lists the backups and WAL segments in all storages the multi-storage folder uses.
+func AuditStorages(ctx context.Context, rootFolder storage.Folder) (*StorageAudit, error) {
+	audit := &StorageAudit{
+		Storages:    multistorage.UsedStorages(rootFolder),
+		WALSegments: map[string][]string{},
+	}
+
+	backupObjects, err := multistorage.ListFolderRecursively(ctx, rootFolder.GetSubFolder(utility.BaseBackupPath))
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	backups := map[string]*BackupAudit{}
+	for _, object := range backupObjects {
+		backupName := backupNameOf(object.GetName())
+		if backupName == "" {
+			continue
+		}
+		backup, ok := backups[backupName]
+		if !ok {
+			backup = &BackupAudit{Name: backupName, Pieces: map[string][]string{}}
+			backups[backupName] = backup
+		}
+		piecePath := path.Join(utility.BaseBackupPath, object.GetName())
+		backup.Pieces[piecePath] = append(backup.Pieces[piecePath], multistorage.GetStorage(object))
+	}
+	for _, backup := range backups {
+		if _, ok := backup.Pieces[backup.sentinelPath()]; ok {
+			backup.TarPartsCount = fetchTarPartsCount(ctx, rootFolder.GetSubFolder(utility.BaseBackupPath), backup.Name)
+		}
+		audit.Backups = append(audit.Backups, *backup)
+	}
+	slices.SortFunc(audit.Backups, func(a, b BackupAudit) int { return strings.Compare(a.Name, b.Name) })
+
+	walObjects, err := multistorage.ListFolderRecursively(ctx, rootFolder.GetSubFolder(utility.WalPath))
+	if err != nil {
+		return nil, fmt.Errorf("list WAL: %w", err)
+	}
+	for _, object := range walObjects {
+		if _, _, ok := parseWALSegmentName(path.Base(object.GetName())); !ok {
+			continue
+		}
+		audit.WALSegments[object.GetName()] = append(audit.WALSegments[object.GetName()], multistorage.GetStorage(object))
+	}
+	return audit, nil
+}
+
+// fetchTarPartsCount provides the number of tar partitions of the backup, taken from the compression decisions in
+// the sentinel or from the tar file sets in the sentinel or the files metadata. Provides 0 if none of them is known.
+func fetchTarPartsCount(ctx context.Context, backupsFolder storage.Folder, backupName string) int {
+	var sentinel tarPartsDto
+	err := internal.FetchDto(ctx, backupsFolder, &sentinel, backupName+utility.SentinelSuffix)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to read the sentinel of backup %s, can't check its last tar part: %v", backupName, err)
+		return 0
+	}
+	if len(sentinel.CompressionDecisions) > 0 {
+		names := make([]string, 0, len(sentinel.CompressionDecisions))
+		for _, decision := range sentinel.CompressionDecisions {
+			names = append(names, decision.Name)
+		}
+		return maxTarPartNumber(names)
+	}
+	if len(sentinel.TarFileSets) > 0 {
+		return maxTarPartNumber(slices.Collect(maps.Keys(sentinel.TarFileSets)))
+	}
+	if sentinel.FilesMetadataDisabled {
+		return 0
+	}
+
+	var filesMetadata tarPartsDto
+	err = internal.FetchDto(ctx, backupsFolder, &filesMetadata, path.Join(backupName, filesMetadataName))
+	if err != nil {
+		// backups of WAL-E, old WAL-G versions and other databases don't have it
+		tracelog.DebugLogger.Printf("Failed to read the files metadata of backup %s: %v", backupName, err)
+		return 0
+	}
+	return maxTarPartNumber(slices.Collect(maps.Keys(filesMetadata.TarFileSets)))
+}
+
+func maxTarPartNumber(tarNames []string) int {
+	maxPart := 0
+	for _, name := range tarNames {
+		if number, ok := parseTarPartNumber(name); ok {
+			maxPart = max(maxPart, number)
+		}
+	}
+	return maxPart
+}
+
+func parseTarPartNumber(name string) (int, bool) {
+	match := tarPartNameRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+	number, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// backupNameOf provides the name of the backup the object in the backups folder belongs to.
+func backupNameOf(objectPath string) string {
+	dir, fileName := path.Split(objectPath)
+	if dir == "" {
+		if strings.HasPrefix(fileName, utility.BackupNamePrefix) && strings.HasSuffix(fileName, utility.SentinelSuffix) {
+			return strings.TrimSuffix(fileName, utility.SentinelSuffix)
+		}
+		return ""
+	}
+	backupName, _, _ := strings.Cut(dir, "/")
+	if !strings.HasPrefix(backupName, utility.BackupNamePrefix) {
+		return ""
+	}
+	return backupName
+}
+
+func (b BackupAudit) sentinelPath() string {
+	return path.Join(utility.BaseBackupPath, b.Name+utility.SentinelSuffix)
+}
+
+// piecesIn counts the backup objects stored in the storage.
+func (b BackupAudit) piecesIn(storageName string) int {
+	count := 0
+	for _, storages := range b.Pieces {
+		if slices.Contains(storages, storageName) {
+			count++
+		}
+	}
+	return count
+}
+
+// Problems describes what's wrong with the backup, if anything.
+func (b BackupAudit) Problems(storageNames []string) []string {
+	var problems []string
+	_, hasSentinel := b.Pieces[b.sentinelPath()]
+	switch {
+	case !hasSentinel:
+		problems = append(problems, "no sentinel")
+	case len(b.Pieces) == 1:
+		problems = append(problems, "no data")
+	}
+	if missing := b.missingTarParts(); len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("missing tar parts %v", missing))
+	}
+
+	var holders []string
+	complete := false
+	for _, name := range storageNames {
+		count := b.piecesIn(name)
+		if count == 0 {
+			continue
+		}
+		holders = append(holders, fmt.Sprintf("%s (%d/%d)", name, count, len(b.Pieces)))
+		complete = complete || count == len(b.Pieces)
+	}
+	if len(holders) > 1 && !complete {
+		problems = append(problems, "split between storages "+strings.Join(holders, ", "))
+	}
+	return problems
+}
+
+// missingTarParts provides the numbers of tar partitions that are missing in all storages, assuming that partitions
+// are numbered sequentially starting from 1. The last partitions can be found missing only if TarPartsCount is known.
+func (b BackupAudit) missingTarParts() []int {
+	tarsFolder := path.Join(utility.BaseBackupPath, b.Name, "tar_partitions") + "/"
+	found := map[int]bool{}
+	maxPart := b.TarPartsCount
+	for piecePath := range b.Pieces {
+		if !strings.HasPrefix(piecePath, tarsFolder) {
+			continue
+		}
+		number, ok := parseTarPartNumber(strings.TrimPrefix(piecePath, tarsFolder))
+		if !ok {
+			continue
+		}
+		found[number] = true
+		maxPart = max(maxPart, number)
+	}
+	var missing []int
+	for number := 1; number <= maxPart; number++ {
+		if !found[number] {
+			missing = append(missing, number)
+		}
+	}
+	return missing
+}
+
+// WALRange is a sequence of consecutive WAL segments on a timeline.
+type WALRange struct {
+	Timeline uint32
+	First    string
+	Last     string
+	Count    int
+}
+
+type walSegment struct {
+	timeline uint32
+	segNo    uint64
+	name     string
+}
+
+// WALRanges groups the WAL segments found in the storage into ranges of consecutive segments. If storageName is
+// empty, segments from all storages are grouped together.
+func (a StorageAudit) WALRanges(storageName string) []WALRange {
+	var segments []walSegment
+	for name, storages := range a.WALSegments {
+		if storageName != "" && !slices.Contains(storages, storageName) {
+			continue
+		}
+		timeline, segNo, _ := parseWALSegmentName(path.Base(name))
+		segments = append(segments, walSegment{timeline: timeline, segNo: segNo, name: name})
+	}
+	slices.SortFunc(segments, func(a, b walSegment) int {
+		return cmp.Or(cmp.Compare(a.timeline, b.timeline), cmp.Compare(a.segNo, b.segNo), strings.Compare(a.name, b.name))
+	})
+
+	var ranges []WALRange
+	var last walSegment
+	for i, segment := range segments {
+		switch {
+		case i > 0 && segment.timeline == last.timeline && segment.segNo == last.segNo:
+			// The same segment compressed in different ways
+			continue
+		case i > 0 && segment.timeline == last.timeline && segment.segNo == last.segNo+1:
+			ranges[len(ranges)-1].Last = segment.name
+			ranges[len(ranges)-1].Count++
+		default:
+			ranges = append(ranges, WALRange{Timeline: segment.timeline, First: segment.name, Last: segment.name, Count: 1})
+		}
+		last = segment
+	}
+	return ranges
+}
+
+// parseWALSegmentName parses the timeline and the segment number from the WAL segment object name. History files,
+// backup labels and partial segments aren't segments.
+func parseWALSegmentName(name string) (timeline uint32, segNo uint64, ok bool) {
+	match := walSegmentNameRegex.FindStringSubmatch(name)
+	if match == nil || match[4] == ".partial" {
+		return 0, 0, false
+	}
+	timeline64, _ := strconv.ParseUint(match[1], 16, 32)
+	logID, _ := strconv.ParseUint(match[2], 16, 32)
+	seg, _ := strconv.ParseUint(match[3], 16, 32)
+	return uint32(timeline64), logID*walSegmentsPerLogID() + seg, true
+}
+
+func walSegmentsPerLogID() uint64 {
+	walSizeMB := viper.GetUint64(conf.PgWalSize)
+	if walSizeMB == 0 {
+		walSizeMB = 16
+	}
+	return 0x100000000 / (walSizeMB * 1024 * 1024)
+}
+
+// WriteAudit prints where the backups and WAL are stored, and the problems found. Provides the number of problems.
+func WriteAudit(audit *StorageAudit, output io.Writer) (int, error) {
+	problemsCount := 0
+	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
+	_, err := fmt.Fprintln(writer, "backup\tstorage\tobjects\tsentinel\tproblems")
+	if err != nil {
+		return 0, err
+	}
+	for _, backup := range audit.Backups {
+		problems := backup.Problems(audit.Storages)
+		problemsCount += len(problems)
+		for _, storageName := range audit.Storages {
+			count := backup.piecesIn(storageName)
+			if count == 0 {
+				continue
+			}
+			hasSentinel := slices.Contains(backup.Pieces[backup.sentinelPath()], storageName)
+			_, err = fmt.Fprintf(writer, "%s\t%s\t%d/%d\t%t\t%s\n",
+				backup.Name, storageName, count, len(backup.Pieces), hasSentinel, strings.Join(problems, "; "))
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = fmt.Fprintln(writer, "\nstorage\ttimeline\tfirst WAL\tlast WAL\tsegments")
+	if err != nil {
+		return 0, err
+	}
+	for _, storageName := range audit.Storages {
+		for _, walRange := range audit.WALRanges(storageName) {
+			_, err = fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%d\n",
+				storageName, walRange.Timeline, walRange.First, walRange.Last, walRange.Count)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	allRanges := audit.WALRanges("")
+	for i := 1; i < len(allRanges); i++ {
+		if allRanges[i].Timeline != allRanges[i-1].Timeline {
+			continue
+		}
+		problemsCount++
+		_, err = fmt.Fprintf(output, "WAL gap on timeline %d between %s and %s: missing in all storages\n",
+			allRanges[i].Timeline, allRanges[i-1].Last, allRanges[i].First)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return problemsCount, nil
+}
+
+// RepairPlan lists the files to copy to the primary storage from each of the other storages. Backup data and WAL are
+// copied first, and sentinels after them, so a backup doesn't look complete in the primary storage before it is.
+type RepairPlan struct {
+	Data      map[string][]string
+	Sentinels map[string][]string
+}
+
+// PlanRepair selects the files that are missing in the primary storage, and the storages to copy them from. Pieces
+// of backups that don't have a sentinel in any storage aren't copied, since such backups can't be restored anyway.
+func PlanRepair(audit *StorageAudit) RepairPlan {
+	plan := RepairPlan{Data: map[string][]string{}, Sentinels: map[string][]string{}}
+	primary := consts.DefaultStorage
+
+	for _, backup := range audit.Backups {
+		if _, ok := backup.Pieces[backup.sentinelPath()]; !ok {
+			continue
+		}
+		for piecePath, storages := range backup.Pieces {
+			if slices.Contains(storages, primary) {
+				continue
+			}
+			source := firstInOrder(audit.Storages, storages)
+			if piecePath == backup.sentinelPath() {
+				plan.Sentinels[source] = append(plan.Sentinels[source], piecePath)
+			} else {
+				plan.Data[source] = append(plan.Data[source], piecePath)
+			}
+		}
+	}
+	for name, storages := range audit.WALSegments {
+		if slices.Contains(storages, primary) {
+			continue
+		}
+		source := firstInOrder(audit.Storages, storages)
+		plan.Data[source] = append(plan.Data[source], path.Join(utility.WalPath, name))
+	}
+
+	for _, paths := range plan.Data {
+		slices.Sort(paths)
+	}
+	for _, paths := range plan.Sentinels {
+		slices.Sort(paths)
+	}
+	return plan
+}
+
+func firstInOrder(storagesInOrder, storages []string) string {
+	for _, name := range storagesInOrder {
+		if slices.Contains(storages, name) {
+			return name
+		}
+	}
+	return storages[0]
+}
+
+// HandleAudit prints where the backups and WAL are stored in the multi-storage, and the problems found. If repairCfg
+// is set, copies the pieces missing in the primary storage from the other storages. Otherwise, fails if any problems
+// are found.
+func HandleAudit(ctx context.Context, rootFolder storage.Folder, output io.Writer, repairCfg *transfer.HandlerConfig) error {
+	audit, err := AuditStorages(ctx, rootFolder)
+	if err != nil {
+		return err
+	}
+	problemsCount, err := WriteAudit(audit, output)
+	if err != nil {
+		return fmt.Errorf("write audit: %w", err)
+	}
+
+	if repairCfg == nil {
+		if problemsCount > 0 {
+			return fmt.Errorf("%d problems found", problemsCount)
+		}
+		return nil
+	}
+	if !slices.Contains(audit.Storages, consts.DefaultStorage) {
+		return fmt.Errorf("can't repair: the primary storage is not alive")
+	}
+
+	plan := PlanRepair(audit)
+	for _, phase := range []map[string][]string{plan.Data, plan.Sentinels} {
+		for _, source := range audit.Storages {
+			paths := phase[source]
+			if len(paths) == 0 {
+				continue
+			}
+			tracelog.InfoLogger.Printf("Copying %d files from storage %q to %q", len(paths), source, consts.DefaultStorage)
+			handler, err := transfer.NewHandler(ctx, source, consts.DefaultStorage, transfer.NewListedFileLister(paths), repairCfg)
+			if err != nil {
+				return err
+			}
+			err = handler.Handle(ctx)
+			if err != nil {
+				return fmt.Errorf("copy files from storage %q: %w", source, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package storagetools
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/internal/multistorage/policies"
+	"github.com/wal-g/wal-g/internal/multistorage/stats"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// newAuditTestFolder puts the files to the storages, with the content from contents or empty.
+func newAuditTestFolder(t *testing.T, files map[string][]string, contents map[string]string) storage.Folder {
+	folders := map[string]storage.Folder{
+		"default":  memory.NewFolder("default/", memory.NewKVS()),
+		"failover": memory.NewFolder("failover/", memory.NewKVS()),
+	}
+	for storageName, paths := range files {
+		for _, filePath := range paths {
+			require.NoError(t, folders[storageName].PutObject(t.Context(), filePath, bytes.NewBufferString(contents[filePath])))
+		}
+	}
+	folder := multistorage.NewFolder(folders, stats.NewNopCollector([]string{"default", "failover"}))
+	folder, err := multistorage.UseAllAliveStorages(t.Context(), folder)
+	require.NoError(t, err)
+	return multistorage.SetPolicies(folder, policies.UniteAllStorages)
+}
+
+func TestAuditStorages(t *testing.T) {
+	folder := newAuditTestFolder(t, map[string][]string{
+		"default": {
+			"basebackups_005/base_1_backup_stop_sentinel.json",
+			"basebackups_005/base_1/tar_partitions/part_001.tar.br",
+			"basebackups_005/base_1/tar_partitions/part_002.tar.br",
+			"basebackups_005/base_2/tar_partitions/part_001.tar.br",
+			"basebackups_005/base_3/tar_partitions/part_001.tar.br",
+			"wal_005/000000010000000000000001.br",
+			"wal_005/000000010000000000000002.br",
+		},
+		"failover": {
+			"basebackups_005/base_2_backup_stop_sentinel.json",
+			"basebackups_005/base_2/tar_partitions/part_002.tar.br",
+			"basebackups_005/base_3/tar_partitions/part_003.tar.br",
+			"wal_005/000000010000000000000003.br",
+			"wal_005/000000010000000000000005.br",
+			"wal_005/000000010000000000000005.partial.br",
+		},
+	}, nil)
+
+	audit, err := AuditStorages(t.Context(), folder)
+	require.NoError(t, err)
+
+	require.Len(t, audit.Backups, 3)
+	assert.Empty(t, audit.Backups[0].Problems(audit.Storages))
+	assert.Equal(t, []string{"split between storages default (1/3), failover (2/3)"},
+		audit.Backups[1].Problems(audit.Storages))
+	assert.Equal(t, []string{"no sentinel", "missing tar parts [2]",
+		"split between storages default (1/2), failover (1/2)"}, audit.Backups[2].Problems(audit.Storages))
+
+	assert.Equal(t, []WALRange{
+		{Timeline: 1, First: "000000010000000000000001.br", Last: "000000010000000000000002.br", Count: 2},
+	}, audit.WALRanges("default"))
+	assert.Equal(t, []WALRange{
+		{Timeline: 1, First: "000000010000000000000001.br", Last: "000000010000000000000003.br", Count: 3},
+		{Timeline: 1, First: "000000010000000000000005.br", Last: "000000010000000000000005.br", Count: 1},
+	}, audit.WALRanges(""))
+
+	output := new(bytes.Buffer)
+	problems, err := WriteAudit(audit, output)
+	require.NoError(t, err)
+	assert.Equal(t, 5, problems)
+	assert.Contains(t, output.String(), "WAL gap on timeline 1 between 000000010000000000000003.br and 000000010000000000000005.br")
+
+	plan := PlanRepair(audit)
+	assert.Equal(t, map[string][]string{"failover": {
+		"basebackups_005/base_2/tar_partitions/part_002.tar.br",
+		"wal_005/000000010000000000000003.br",
+		"wal_005/000000010000000000000005.br",
+	}}, plan.Data)
+	assert.Equal(t, map[string][]string{"failover": {
+		"basebackups_005/base_2_backup_stop_sentinel.json",
+	}}, plan.Sentinels)
+}
+
+func TestAuditStorages_MissingLastTarPart(t *testing.T) {
+	folder := newAuditTestFolder(t, map[string][]string{
+		"default": {
+			"basebackups_005/base_1_backup_stop_sentinel.json",
+			"basebackups_005/base_1/tar_partitions/part_001.tar.br",
+			"basebackups_005/base_1/tar_partitions/part_002.tar.br",
+			"basebackups_005/base_2_backup_stop_sentinel.json",
+			"basebackups_005/base_2/files_metadata.json",
+			"basebackups_005/base_2/tar_partitions/part_001.tar.br",
+			"basebackups_005/base_2/tar_partitions/pg_control.tar.br",
+			"basebackups_005/base_3_backup_stop_sentinel.json",
+			"basebackups_005/base_3/tar_partitions/part_001.tar.br",
+		},
+	}, map[string]string{
+		"basebackups_005/base_1_backup_stop_sentinel.json": `{"CompressionDecisions":[` +
+			`{"Name":"part_001.tar.br"},{"Name":"part_002.tar.br"},{"Name":"part_003.tar.br"}]}`,
+		"basebackups_005/base_2_backup_stop_sentinel.json": `{"LSN":1}`,
+		"basebackups_005/base_2/files_metadata.json": `{"TarFileSets":{` +
+			`"part_001.tar.br":["base/1"],"part_002.tar.br":["base/2"],"pg_control.tar.br":["global/pg_control"]}}`,
+		"basebackups_005/base_3_backup_stop_sentinel.json": `{"FilesMetadataDisabled":true}`,
+	})
+
+	audit, err := AuditStorages(t.Context(), folder)
+	require.NoError(t, err)
+
+	require.Len(t, audit.Backups, 3)
+	assert.Equal(t, 3, audit.Backups[0].TarPartsCount)
+	assert.Equal(t, []string{"missing tar parts [3]"}, audit.Backups[0].Problems(audit.Storages))
+	assert.Equal(t, 2, audit.Backups[1].TarPartsCount)
+	assert.Equal(t, []string{"missing tar parts [2]"}, audit.Backups[1].Problems(audit.Storages))
+	assert.Zero(t, audit.Backups[2].TarPartsCount)
+	assert.Empty(t, audit.Backups[2].Problems(audit.Storages))
+}
+
+func TestAuditStorages_NestedWAL(t *testing.T) {
+	folder := newAuditTestFolder(t, map[string][]string{
+		"default": {
+			"wal_005/000000010000000000000001.br",
+			"wal_005/archive/000000010000000000000002.br",
+		},
+		"failover": {
+			"wal_005/archive/000000010000000000000003.br",
+		},
+	}, nil)
+
+	audit, err := AuditStorages(t.Context(), folder)
+	require.NoError(t, err)
+
+	assert.Equal(t, []WALRange{
+		{Timeline: 1, First: "000000010000000000000001.br", Last: "archive/000000010000000000000003.br", Count: 3},
+	}, audit.WALRanges(""))
+	plan := PlanRepair(audit)
+	assert.Equal(t, map[string][]string{"failover": {"wal_005/archive/000000010000000000000003.br"}}, plan.Data)
+}
+
+func TestParseWALSegmentName(t *testing.T) {
+	timeline, segNo, ok := parseWALSegmentName("0000000200000001000000FF.lz4")
+	require.True(t, ok)
+	assert.Equal(t, uint32(2), timeline)
+	assert.Equal(t, uint64(0x1FF), segNo)
+
+	for _, name := range []string{"00000002.history.br", "000000010000000000000001.partial", "basebackups_005"} {
+		_, _, ok = parseWALSegmentName(name)
+		assert.False(t, ok, name)
+	}
+}
@@ -0,0 +1,18 @@
+package storagetools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wal-g/wal-g/internal/dedup"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// HandleDedupGC deletes the deduplicated chunks which are no longer referenced by any backup.
+func HandleDedupGC(ctx context.Context, folder storage.Folder) error {
+	dedupFolder, ok := folder.(*dedup.Folder)
+	if !ok {
+		return fmt.Errorf("the deduplication isn't enabled for the storage, see WALG_USE_DEDUPLICATION")
+	}
+	return dedupFolder.CollectGarbage(ctx)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/objectheader"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
@@ -64,8 +65,16 @@ func downloadObject(ctx context.Context, objectPath string, folder storage.Folde
 	defer objReadCloser.Close()
 	var objReader io.Reader = objReadCloser
 
+	var header *objectheader.Header
+	if decrypt || decompress {
+		header, objReader, err = objectheader.Read(objReader)
+		if err != nil {
+			return err
+		}
+	}
+
 	if decrypt {
-		objReader, err = internal.DecryptBytes(objReader)
+		objReader, err = internal.DecryptWithHeader(objReader, header)
 		if err != nil {
 			return err
 		}
@@ -75,11 +84,18 @@ func downloadObject(ctx context.Context, objectPath string, folder storage.Folde
 		fileName := path.Base(objectPath)
 		fileExt := path.Ext(fileName)
 		decompressor := compression.FindDecompressor(fileExt)
-		if decompressor == nil {
+		if header != nil {
+			decompressor, err = internal.SelectDecompressor(header, decompressor)
+			if err != nil {
+				return err
+			}
+		}
+		switch {
+		case decompressor == nil && (header == nil || header.Compression == ""):
 			tracelog.WarningLogger.Printf(
 				"decompressor for extension '%s' was not found (supported methods: %v), will download uncompressed",
 				fileExt, compression.CompressingAlgorithms)
-		} else {
+		case decompressor != nil:
 			decrypterObjReadCloser, err := decompressor.Decompress(objReader)
 			if err != nil {
 				return err
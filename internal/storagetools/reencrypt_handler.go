@@ -0,0 +1,403 @@
+package storagetools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/integrity"
+	"github.com/wal-g/wal-g/internal/objectheader"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	sentinelKeyFingerprintField = "KeyFingerprint"
+	metadataKeyFingerprintField = "key_fingerprint"
+
+	progressHeaderPrefix = "# wal-g st reencrypt "
+	progressStarted      = "start"
+	progressDone         = "done"
+)
+
+// ReencryptConfig describes how HandleReencrypt rewrites the objects.
+type ReencryptConfig struct {
+	// From decrypts the objects and To encrypts them again. One of them can be nil if the objects are not encrypted
+	// or should not be encrypted anymore.
+	From crypto.Crypter
+	To   crypto.Crypter
+
+	// TargetPrefix is the folder where the rewritten objects are put to. Objects are rewritten in place if it is empty.
+	TargetPrefix string
+
+	// ProgressPath is the local file where the rewritten objects are recorded, so that an interrupted run can be
+	// resumed.
+	ProgressPath string
+
+	Concurrency int
+}
+
+// HandleReencrypt rewrites the objects under the prefix encrypted with the new key. Objects are only decrypted and
+// encrypted again, they stay compressed, and their object headers are updated with the new key. Sentinels and other JSON metadata are not encrypted, so they are rewritten
+// as-is, except for the fingerprint of the key recorded in the backup sentinels and metadata.
+func HandleReencrypt(ctx context.Context, rootFolder storage.Folder, prefix string, cfg ReencryptConfig) error {
+	if cfg.From == nil && cfg.To == nil {
+		return errors.New("neither the old nor the new encryption is configured")
+	}
+	fromFingerprint, err := crypto.KeyFingerprint(cfg.From)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to get the fingerprint of the old key: %v", err)
+	}
+	toFingerprint, err := crypto.KeyFingerprint(cfg.To)
+	if err != nil {
+		return fmt.Errorf("get the fingerprint of the new key: %w", err)
+	}
+	targetPrefix := strings.Trim(cfg.TargetPrefix, "/")
+
+	objects, err := storage.ListFolderRecursivelyWithPrefix(ctx, rootFolder, prefix)
+	if err != nil {
+		return err
+	}
+	objectNames := selectObjectsToReencrypt(objects, targetPrefix)
+	tracelog.InfoLogger.Printf("Found %d objects to re-encrypt from %q to %q", len(objectNames), fromFingerprint, toFingerprint)
+
+	header := fmt.Sprintf("from=%s to=%s prefix=%s target=%s", fromFingerprint, toFingerprint, prefix, targetPrefix)
+	progress, err := openReencryptProgress(cfg.ProgressPath, header)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(progress, "failed to close the progress file")
+
+	reencrypter := &reencrypter{
+		folder:        rootFolder,
+		from:          cfg.From,
+		to:            cfg.To,
+		toFingerprint: toFingerprint,
+		targetPrefix:  targetPrefix,
+		progress:      progress,
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(cfg.Concurrency, 1))
+	skipped := 0
+	for _, name := range objectNames {
+		state := progress.state(name)
+		if state == progressDone {
+			skipped++
+			continue
+		}
+		group.Go(func() error {
+			err := reencrypter.rewrite(groupCtx, name, state == progressStarted)
+			if err != nil {
+				return fmt.Errorf("re-encrypt %q: %w", name, err)
+			}
+			return nil
+		})
+	}
+	err = group.Wait()
+	tracelog.InfoLogger.Printf("Re-encrypted %d objects, %d objects were re-encrypted by previous runs",
+		reencrypter.objectsHandled, skipped)
+	if err != nil {
+		return err
+	}
+
+	manifests, manifestFolders, err := rootFolder.GetSubFolder(integrity.FolderName).ListFolder(ctx)
+	if err == nil && len(manifests)+len(manifestFolders) > 0 {
+		tracelog.WarningLogger.Printf("Integrity manifests in %q still describe the objects encrypted with the old key, "+
+			"`st verify` will report the re-encrypted objects as modified", integrity.FolderName)
+	}
+	return nil
+}
+
+// selectObjectsToReencrypt skips the integrity manifests, which describe the objects as they were uploaded, and the
+// objects that are already in the target folder.
+func selectObjectsToReencrypt(objects []storage.Object, targetPrefix string) []string {
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.GetName(), "/")
+		if strings.HasPrefix(name, integrity.FolderName+"/") {
+			continue
+		}
+		if targetPrefix != "" && strings.HasPrefix(name, targetPrefix+"/") {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+type reencrypter struct {
+	folder        storage.Folder
+	from          crypto.Crypter
+	to            crypto.Crypter
+	toFingerprint string
+	targetPrefix  string
+	progress      *reencryptProgress
+
+	mutex          sync.Mutex
+	objectsHandled int
+}
+
+func (r *reencrypter) rewrite(ctx context.Context, name string, started bool) error {
+	if strings.HasSuffix(name, ".json") {
+		return r.rewriteMetadata(ctx, name)
+	}
+
+	// The previous run might have been interrupted after the object had been rewritten in place, but before it was
+	// recorded as done. Such objects can be decrypted with the new key already.
+	if started && r.targetPrefix == "" {
+		reencrypted, err := r.isReencrypted(ctx, name)
+		if err != nil {
+			return err
+		}
+		if reencrypted {
+			tracelog.DebugLogger.Printf("%s was re-encrypted by the previous run", name)
+			return r.done(name)
+		}
+	}
+
+	err := r.progress.record(progressStarted, name)
+	if err != nil {
+		return err
+	}
+	reader, err := r.folder.ReadObject(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(reader, "failed to close the object reader")
+
+	header, content, err := objectheader.Read(reader)
+	if err != nil {
+		return err
+	}
+	var decrypted = content
+	if r.from != nil && (header == nil || header.IsEncrypted()) {
+		decrypted, err = r.from.Decrypt(content)
+		if err != nil {
+			return fmt.Errorf("decrypt: %w", err)
+		}
+	}
+	encrypted, err := Encrypt(decrypted, r.to)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	if closer, ok := encrypted.(io.Closer); ok {
+		// stops the encrypting goroutine if the upload fails
+		defer utility.LoggedClose(closer, "failed to close the encrypted reader")
+	}
+	if header != nil {
+		headerBytes, err := r.reencryptedHeader(*header).Marshal()
+		if err != nil {
+			return err
+		}
+		encrypted = io.MultiReader(bytes.NewReader(headerBytes), encrypted)
+	}
+
+	err = r.folder.PutObject(ctx, r.targetPath(name), encrypted)
+	if err != nil {
+		return err
+	}
+	return r.done(name)
+}
+
+// isReencrypted tells if the object can be decrypted with the new key. If the objects are decrypted, plain content
+// can't be told apart from any other, so the object is considered rewritten if it can't be decrypted with the old key.
+func (r *reencrypter) isReencrypted(ctx context.Context, name string) (bool, error) {
+	reader, err := r.folder.ReadObject(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	defer utility.LoggedClose(reader, "failed to close the object reader")
+
+	header, content, err := objectheader.Read(reader)
+	if err != nil {
+		return false, err
+	}
+	if header != nil {
+		return *header == r.reencryptedHeader(*header), nil
+	}
+	if r.to != nil {
+		return canDecrypt(content, r.to), nil
+	}
+	return !canDecrypt(content, r.from), nil
+}
+
+// reencryptedHeader provides the header of the object encrypted with the new key.
+func (r *reencrypter) reencryptedHeader(header objectheader.Header) objectheader.Header {
+	header.Crypter = ""
+	if r.to != nil {
+		header.Crypter = r.to.Name()
+	}
+	header.KeyID = r.toFingerprint
+	return header
+}
+
+func canDecrypt(reader io.Reader, crypter crypto.Crypter) bool {
+	decrypted, err := crypter.Decrypt(reader)
+	if err != nil {
+		return false
+	}
+	_, err = decrypted.Read(make([]byte, 1))
+	return err == nil || errors.Is(err, io.EOF)
+}
+
+// rewriteMetadata updates the key fingerprint in the backup sentinels and metadata, and copies other JSON objects to
+// the target folder as-is.
+func (r *reencrypter) rewriteMetadata(ctx context.Context, name string) error {
+	var field string
+	switch {
+	case strings.HasSuffix(name, utility.SentinelSuffix):
+		field = sentinelKeyFingerprintField
+	case path.Base(name) == utility.MetadataFileName:
+		field = metadataKeyFingerprintField
+	case r.targetPrefix == "":
+		return r.done(name)
+	default:
+		err := r.folder.CopyObject(ctx, name, r.targetPath(name))
+		if err != nil {
+			return err
+		}
+		return r.done(name)
+	}
+
+	reader, err := r.folder.ReadObject(ctx, name)
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(reader)
+	utility.LoggedClose(reader, "failed to close the object reader")
+	if err != nil {
+		return err
+	}
+	updated, err := setKeyFingerprint(content, field, r.toFingerprint)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to update the key fingerprint in %s, leaving it as-is: %v", name, err)
+		updated = content
+	}
+	err = r.folder.PutObject(ctx, r.targetPath(name), bytes.NewReader(updated))
+	if err != nil {
+		return err
+	}
+	return r.done(name)
+}
+
+func setKeyFingerprint(content []byte, field, fingerprint string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(content, &fields)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint == "" {
+		delete(fields, field)
+	} else {
+		fields[field], err = json.Marshal(fingerprint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func (r *reencrypter) targetPath(name string) string {
+	if r.targetPrefix == "" {
+		return name
+	}
+	return path.Join(r.targetPrefix, name)
+}
+
+func (r *reencrypter) done(name string) error {
+	err := r.progress.record(progressDone, name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.objectsHandled++
+	if r.objectsHandled%1000 == 0 {
+		tracelog.InfoLogger.Printf("Re-encrypted %d objects", r.objectsHandled)
+	}
+	return nil
+}
+
+// reencryptProgress is a local file where objects are recorded when their rewriting is started and done. The header
+// line makes sure that the run is resumed with the same keys and folders.
+type reencryptProgress struct {
+	file   *os.File
+	states map[string]string
+
+	mutex sync.Mutex
+}
+
+func openReencryptProgress(progressPath, header string) (*reencryptProgress, error) {
+	header = progressHeaderPrefix + header
+	progress := &reencryptProgress{states: make(map[string]string)}
+
+	content, err := os.ReadFile(progressPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		content = []byte(header + "\n")
+		err = os.WriteFile(progressPath, content, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("create the progress file: %w", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("read the progress file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	if !scanner.Scan() || scanner.Text() != header {
+		return nil, fmt.Errorf("progress file %q belongs to another re-encryption (%q), remove it to start over",
+			progressPath, scanner.Text())
+	}
+	for scanner.Scan() {
+		state, name, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || (state != progressStarted && state != progressDone) {
+			// the last line can be incomplete if the previous run was interrupted
+			continue
+		}
+		if progress.states[name] != progressDone {
+			progress.states[name] = state
+		}
+	}
+
+	progress.file, err = os.OpenFile(progressPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open the progress file: %w", err)
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		_, err = progress.file.WriteString("\n")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return progress, nil
+}
+
+func (progress *reencryptProgress) state(name string) string {
+	return progress.states[name]
+}
+
+func (progress *reencryptProgress) record(state, name string) error {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	_, err := progress.file.WriteString(state + " " + name + "\n")
+	if err != nil {
+		return fmt.Errorf("record the progress: %w", err)
+	}
+	return nil
+}
+
+func (progress *reencryptProgress) Close() error {
+	return progress.file.Close()
+}
@@ -0,0 +1,216 @@
+package storagetools
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	agelib "filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/crypto/age"
+	"github.com/wal-g/wal-g/internal/objectheader"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	reencryptTestWal      = "wal_005/000000010000000000000001.br"
+	reencryptTestPart     = "basebackups_005/base_1/tar_partitions/part_1.tar.br"
+	reencryptTestSentinel = "basebackups_005/base_1_backup_stop_sentinel.json"
+	reencryptTestMetadata = "basebackups_005/base_1/metadata.json"
+	reencryptTestManifest = "integrity_005/wal_005/0000000100000000/manifest.json"
+)
+
+func newAgeTestCrypter(t *testing.T) crypto.Crypter {
+	identity, err := agelib.GenerateX25519Identity()
+	require.NoError(t, err)
+	identityPath := filepath.Join(t.TempDir(), "identity.txt")
+	require.NoError(t, os.WriteFile(identityPath, []byte(identity.String()+"\n"), 0600))
+	return age.CrypterFromRecipients(identity.Recipient().String(), identityPath)
+}
+
+func putEncrypted(t *testing.T, folder storage.Folder, name string, crypter crypto.Crypter, content string) {
+	encrypted, err := Encrypt(bytes.NewBufferString(content), crypter)
+	require.NoError(t, err)
+	require.NoError(t, folder.PutObject(t.Context(), name, encrypted))
+}
+
+func readDecrypted(t *testing.T, folder storage.Folder, name string, crypter crypto.Crypter) (string, error) {
+	reader, err := folder.ReadObject(t.Context(), name)
+	require.NoError(t, err)
+	defer reader.Close()
+	decrypted, err := crypter.Decrypt(reader)
+	if err != nil {
+		return "", err
+	}
+	content, err := io.ReadAll(decrypted)
+	return string(content), err
+}
+
+func readJSONField(t *testing.T, folder storage.Folder, name, field string) interface{} {
+	reader, err := folder.ReadObject(t.Context(), name)
+	require.NoError(t, err)
+	defer reader.Close()
+	var fields map[string]interface{}
+	require.NoError(t, json.NewDecoder(reader).Decode(&fields))
+	return fields[field]
+}
+
+func newReencryptTestFolder(t *testing.T, crypter crypto.Crypter) storage.Folder {
+	folder := memory.NewFolder("", memory.NewKVS())
+	putEncrypted(t, folder, reencryptTestWal, crypter, "wal")
+	putEncrypted(t, folder, reencryptTestPart, crypter, "part")
+	require.NoError(t, folder.PutObject(t.Context(), reencryptTestSentinel,
+		bytes.NewBufferString(`{"LSN":1,"KeyFingerprint":"old"}`)))
+	require.NoError(t, folder.PutObject(t.Context(), reencryptTestMetadata,
+		bytes.NewBufferString(`{"start_lsn":1,"key_fingerprint":"old"}`)))
+	require.NoError(t, folder.PutObject(t.Context(), reencryptTestManifest, bytes.NewBufferString(`{}`)))
+	return folder
+}
+
+func TestHandleReencrypt_InPlace(t *testing.T) {
+	from, to := newAgeTestCrypter(t), newAgeTestCrypter(t)
+	toFingerprint, err := crypto.KeyFingerprint(to)
+	require.NoError(t, err)
+	folder := newReencryptTestFolder(t, from)
+	cfg := ReencryptConfig{
+		From:         from,
+		To:           to,
+		ProgressPath: filepath.Join(t.TempDir(), "progress"),
+		Concurrency:  2,
+	}
+
+	require.NoError(t, HandleReencrypt(t.Context(), folder, "", cfg))
+
+	for name, expected := range map[string]string{reencryptTestWal: "wal", reencryptTestPart: "part"} {
+		content, err := readDecrypted(t, folder, name, to)
+		require.NoError(t, err)
+		assert.Equal(t, expected, content)
+		_, err = readDecrypted(t, folder, name, from)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, toFingerprint, readJSONField(t, folder, reencryptTestSentinel, "KeyFingerprint"))
+	assert.Equal(t, toFingerprint, readJSONField(t, folder, reencryptTestMetadata, "key_fingerprint"))
+	assert.Equal(t, float64(1), readJSONField(t, folder, reencryptTestSentinel, "LSN"))
+
+	// everything is recorded as done, so the second run doesn't decrypt the objects with the old key
+	require.NoError(t, HandleReencrypt(t.Context(), folder, "", cfg))
+
+	// the run can't be resumed with other keys
+	cfg.To = from
+	assert.ErrorContains(t, HandleReencrypt(t.Context(), folder, "", cfg), "belongs to another re-encryption")
+}
+
+func TestHandleReencrypt_ResumeAfterRewrite(t *testing.T) {
+	from, to := newAgeTestCrypter(t), newAgeTestCrypter(t)
+	fromFingerprint, err := crypto.KeyFingerprint(from)
+	require.NoError(t, err)
+	toFingerprint, err := crypto.KeyFingerprint(to)
+	require.NoError(t, err)
+	folder := newReencryptTestFolder(t, from)
+
+	// the previous run was interrupted after the WAL segment was rewritten, but before it was recorded as done
+	putEncrypted(t, folder, reencryptTestWal, to, "wal")
+	progressPath := filepath.Join(t.TempDir(), "progress")
+	progress := progressHeaderPrefix + "from=" + fromFingerprint + " to=" + toFingerprint + " prefix=wal_005 target=\n" +
+		"start " + reencryptTestWal + "\n"
+	require.NoError(t, os.WriteFile(progressPath, []byte(progress), 0600))
+
+	cfg := ReencryptConfig{From: from, To: to, ProgressPath: progressPath, Concurrency: 1}
+	require.NoError(t, HandleReencrypt(t.Context(), folder, "wal_005", cfg))
+
+	content, err := readDecrypted(t, folder, reencryptTestWal, to)
+	require.NoError(t, err)
+	assert.Equal(t, "wal", content)
+	content, err = readDecrypted(t, folder, reencryptTestPart, from)
+	require.NoError(t, err)
+	assert.Equal(t, "part", content)
+}
+
+func TestHandleReencrypt_ResumeDoesNotSkipUndecryptableObjects(t *testing.T) {
+	from, to, other := newAgeTestCrypter(t), newAgeTestCrypter(t), newAgeTestCrypter(t)
+	fromFingerprint, err := crypto.KeyFingerprint(from)
+	require.NoError(t, err)
+	toFingerprint, err := crypto.KeyFingerprint(to)
+	require.NoError(t, err)
+	folder := newReencryptTestFolder(t, from)
+
+	// neither key decrypts the object, so it isn't considered re-encrypted by the previous run
+	putEncrypted(t, folder, reencryptTestWal, other, "wal")
+	progressPath := filepath.Join(t.TempDir(), "progress")
+	progress := progressHeaderPrefix + "from=" + fromFingerprint + " to=" + toFingerprint + " prefix=wal_005 target=\n" +
+		"start " + reencryptTestWal + "\n"
+	require.NoError(t, os.WriteFile(progressPath, []byte(progress), 0600))
+
+	cfg := ReencryptConfig{From: from, To: to, ProgressPath: progressPath, Concurrency: 1}
+	assert.ErrorContains(t, HandleReencrypt(t.Context(), folder, "wal_005", cfg), "decrypt")
+}
+
+func TestHandleReencrypt_TargetPrefix(t *testing.T) {
+	from, to := newAgeTestCrypter(t), newAgeTestCrypter(t)
+	folder := newReencryptTestFolder(t, from)
+	require.NoError(t, folder.PutObject(t.Context(), "basebackups_005/base_1/files_metadata.json",
+		bytes.NewBufferString(`{}`)))
+	cfg := ReencryptConfig{
+		From:         from,
+		To:           to,
+		TargetPrefix: "reencrypted/",
+		ProgressPath: filepath.Join(t.TempDir(), "progress"),
+		Concurrency:  2,
+	}
+
+	require.NoError(t, HandleReencrypt(t.Context(), folder, "", cfg))
+
+	content, err := readDecrypted(t, folder, "reencrypted/"+reencryptTestPart, to)
+	require.NoError(t, err)
+	assert.Equal(t, "part", content)
+	content, err = readDecrypted(t, folder, reencryptTestPart, from)
+	require.NoError(t, err)
+	assert.Equal(t, "part", content)
+	assert.Equal(t, "old", readJSONField(t, folder, reencryptTestSentinel, "KeyFingerprint"))
+
+	for _, name := range []string{reencryptTestSentinel, "basebackups_005/base_1/files_metadata.json"} {
+		exists, err := folder.Exists(t.Context(), "reencrypted/"+name)
+		require.NoError(t, err)
+		assert.True(t, exists, name)
+	}
+	exists, err := folder.Exists(t.Context(), "reencrypted/"+reencryptTestManifest)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestHandleReencrypt_ObjectHeader(t *testing.T) {
+	from, to := newAgeTestCrypter(t), newAgeTestCrypter(t)
+	fromFingerprint, err := crypto.KeyFingerprint(from)
+	require.NoError(t, err)
+	toFingerprint, err := crypto.KeyFingerprint(to)
+	require.NoError(t, err)
+
+	folder := memory.NewFolder("", memory.NewKVS())
+	header, err := objectheader.Header{Compression: "br", Crypter: from.Name(), KeyID: fromFingerprint}.Marshal()
+	require.NoError(t, err)
+	encrypted, err := Encrypt(bytes.NewBufferString("wal"), from)
+	require.NoError(t, err)
+	require.NoError(t, folder.PutObject(t.Context(), reencryptTestWal, io.MultiReader(bytes.NewReader(header), encrypted)))
+
+	cfg := ReencryptConfig{From: from, To: to, ProgressPath: filepath.Join(t.TempDir(), "progress"), Concurrency: 1}
+	require.NoError(t, HandleReencrypt(t.Context(), folder, "", cfg))
+
+	reader, err := folder.ReadObject(t.Context(), reencryptTestWal)
+	require.NoError(t, err)
+	defer reader.Close()
+	reencryptedHeader, content, err := objectheader.Read(reader)
+	require.NoError(t, err)
+	require.NotNil(t, reencryptedHeader)
+	assert.Equal(t, objectheader.Header{Compression: "br", Crypter: to.Name(), KeyID: toFingerprint}, *reencryptedHeader)
+	decrypted, err := to.Decrypt(content)
+	require.NoError(t, err)
+	wal, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "wal", string(wal))
+}
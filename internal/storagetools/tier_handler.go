@@ -0,0 +1,68 @@
+package storagetools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// HandleTier moves the data objects of old backups to the storage class. Backups are selected if they are older than
+// olderThan and not among the retainCount newest ones. A zero olderThan or negative retainCount disables the
+// corresponding condition, but at least one of them must be set. The backups that the kept delta backups are
+// incremented from are never selected.
+func HandleTier(ctx context.Context, rootFolder storage.Folder, storageClass string, olderThan time.Duration,
+	retainCount int, confirm bool) error {
+	if olderThan <= 0 && retainCount < 0 {
+		return fmt.Errorf("either the age or the number of retained backups must be specified")
+	}
+
+	backups, err := internal.GetBackups(ctx, rootFolder.GetSubFolder(utility.BaseBackupPath))
+	if _, ok := err.(internal.NoBackupsFoundError); ok {
+		tracelog.InfoLogger.Println("No backups found")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("list backups: %w", err)
+	}
+
+	backupNames := selectBackupsToTier(backups, time.Now().Add(-olderThan), olderThan > 0, retainCount)
+	retainedNames := make([]string, 0, len(backups)-len(backupNames))
+	for _, backup := range backups {
+		if !slices.Contains(backupNames, backup.BackupName) {
+			retainedNames = append(retainedNames, backup.BackupName)
+		}
+	}
+	backupNames, err = internal.ExcludeIncrementBases(ctx, rootFolder.GetSubFolder(utility.BaseBackupPath),
+		backupNames, retainedNames)
+	if err != nil {
+		return err
+	}
+	if len(backupNames) == 0 {
+		tracelog.InfoLogger.Printf("No backup found for moving to %s", storageClass)
+		return nil
+	}
+	tracelog.InfoLogger.Printf("Backups to move to %s: %v", storageClass, backupNames)
+	return internal.TierBackups(ctx, rootFolder, backupNames, storageClass, confirm)
+}
+
+func selectBackupsToTier(backups []internal.BackupTime, before time.Time, checkTime bool, retainCount int) []string {
+	internal.SortBackupTimeSlices(backups)
+	backupNames := make([]string, 0)
+	// Backups are sorted from the oldest to the newest one
+	for i, backup := range backups {
+		if retainCount >= 0 && i >= len(backups)-retainCount {
+			break
+		}
+		if checkTime && !backup.Time.Before(before) {
+			break
+		}
+		backupNames = append(backupNames, backup.BackupName)
+	}
+	return backupNames
+}
@@ -0,0 +1,35 @@
+package storagetools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+)
+
+func TestSelectBackupsToTier(t *testing.T) {
+	now := time.Now()
+	backups := []internal.BackupTime{
+		{BackupName: "base_3", Time: now.Add(-1 * 24 * time.Hour)},
+		{BackupName: "base_1", Time: now.Add(-30 * 24 * time.Hour)},
+		{BackupName: "base_2", Time: now.Add(-10 * 24 * time.Hour)},
+	}
+
+	t.Run("by age", func(t *testing.T) {
+		names := selectBackupsToTier(backups, now.Add(-7*24*time.Hour), true, -1)
+		assert.Equal(t, []string{"base_1", "base_2"}, names)
+	})
+	t.Run("by count", func(t *testing.T) {
+		names := selectBackupsToTier(backups, now, false, 1)
+		assert.Equal(t, []string{"base_1", "base_2"}, names)
+	})
+	t.Run("by age and count", func(t *testing.T) {
+		names := selectBackupsToTier(backups, now.Add(-7*24*time.Hour), true, 2)
+		assert.Equal(t, []string{"base_1"}, names)
+	})
+	t.Run("retain everything", func(t *testing.T) {
+		names := selectBackupsToTier(backups, now, false, 3)
+		assert.Empty(t, names)
+	})
+}
@@ -45,6 +45,33 @@ func (l *RegularFileLister) ListFilesToMove(ctx context.Context, source, target
 	return limitedFiles, len(limitedFiles), nil
 }
 
+// ListedFileLister lists the specified files, except for the ones that already exist in the target storage. It's used
+// when the files to move are selected by the caller, e.g. to complete backups split between storages.
+type ListedFileLister struct {
+	Paths []string
+}
+
+func NewListedFileLister(paths []string) *ListedFileLister {
+	return &ListedFileLister{
+		Paths: paths,
+	}
+}
+
+func (l *ListedFileLister) ListFilesToMove(ctx context.Context, _, target storage.Folder) (files []FilesGroup, num int, err error) {
+	for _, filePath := range l.Paths {
+		exists, err := target.Exists(ctx, filePath)
+		if err != nil {
+			return nil, 0, fmt.Errorf("check file %q for existence in the target storage: %w", filePath, err)
+		}
+		if exists {
+			continue
+		}
+		files = append(files, FilesGroup{FileToMove{path: filePath}})
+	}
+	tracelog.InfoLogger.Printf("Files will be transferred: %d", len(files))
+	return files, len(files), nil
+}
+
 func listMissingFiles(
 	ctx context.Context, source, target storage.Folder, prefix string, overwrite bool,
 ) (map[string]storage.Object, error) {
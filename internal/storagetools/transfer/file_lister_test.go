@@ -95,3 +95,19 @@ func TestRegularFileLister_ListFilesToMove(t *testing.T) {
 		require.Len(t, groups, 1)
 	})
 }
+
+func TestListedFileLister_ListFilesToMove(t *testing.T) {
+	source := memory.NewFolder("source/", memory.NewKVS())
+	target := memory.NewFolder("target/", memory.NewKVS())
+	_ = source.PutObject(t.Context(), "1/a", &bytes.Buffer{})
+	_ = source.PutObject(t.Context(), "1/b", &bytes.Buffer{})
+	_ = source.PutObject(t.Context(), "1/c", &bytes.Buffer{})
+	_ = target.PutObject(t.Context(), "1/b", &bytes.Buffer{})
+
+	l := NewListedFileLister([]string{"1/a", "1/b"})
+	groups, num, err := l.ListFilesToMove(t.Context(), source, target)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, num)
+	assert.Equal(t, []FilesGroup{{FileToMove{path: "1/a"}}}, groups)
+}
@@ -0,0 +1,55 @@
+package storagetools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/integrity"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// HandleVerify compares the objects under the prefix with the sizes and checksums recorded in the integrity
+// manifests at upload time. Objects that are missing are reported, but fail the check only if failOnMissing is set,
+// because the objects may be removed without deleting their manifests, e.g. by a storage lifecycle policy.
+func HandleVerify(ctx context.Context, prefix string, folder storage.Folder, concurrency int, failOnMissing bool) error {
+	report, err := integrity.Verify(ctx, folder, prefix, concurrency)
+	if err != nil {
+		return fmt.Errorf("verify objects: %w", err)
+	}
+
+	for _, missing := range report.Missing {
+		tracelog.WarningLogger.Printf("Object %q is missing", missing)
+	}
+	for _, mismatch := range report.Corrupted {
+		tracelog.ErrorLogger.Printf("Object %q is corrupted: %s", mismatch.Path, mismatch.Reason)
+	}
+	tracelog.InfoLogger.Printf("Verified %d objects: %d OK, %d corrupted, %d missing",
+		report.Verified+len(report.Corrupted)+len(report.Missing),
+		report.Verified, len(report.Corrupted), len(report.Missing))
+
+	if len(report.Corrupted) > 0 {
+		return fmt.Errorf("%d objects are corrupted", len(report.Corrupted))
+	}
+	if failOnMissing && len(report.Missing) > 0 {
+		return fmt.Errorf("%d objects are missing", len(report.Missing))
+	}
+	return nil
+}
+
+// HandleFlushIntegritySpool writes the integrity manifests of all the objects collected in the local spool for the
+// storage, without waiting for the batches to fill up, so that the objects can be verified right away.
+func HandleFlushIntegritySpool(ctx context.Context, storageName string, st storage.Storage) error {
+	hashable, ok := st.(storage.HashableStorage)
+	if !ok {
+		return fmt.Errorf("storage %q can't be identified to find its integrity spool", storageName)
+	}
+	spool := internal.NewIntegritySpool(multistorage.StorageKey(storageName, hashable))
+	err := integrity.NewRecorder(st.RootFolder(), spool).FlushSpool(ctx)
+	if err != nil {
+		return fmt.Errorf("flush integrity spool: %w", err)
+	}
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/adaptive"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/testtools"
 )
 
@@ -110,3 +112,33 @@ func TestPackFileTo(t *testing.T) {
 	}
 	assert.Equal(t, []byte(mockData), interpreter.Out)
 }
+
+func TestStorageTarBall_AdaptiveCompression(t *testing.T) {
+	compressor := adaptive.NewCompressor(adaptive.DefaultStrongLevel)
+	folder := memory.NewFolder("", memory.NewKVS())
+	uploader := internal.NewRegularUploader(compressor, folder)
+
+	tarBall := internal.NewStorageTarBallMaker("base_1", uploader).Make(false)
+	tarBall.SetUp(t.Context(), nil)
+	data := strings.Repeat("compressible ", 1000)
+	_, err := internal.PackFileTo(tarBall, &tar.Header{Name: "file", Mode: 0600, Size: int64(len(data)),
+		Typeflag: tar.TypeReg}, strings.NewReader(data))
+	assert.NoError(t, err)
+	assert.NoError(t, tarBall.CloseTar())
+	assert.NoError(t, tarBall.AwaitUploads())
+
+	assert.Equal(t, "part_001.tar.adaptive", tarBall.Name())
+	decisions := adaptive.Decisions(uploader.Compression())
+	assert.Len(t, decisions, 1)
+	assert.Equal(t, tarBall.Name(), decisions[0].Name)
+	assert.Equal(t, adaptive.MethodZstd, decisions[0].Method)
+
+	object, err := folder.ReadObject(t.Context(), internal.GetBackupTarPath("base_1", tarBall.Name()))
+	assert.NoError(t, err)
+	defer object.Close()
+	reader, err := internal.DecryptAndDecompressTar(object, tarBall.Name(), nil)
+	assert.NoError(t, err)
+	header, err := tar.NewReader(reader).Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "file", header.Name)
+}
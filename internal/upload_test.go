@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/integrity"
 	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/test/mocks"
 	"go.uber.org/mock/gomock"
@@ -115,3 +116,21 @@ func TestUploadMock(t *testing.T) {
 
 	assert.Error(t, uploadWithErr)
 }
+
+func TestUploadRecordsIntegrityManifest(t *testing.T) {
+	kvs := memory.NewKVS()
+	folder := memory.NewFolder("in_memory/", kvs)
+	uploader := internal.NewRegularUploader(nil, folder)
+	uploader.EnableIntegrityManifest(nil)
+	uploader.ChangeDirectory("basebackups_005")
+
+	err := uploader.Upload(t.Context(), "base_1/tar_partitions/part_1.tar", strings.NewReader("part"))
+	assert.NoError(t, err)
+	err = uploader.Upload(t.Context(), "base_1_backup_stop_sentinel.json", strings.NewReader("{}"))
+	assert.NoError(t, err)
+
+	report, err := integrity.Verify(t.Context(), folder, "basebackups_005/", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Verified)
+	assert.Empty(t, report.Corrupted)
+}
@@ -6,12 +6,18 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/checksum"
 	"github.com/wal-g/wal-g/internal/compression"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/integrity"
 	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/internal/multistorage"
 	"github.com/wal-g/wal-g/internal/statistics"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
@@ -52,6 +58,13 @@ type RegularUploader struct {
 	failed          atomic.Bool
 	tarSize         *atomic.Int64
 	dataSize        *atomic.Int64
+
+	// integrity records the checksums of uploaded objects, nil if disabled (see EnableIntegrityManifest)
+	integrity *integrity.Recorder
+	// directory is the path of UploadingFolder relative to the folder the integrity manifests are recorded for
+	directory string
+	// isClone is set for the uploaders created by Clone, which don't write the integrity manifests on Finish
+	isClone bool
 }
 
 var _ Uploader = &RegularUploader{}
@@ -131,6 +144,10 @@ func (uploader *RegularUploader) Finish() {
 	if uploader.failed.Load() {
 		tracelog.ErrorLogger.Printf("WAL-G could not complete upload.\n")
 	}
+	if !uploader.isClone {
+		// the clones share the recorder, so the parent writes the manifests once all of them have finished
+		uploader.flushIntegrityManifest(context.Background())
+	}
 }
 
 // Clone creates similar Uploader with new WaitGroup
@@ -142,6 +159,9 @@ func (uploader *RegularUploader) Clone() Uploader {
 		failed:          atomic.Bool{},
 		tarSize:         uploader.tarSize,
 		dataSize:        uploader.dataSize,
+		integrity:       uploader.integrity,
+		directory:       uploader.directory,
+		isClone:         true,
 	}
 	clone.failed.Store(uploader.Failed())
 	return clone
@@ -183,6 +203,29 @@ func (uploader *RegularUploader) DisableSizeTracking() {
 	uploader.dataSize = nil
 }
 
+// EnableIntegrityManifest makes the uploader record the size and SHA-256 of every uploaded object
+// into the integrity manifests under the current UploadingFolder, which is expected to be the storage root.
+// The spool may be nil, then the objects stored right in a directory get a manifest each.
+func (uploader *RegularUploader) EnableIntegrityManifest(spool *integrity.Spool) {
+	uploader.integrity = integrity.NewRecorder(uploader.UploadingFolder, spool)
+	uploader.directory = ""
+}
+
+// NewIntegritySpool provides the spool of the integrity manifest entries of the objects uploaded to the storages with
+// the keys, see multistorage.UsedStorageKeys.
+func NewIntegritySpool(storageKeys ...string) *integrity.Spool {
+	storageKey := strings.Join(append(storageKeys, viper.GetString(conf.StoragePrefixSetting)), ",")
+	return integrity.NewSpool(integrity.DefaultSpoolPath(storageKey))
+}
+
+// integritySpoolOf provides the spool for the objects uploaded to the folder, or nil if its storages are unknown.
+func integritySpoolOf(folder storage.Folder) *integrity.Spool {
+	if _, ok := folder.(multistorage.Folder); !ok {
+		return nil
+	}
+	return NewIntegritySpool(multistorage.UsedStorageKeys(folder)...)
+}
+
 // Compression returns configured compressor
 func (uploader *RegularUploader) Compression() compression.Compressor {
 	return uploader.Compressor
@@ -196,6 +239,12 @@ func (uploader *RegularUploader) Upload(ctx context.Context, path string, conten
 	if uploader.tarSize != nil {
 		content = utility.NewWithSizeReader(content, uploader.tarSize)
 	}
+	var objectChecksum *checksum.Calculator
+	objectSize := new(atomic.Int64)
+	if uploader.integrity != nil {
+		objectChecksum = checksum.CreateCalculator()
+		content = utility.NewWithSizeReader(checksum.CreateReaderWithChecksum(content, objectChecksum), objectSize)
+	}
 	err := uploader.UploadingFolder.PutObject(ctx, path, content)
 	if err != nil {
 		statistics.WalgMetrics.UploadedFilesFailedTotal.Inc()
@@ -203,9 +252,31 @@ func (uploader *RegularUploader) Upload(ctx context.Context, path string, conten
 		tracelog.ErrorLogger.Printf(tracelog.GetErrorFormatter()+"\n", err)
 		return err
 	}
+	if uploader.integrity != nil {
+		err = uploader.integrity.Record(ctx, uploader.directory, path, objectSize.Load(), objectChecksum.Checksum())
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to record %q in the integrity manifest: %v", path, err)
+		}
+		if !strings.Contains(strings.Trim(path, "/"), "/") {
+			// Objects stored right in the directory, like sentinels, are usually the last ones uploaded by
+			// the command, so the manifests are written without waiting for Finish, which not every command calls.
+			// The other flat objects, like WAL segments, are batched in the spool and don't add manifests here.
+			uploader.flushIntegrityManifest(ctx)
+		}
+	}
 	return nil
 }
 
+func (uploader *RegularUploader) flushIntegrityManifest(ctx context.Context) {
+	if uploader.integrity == nil {
+		return
+	}
+	err := uploader.integrity.Flush(ctx)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to upload the integrity manifest: %v", err)
+	}
+}
+
 // UploadJSON uploads raw JSON to storage without allocating memory for whole JSON.
 func (uploader *RegularUploader) UploadJSON(ctx context.Context, path string, data any) error {
 	reader, writer := io.Pipe()
@@ -241,11 +312,13 @@ func (uploader *RegularUploader) UploadMultiple(ctx context.Context, objects []U
 			return err
 		}
 	}
+	uploader.flushIntegrityManifest(ctx)
 	return nil
 }
 
 func (uploader *RegularUploader) ChangeDirectory(relativePath string) {
 	uploader.UploadingFolder = uploader.UploadingFolder.GetSubFolder(relativePath)
+	uploader.directory = storage.JoinPath(uploader.directory, relativePath)
 }
 
 func (uploader *RegularUploader) Folder() storage.Folder {
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	zstdcompression "github.com/wal-g/wal-g/internal/compression/zstd"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const ZstdDictionaryExtension = ".zdict"
+
+// ZstdDictionaryName provides the name of the dictionary object in the dictionaries folder
+func ZstdDictionaryName(id uint32) string {
+	return fmt.Sprintf("%08x%s", id, ZstdDictionaryExtension)
+}
+
+// ReadZstdDictionary reads and decrypts the dictionary with the given ID
+func ReadZstdDictionary(ctx context.Context, dictionaryReader StorageFolderReader, id uint32) ([]byte, error) {
+	object, err := dictionaryReader.ReadObject(ctx, ZstdDictionaryName(id))
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(object, "")
+
+	decrypted, err := DecryptBytes(object)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(decrypted)
+}
+
+// ConfigureZstdDictionaryLoader makes the zstd decompressor load the dictionaries, e.g. of the WAL compressed by
+// wal-push, from the storage. Nothing is read until a stream compressed with a dictionary is met, and the loaded
+// dictionaries are kept in memory.
+func ConfigureZstdDictionaryLoader(ctx context.Context, getRootReader func() (StorageFolderReader, error)) {
+	var mutex sync.Mutex
+	var dictionaryReader StorageFolderReader
+	dictionaries := make(map[uint32][]byte)
+
+	zstdcompression.SetDictionaryLoader(func(id uint32) ([]byte, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if dictionary, ok := dictionaries[id]; ok {
+			return dictionary, nil
+		}
+		if dictionaryReader == nil {
+			rootReader, err := getRootReader()
+			if err != nil {
+				return nil, err
+			}
+			dictionaryReader = rootReader.SubFolder(utility.WalDictionaryPath)
+		}
+		dictionary, err := ReadZstdDictionary(ctx, dictionaryReader, id)
+		if err != nil {
+			return nil, err
+		}
+		dictionaries[id] = dictionary
+		return dictionary, nil
+	})
+}
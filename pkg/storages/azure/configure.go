@@ -20,6 +20,8 @@ const (
 	BuffersSetting      = "AZURE_MAX_BUFFERS"
 	TryTimeoutSetting   = "AZURE_TRY_TIMEOUT"
 	BlobStoreAPIVersion = "AZURE_BLOB_STORE_API_VERSION"
+	RetentionPeriod     = "AZURE_RETENTION_PERIOD"
+	RetentionMode       = "AZURE_RETENTION_MODE"
 )
 
 // SettingList provides a list of GCS folder settings.
@@ -33,6 +35,8 @@ var SettingList = []string{
 	BuffersSetting,
 	TryTimeoutSetting,
 	BlobStoreAPIVersion,
+	RetentionPeriod,
+	RetentionMode,
 }
 
 const (
@@ -42,6 +46,8 @@ const (
 	defaultBuffers    = 4
 	defaultTryTimeout = 5
 	defaultEnvName    = "AzurePublicCloud"
+
+	defaultRetentionMode = "Unlocked"
 )
 
 // TODO: Unit tests
@@ -97,6 +103,18 @@ func ConfigureStorage(
 
 	blobStoreAPIVersion := settings[BlobStoreAPIVersion]
 
+	retentionPeriod, err := setting.IntOptional(settings, RetentionPeriod, 0)
+	if err != nil {
+		return nil, err
+	}
+	retentionMode := defaultRetentionMode
+	if mode, ok := settings[RetentionMode]; ok {
+		retentionMode = mode
+	}
+	if retentionMode != "Unlocked" && retentionMode != "Locked" {
+		return nil, fmt.Errorf("invalid %s %q: expected \"Unlocked\" or \"Locked\"", RetentionMode, retentionMode)
+	}
+
 	config := &Config{
 		Secrets: &Secrets{
 			AccessKey: accessKey,
@@ -113,6 +131,10 @@ func ConfigureStorage(
 			Buffers:    buffers,
 		},
 		BlobStoreAPIVersion: blobStoreAPIVersion,
+		Retention: &RetentionConfig{
+			Period: time.Second * time.Duration(retentionPeriod),
+			Mode:   retentionMode,
+		},
 	}
 
 	st, err := NewStorage(config, rootWraps...)
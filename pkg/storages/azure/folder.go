@@ -22,6 +22,7 @@ type Folder struct {
 	containerClient     *container.Client
 	uploadStreamOptions blockblob.UploadStreamOptions
 	timeout             time.Duration
+	retention           *RetentionConfig
 }
 
 func NewFolder(
@@ -29,6 +30,7 @@ func NewFolder(
 	containerClient *container.Client,
 	uploadStreamOptions azblob.UploadStreamOptions,
 	timeout time.Duration,
+	retention *RetentionConfig,
 ) *Folder {
 	// Trim leading slash because there's no difference between absolute and relative paths in Azure.
 	path = strings.TrimPrefix(path, "/")
@@ -37,6 +39,7 @@ func NewFolder(
 		containerClient,
 		uploadStreamOptions,
 		timeout,
+		retention,
 	}
 }
 
@@ -113,6 +116,7 @@ func (folder *Folder) ListFolder(ctx context.Context) ([]storage.Object, []stora
 				folder.containerClient,
 				folder.uploadStreamOptions,
 				folder.timeout,
+				folder.retention,
 			))
 		}
 	}
@@ -124,7 +128,8 @@ func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder
 		storage.AddDelimiterToPath(storage.JoinPath(folder.path, subFolderRelativePath)),
 		folder.containerClient,
 		folder.uploadStreamOptions,
-		folder.timeout)
+		folder.timeout,
+		folder.retention)
 }
 
 func (folder *Folder) ReadObject(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
@@ -151,6 +156,14 @@ func (folder *Folder) PutObject(ctx context.Context, name string, content io.Rea
 		return fmt.Errorf("upload blob %q: %w", path, err)
 	}
 
+	// Immutability policies can't be passed with the upload, so the blob is locked right after it.
+	if folder.IsRetentionEnabled() {
+		until := time.Now().Add(folder.retention.Period)
+		if err := folder.setImmutabilityPolicy(ctx, blobClient, until); err != nil {
+			return fmt.Errorf("set immutability policy of blob %q: %w", path, err)
+		}
+	}
+
 	tracelog.DebugLogger.Printf("Put %v done\n", name)
 	return nil
 }
@@ -0,0 +1,33 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.RangeReader = &Folder{}
+
+func (folder *Folder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	blobClient := folder.containerClient.NewBlockBlobClient(path)
+
+	get, err := blobClient.DownloadStream(ctx, &blob.DownloadStreamOptions{
+		Range: blob.HTTPRange{Offset: offset, Count: length},
+	})
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, storage.NewObjectNotFoundError(path)
+		}
+		return nil, fmt.Errorf("download range of blob %q: %w", path, err)
+	}
+	return get.Body, nil
+}
@@ -0,0 +1,65 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.RetentionFolder = &Folder{}
+
+// IsRetentionEnabled reports whether the uploaded blobs are locked, i.e. AZURE_RETENTION_PERIOD is set.
+func (folder *Folder) IsRetentionEnabled() bool {
+	return folder.retention != nil && folder.retention.Period > 0
+}
+
+// SetRetention sets the immutability policy of the blob in the configured AZURE_RETENTION_MODE. The container must
+// have version-level immutability support enabled.
+func (folder *Folder) SetRetention(ctx context.Context, objectRelativePath string, until time.Time) error {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	err := folder.setImmutabilityPolicy(ctx, folder.containerClient.NewBlockBlobClient(path), until)
+	if err != nil && bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return fmt.Errorf("set immutability policy of blob %q: %w", path, err)
+	}
+	return nil
+}
+
+func (folder *Folder) setImmutabilityPolicy(ctx context.Context, blobClient *blockblob.Client, until time.Time) error {
+	mode := blob.ImmutabilityPolicySetting(defaultRetentionMode)
+	if folder.retention != nil && folder.retention.Mode != "" {
+		mode = blob.ImmutabilityPolicySetting(folder.retention.Mode)
+	}
+	_, err := blobClient.SetImmutabilityPolicy(ctx, until, &blob.SetImmutabilityPolicyOptions{Mode: &mode})
+	return err
+}
+
+// GetRetention provides the expiry time of the blob immutability policy. Blobs under a legal hold are reported as
+// locked forever.
+func (folder *Folder) GetRetention(ctx context.Context, objectRelativePath string) (time.Time, error) {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	props, err := folder.containerClient.NewBlockBlobClient(path).GetProperties(ctx, nil)
+	if err != nil && bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return time.Time{}, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get Azure object stats %q: %w", path, err)
+	}
+	if props.LegalHold != nil && *props.LegalHold {
+		return maxRetentionTime, nil
+	}
+	if props.ImmutabilityPolicyExpiresOn == nil || !props.ImmutabilityPolicyExpiresOn.After(time.Now()) {
+		return time.Time{}, nil
+	}
+	return *props.ImmutabilityPolicyExpiresOn, nil
+}
+
+// maxRetentionTime stands for the unlimited retention of legal holds.
+var maxRetentionTime = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
@@ -30,6 +30,7 @@ type Config struct {
 	TryTimeout          time.Duration
 	Uploader            *UploaderConfig
 	BlobStoreAPIVersion string
+	Retention           *RetentionConfig
 }
 
 type Secrets struct {
@@ -42,6 +43,12 @@ type UploaderConfig struct {
 	Buffers    int
 }
 
+// RetentionConfig describes the immutability policy set on the uploaded blobs. Zero period disables it.
+type RetentionConfig struct {
+	Period time.Duration
+	Mode   string
+}
+
 type authType string
 
 const (
@@ -103,7 +110,7 @@ func NewStorage(config *Config, rootWraps ...storage.WrapRootFolder) (*Storage,
 		Concurrency: config.Uploader.Buffers,
 	}
 
-	var folder storage.Folder = NewFolder(config.RootPath, containerClient, uploadStreamOpts, config.TryTimeout)
+	var folder storage.Folder = NewFolder(config.RootPath, containerClient, uploadStreamOpts, config.TryTimeout, config.Retention)
 
 	for _, wrap := range rootWraps {
 		folder = wrap(folder)
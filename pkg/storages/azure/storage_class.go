@@ -0,0 +1,71 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.StorageClassFolder = &Folder{}
+
+// GetStorageClass provides the access tier of the blob.
+func (folder *Folder) GetStorageClass(ctx context.Context, objectRelativePath string) (string, error) {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	props, err := folder.containerClient.NewBlockBlobClient(path).GetProperties(ctx, nil)
+	if err != nil && bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return "", storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("get Azure object stats %q: %w", path, err)
+	}
+	if props.AccessTier == nil {
+		return "", nil
+	}
+	return *props.AccessTier, nil
+}
+
+// SetStorageClass sets the access tier of the blob.
+func (folder *Folder) SetStorageClass(ctx context.Context, objectRelativePath string, storageClass string) error {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	_, err := folder.containerClient.NewBlockBlobClient(path).SetTier(ctx, blob.AccessTier(storageClass), nil)
+	if err != nil && bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return fmt.Errorf("set access tier of blob %q to %s: %w", path, storageClass, err)
+	}
+	return nil
+}
+
+// RestoreObject rehydrates archived blobs to the Hot tier. Unlike S3, the rehydration is permanent, so the number of
+// days is ignored.
+func (folder *Folder) RestoreObject(ctx context.Context, objectRelativePath string, _ int) (bool, error) {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	blobClient := folder.containerClient.NewBlockBlobClient(path)
+	props, err := blobClient.GetProperties(ctx, nil)
+	if err != nil && bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return false, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return false, fmt.Errorf("get Azure object stats %q: %w", path, err)
+	}
+	if props.AccessTier == nil || blob.AccessTier(*props.AccessTier) != blob.AccessTierArchive {
+		return true, nil
+	}
+	if props.ArchiveStatus != nil {
+		// Rehydration is already in progress
+		return false, nil
+	}
+
+	tracelog.DebugLogger.Printf("Rehydrate blob %q to the %s tier", path, blob.AccessTierHot)
+	priority := blob.RehydratePriorityStandard
+	_, err = blobClient.SetTier(ctx, blob.AccessTierHot, &blob.SetTierOptions{RehydratePriority: &priority})
+	if err != nil {
+		return false, fmt.Errorf("rehydrate blob %q: %w", path, err)
+	}
+	return false, nil
+}
@@ -0,0 +1,47 @@
+package external
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/pkg/storages/storage/setting"
+)
+
+const (
+	commandSetting        = "EXTERNAL_COMMAND"
+	skipValidationSetting = "EXTERNAL_SKIP_VALIDATION"
+)
+
+var SettingList = []string{
+	commandSetting,
+	skipValidationSetting,
+}
+
+const defaultSkipValidation = false
+
+// ConfigureStorage configures a storage served by an external helper. The prefix is passed to the helper as is,
+// e.g. "pcloud:backups/wal-g" for rclone.
+func ConfigureStorage(
+	ctx context.Context,
+	prefix string,
+	settings map[string]string,
+	rootWraps ...storage.WrapRootFolder,
+) (storage.HashableStorage, error) {
+	skipValidation, err := setting.BoolOptional(settings, skipValidationSetting, defaultSkipValidation)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		Command:        settings[commandSetting],
+		Prefix:         prefix,
+		SkipValidation: skipValidation,
+	}
+
+	st, err := NewStorage(ctx, config, rootWraps...)
+	if err != nil {
+		return nil, fmt.Errorf("create external storage: %w", err)
+	}
+	return st, nil
+}
@@ -0,0 +1,170 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.Folder = &Folder{}
+
+// maxDeleteBatchSize limits the number of paths passed to a single "delete" operation to fit into the command line.
+const maxDeleteBatchSize = 100
+
+// Folder is a directory of the storage served by the external helper. The path is relative to the storage prefix
+// and is either empty or ends with "/".
+type Folder struct {
+	helper *Helper
+	prefix string
+	path   string
+}
+
+func NewFolder(helper *Helper, prefix, path string) *Folder {
+	return &Folder{
+		helper: helper,
+		prefix: prefix,
+		path:   storage.AddDelimiterToPath(strings.TrimPrefix(path, "/")),
+	}
+}
+
+// fullPath provides the path passed to the helper. The prefix is joined with a "/" unless it ends with ":", so
+// rclone remotes like "pcloud:" work as expected.
+func (folder *Folder) fullPath(relativePath string) string {
+	relativePath = strings.TrimPrefix(folder.path+strings.TrimPrefix(relativePath, "/"), "/")
+	prefix := strings.TrimSuffix(folder.prefix, "/")
+	if prefix == "" || strings.HasSuffix(prefix, ":") {
+		return prefix + relativePath
+	}
+	if relativePath == "" {
+		return prefix
+	}
+	return prefix + "/" + relativePath
+}
+
+func (folder *Folder) GetPath() string {
+	return folder.path
+}
+
+func (folder *Folder) ListFolder(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	entries, err := folder.helper.List(ctx, folder.fullPath(""))
+	if isNotFound(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("list external storage folder %q: %w", folder.path, err)
+	}
+
+	for _, entry := range entries {
+		if isTmpObject(entry.Name) {
+			continue
+		}
+		if entry.IsDir {
+			subFolders = append(subFolders, NewFolder(folder.helper, folder.prefix, folder.path+entry.Name))
+			continue
+		}
+		objects = append(objects, storage.NewLocalObject(entry.Name, entry.ModTime, entry.Size))
+	}
+	return objects, subFolders, nil
+}
+
+func (folder *Folder) DeleteObjects(ctx context.Context, objectsWithRelativePaths []storage.Object) error {
+	for start := 0; start < len(objectsWithRelativePaths); start += maxDeleteBatchSize {
+		batch := objectsWithRelativePaths[start:min(start+maxDeleteBatchSize, len(objectsWithRelativePaths))]
+		paths := make([]string, 0, len(batch))
+		for _, object := range batch {
+			path := folder.fullPath(object.GetName())
+			tracelog.DebugLogger.Printf("Delete object %v\n", path)
+			paths = append(paths, path)
+		}
+		err := folder.helper.Delete(ctx, paths...)
+		if err != nil {
+			return fmt.Errorf("delete external storage objects: %w", err)
+		}
+	}
+	return nil
+}
+
+func (folder *Folder) Exists(ctx context.Context, objectRelativePath string) (bool, error) {
+	_, err := folder.StatObject(ctx, objectRelativePath)
+	if _, ok := err.(storage.ObjectNotFoundError); ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (storage.Object, error) {
+	path := folder.fullPath(objectRelativePath)
+	entry, err := folder.helper.Stat(ctx, path)
+	if isNotFound(err) {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stat external storage object %q: %w", path, err)
+	}
+	if entry.IsDir {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	return storage.NewLocalObject(objectRelativePath, entry.ModTime, entry.Size), nil
+}
+
+func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return NewFolder(folder.helper, folder.prefix, storage.JoinPath(folder.path, subFolderRelativePath))
+}
+
+func (folder *Folder) ReadObject(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	path := folder.fullPath(objectRelativePath)
+	reader, err := folder.helper.Get(ctx, path)
+	if isNotFound(err) {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read external storage object %q: %w", path, err)
+	}
+	return reader, nil
+}
+
+func (folder *Folder) PutObject(ctx context.Context, name string, content io.Reader) error {
+	path := folder.fullPath(name)
+	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.path)
+	err := folder.helper.Put(ctx, path, content)
+	if err != nil {
+		return fmt.Errorf("put external storage object %q: %w", path, err)
+	}
+	return nil
+}
+
+func (folder *Folder) CopyObject(ctx context.Context, srcPath string, dstPath string) error {
+	exists, err := folder.Exists(ctx, srcPath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.NewObjectNotFoundError(folder.fullPath(srcPath))
+	}
+	err = folder.helper.Copy(ctx, folder.fullPath(srcPath), folder.fullPath(dstPath))
+	if err != nil {
+		return fmt.Errorf("copy external storage object %q to %q: %w", srcPath, dstPath, err)
+	}
+	return nil
+}
+
+// Validate checks that the helper works by listing the root folder.
+func (folder *Folder) Validate(ctx context.Context) error {
+	_, _, err := folder.ListFolder(ctx)
+	return err
+}
+
+// NOT IMPLEMENTED
+func (folder *Folder) SetVersioningEnabled(_ context.Context, enable bool) {}
+
+// NOT IMPLEMENTED
+func (folder *Folder) GetVersioningEnabled(_ context.Context) bool {
+	return false
+}
@@ -0,0 +1,210 @@
+package external
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const fakeHelperEnv = "WALG_TEST_EXTERNAL_HELPER"
+
+// TestMain makes the test binary act as a fake helper that serves a local directory, when run by the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeHelperEnv) == "1" {
+		os.Exit(runFakeHelper(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+func runFakeHelper(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "not enough arguments")
+		return 1
+	}
+	op, paths := args[0], args[1:]
+	var err error
+	switch op {
+	case opList:
+		err = fakeList(paths[0])
+	case opStat:
+		err = fakeStat(paths[0])
+	case opGet:
+		err = fakeGet(paths[0])
+	case opPut:
+		err = fakePut(paths[0], os.Stdin)
+	case opCopy:
+		var src *os.File
+		src, err = os.Open(paths[0])
+		if err == nil {
+			defer src.Close()
+			err = fakePut(paths[1], src)
+		}
+	case opMove:
+		err = os.Rename(paths[0], paths[1])
+	case opDelete:
+		for _, path := range paths {
+			if removeErr := os.Remove(path); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+				err = removeErr
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown operation %q", op)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintln(os.Stderr, err)
+		return exitCodeObjectNotFound
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func toEntry(info fs.FileInfo) Entry {
+	return Entry{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime(), IsDir: info.IsDir()}
+}
+
+func fakeList(path string) error {
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	entries := make([]Entry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, toEntry(info))
+	}
+	return json.NewEncoder(os.Stdout).Encode(entries)
+}
+
+func fakeStat(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(toEntry(info))
+}
+
+func fakeGet(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(os.Stdout, file)
+	return err
+}
+
+func fakePut(path string, content io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func newTestStorage(t *testing.T, command string) (*Storage, string) {
+	t.Setenv(fakeHelperEnv, "1")
+	root := t.TempDir()
+	if command == "" {
+		command = "'" + os.Args[0] + "'"
+	}
+	st, err := NewStorage(t.Context(), &Config{Command: command, Prefix: root})
+	require.NoError(t, err)
+	return st, root
+}
+
+func TestExternalFolder(t *testing.T) {
+	st, _ := newTestStorage(t, "")
+	storage.RunFolderTest(st.RootFolder(), t)
+}
+
+func TestExternalFolder_MissingObjects(t *testing.T) {
+	st, _ := newTestStorage(t, "")
+	folder := st.RootFolder()
+
+	_, err := folder.ReadObject(t.Context(), "missing")
+	assert.IsType(t, storage.ObjectNotFoundError{}, err)
+	_, err = folder.StatObject(t.Context(), "missing")
+	assert.IsType(t, storage.ObjectNotFoundError{}, err)
+	err = folder.CopyObject(t.Context(), "missing", "copy")
+	assert.IsType(t, storage.ObjectNotFoundError{}, err)
+
+	objects, subFolders, err := folder.GetSubFolder("missing").ListFolder(t.Context())
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+	assert.Empty(t, subFolders)
+}
+
+func TestExternalFolder_EmptyObject(t *testing.T) {
+	st, root := newTestStorage(t, "")
+	require.NoError(t, os.WriteFile(filepath.Join(root, "empty"), nil, 0644))
+
+	reader, err := st.RootFolder().ReadObject(t.Context(), "empty")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+	assert.NoError(t, reader.Close())
+}
+
+func TestExternalFolder_HelperErrors(t *testing.T) {
+	_, err := NewStorage(t.Context(), &Config{Command: "echo oops >&2; exit 1 #", Prefix: t.TempDir()})
+	var helperErr *HelperError
+	require.ErrorAs(t, err, &helperErr)
+	assert.Equal(t, 1, helperErr.ExitCode)
+	assert.Equal(t, "oops", strings.TrimSpace(helperErr.Stderr))
+}
+
+func TestExternalFolder_FailedPut(t *testing.T) {
+	st, root := newTestStorage(t, "")
+	folder := st.RootFolder()
+
+	readErr := errors.New("read failed")
+	content := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(readErr))
+	err := folder.PutObject(t.Context(), "dir/object", content)
+	assert.ErrorIs(t, err, readErr)
+
+	exists, err := folder.Exists(t.Context(), "dir/object")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	files, err := os.ReadDir(filepath.Join(root, "dir"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestFullPath(t *testing.T) {
+	helper := NewHelper("true")
+	assert.Equal(t, "pcloud:wal_005/file",
+		NewFolder(helper, "pcloud:", "").GetSubFolder("wal_005").(*Folder).fullPath("file"))
+	assert.Equal(t, "pcloud:backups/wal_005/file",
+		NewFolder(helper, "pcloud:backups/", "wal_005").fullPath("/file"))
+	assert.Equal(t, "/var/backups", NewFolder(helper, "/var/backups", "").fullPath(""))
+}
@@ -0,0 +1,305 @@
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/wal-g/tracelog"
+)
+
+// The helper protocol. WAL-G runs the configured command once per operation, appending the operation name and its
+// arguments. Paths are the storage prefix joined with the object path, e.g. "pcloud:backups/wal_005/000000010000000000000001.lz4".
+//
+//	list <path>              print the entries of the directory as a JSON array of {"Name", "Size", "ModTime", "IsDir"}
+//	stat <path>              print a single JSON entry of the object
+//	get <path>               write the object content to stdout
+//	put <path>               store the content read from stdin as the object, creating the parent directories
+//	copy <src path> <dst>    copy the object
+//	move <src path> <dst>    rename the object, replacing the destination
+//	delete <path> [<path>]   delete the objects, it's not an error if some of them don't exist
+//
+// The exit code must be 0 on success, and 3 or 4 if the requested object or directory is not found. Any other exit
+// code means an error, which is reported together with the helper's stderr. The entries format and exit codes match
+// the output of "rclone lsjson" and the exit codes of rclone, so rclone can be used with a trivial wrapper script.
+const (
+	opList   = "list"
+	opStat   = "stat"
+	opGet    = "get"
+	opPut    = "put"
+	opCopy   = "copy"
+	opMove   = "move"
+	opDelete = "delete"
+
+	exitCodeDirectoryNotFound = 3
+	exitCodeObjectNotFound    = 4
+
+	// maxStderrLength limits the part of the helper's stderr included into errors.
+	maxStderrLength = 4096
+
+	// shell runs the helper command. It's not taken from $SHELL, which may be a non-POSIX one, like fish.
+	shell = "/bin/sh"
+
+	// tmpObjectSuffix marks the objects being put, which are renamed once the content is stored completely.
+	tmpObjectSuffix = ".walg-tmp"
+)
+
+// Entry is the description of an object or a directory printed by the helper.
+type Entry struct {
+	Name    string    `json:"Name"`
+	Size    int64     `json:"Size"`
+	ModTime time.Time `json:"ModTime"`
+	IsDir   bool      `json:"IsDir"`
+}
+
+// HelperError is returned when the helper exits with an unexpected code.
+type HelperError struct {
+	Operation string
+	ExitCode  int
+	Stderr    string
+}
+
+func (err *HelperError) Error() string {
+	return fmt.Sprintf("external storage helper %q operation exited with code %d: %s",
+		err.Operation, err.ExitCode, strings.TrimSpace(err.Stderr))
+}
+
+func (err *HelperError) isNotFound() bool {
+	return err.ExitCode == exitCodeDirectoryNotFound || err.ExitCode == exitCodeObjectNotFound
+}
+
+func isNotFound(err error) bool {
+	var helperErr *HelperError
+	return errors.As(err, &helperErr) && helperErr.isNotFound()
+}
+
+// Helper runs the external program implementing the storage operations.
+type Helper struct {
+	command string
+}
+
+func NewHelper(command string) *Helper {
+	return &Helper{command: command}
+}
+
+// newCmd runs the command through the shell, so it can contain arguments and quotes, while the operation arguments
+// are passed as positional parameters and need no escaping.
+func (helper *Helper) newCmd(ctx context.Context, op string, args ...string) *exec.Cmd {
+	shellArgs := append([]string{"-c", helper.command + ` "$@"`, "wal-g-external", op}, args...)
+	return exec.CommandContext(ctx, shell, shellArgs...)
+}
+
+func (helper *Helper) run(ctx context.Context, stdin io.Reader, op string, args ...string) ([]byte, error) {
+	cmd := helper.newCmd(ctx, op, args...)
+	stdout := &bytes.Buffer{}
+	stderr := &limitedBuffer{limit: maxStderrLength}
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, newHelperError(op, err, stderr)
+	}
+	return stdout.Bytes(), nil
+}
+
+func newHelperError(op string, err error, stderr *limitedBuffer) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &HelperError{Operation: op, ExitCode: exitErr.ExitCode(), Stderr: stderr.String()}
+	}
+	return fmt.Errorf("run external storage helper %q operation: %w", op, err)
+}
+
+func (helper *Helper) List(ctx context.Context, path string) ([]Entry, error) {
+	output, err := helper.run(ctx, nil, opList, path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	err = json.Unmarshal(output, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("parse the output of external storage helper %q operation: %w", opList, err)
+	}
+	return entries, nil
+}
+
+func (helper *Helper) Stat(ctx context.Context, path string) (*Entry, error) {
+	output, err := helper.run(ctx, nil, opStat, path)
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{}
+	err = json.Unmarshal(output, entry)
+	if err != nil {
+		return nil, fmt.Errorf("parse the output of external storage helper %q operation: %w", opStat, err)
+	}
+	return entry, nil
+}
+
+// Get starts streaming the object. Not found errors are reported right away, and other errors of the helper are
+// reported when the stream ends.
+func (helper *Helper) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := helper.newCmd(ctx, opGet, path)
+	stderr := &limitedBuffer{limit: maxStderrLength}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("run external storage helper %q operation: %w", opGet, err)
+	}
+
+	reader := &helperReader{
+		Reader: bufio.NewReader(stdout),
+		cmd:    cmd,
+		stderr: stderr,
+		cancel: cancel,
+	}
+	// The helper doesn't print anything if the object doesn't exist, so wait for the first byte to tell missing
+	// objects from empty ones.
+	_, err = reader.Reader.Peek(1)
+	if err == io.EOF {
+		err = reader.wait()
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Put stores the content under a temporary name first, and renames it only after both the helper and the content
+// reader have succeeded, so a failed upload never leaves a truncated object under the requested name.
+func (helper *Helper) Put(ctx context.Context, path string, content io.Reader) error {
+	tmpPath, err := tmpObjectPath(path)
+	if err != nil {
+		return err
+	}
+	contentReader := &errorTrackingReader{Reader: content}
+	_, err = helper.run(ctx, contentReader, opPut, tmpPath)
+	if err == nil && contentReader.err != nil {
+		err = fmt.Errorf("read the content to put: %w", contentReader.err)
+	}
+	if err == nil {
+		_, err = helper.run(ctx, nil, opMove, tmpPath, path)
+	}
+	if err != nil {
+		if _, deleteErr := helper.run(context.WithoutCancel(ctx), nil, opDelete, tmpPath); deleteErr != nil {
+			tracelog.WarningLogger.Printf("Failed to delete temporary external storage object %q: %v", tmpPath, deleteErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func tmpObjectPath(path string) (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("generate temporary object name: %w", err)
+	}
+	return fmt.Sprintf("%s.%x%s", path, random, tmpObjectSuffix), nil
+}
+
+func isTmpObject(name string) bool {
+	return strings.HasSuffix(name, tmpObjectSuffix)
+}
+
+func (helper *Helper) Copy(ctx context.Context, srcPath, dstPath string) error {
+	_, err := helper.run(ctx, nil, opCopy, srcPath, dstPath)
+	return err
+}
+
+func (helper *Helper) Delete(ctx context.Context, paths ...string) error {
+	_, err := helper.run(ctx, nil, opDelete, paths...)
+	return err
+}
+
+// helperReader streams the stdout of the "get" operation and checks the exit code of the helper at the end.
+type helperReader struct {
+	*bufio.Reader
+	cmd    *exec.Cmd
+	stderr *limitedBuffer
+	cancel context.CancelFunc
+	waited bool
+}
+
+func (reader *helperReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	if err == io.EOF {
+		waitErr := reader.wait()
+		if waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (reader *helperReader) wait() error {
+	if reader.waited {
+		return nil
+	}
+	reader.waited = true
+	defer reader.cancel()
+	err := reader.cmd.Wait()
+	if err != nil {
+		return newHelperError(opGet, err, reader.stderr)
+	}
+	return nil
+}
+
+// Close stops the helper if the object hasn't been read till the end.
+func (reader *helperReader) Close() error {
+	if reader.waited {
+		return nil
+	}
+	reader.cancel()
+	reader.waited = true
+	_ = reader.cmd.Wait()
+	return nil
+}
+
+// errorTrackingReader remembers the error of the content reader, because the helper just sees the end of stdin if
+// the content can't be read.
+type errorTrackingReader struct {
+	io.Reader
+	err error
+}
+
+func (reader *errorTrackingReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		reader.err = err
+	}
+	return n, err
+}
+
+// limitedBuffer keeps only the beginning of the written data.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (buffer *limitedBuffer) Write(p []byte) (int, error) {
+	if free := buffer.limit - buffer.Len(); free > 0 {
+		buffer.Buffer.Write(p[:min(len(p), free)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,61 @@
+package external
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.HashableStorage = &Storage{}
+
+// Storage delegates all operations to an external helper program, so storages that WAL-G doesn't support natively
+// can be used without compiling them into WAL-G. See the protocol description in helper.go.
+type Storage struct {
+	rootFolder storage.Folder
+	hash       string
+}
+
+type Config struct {
+	Command        string
+	Prefix         string
+	SkipValidation bool
+}
+
+func NewStorage(ctx context.Context, config *Config, rootWraps ...storage.WrapRootFolder) (*Storage, error) {
+	if config.Command == "" {
+		return nil, fmt.Errorf("the helper command is not configured")
+	}
+
+	folder := NewFolder(NewHelper(config.Command), config.Prefix, "")
+	if !config.SkipValidation {
+		err := folder.Validate(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("validate external storage root folder: %w", err)
+		}
+	}
+
+	var rootFolder storage.Folder = folder
+	for _, wrap := range rootWraps {
+		rootFolder = wrap(rootFolder)
+	}
+
+	hash, err := storage.ComputeConfigHash("external", config)
+	if err != nil {
+		return nil, fmt.Errorf("compute config hash: %w", err)
+	}
+
+	return &Storage{rootFolder, hash}, nil
+}
+
+func (s *Storage) RootFolder() storage.Folder {
+	return s.rootFolder
+}
+
+func (s *Storage) ConfigHash() string {
+	return s.hash
+}
+
+func (s *Storage) Close() error {
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
@@ -18,6 +19,10 @@ import (
 
 const dirDefaultMode = 0755
 
+// retentionDirName is the hidden directory in the storage root that emulates object locks. It mirrors the storage
+// layout and keeps the time until which each locked object is retained.
+const retentionDirName = ".walg_retention"
+
 // Folder represents folder on the file system
 // TODO: Unit tests
 type Folder struct {
@@ -43,6 +48,9 @@ func (folder *Folder) ListFolder(_ context.Context) (objects []storage.Object, s
 	}
 	for _, fileInfo := range files {
 		if fileInfo.IsDir() {
+			if folder.subPath == "" && fileInfo.Name() == retentionDirName {
+				continue
+			}
 			// I do not use GetSubfolder() intentially
 			subPath := path.Join(folder.subPath, fileInfo.Name()) + "/"
 			subFolders = append(subFolders, NewFolder(folder.rootPath, subPath))
@@ -63,7 +71,14 @@ func (folder *Folder) DeleteObjects(_ context.Context, objectsWithRelativePaths
 	baseDir := path.Join(folder.rootPath, folder.subPath)
 	for _, object := range objectsWithRelativePaths {
 		filePath := folder.GetFilePath(object.GetName())
-		err := os.RemoveAll(filePath)
+		until, err := folder.readRetention(object.GetName())
+		if err != nil {
+			return err
+		}
+		if until.After(time.Now()) {
+			return storage.NewObjectLockedError(filePath, until)
+		}
+		err = os.RemoveAll(filePath)
 		if os.IsNotExist(err) {
 			continue
 		}
@@ -71,6 +86,12 @@ func (folder *Folder) DeleteObjects(_ context.Context, objectsWithRelativePaths
 			return fmt.Errorf("unable to delete file %q: %w", object.GetName(), err)
 		}
 		removeEmptyParentDirs(path.Dir(filePath), baseDir)
+		if !until.IsZero() {
+			retentionPath := folder.getRetentionFilePath(object.GetName())
+			if err := os.Remove(retentionPath); err == nil {
+				removeEmptyParentDirs(path.Dir(retentionPath), path.Join(folder.rootPath, retentionDirName))
+			}
+		}
 	}
 	return nil
 }
@@ -142,6 +163,13 @@ func (folder *Folder) PutObject(ctx context.Context, name string, content io.Rea
 	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.subPath)
 	content = contextio.NewReader(ctx, content)
 	filePath := folder.GetFilePath(name)
+	until, err := folder.readRetention(name)
+	if err != nil {
+		return err
+	}
+	if until.After(time.Now()) {
+		return storage.NewObjectLockedError(filePath, until)
+	}
 	randomSuffix, err := storage.NewTimestampRandomTag()
 	if err != nil {
 		return fmt.Errorf("failed to generate random postfix: %w", err)
@@ -243,6 +271,71 @@ func (folder *Folder) EnsureExists() error {
 	return nil
 }
 
+// IsRetentionEnabled reports that the retention is always emulated by the FS storage.
+func (folder *Folder) IsRetentionEnabled() bool {
+	return true
+}
+
+func (folder *Folder) SetRetention(_ context.Context, objectRelativePath string, until time.Time) error {
+	filePath := folder.GetFilePath(objectRelativePath)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return storage.NewObjectNotFoundError(filePath)
+	}
+	current, err := folder.readRetention(objectRelativePath)
+	if err != nil {
+		return err
+	}
+	if current.After(time.Now()) && current.After(until) {
+		return fmt.Errorf("unable to shorten the retention of %q locked until %s", filePath, current.Format(time.RFC3339))
+	}
+	retentionPath := folder.getRetentionFilePath(objectRelativePath)
+	file, err := OpenFileWithDir(retentionPath)
+	if err != nil {
+		return fmt.Errorf("unable to open retention file %q: %w", retentionPath, err)
+	}
+	_, err = file.WriteString(until.UTC().Format(time.RFC3339Nano))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write retention file %q: %w", retentionPath, err)
+	}
+	return nil
+}
+
+func (folder *Folder) GetRetention(_ context.Context, objectRelativePath string) (time.Time, error) {
+	filePath := folder.GetFilePath(objectRelativePath)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return time.Time{}, storage.NewObjectNotFoundError(filePath)
+	}
+	until, err := folder.readRetention(objectRelativePath)
+	if err != nil || !until.After(time.Now()) {
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+// readRetention provides the time until which the object was locked, or the zero time if it was never locked.
+func (folder *Folder) readRetention(objectRelativePath string) (time.Time, error) {
+	retentionPath := folder.getRetentionFilePath(objectRelativePath)
+	data, err := os.ReadFile(retentionPath)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to read retention file %q: %w", retentionPath, err)
+	}
+	until, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse retention file %q: %w", retentionPath, err)
+	}
+	return until, nil
+}
+
+func (folder *Folder) getRetentionFilePath(objectRelativePath string) string {
+	return path.Join(folder.rootPath, retentionDirName, folder.subPath, objectRelativePath)
+}
+
 func (folder *Folder) Validate(ctx context.Context) error {
 	return nil
 }
@@ -109,3 +109,10 @@ func setupTmpDir(t *testing.T) string {
 	}
 	return tmpDir
 }
+
+func TestFSFolderRetention(t *testing.T) {
+	st, err := ConfigureStorage(t.Context(), t.TempDir(), nil)
+	assert.NoError(t, err)
+
+	storage.RunRetentionFolderTest(st.RootFolder(), t)
+}
@@ -16,6 +16,8 @@ const (
 	encryptionKeySetting   = "GCS_ENCRYPTION_KEY"
 	maxChunkSizeSetting    = "GCS_MAX_CHUNK_SIZE"
 	maxRetriesSetting      = "GCS_MAX_RETRIES"
+	retentionPeriodSetting = "GCS_RETENTION_PERIOD"
+	retentionModeSetting   = "GCS_RETENTION_MODE"
 )
 
 // SettingList provides a list of GCS folder settings.
@@ -25,6 +27,8 @@ var SettingList = []string{
 	encryptionKeySetting,
 	maxChunkSizeSetting,
 	maxRetriesSetting,
+	retentionPeriodSetting,
+	retentionModeSetting,
 }
 
 const (
@@ -38,6 +42,9 @@ const (
 	// defaultMaxRetries limits upload and download retries during interaction with GCS.
 	defaultMaxRetries = 16
 
+	// defaultRetentionMode allows to extend the retention of uploaded objects and to remove it with an override.
+	defaultRetentionMode = "Unlocked"
+
 	encryptionKeySize = 32
 )
 
@@ -95,6 +102,18 @@ func ConfigureStorage(
 		return nil, err
 	}
 
+	retentionPeriod, err := setting.IntOptional(settings, retentionPeriodSetting, 0)
+	if err != nil {
+		return nil, err
+	}
+	retentionMode := defaultRetentionMode
+	if mode, ok := settings[retentionModeSetting]; ok {
+		retentionMode = mode
+	}
+	if retentionMode != "Unlocked" && retentionMode != "Locked" {
+		return nil, fmt.Errorf("invalid %s %q: expected \"Unlocked\" or \"Locked\"", retentionModeSetting, retentionMode)
+	}
+
 	config := &Config{
 		Secrets: &Secrets{
 			EncryptionKey: encryptionKey,
@@ -107,6 +126,10 @@ func ConfigureStorage(
 			MaxChunkSize: maxChunkSize,
 			MaxRetries:   maxRetries,
 		},
+		Retention: &RetentionConfig{
+			Period: time.Second * time.Duration(retentionPeriod),
+			Mode:   retentionMode,
+		},
 	}
 
 	st, err := NewStorage(ctx, config, rootWraps...)
@@ -7,6 +7,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	gcs "cloud.google.com/go/storage"
 	"github.com/wal-g/tracelog"
@@ -226,6 +227,13 @@ func (folder *Folder) PutObject(ctx context.Context, name string, content io.Rea
 		return fmt.Errorf("compose GCS temporary chunks into an object: %w", err)
 	}
 
+	if folder.IsRetentionEnabled() {
+		until := time.Now().Add(folder.config.Retention.Period)
+		if err := folder.updateRetention(ctx, object, until); err != nil {
+			return fmt.Errorf("set retention of GCS object %q: %w", objectPath, err)
+		}
+	}
+
 	tracelog.DebugLogger.Printf("Put %v done\n", name)
 
 	return nil
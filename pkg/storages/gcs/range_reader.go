@@ -0,0 +1,28 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.RangeReader = &Folder{}
+
+func (folder *Folder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	objPath := folder.joinPath(folder.path, objectRelativePath)
+	reader, err := folder.BuildObjectHandle(objPath).NewRangeReader(ctx, offset, length)
+	if err == gcs.ErrObjectNotExist {
+		return nil, storage.NewObjectNotFoundError(objPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read range of GCS object %q: %w", objPath, err)
+	}
+	return reader, nil
+}
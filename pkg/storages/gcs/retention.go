@@ -0,0 +1,66 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.RetentionFolder = &Folder{}
+
+// IsRetentionEnabled reports whether the uploaded objects are locked, i.e. GCS_RETENTION_PERIOD is set.
+func (folder *Folder) IsRetentionEnabled() bool {
+	return folder.config.Retention != nil && folder.config.Retention.Period > 0
+}
+
+// SetRetention sets the object retention in the configured GCS_RETENTION_MODE. The bucket must have object
+// retention enabled.
+func (folder *Folder) SetRetention(ctx context.Context, objectRelativePath string, until time.Time) error {
+	objPath := folder.joinPath(folder.path, objectRelativePath)
+	ctx, cancel := folder.createTimeoutContext(ctx)
+	defer cancel()
+	err := folder.updateRetention(ctx, folder.BuildObjectHandle(objPath), until)
+	if err == gcs.ErrObjectNotExist {
+		return storage.NewObjectNotFoundError(objPath)
+	}
+	if err != nil {
+		return fmt.Errorf("set retention of GCS object %q: %w", objPath, err)
+	}
+	return nil
+}
+
+func (folder *Folder) updateRetention(ctx context.Context, object *gcs.ObjectHandle, until time.Time) error {
+	mode := defaultRetentionMode
+	if folder.config.Retention != nil && folder.config.Retention.Mode != "" {
+		mode = folder.config.Retention.Mode
+	}
+	_, err := object.Update(ctx, gcs.ObjectAttrsToUpdate{
+		Retention: &gcs.ObjectRetention{Mode: mode, RetainUntil: until},
+	})
+	return err
+}
+
+// GetRetention takes into account both the object retention and the retention policy of the bucket.
+func (folder *Folder) GetRetention(ctx context.Context, objectRelativePath string) (time.Time, error) {
+	objPath := folder.joinPath(folder.path, objectRelativePath)
+	ctx, cancel := folder.createTimeoutContext(ctx)
+	defer cancel()
+	attrs, err := folder.BuildObjectHandle(objPath).Attrs(ctx)
+	if err == gcs.ErrObjectNotExist {
+		return time.Time{}, storage.NewObjectNotFoundError(objPath)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get GCS object stats %q: %w", objPath, err)
+	}
+	until := attrs.RetentionExpirationTime
+	if attrs.Retention != nil && attrs.Retention.RetainUntil.After(until) {
+		until = attrs.Retention.RetainUntil
+	}
+	if !until.After(time.Now()) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
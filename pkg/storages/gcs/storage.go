@@ -24,6 +24,7 @@ type Config struct {
 	NormalizePrefix bool
 	ContextTimeout  time.Duration
 	Uploader        *UploaderConfig
+	Retention       *RetentionConfig
 }
 
 type Secrets struct {
@@ -35,6 +36,12 @@ type UploaderConfig struct {
 	MaxRetries   int
 }
 
+// RetentionConfig describes the object retention set on the uploaded objects. Zero period disables it.
+type RetentionConfig struct {
+	Period time.Duration
+	Mode   string
+}
+
 // TODO: unit tests
 func NewStorage(ctx context.Context, config *Config, rootWraps ...storage.WrapRootFolder) (*Storage, error) {
 	client, err := gcs.NewClient(ctx)
@@ -0,0 +1,56 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.StorageClassFolder = &Folder{}
+
+func (folder *Folder) GetStorageClass(ctx context.Context, objectRelativePath string) (string, error) {
+	objPath := folder.joinPath(folder.path, objectRelativePath)
+	ctx, cancel := folder.createTimeoutContext(ctx)
+	defer cancel()
+	attrs, err := folder.BuildObjectHandle(objPath).Attrs(ctx)
+	if err == gcs.ErrObjectNotExist {
+		return "", storage.NewObjectNotFoundError(objPath)
+	}
+	if err != nil {
+		return "", fmt.Errorf("get GCS object stats %q: %w", objPath, err)
+	}
+	return attrs.StorageClass, nil
+}
+
+// SetStorageClass rewrites the object in place with the new storage class.
+func (folder *Folder) SetStorageClass(ctx context.Context, objectRelativePath string, storageClass string) error {
+	objPath := folder.joinPath(folder.path, objectRelativePath)
+	object := folder.BuildObjectHandle(objPath)
+	ctx, cancel := folder.createTimeoutContext(ctx)
+	defer cancel()
+	copier := object.CopierFrom(object)
+	copier.StorageClass = storageClass
+	_, err := copier.Run(ctx)
+	if err == gcs.ErrObjectNotExist {
+		return storage.NewObjectNotFoundError(objPath)
+	}
+	if err != nil {
+		return fmt.Errorf("change storage class of GCS object %q to %s: %w", objPath, storageClass, err)
+	}
+	return nil
+}
+
+// RestoreObject only checks that the object exists, since objects of all GCS storage classes, including ARCHIVE,
+// are readable right away.
+func (folder *Folder) RestoreObject(ctx context.Context, objectRelativePath string, _ int) (bool, error) {
+	exists, err := folder.Exists(ctx, objectRelativePath)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, storage.NewObjectNotFoundError(folder.joinPath(folder.path, objectRelativePath))
+	}
+	return true, nil
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/wal-g/wal-g/internal/contextio"
@@ -68,7 +69,11 @@ func (folder *Folder) ListFolder(_ context.Context) (objects []storage.Object, s
 
 func (folder *Folder) DeleteObjects(_ context.Context, objectsWithRelativePath []storage.Object) error {
 	for _, object := range objectsWithRelativePath {
-		folder.KVS.Delete(storage.JoinPath(folder.path, object.GetName()))
+		objectPath := storage.JoinPath(folder.path, object.GetName())
+		if until := folder.KVS.GetRetention(objectPath); !until.IsZero() {
+			return storage.NewObjectLockedError(objectPath, until)
+		}
+		folder.KVS.Delete(objectPath)
 	}
 	return nil
 }
@@ -86,12 +91,31 @@ func (folder *Folder) ReadObject(_ context.Context, objectRelativePath string) (
 	return io.NopCloser(&object.Data), nil
 }
 
+func (folder *Folder) ReadObjectRange(
+	_ context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	object, exists := folder.KVS.Load(objectAbsPath)
+	if !exists {
+		return nil, storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	data := object.Data.Bytes()
+	offset = min(offset, int64(len(data)))
+	end := min(offset+length, int64(len(data)))
+	return io.NopCloser(bytes.NewReader(data[offset:end])), nil
+}
+
 func (folder *Folder) PutObject(ctx context.Context, name string, content io.Reader) error {
 	data, err := io.ReadAll(contextio.NewReader(ctx, content))
 	objectPath := path.Join(folder.path, name)
 	if err != nil {
 		return errors.Wrapf(err, "failed to put '%s' in memory storage", objectPath)
 	}
+	if until := folder.KVS.GetRetention(objectPath); !until.IsZero() {
+		return storage.NewObjectLockedError(objectPath, until)
+	}
 	folder.KVS.Store(objectPath, *bytes.NewBuffer(data))
 	return nil
 }
@@ -114,6 +138,80 @@ func (folder *Folder) CopyObject(ctx context.Context, srcPath string, dstPath st
 	return nil
 }
 
+// IsRetentionEnabled reports that the retention is always emulated by the memory storage.
+func (folder *Folder) IsRetentionEnabled() bool {
+	return true
+}
+
+func (folder *Folder) SetRetention(_ context.Context, objectRelativePath string, until time.Time) error {
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	if _, exists := folder.KVS.Load(objectAbsPath); !exists {
+		return storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	if current := folder.KVS.GetRetention(objectAbsPath); current.After(until) {
+		return errors.Errorf("can't shorten the retention of '%s' locked until %s", objectAbsPath, current.Format(time.RFC3339))
+	}
+	folder.KVS.SetRetention(objectAbsPath, until)
+	return nil
+}
+
+func (folder *Folder) GetRetention(_ context.Context, objectRelativePath string) (time.Time, error) {
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	if _, exists := folder.KVS.Load(objectAbsPath); !exists {
+		return time.Time{}, storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	return folder.KVS.GetRetention(objectAbsPath), nil
+}
+
+// DefaultStorageClass is the storage class of objects which storage class has never been changed.
+const DefaultStorageClass = "STANDARD"
+
+// archiveStorageClasses require restoring objects before reading, like S3 Glacier does. The restore requested by
+// RestoreObject completes at the next call.
+var archiveStorageClasses = map[string]bool{
+	"GLACIER":      true,
+	"DEEP_ARCHIVE": true,
+}
+
+func (folder *Folder) GetStorageClass(_ context.Context, objectRelativePath string) (string, error) {
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	if _, exists := folder.KVS.Load(objectAbsPath); !exists {
+		return "", storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	return folder.loadStorageClass(objectAbsPath).storageClass, nil
+}
+
+func (folder *Folder) SetStorageClass(_ context.Context, objectRelativePath string, storageClass string) error {
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	if _, exists := folder.KVS.Load(objectAbsPath); !exists {
+		return storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	folder.KVS.storageClasses.Store(objectAbsPath, storageClassState{storageClass: storageClass})
+	return nil
+}
+
+func (folder *Folder) RestoreObject(_ context.Context, objectRelativePath string, _ int) (bool, error) {
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	if _, exists := folder.KVS.Load(objectAbsPath); !exists {
+		return false, storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	state := folder.loadStorageClass(objectAbsPath)
+	if !archiveStorageClasses[state.storageClass] || state.restoreRequested {
+		return true, nil
+	}
+	state.restoreRequested = true
+	folder.KVS.storageClasses.Store(objectAbsPath, state)
+	return false, nil
+}
+
+func (folder *Folder) loadStorageClass(objectAbsPath string) storageClassState {
+	state, ok := folder.KVS.storageClasses.Load(objectAbsPath)
+	if !ok {
+		return storageClassState{storageClass: DefaultStorageClass}
+	}
+	return state.(storageClassState)
+}
+
 func (folder *Folder) Validate(ctx context.Context) error {
 	return nil
 }
@@ -9,3 +9,7 @@ import (
 func TestMemoryFolder(t *testing.T) {
 	storage.RunFolderTest(NewFolder("in_memory/", NewKVS()), t)
 }
+
+func TestMemoryFolderRetention(t *testing.T) {
+	storage.RunRetentionFolderTest(NewFolder("in_memory/", NewKVS()), t)
+}
@@ -37,10 +37,20 @@ type KVS struct {
 	order   *[]string
 	orderMu sync.Mutex
 	timeNow func() time.Time
+	// retention emulates object locks: it maps keys to the time until which they are locked
+	retention *sync.Map
+	// storageClasses emulates storage classes: it maps keys to their storageClassState
+	storageClasses *sync.Map
+}
+
+type storageClassState struct {
+	storageClass     string
+	restoreRequested bool
 }
 
 func NewKVS(opts ...func(*KVS)) *KVS {
-	s := &KVS{underlying: &sync.Map{}, timeNow: time.Now, order: &[]string{}}
+	s := &KVS{underlying: &sync.Map{}, timeNow: time.Now, order: &[]string{},
+		retention: &sync.Map{}, storageClasses: &sync.Map{}}
 	for _, o := range opts {
 		o(s)
 	}
@@ -67,6 +77,7 @@ func (storage *KVS) Store(key string, value bytes.Buffer) {
 	popOrderedKey(key, storage)
 	*storage.order = append(*storage.order, key)
 	storage.underlying.Store(key, TimeStampData(value, storage.timeNow))
+	storage.storageClasses.Delete(key)
 }
 
 func popOrderedKey(key string, storage *KVS) {
@@ -82,6 +93,25 @@ func (storage *KVS) Delete(key string) {
 	defer storage.orderMu.Unlock()
 	popOrderedKey(key, storage)
 	storage.underlying.Delete(key)
+	storage.retention.Delete(key)
+	storage.storageClasses.Delete(key)
+}
+
+func (storage *KVS) SetRetention(key string, until time.Time) {
+	storage.retention.Store(key, until)
+}
+
+// GetRetention provides the time until which the key is locked, or the zero time if it isn't locked anymore.
+func (storage *KVS) GetRetention(key string) time.Time {
+	untilInterface, ok := storage.retention.Load(key)
+	if !ok {
+		return time.Time{}
+	}
+	until := untilInterface.(time.Time)
+	if !until.After(storage.timeNow()) {
+		return time.Time{}
+	}
+	return until
 }
 
 func (storage *KVS) Range(callback func(key string, value TimeStampedData) bool) {
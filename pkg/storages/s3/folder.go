@@ -134,7 +134,14 @@ func (folder *Folder) CopyObject(ctx context.Context, srcPath string, dstPath st
 	source := path.Join(*folder.bucket, folder.path, srcPath)
 	dst := path.Join(folder.path, dstPath)
 	input := &s3.CopyObjectInput{CopySource: &source, Bucket: folder.bucket, Key: &dst}
+	folder.setCopyEncryption(input)
 
+	_, err := folder.s3API.CopyObjectWithContext(ctx, input)
+	return err
+}
+
+// setCopyEncryption sets the server-side encryption parameters of both the source and the destination objects.
+func (folder *Folder) setCopyEncryption(input *s3.CopyObjectInput) {
 	if folder.uploader.serverSideEncryption != "" {
 		if folder.uploader.SSECustomerKey != "" {
 			customerKeyMD5 := GetSSECustomerKeyMD5(folder.uploader.SSECustomerKey)
@@ -154,13 +161,9 @@ func (folder *Folder) CopyObject(ctx context.Context, srcPath string, dstPath st
 			input.SSEKMSKeyId = aws.String(folder.uploader.SSEKMSKeyID)
 		}
 	}
-
-	_, err := folder.s3API.CopyObjectWithContext(ctx, input)
-	return err
 }
 
-func (folder *Folder) ReadObject(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
-	objectPath := folder.path + objectRelativePath
+func (folder *Folder) getObjectInput(objectPath string) *s3.GetObjectInput {
 	input := &s3.GetObjectInput{
 		Bucket: folder.bucket,
 		Key:    aws.String(objectPath),
@@ -173,6 +176,12 @@ func (folder *Folder) ReadObject(ctx context.Context, objectRelativePath string)
 		customerKeyMD5 := GetSSECustomerKeyMD5(folder.uploader.SSECustomerKey)
 		input.SSECustomerKeyMD5 = aws.String(customerKeyMD5)
 	}
+	return input
+}
+
+func (folder *Folder) ReadObject(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	objectPath := folder.path + objectRelativePath
+	input := folder.getObjectInput(objectPath)
 
 	object, err := folder.s3API.GetObjectWithContext(ctx, input)
 	if err != nil {
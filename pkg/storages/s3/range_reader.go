@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
 
 const (
@@ -23,6 +24,28 @@ const (
 
 var DebugLogBufferCounter = 0
 
+var _ storage.RangeReader = &Folder{}
+
+// ReadObjectRange reads a part of the object with a single ranged GetObject request.
+func (folder *Folder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	objectPath := folder.path + objectRelativePath
+	input := folder.getObjectInput(objectPath)
+	input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+
+	object, err := folder.s3API.GetObjectWithContext(ctx, input)
+	if err != nil {
+		if isAwsNotExist(err) {
+			return nil, storage.NewObjectNotFoundError(objectPath)
+		}
+		return nil, errors.Wrapf(err, "failed to read range of object: '%s' from S3", objectPath)
+	}
+	return NewContentLengthValidator(object.Body, aws.Int64Value(object.ContentLength), objectPath), nil
+}
+
 type RangeReader struct {
 	ctx           context.Context //nolint:containedctx // ctx-aware io.Reader; Read carries no ctx, reused for range re-reads
 	lastBody      io.ReadCloser
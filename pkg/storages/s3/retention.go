@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// NoSuchObjectLockConfigurationAWSErrorCode is returned for objects that have never been locked.
+const NoSuchObjectLockConfigurationAWSErrorCode = "NoSuchObjectLockConfiguration"
+
+var _ storage.RetentionFolder = &Folder{}
+
+// IsRetentionEnabled reports whether the uploaded objects are locked, i.e. S3_RETENTION_PERIOD is set.
+func (folder *Folder) IsRetentionEnabled() bool {
+	return folder.uploader.RetentionPeriod != defaultDisabledRetentionPeriod
+}
+
+// SetRetention locks the latest version of the object with the S3 Object Lock in the configured S3_RETENTION_MODE.
+func (folder *Folder) SetRetention(ctx context.Context, objectRelativePath string, until time.Time) error {
+	objectPath := folder.path + objectRelativePath
+	_, err := folder.s3API.PutObjectRetentionWithContext(ctx, &s3.PutObjectRetentionInput{
+		Bucket: folder.bucket,
+		Key:    aws.String(objectPath),
+		Retention: &s3.ObjectLockRetention{
+			Mode:            aws.String(folder.uploader.RetentionMode),
+			RetainUntilDate: aws.Time(until),
+		},
+	})
+	if isAwsNotExist(err) {
+		return storage.NewObjectNotFoundError(objectPath)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to set retention of s3 object '%s'", objectPath)
+	}
+	return nil
+}
+
+// GetRetention provides the retention of the latest version of the object.
+func (folder *Folder) GetRetention(ctx context.Context, objectRelativePath string) (time.Time, error) {
+	objectPath := folder.path + objectRelativePath
+	output, err := folder.s3API.GetObjectRetentionWithContext(ctx, &s3.GetObjectRetentionInput{
+		Bucket: folder.bucket,
+		Key:    aws.String(objectPath),
+	})
+	if isAwsNotExist(err) {
+		return time.Time{}, storage.NewObjectNotFoundError(objectPath)
+	}
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == NoSuchObjectLockConfigurationAWSErrorCode {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to get retention of s3 object '%s'", objectPath)
+	}
+	if output.Retention == nil || output.Retention.RetainUntilDate == nil {
+		return time.Time{}, nil
+	}
+	until := *output.Retention.RetainUntilDate
+	if !until.After(time.Now()) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
@@ -0,0 +1,224 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.StorageClassFolder = &Folder{}
+
+const (
+	// restoreTier is the Glacier retrieval tier used to restore archived objects.
+	restoreTier = s3.TierStandard
+
+	// maxCopyObjectSize is the largest object that can be copied with a single CopyObject request.
+	maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+	// copyPartSize is the default part size of multipart copies, it grows if the object has more than maxCopyParts.
+	copyPartSize = 512 * 1024 * 1024
+	maxCopyParts = 10000
+)
+
+// GetStorageClass provides the storage class of the object. S3 doesn't report the class of STANDARD objects, so it's
+// returned for them explicitly.
+func (folder *Folder) GetStorageClass(ctx context.Context, objectRelativePath string) (string, error) {
+	output, err := folder.headObject(ctx, objectRelativePath)
+	if err != nil {
+		return "", err
+	}
+	if output == nil {
+		return "", storage.NewObjectNotFoundError(folder.path + objectRelativePath)
+	}
+	return storageClassOf(output), nil
+}
+
+// SetStorageClass copies the object in place with the new storage class. Objects larger than 5 GiB can't be copied
+// with a single request, so they're copied part by part with a multipart upload.
+func (folder *Folder) SetStorageClass(ctx context.Context, objectRelativePath string, storageClass string) error {
+	objectPath := folder.path + objectRelativePath
+	head, err := folder.headObject(ctx, objectRelativePath)
+	if err != nil {
+		return err
+	}
+	if head == nil {
+		return storage.NewObjectNotFoundError(objectPath)
+	}
+
+	size := aws.Int64Value(head.ContentLength)
+	if size > maxCopyObjectSize {
+		err = folder.multipartCopyWithStorageClass(ctx, objectPath, size, head, storageClass)
+	} else {
+		input := &s3.CopyObjectInput{
+			CopySource:        aws.String(path.Join(*folder.bucket, objectPath)),
+			Bucket:            folder.bucket,
+			Key:               aws.String(objectPath),
+			StorageClass:      aws.String(storageClass),
+			MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+		}
+		folder.setCopyEncryption(input)
+		_, err = folder.s3API.CopyObjectWithContext(ctx, input)
+	}
+	if isAwsNotExist(err) {
+		return storage.NewObjectNotFoundError(objectPath)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to change storage class of s3 object '%s' to %s", objectPath, storageClass)
+	}
+	return nil
+}
+
+// multipartCopyWithStorageClass copies the object in place with UploadPartCopy requests. Unlike CopyObject, multipart
+// uploads don't copy the metadata, so it's taken from the HEAD response.
+func (folder *Folder) multipartCopyWithStorageClass(
+	ctx context.Context,
+	objectPath string,
+	size int64,
+	head *s3.HeadObjectOutput,
+	storageClass string,
+) error {
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:             folder.bucket,
+		Key:                aws.String(objectPath),
+		StorageClass:       aws.String(storageClass),
+		Metadata:           head.Metadata,
+		ContentType:        head.ContentType,
+		ContentEncoding:    head.ContentEncoding,
+		ContentDisposition: head.ContentDisposition,
+		CacheControl:       head.CacheControl,
+	}
+	folder.setCreateMultipartUploadEncryption(createInput)
+	upload, err := folder.s3API.CreateMultipartUploadWithContext(ctx, createInput)
+	if err != nil {
+		return err
+	}
+
+	parts, err := folder.copyParts(ctx, objectPath, size, upload.UploadId)
+	if err != nil {
+		_, abortErr := folder.s3API.AbortMultipartUploadWithContext(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   folder.bucket,
+			Key:      aws.String(objectPath),
+			UploadId: upload.UploadId,
+		})
+		if abortErr != nil {
+			tracelog.WarningLogger.Printf("Failed to abort multipart upload of s3 object '%s': %v", objectPath, abortErr)
+		}
+		return err
+	}
+
+	_, err = folder.s3API.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          folder.bucket,
+		Key:             aws.String(objectPath),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
+func (folder *Folder) copyParts(ctx context.Context, objectPath string, size int64, uploadID *string) ([]*s3.CompletedPart, error) {
+	partSize := max(copyPartSize, (size+maxCopyParts-1)/maxCopyParts)
+	var parts []*s3.CompletedPart
+	for offset, partNumber := int64(0), int64(1); offset < size; offset, partNumber = offset+partSize, partNumber+1 {
+		input := &s3.UploadPartCopyInput{
+			Bucket:          folder.bucket,
+			Key:             aws.String(objectPath),
+			CopySource:      aws.String(path.Join(*folder.bucket, objectPath)),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, min(offset+partSize, size)-1)),
+			PartNumber:      aws.Int64(partNumber),
+			UploadId:        uploadID,
+		}
+		folder.setUploadPartCopyEncryption(input)
+		output, err := folder.s3API.UploadPartCopyWithContext(ctx, input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to copy part %d", partNumber)
+		}
+		parts = append(parts, &s3.CompletedPart{ETag: output.CopyPartResult.ETag, PartNumber: aws.Int64(partNumber)})
+	}
+	return parts, nil
+}
+
+func (folder *Folder) setCreateMultipartUploadEncryption(input *s3.CreateMultipartUploadInput) {
+	if folder.uploader.serverSideEncryption == "" {
+		return
+	}
+	if folder.uploader.SSECustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String(folder.uploader.serverSideEncryption)
+		input.SSECustomerKey = aws.String(folder.uploader.SSECustomerKey)
+		input.SSECustomerKeyMD5 = aws.String(GetSSECustomerKeyMD5(folder.uploader.SSECustomerKey))
+	} else {
+		input.ServerSideEncryption = aws.String(folder.uploader.serverSideEncryption)
+	}
+	if folder.uploader.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(folder.uploader.SSEKMSKeyID)
+	}
+}
+
+func (folder *Folder) setUploadPartCopyEncryption(input *s3.UploadPartCopyInput) {
+	if folder.uploader.serverSideEncryption == "" || folder.uploader.SSECustomerKey == "" {
+		return
+	}
+	customerKeyMD5 := GetSSECustomerKeyMD5(folder.uploader.SSECustomerKey)
+	input.CopySourceSSECustomerAlgorithm = aws.String(folder.uploader.serverSideEncryption)
+	input.CopySourceSSECustomerKey = aws.String(folder.uploader.SSECustomerKey)
+	input.CopySourceSSECustomerKeyMD5 = aws.String(customerKeyMD5)
+	input.SSECustomerAlgorithm = aws.String(folder.uploader.serverSideEncryption)
+	input.SSECustomerKey = aws.String(folder.uploader.SSECustomerKey)
+	input.SSECustomerKeyMD5 = aws.String(customerKeyMD5)
+}
+
+// RestoreObject requests a temporary copy of objects archived in Glacier classes, and checks if it's ready.
+func (folder *Folder) RestoreObject(ctx context.Context, objectRelativePath string, days int) (bool, error) {
+	objectPath := folder.path + objectRelativePath
+	output, err := folder.headObject(ctx, objectRelativePath)
+	if err != nil {
+		return false, err
+	}
+	if output == nil {
+		return false, storage.NewObjectNotFoundError(objectPath)
+	}
+	if !isArchiveStorageClass(storageClassOf(output)) {
+		return true, nil
+	}
+
+	// The restore status looks like: ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+	restoreStatus := aws.StringValue(output.Restore)
+	switch {
+	case strings.Contains(restoreStatus, `ongoing-request="false"`):
+		return true, nil
+	case strings.Contains(restoreStatus, `ongoing-request="true"`):
+		return false, nil
+	}
+
+	tracelog.DebugLogger.Printf("Request restore of s3 object '%s' for %d days", objectPath, days)
+	_, err = folder.s3API.RestoreObjectWithContext(ctx, &s3.RestoreObjectInput{
+		Bucket: folder.bucket,
+		Key:    aws.String(objectPath),
+		RestoreRequest: &s3.RestoreRequest{
+			Days:                 aws.Int64(int64(days)),
+			GlacierJobParameters: &s3.GlacierJobParameters{Tier: aws.String(restoreTier)},
+		},
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to request restore of s3 object '%s'", objectPath)
+	}
+	return false, nil
+}
+
+func storageClassOf(output *s3.HeadObjectOutput) string {
+	if output.StorageClass == nil {
+		return s3.StorageClassStandard
+	}
+	return *output.StorageClass
+}
+
+// isArchiveStorageClass checks if objects of the class must be restored before reading. Glacier Instant Retrieval
+// objects are readable right away.
+func isArchiveStorageClass(storageClass string) bool {
+	return storageClass == s3.StorageClassGlacier || storageClass == s3.StorageClassDeepArchive
+}
@@ -0,0 +1,97 @@
+package s3_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	walgs3 "github.com/wal-g/wal-g/pkg/storages/s3"
+)
+
+type mockS3ClientStorageClass struct {
+	s3iface.S3API
+	size int64
+
+	copyObjectInputs     []*s3.CopyObjectInput
+	createMultipartInput *s3.CreateMultipartUploadInput
+	partCopyInputs       []*s3.UploadPartCopyInput
+	completeInput        *s3.CompleteMultipartUploadInput
+}
+
+func (m *mockS3ClientStorageClass) HeadObjectWithContext(
+	aws.Context, *s3.HeadObjectInput, ...request.Option,
+) (*s3.HeadObjectOutput, error) {
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(m.size),
+		ContentType:   aws.String("application/octet-stream"),
+		Metadata:      map[string]*string{"Key": aws.String("value")},
+	}, nil
+}
+
+func (m *mockS3ClientStorageClass) CopyObjectWithContext(
+	_ aws.Context, input *s3.CopyObjectInput, _ ...request.Option,
+) (*s3.CopyObjectOutput, error) {
+	m.copyObjectInputs = append(m.copyObjectInputs, input)
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (m *mockS3ClientStorageClass) CreateMultipartUploadWithContext(
+	_ aws.Context, input *s3.CreateMultipartUploadInput, _ ...request.Option,
+) (*s3.CreateMultipartUploadOutput, error) {
+	m.createMultipartInput = input
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil
+}
+
+func (m *mockS3ClientStorageClass) UploadPartCopyWithContext(
+	_ aws.Context, input *s3.UploadPartCopyInput, _ ...request.Option,
+) (*s3.UploadPartCopyOutput, error) {
+	m.partCopyInputs = append(m.partCopyInputs, input)
+	return &s3.UploadPartCopyOutput{CopyPartResult: &s3.CopyPartResult{ETag: aws.String("etag")}}, nil
+}
+
+func (m *mockS3ClientStorageClass) CompleteMultipartUploadWithContext(
+	_ aws.Context, input *s3.CompleteMultipartUploadInput, _ ...request.Option,
+) (*s3.CompleteMultipartUploadOutput, error) {
+	m.completeInput = input
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func TestSetStorageClass(t *testing.T) {
+	newFolder := func(client s3iface.S3API) *walgs3.Folder {
+		uploader := walgs3.NewUploader(nil, "", "", "", "STANDARD", "GOVERNANCE", 0)
+		return walgs3.NewFolder(client, uploader, "path/", &walgs3.Config{Bucket: "bucket"})
+	}
+
+	t.Run("copy small objects with a single request", func(t *testing.T) {
+		client := &mockS3ClientStorageClass{size: 1024}
+		err := newFolder(client).SetStorageClass(t.Context(), "object", s3.StorageClassGlacier)
+		require.NoError(t, err)
+
+		require.Len(t, client.copyObjectInputs, 1)
+		assert.Equal(t, s3.StorageClassGlacier, *client.copyObjectInputs[0].StorageClass)
+		assert.Nil(t, client.createMultipartInput)
+	})
+
+	t.Run("copy objects larger than 5 GiB by parts", func(t *testing.T) {
+		const gib = 1024 * 1024 * 1024
+		client := &mockS3ClientStorageClass{size: 6*gib + 1}
+		err := newFolder(client).SetStorageClass(t.Context(), "object", s3.StorageClassGlacier)
+		require.NoError(t, err)
+
+		assert.Empty(t, client.copyObjectInputs)
+		require.NotNil(t, client.createMultipartInput)
+		assert.Equal(t, s3.StorageClassGlacier, *client.createMultipartInput.StorageClass)
+		assert.Equal(t, "value", *client.createMultipartInput.Metadata["Key"])
+
+		require.Len(t, client.partCopyInputs, 13)
+		assert.Equal(t, "bytes=0-536870911", *client.partCopyInputs[0].CopySourceRange)
+		assert.Equal(t, "bucket/path/object", *client.partCopyInputs[0].CopySource)
+		assert.Equal(t, "bytes=6442450944-6442450944", *client.partCopyInputs[12].CopySourceRange)
+		require.NotNil(t, client.completeInput)
+		assert.Len(t, client.completeInput.MultipartUpload.Parts, 13)
+	})
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+var ErrRangeReadNotSupported = errors.New("ranged reads are not supported by the storage")
+
+// RangeReader is an optional interface that folders can implement to read parts of objects, so that large objects
+// can be downloaded with several concurrent requests.
+type RangeReader interface {
+	// ReadObjectRange reads length bytes of the object starting from offset. Must return ObjectNotFoundError in case
+	// the object doesn't exist.
+	ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error)
+}
+
+// ReadObjectRange reads a part of the object. Returns ErrRangeReadNotSupported if the folder doesn't support ranged
+// reads.
+func ReadObjectRange(
+	ctx context.Context,
+	folder Folder,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	rr, ok := folder.(RangeReader)
+	if !ok {
+		return nil, ErrRangeReadNotSupported
+	}
+	return rr.ReadObjectRange(ctx, objectRelativePath, offset, length)
+}
+
+// ParallelReadConfig configures reading objects with concurrent ranged requests.
+type ParallelReadConfig struct {
+	// PartSize is the size of ranges the object is split into. At most Concurrency+1 parts are kept in memory.
+	PartSize int64
+	// Concurrency is the number of ranges downloaded at the same time. Values less than 2 disable parallel reads.
+	Concurrency int
+	// PartRetries is the number of times a range is requested again if its download fails.
+	PartRetries int
+}
+
+// ReadObjectParallel reads the object with concurrent ranged requests and provides its content in order. Objects
+// that are smaller than two parts, and objects in folders that don't implement RangeReader, are read with a single
+// ReadObject call. If a ranged read returns ErrRangeReadNotSupported, the rest of the object is read with ReadObject.
+func ReadObjectParallel(
+	ctx context.Context,
+	folder Folder,
+	objectRelativePath string,
+	config ParallelReadConfig,
+) (io.ReadCloser, error) {
+	rr, ok := folder.(RangeReader)
+	if !ok || config.Concurrency < 2 || config.PartSize <= 0 {
+		return folder.ReadObject(ctx, objectRelativePath)
+	}
+	object, err := folder.StatObject(ctx, objectRelativePath)
+	if err != nil {
+		return nil, err
+	}
+	size := object.GetSize()
+	if size < 2*config.PartSize {
+		return folder.ReadObject(ctx, objectRelativePath)
+	}
+	tracelog.DebugLogger.Printf("Reading object %q of %d bytes with %d concurrent ranged requests",
+		objectRelativePath, size, config.Concurrency)
+	reader := newParallelRangeReader(ctx, rr, objectRelativePath, size, config)
+	reader.fallback = func() (io.ReadCloser, error) {
+		return folder.ReadObject(ctx, objectRelativePath)
+	}
+	return reader, nil
+}
+
+type rangePart struct {
+	data []byte
+	err  error
+}
+
+type parallelRangeReader struct {
+	cancel context.CancelFunc
+	// parts provides the results of downloading the ranges in the order of their offsets.
+	parts   <-chan chan rangePart
+	current []byte
+	err     error
+	// offset is the number of bytes provided to the caller
+	offset int64
+	// fallback opens the whole object when ranged reads are not supported, fallbackReader is the opened object
+	fallback       func() (io.ReadCloser, error)
+	fallbackReader io.ReadCloser
+}
+
+// NewParallelRangeReader provides a reader of the object of the specified size, which downloads its ranges
+// concurrently and reassembles them in order.
+func NewParallelRangeReader(
+	ctx context.Context,
+	rr RangeReader,
+	objectRelativePath string,
+	size int64,
+	config ParallelReadConfig,
+) io.ReadCloser {
+	return newParallelRangeReader(ctx, rr, objectRelativePath, size, config)
+}
+
+func newParallelRangeReader(
+	ctx context.Context,
+	rr RangeReader,
+	objectRelativePath string,
+	size int64,
+	config ParallelReadConfig,
+) *parallelRangeReader {
+	ctx, cancel := context.WithCancel(ctx)
+	// The capacity of the queue limits the number of parts that are downloaded, but not read yet
+	parts := make(chan chan rangePart, config.Concurrency)
+	go func() {
+		defer close(parts)
+		for offset := int64(0); offset < size; offset += config.PartSize {
+			length := min(config.PartSize, size-offset)
+			result := make(chan rangePart, 1)
+			select {
+			case parts <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func(offset, length int64) {
+				data, err := readRange(ctx, rr, objectRelativePath, offset, length, config.PartRetries)
+				result <- rangePart{data: data, err: err}
+			}(offset, length)
+		}
+	}()
+	return &parallelRangeReader{
+		cancel: cancel,
+		parts:  parts,
+	}
+}
+
+func readRange(
+	ctx context.Context,
+	rr RangeReader,
+	objectRelativePath string,
+	offset, length int64,
+	retries int,
+) ([]byte, error) {
+	data := make([]byte, length)
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		err = readRangeOnce(ctx, rr, objectRelativePath, offset, data)
+		if err == nil {
+			return data, nil
+		}
+		if _, notFound := err.(ObjectNotFoundError); notFound || errors.Is(err, ErrRangeReadNotSupported) {
+			return nil, err
+		}
+		tracelog.DebugLogger.Printf("Failed to read range %d-%d of object %q [%d/%d]: %v",
+			offset, offset+length-1, objectRelativePath, attempt, retries, err)
+	}
+	return nil, fmt.Errorf("read range %d-%d of object %q: %w", offset, offset+length-1, objectRelativePath, err)
+}
+
+func readRangeOnce(ctx context.Context, rr RangeReader, objectRelativePath string, offset int64, data []byte) error {
+	reader, err := rr.ReadObjectRange(ctx, objectRelativePath, offset, int64(len(data)))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+	_, err = io.ReadFull(reader, data)
+	return err
+}
+
+func (r *parallelRangeReader) Read(p []byte) (int, error) {
+	if r.fallbackReader != nil {
+		n, err := r.fallbackReader.Read(p)
+		r.offset += int64(n)
+		return n, err
+	}
+	for len(r.current) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		result, ok := <-r.parts
+		if !ok {
+			r.err = io.EOF
+			continue
+		}
+		part := <-result
+		if errors.Is(part.err, ErrRangeReadNotSupported) && r.fallback != nil {
+			r.err = r.fallBack()
+			if r.err == nil {
+				return r.Read(p)
+			}
+			continue
+		}
+		if part.err != nil {
+			r.err = part.err
+			continue
+		}
+		r.current = part.data
+	}
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	r.offset += int64(n)
+	return n, nil
+}
+
+// fallBack stops the ranged reads and continues reading the object with a single request from the current offset
+func (r *parallelRangeReader) fallBack() error {
+	r.cancel()
+	tracelog.DebugLogger.Printf("Ranged reads are not supported, reading the object from offset %d at once", r.offset)
+	reader, err := r.fallback()
+	if err != nil {
+		return err
+	}
+	if _, err = io.CopyN(io.Discard, reader, r.offset); err != nil {
+		_ = reader.Close()
+		return err
+	}
+	r.fallbackReader = reader
+	return nil
+}
+
+func (r *parallelRangeReader) Close() error {
+	r.cancel()
+	if r.fallbackReader != nil {
+		err := r.fallbackReader.Close()
+		r.fallbackReader = nil
+		r.err = errors.New("read from closed parallel range reader")
+		return err
+	}
+	if r.err == nil {
+		r.err = errors.New("read from closed parallel range reader")
+	}
+	r.current = nil
+	return nil
+}
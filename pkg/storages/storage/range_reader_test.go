@@ -0,0 +1,148 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+type flakyRangeFolder struct {
+	*memory.Folder
+	mutex sync.Mutex
+	// failures maps offsets of ranges to the number of times reading them fails
+	failures map[int64]int
+	// notSupportedFrom makes ranged reads from this offset on return ErrRangeReadNotSupported, if it's not negative
+	notSupportedFrom int64
+	requests         atomic.Int32
+}
+
+func (f *flakyRangeFolder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	f.requests.Add(1)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.notSupportedFrom >= 0 && offset >= f.notSupportedFrom {
+		return nil, storage.ErrRangeReadNotSupported
+	}
+	if f.failures[offset] > 0 {
+		f.failures[offset]--
+		return nil, errors.New("connection reset")
+	}
+	return f.Folder.ReadObjectRange(ctx, objectRelativePath, offset, length)
+}
+
+func newRangeTestFolder(t *testing.T, content []byte) *flakyRangeFolder {
+	folder := memory.NewFolder("test/", memory.NewKVS())
+	require.NoError(t, folder.PutObject(context.Background(), "object", bytes.NewReader(content)))
+	return &flakyRangeFolder{Folder: folder, failures: map[int64]int{}, notSupportedFrom: -1}
+}
+
+func TestReadObjectParallel(t *testing.T) {
+	content := make([]byte, 1000)
+	rand.New(rand.NewSource(0)).Read(content)
+	config := storage.ParallelReadConfig{PartSize: 64, Concurrency: 4, PartRetries: 1}
+
+	t.Run("reassembles parts in order", func(t *testing.T) {
+		folder := newRangeTestFolder(t, content)
+		reader, err := storage.ReadObjectParallel(context.Background(), folder, "object", config)
+		require.NoError(t, err)
+		actual, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.Equal(t, content, actual)
+		assert.Equal(t, int32(16), folder.requests.Load())
+	})
+
+	t.Run("retries failed parts", func(t *testing.T) {
+		folder := newRangeTestFolder(t, content)
+		folder.failures[128] = 1
+		reader, err := storage.ReadObjectParallel(context.Background(), folder, "object", config)
+		require.NoError(t, err)
+		actual, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, content, actual)
+	})
+
+	t.Run("fails after retries", func(t *testing.T) {
+		folder := newRangeTestFolder(t, content)
+		folder.failures[128] = 2
+		reader, err := storage.ReadObjectParallel(context.Background(), folder, "object", config)
+		require.NoError(t, err)
+		actual, err := io.ReadAll(reader)
+		assert.ErrorContains(t, err, "connection reset")
+		assert.Equal(t, content[:128], actual)
+		require.NoError(t, reader.Close())
+	})
+
+	t.Run("small objects are read at once", func(t *testing.T) {
+		folder := newRangeTestFolder(t, content[:100])
+		reader, err := storage.ReadObjectParallel(context.Background(), folder, "object", config)
+		require.NoError(t, err)
+		actual, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, content[:100], actual)
+		assert.Equal(t, int32(0), folder.requests.Load())
+	})
+
+	t.Run("folders without ranged reads", func(t *testing.T) {
+		folder := newRangeTestFolder(t, content)
+		reader, err := storage.ReadObjectParallel(context.Background(), folder.Folder.GetSubFolder(""), "object", config)
+		require.NoError(t, err)
+		actual, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, content, actual)
+	})
+
+	t.Run("wrappers of folders without ranged reads", func(t *testing.T) {
+		folder := newRangeTestFolder(t, content)
+		folder.notSupportedFrom = 0
+		reader, err := storage.ReadObjectParallel(context.Background(), folder, "object", config)
+		require.NoError(t, err)
+		actual, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.Equal(t, content, actual)
+		assert.LessOrEqual(t, folder.requests.Load(), int32(config.Concurrency+1), "ranges should not be retried")
+	})
+
+	t.Run("ranged reads become unsupported", func(t *testing.T) {
+		folder := newRangeTestFolder(t, content)
+		folder.notSupportedFrom = 256
+		reader, err := storage.ReadObjectParallel(context.Background(), folder, "object", config)
+		require.NoError(t, err)
+		actual, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.Equal(t, content, actual)
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		folder := newRangeTestFolder(t, content)
+		_, err := storage.ReadObjectParallel(context.Background(), folder, "missing", config)
+		assert.IsType(t, storage.ObjectNotFoundError{}, err)
+	})
+
+	t.Run("close before reading everything", func(t *testing.T) {
+		folder := newRangeTestFolder(t, content)
+		reader, err := storage.ReadObjectParallel(context.Background(), folder, "object", config)
+		require.NoError(t, err)
+		_, err = io.ReadFull(reader, make([]byte, 100))
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		_, err = reader.Read(make([]byte, 1))
+		assert.Error(t, err)
+	})
+}
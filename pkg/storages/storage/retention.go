@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+var ErrRetentionNotSupported = errors.New("object retention is not supported by the storage")
+
+// RetentionFolder is an optional interface that folders can implement to protect objects from being deleted or
+// overwritten until some moment (S3 Object Lock, GCS object retention, Azure immutability policies).
+type RetentionFolder interface {
+	// IsRetentionEnabled reports whether objects in the folder may be locked, so it's worth checking them before
+	// deletion.
+	IsRetentionEnabled() bool
+
+	// SetRetention locks the object until the specified time. Most storages don't allow to shorten the retention of
+	// an already locked object.
+	SetRetention(ctx context.Context, objectRelativePath string, until time.Time) error
+
+	// GetRetention provides the time until which the object is locked. The zero time means the object isn't locked.
+	// Must return ObjectNotFoundError in case the object doesn't exist.
+	GetRetention(ctx context.Context, objectRelativePath string) (time.Time, error)
+}
+
+// IsRetentionEnabled checks if the objects in the folder may be locked.
+func IsRetentionEnabled(folder Folder) bool {
+	rf, ok := folder.(RetentionFolder)
+	return ok && rf.IsRetentionEnabled()
+}
+
+// SetRetention locks the object until the specified time. Returns ErrRetentionNotSupported if the folder doesn't
+// support retention.
+func SetRetention(ctx context.Context, folder Folder, objectRelativePath string, until time.Time) error {
+	rf, ok := folder.(RetentionFolder)
+	if !ok {
+		return ErrRetentionNotSupported
+	}
+	return rf.SetRetention(ctx, objectRelativePath, until)
+}
+
+// GetRetention provides the time until which the object is locked. Objects in folders that don't support retention
+// are never locked.
+func GetRetention(ctx context.Context, folder Folder, objectRelativePath string) (time.Time, error) {
+	rf, ok := folder.(RetentionFolder)
+	if !ok {
+		return time.Time{}, nil
+	}
+	return rf.GetRetention(ctx, objectRelativePath)
+}
+
+type ObjectLockedError struct {
+	error
+	Until time.Time
+}
+
+func NewObjectLockedError(path string, until time.Time) ObjectLockedError {
+	return ObjectLockedError{
+		error: errors.Errorf("object '%s' is locked until %s", path, until.Format(time.RFC3339)),
+		Until: until,
+	}
+}
+
+func (err ObjectLockedError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+var ErrStorageClassNotSupported = errors.New("storage classes are not supported by the storage")
+
+// StorageClassFolder is an optional interface that folders can implement to move objects between storage classes
+// (S3 storage classes, GCS storage classes, Azure access tiers) after they are uploaded.
+type StorageClassFolder interface {
+	// GetStorageClass provides the current storage class of the object. Must return ObjectNotFoundError in case the
+	// object doesn't exist.
+	GetStorageClass(ctx context.Context, objectRelativePath string) (string, error)
+
+	// SetStorageClass moves the object to the storage class.
+	SetStorageClass(ctx context.Context, objectRelativePath string, storageClass string) error
+
+	// RestoreObject makes the object readable if it's in an archive storage class, like S3 Glacier. Returns true if
+	// the object can be read right away, and false if the restore is in progress, so the call should be repeated
+	// later. Temporary restored copies are kept for the specified number of days, if the storage supports that.
+	RestoreObject(ctx context.Context, objectRelativePath string, days int) (bool, error)
+}
+
+// GetStorageClass provides the current storage class of the object. Returns ErrStorageClassNotSupported if the folder
+// doesn't support storage classes.
+func GetStorageClass(ctx context.Context, folder Folder, objectRelativePath string) (string, error) {
+	scf, ok := folder.(StorageClassFolder)
+	if !ok {
+		return "", ErrStorageClassNotSupported
+	}
+	return scf.GetStorageClass(ctx, objectRelativePath)
+}
+
+// SetStorageClass moves the object to the storage class. Returns ErrStorageClassNotSupported if the folder doesn't
+// support storage classes.
+func SetStorageClass(ctx context.Context, folder Folder, objectRelativePath string, storageClass string) error {
+	scf, ok := folder.(StorageClassFolder)
+	if !ok {
+		return ErrStorageClassNotSupported
+	}
+	return scf.SetStorageClass(ctx, objectRelativePath, storageClass)
+}
+
+// RestoreObject makes the object readable if it's archived. Objects in folders that don't support storage classes
+// are always readable.
+func RestoreObject(ctx context.Context, folder Folder, objectRelativePath string, days int) (bool, error) {
+	scf, ok := folder.(StorageClassFolder)
+	if !ok {
+		return true, nil
+	}
+	return scf.RestoreObject(ctx, objectRelativePath, days)
+}
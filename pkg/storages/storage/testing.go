@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 //nolint:funlen
@@ -134,3 +135,43 @@ func RunFolderTest(storageFolder Folder, t *testing.T) {
 	_, err = sub1.ReadObject(ctx, "Tumba Yumba")
 	assert.Error(t, err.(ObjectNotFoundError))
 }
+
+// RunRetentionFolderTest checks that locked objects of a RetentionFolder can't be deleted or overwritten.
+func RunRetentionFolderTest(storageFolder Folder, t *testing.T) {
+	ctx := t.Context()
+	require.True(t, IsRetentionEnabled(storageFolder))
+
+	require.NoError(t, storageFolder.PutObject(ctx, "locked", strings.NewReader("data")))
+	require.NoError(t, storageFolder.PutObject(ctx, "Sub/unlocked", strings.NewReader("data")))
+
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, SetRetention(ctx, storageFolder, "locked", until))
+
+	retention, err := GetRetention(ctx, storageFolder, "locked")
+	assert.NoError(t, err)
+	assert.True(t, until.Equal(retention))
+	retention, err = GetRetention(ctx, storageFolder.GetSubFolder("Sub"), "unlocked")
+	assert.NoError(t, err)
+	assert.True(t, retention.IsZero())
+	_, err = GetRetention(ctx, storageFolder, "missing")
+	assert.IsType(t, ObjectNotFoundError{}, err)
+	assert.IsType(t, ObjectNotFoundError{}, SetRetention(ctx, storageFolder, "missing", until))
+
+	assert.Error(t, SetRetention(ctx, storageFolder, "locked", until.Add(-time.Minute)))
+	assert.NoError(t, SetRetention(ctx, storageFolder, "locked", until.Add(time.Minute)))
+
+	err = storageFolder.DeleteObjects(ctx, []Object{NewLocalObject("locked", time.Time{}, 0)})
+	assert.IsType(t, ObjectLockedError{}, err)
+	err = storageFolder.PutObject(ctx, "locked", strings.NewReader("overwritten"))
+	assert.IsType(t, ObjectLockedError{}, err)
+
+	objects, subFolders, err := storageFolder.ListFolder(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+	assert.Len(t, subFolders, 1)
+
+	assert.NoError(t, storageFolder.DeleteObjects(ctx, []Object{NewLocalObject("Sub/unlocked", time.Time{}, 0)}))
+	exists, err := storageFolder.Exists(ctx, "Sub/unlocked")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
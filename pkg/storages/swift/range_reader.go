@@ -0,0 +1,30 @@
+package swift
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ncw/swift/v2"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.RangeReader = &Folder{}
+
+func (folder *Folder) ReadObjectRange(
+	ctx context.Context,
+	objectRelativePath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	headers := swift.Headers{"Range": fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}
+	// Checking the hash isn't possible for a part of the object
+	readContents, _, err := folder.connection.ObjectOpen(ctx, folder.container.Name, path, false, headers)
+	if err == swift.ObjectNotFound {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open range of Swift object %q: %w", path, err)
+	}
+	return readContents, nil
+}
@@ -0,0 +1,298 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getlastmodified/></D:prop></D:propfind>`
+
+// StatusError is returned when a WebDAV server answers a request with an unexpected HTTP status.
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (err StatusError) Error() string {
+	return fmt.Sprintf("WebDAV %s %q: unexpected status %d %s",
+		err.Method, err.Path, err.StatusCode, http.StatusText(err.StatusCode))
+}
+
+func isNotFound(err error) bool {
+	var statusErr StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// isMissingParent reports whether the request failed because the parent collection of the target doesn't exist.
+// RFC 4918 requires 409 Conflict here, but some servers answer with 404 Not Found instead.
+func isMissingParent(err error) bool {
+	var statusErr StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusConflict || statusErr.StatusCode == http.StatusNotFound)
+}
+
+// resource is a single entry of a PROPFIND response.
+type resource struct {
+	path         string
+	isCollection bool
+	size         int64
+	lastModified time.Time
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// Client performs WebDAV requests against a single server. All paths it accepts are relative to the server root.
+type Client struct {
+	httpClient *http.Client
+	endpoint   string
+	user       string
+	password   string
+
+	// knownCollections caches collections that are known to exist, so uploads into the same directory don't have
+	// to issue MKCOL requests every time.
+	knownCollections sync.Map
+}
+
+func NewClient(httpClient *http.Client, endpoint, user, password string) *Client {
+	return &Client{
+		httpClient: httpClient,
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		user:       user,
+		password:   password,
+	}
+}
+
+func (client *Client) url(resourcePath string) string {
+	return client.endpoint + (&url.URL{Path: "/" + strings.TrimPrefix(resourcePath, "/")}).EscapedPath()
+}
+
+func (client *Client) do(
+	ctx context.Context,
+	method, resourcePath string,
+	body io.Reader,
+	headers map[string]string,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, client.url(resourcePath), body)
+	if err != nil {
+		return nil, fmt.Errorf("create WebDAV %s request %q: %w", method, resourcePath, err)
+	}
+	if client.user != "" {
+		req.SetBasicAuth(client.user, client.password)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("WebDAV %s %q: %w", method, resourcePath, err)
+	}
+	return resp, nil
+}
+
+// doAndClose performs a request without a meaningful response body and checks its status against the expected ones.
+func (client *Client) doAndClose(
+	ctx context.Context,
+	method, resourcePath string,
+	body io.Reader,
+	headers map[string]string,
+	expectedStatuses ...int,
+) error {
+	resp, err := client.do(ctx, method, resourcePath, body, headers)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	for _, status := range expectedStatuses {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	return StatusError{method, resourcePath, resp.StatusCode}
+}
+
+// Propfind lists the resource itself (depth "0") or the resource with its direct members (depth "1").
+func (client *Client) Propfind(ctx context.Context, resourcePath string, depth string) ([]resource, error) {
+	resp, err := client.do(ctx, "PROPFIND", resourcePath, strings.NewReader(propfindBody), map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, StatusError{"PROPFIND", resourcePath, resp.StatusCode}
+	}
+
+	var result multistatus
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("decode WebDAV PROPFIND response for %q: %w", resourcePath, err)
+	}
+
+	resources := make([]resource, 0, len(result.Responses))
+	for _, response := range result.Responses {
+		hrefURL, err := url.Parse(response.Href)
+		if err != nil {
+			return nil, fmt.Errorf("parse WebDAV href %q: %w", response.Href, err)
+		}
+		res := resource{path: strings.TrimPrefix(hrefURL.Path, "/")}
+		for _, propstat := range response.Propstats {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			prop := propstat.Prop
+			res.isCollection = res.isCollection || prop.ResourceType.Collection != nil
+			if prop.ContentLength != "" {
+				res.size, err = strconv.ParseInt(prop.ContentLength, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("parse content length of %q: %w", res.path, err)
+				}
+			}
+			if prop.LastModified != "" {
+				res.lastModified, err = http.ParseTime(prop.LastModified)
+				if err != nil {
+					return nil, fmt.Errorf("parse last modified time of %q: %w", res.path, err)
+				}
+			}
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+func (client *Client) Get(ctx context.Context, resourcePath string) (io.ReadCloser, error) {
+	resp, err := client.do(ctx, http.MethodGet, resourcePath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, StatusError{http.MethodGet, resourcePath, resp.StatusCode}
+	}
+	return resp.Body, nil
+}
+
+// Put uploads the content, creating missing parent collections beforehand. The content is streamed, so the request
+// can't be retried.
+func (client *Client) Put(ctx context.Context, resourcePath string, content io.Reader) error {
+	err := client.MkcolAll(ctx, path.Dir(resourcePath))
+	if err != nil {
+		return err
+	}
+	return client.doAndClose(ctx, http.MethodPut, resourcePath, content, nil,
+		http.StatusOK, http.StatusCreated, http.StatusNoContent)
+}
+
+// Move renames the resource, replacing the destination if it exists.
+func (client *Client) Move(ctx context.Context, srcPath, dstPath string) error {
+	return client.withParentCollection(ctx, dstPath, func() error {
+		return client.doAndClose(ctx, "MOVE", srcPath, nil, map[string]string{
+			"Destination": client.url(dstPath),
+			"Overwrite":   "T",
+		}, http.StatusCreated, http.StatusNoContent)
+	})
+}
+
+// Copy copies the resource, replacing the destination if it exists.
+func (client *Client) Copy(ctx context.Context, srcPath, dstPath string) error {
+	return client.withParentCollection(ctx, dstPath, func() error {
+		return client.doAndClose(ctx, "COPY", srcPath, nil, map[string]string{
+			"Destination": client.url(dstPath),
+			"Overwrite":   "T",
+			"Depth":       "0",
+		}, http.StatusCreated, http.StatusNoContent)
+	})
+}
+
+// Delete removes the resource. Collections are removed recursively. Missing resources are ignored.
+func (client *Client) Delete(ctx context.Context, resourcePath string) error {
+	err := client.doAndClose(ctx, http.MethodDelete, resourcePath, nil, nil,
+		http.StatusOK, http.StatusNoContent, http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+	client.forgetCollections(resourcePath)
+	return nil
+}
+
+// MkcolAll creates the collection along with all its missing parents, like os.MkdirAll does.
+func (client *Client) MkcolAll(ctx context.Context, collectionPath string) error {
+	collectionPath = strings.Trim(collectionPath, "/")
+	if collectionPath == "" || collectionPath == "." {
+		return nil
+	}
+	if _, ok := client.knownCollections.Load(collectionPath); ok {
+		return nil
+	}
+
+	// 405 Method Not Allowed means that the collection already exists.
+	err := client.doAndClose(ctx, "MKCOL", collectionPath+"/", nil, nil,
+		http.StatusCreated, http.StatusMethodNotAllowed)
+	if isMissingParent(err) {
+		err = client.MkcolAll(ctx, path.Dir(collectionPath))
+		if err != nil {
+			return err
+		}
+		err = client.doAndClose(ctx, "MKCOL", collectionPath+"/", nil, nil,
+			http.StatusCreated, http.StatusMethodNotAllowed)
+	}
+	if err != nil {
+		return err
+	}
+	client.knownCollections.Store(collectionPath, struct{}{})
+	return nil
+}
+
+// withParentCollection runs a request without a body, and if it fails because the parent collection of dstPath
+// doesn't exist, creates it and retries once.
+func (client *Client) withParentCollection(ctx context.Context, dstPath string, request func() error) error {
+	err := request()
+	if !isMissingParent(err) {
+		return err
+	}
+	client.forgetCollections(path.Dir(dstPath))
+	mkcolErr := client.MkcolAll(ctx, path.Dir(dstPath))
+	if mkcolErr != nil {
+		return mkcolErr
+	}
+	return request()
+}
+
+func (client *Client) forgetCollections(resourcePath string) {
+	resourcePath = strings.Trim(resourcePath, "/")
+	client.knownCollections.Range(func(key, _ any) bool {
+		collectionPath := key.(string)
+		if collectionPath == resourcePath || strings.HasPrefix(collectionPath, resourcePath+"/") {
+			client.knownCollections.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,78 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/pkg/storages/storage/setting"
+)
+
+const (
+	usernameSetting       = "WEBDAV_USERNAME"
+	passwordSetting       = "WEBDAV_PASSWORD"
+	caCertFileSetting     = "WEBDAV_CA_CERT_FILE"
+	skipValidationSetting = "WEBDAV_SKIP_VALIDATION"
+)
+
+var SettingList = []string{
+	usernameSetting,
+	passwordSetting,
+	caCertFileSetting,
+	skipValidationSetting,
+}
+
+const defaultSkipValidation = false
+
+func ConfigureStorage(
+	ctx context.Context,
+	prefix string,
+	settings map[string]string,
+	rootWraps ...storage.WrapRootFolder,
+) (storage.HashableStorage, error) {
+	endpoint, rootPath, err := parsePrefix(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("parse WebDAV storage prefix %q: %w", prefix, err)
+	}
+
+	skipValidation, err := setting.BoolOptional(settings, skipValidationSetting, defaultSkipValidation)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		Secrets: &Secrets{
+			Password: settings[passwordSetting],
+		},
+		Endpoint:       endpoint,
+		RootPath:       rootPath,
+		User:           settings[usernameSetting],
+		CACertFile:     settings[caCertFileSetting],
+		SkipValidation: skipValidation,
+	}
+
+	st, err := NewStorage(ctx, config, rootWraps...)
+	if err != nil {
+		return nil, fmt.Errorf("create WebDAV storage: %w", err)
+	}
+	return st, nil
+}
+
+// parsePrefix splits a prefix like "https://host:8443/remote.php/dav/files/user/walg" into the server endpoint
+// ("https://host:8443") and the root path on that server ("remote.php/dav/files/user/walg").
+func parsePrefix(prefix string) (endpoint, rootPath string, err error) {
+	prefixURL, err := url.Parse(prefix)
+	if err != nil {
+		return "", "", err
+	}
+	if prefixURL.Scheme != "http" && prefixURL.Scheme != "https" {
+		return "", "", fmt.Errorf("unsupported url scheme %q, expected http or https", prefixURL.Scheme)
+	}
+	if prefixURL.Host == "" {
+		return "", "", fmt.Errorf("missing url host")
+	}
+	endpoint = prefixURL.Scheme + "://" + prefixURL.Host
+	return endpoint, strings.Trim(prefixURL.Path, "/"), nil
+}
@@ -0,0 +1,176 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.Folder = &Folder{}
+
+// Folder represents a WebDAV collection. Its path is relative to the server root and always ends with "/".
+type Folder struct {
+	client *Client
+	path   string
+}
+
+func NewFolder(client *Client, path string) *Folder {
+	// Trim leading slash because paths are always resolved against the server root.
+	path = strings.TrimPrefix(path, "/")
+	return &Folder{client, path}
+}
+
+func (folder *Folder) GetPath() string {
+	return folder.path
+}
+
+func (folder *Folder) ListFolder(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	resources, err := folder.client.Propfind(ctx, folder.path, "1")
+	if isNotFound(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("list WebDAV folder %q: %w", folder.path, err)
+	}
+
+	for _, res := range resources {
+		// The collection itself is always a part of the response, and some servers return its path without
+		// the trailing slash.
+		name, ok := strings.CutPrefix(strings.TrimSuffix(res.path, "/"), folder.path)
+		if !ok || name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		if res.isCollection {
+			subFolders = append(subFolders, NewFolder(folder.client, folder.path+name+"/"))
+			continue
+		}
+		if storage.HasTimestampRandomTmpSuffix(name) {
+			continue // Do not list objects that have not been written yet, like S3.
+		}
+		objects = append(objects, storage.NewLocalObject(name, res.lastModified, res.size))
+	}
+	return objects, subFolders, nil
+}
+
+func (folder *Folder) DeleteObjects(ctx context.Context, objectsWithRelativePaths []storage.Object) error {
+	for _, object := range objectsWithRelativePaths {
+		path := folder.path + object.GetName()
+		tracelog.DebugLogger.Printf("Delete object %v\n", path)
+		err := folder.client.Delete(ctx, path)
+		if err != nil {
+			return fmt.Errorf("delete WebDAV object %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (folder *Folder) Exists(ctx context.Context, objectRelativePath string) (bool, error) {
+	_, err := folder.StatObject(ctx, objectRelativePath)
+	if _, ok := err.(storage.ObjectNotFoundError); ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (storage.Object, error) {
+	path := folder.path + objectRelativePath
+	resources, err := folder.client.Propfind(ctx, path, "0")
+	if isNotFound(err) {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get WebDAV object stats %q: %w", path, err)
+	}
+	if len(resources) == 0 || resources[0].isCollection {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	return storage.NewLocalObject(objectRelativePath, resources[0].lastModified, resources[0].size), nil
+}
+
+func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return NewFolder(folder.client, storage.AddDelimiterToPath(storage.JoinPath(folder.path, subFolderRelativePath)))
+}
+
+func (folder *Folder) ReadObject(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	path := folder.path + objectRelativePath
+	body, err := folder.client.Get(ctx, path)
+	if isNotFound(err) {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read WebDAV object %q: %w", path, err)
+	}
+	return body, nil
+}
+
+// PutObject uploads the content under a temporary name first and then moves it to the target one, so readers never
+// see partially written objects.
+func (folder *Folder) PutObject(ctx context.Context, name string, content io.Reader) error {
+	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.path)
+	path := folder.path + name
+	randomSuffix, err := storage.NewTimestampRandomTag()
+	if err != nil {
+		return fmt.Errorf("failed to generate random postfix: %w", err)
+	}
+	tmpPath := path + randomSuffix
+
+	err = folder.client.Put(ctx, tmpPath, content)
+	if err != nil {
+		folder.cleanupTmpObject(ctx, tmpPath)
+		return fmt.Errorf("put WebDAV object %q: %w", tmpPath, err)
+	}
+	err = folder.client.Move(ctx, tmpPath, path)
+	if err != nil {
+		folder.cleanupTmpObject(ctx, tmpPath)
+		return fmt.Errorf("move WebDAV object %q -> %q: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+func (folder *Folder) cleanupTmpObject(ctx context.Context, tmpPath string) {
+	err := folder.client.Delete(context.WithoutCancel(ctx), tmpPath)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to delete temporary WebDAV object %q: %v", tmpPath, err)
+	}
+}
+
+func (folder *Folder) CopyObject(ctx context.Context, srcPath string, dstPath string) error {
+	if exists, err := folder.Exists(ctx, srcPath); !exists {
+		if err == nil {
+			return storage.NewObjectNotFoundError(srcPath)
+		}
+		return fmt.Errorf("check if WebDAV object exists %q: %w", srcPath, err)
+	}
+	srcPath = folder.path + srcPath
+	dstPath = folder.path + dstPath
+	err := folder.client.Copy(ctx, srcPath, dstPath)
+	if err != nil {
+		return fmt.Errorf("copy WebDAV object %q -> %q: %w", srcPath, dstPath, err)
+	}
+	return nil
+}
+
+// Validate checks that the server is reachable and accepts the credentials. The folder itself may not exist yet:
+// collections are created on the first upload.
+func (folder *Folder) Validate(ctx context.Context) error {
+	_, err := folder.client.Propfind(ctx, folder.path, "0")
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("check WebDAV folder %q: %w", folder.path, err)
+	}
+	return nil
+}
+
+// NOT IMPLEMENTED
+func (folder *Folder) SetVersioningEnabled(_ context.Context, enable bool) {}
+
+// NOT IMPLEMENTED
+func (folder *Folder) GetVersioningEnabled(_ context.Context) bool {
+	return false
+}
@@ -0,0 +1,114 @@
+package webdav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"golang.org/x/net/webdav"
+)
+
+const (
+	testUser     = "walg"
+	testPassword = "secret"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	handler := &webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != testUser || password != testPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func configureTestStorage(t *testing.T, prefix string, password string) (storage.HashableStorage, error) {
+	return ConfigureStorage(t.Context(), prefix, map[string]string{
+		usernameSetting: testUser,
+		passwordSetting: password,
+	})
+}
+
+func TestWebDAVFolder(t *testing.T) {
+	server := newTestServer(t)
+
+	st, err := configureTestStorage(t, server.URL+"/backups/walg", testPassword)
+	require.NoError(t, err)
+	defer st.Close()
+
+	storage.RunFolderTest(st.RootFolder(), t)
+}
+
+func TestWebDAVFolderPutCreatesCollectionsAndHidesTmpObjects(t *testing.T) {
+	server := newTestServer(t)
+
+	st, err := configureTestStorage(t, server.URL+"/walg", testPassword)
+	require.NoError(t, err)
+	defer st.Close()
+	root := st.RootFolder()
+
+	err = root.PutObject(t.Context(), "basebackups_005/base_000/tar_partitions/part_1.tar.lz4", strings.NewReader("data"))
+	require.NoError(t, err)
+
+	folder := root.GetSubFolder("basebackups_005/base_000/tar_partitions")
+	objects, subFolders, err := folder.ListFolder(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, subFolders)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "part_1.tar.lz4", objects[0].GetName())
+	assert.Equal(t, int64(len("data")), objects[0].GetSize())
+
+	client := NewClient(http.DefaultClient, server.URL, testUser, testPassword)
+	tmpName := "part_2.tar.lz4.tmp.20260428T113012Z-9f2c6a4b0a1b2c3d"
+	err = client.Put(t.Context(), folder.GetPath()+tmpName, strings.NewReader("partial"))
+	require.NoError(t, err)
+
+	objects, _, err = folder.ListFolder(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, objects, 1)
+}
+
+func TestWebDAVListMissingFolder(t *testing.T) {
+	server := newTestServer(t)
+
+	st, err := configureTestStorage(t, server.URL+"/walg", testPassword)
+	require.NoError(t, err)
+	defer st.Close()
+
+	objects, subFolders, err := st.RootFolder().GetSubFolder("wal_005").ListFolder(t.Context())
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+	assert.Empty(t, subFolders)
+}
+
+func TestWebDAVValidateRejectsWrongCredentials(t *testing.T) {
+	server := newTestServer(t)
+
+	_, err := configureTestStorage(t, server.URL+"/walg", "wrong")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestParsePrefix(t *testing.T) {
+	endpoint, rootPath, err := parsePrefix("https://cloud.example.com:8443/remote.php/dav/files/user/walg/")
+	require.NoError(t, err)
+	assert.Equal(t, "https://cloud.example.com:8443", endpoint)
+	assert.Equal(t, "remote.php/dav/files/user/walg", rootPath)
+
+	_, _, err = parsePrefix("webdav://cloud.example.com/walg")
+	assert.Error(t, err)
+
+	_, _, err = parsePrefix("http:///walg")
+	assert.Error(t, err)
+}
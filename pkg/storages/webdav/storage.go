@@ -0,0 +1,90 @@
+package webdav
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.HashableStorage = &Storage{}
+
+type Storage struct {
+	client     *Client
+	rootFolder storage.Folder
+	hash       string
+}
+
+type Config struct {
+	Secrets        *Secrets `json:"-"`
+	Endpoint       string
+	RootPath       string
+	User           string
+	CACertFile     string
+	SkipValidation bool
+}
+
+type Secrets struct {
+	Password string
+}
+
+func NewStorage(ctx context.Context, config *Config, rootWraps ...storage.WrapRootFolder) (*Storage, error) {
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	client := NewClient(httpClient, config.Endpoint, config.User, config.Secrets.Password)
+
+	folder := NewFolder(client, storage.AddDelimiterToPath(config.RootPath))
+	if !config.SkipValidation {
+		err = folder.Validate(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("validate WebDAV root folder: %w", err)
+		}
+	}
+
+	var rootFolder storage.Folder = folder
+	for _, wrap := range rootWraps {
+		rootFolder = wrap(rootFolder)
+	}
+
+	hash, err := storage.ComputeConfigHash("webdav", config)
+	if err != nil {
+		return nil, fmt.Errorf("compute config hash: %w", err)
+	}
+
+	return &Storage{client, rootFolder, hash}, nil
+}
+
+func newHTTPClient(config *Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CACertFile != "" {
+		caCert, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA cert file: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in CA cert file %q", config.CACertFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: certPool}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+func (s *Storage) RootFolder() storage.Folder {
+	return s.rootFolder
+}
+
+func (s *Storage) ConfigHash() string {
+	return s.hash
+}
+
+func (s *Storage) Close() error {
+	s.client.httpClient.CloseIdleConnections()
+	return nil
+}
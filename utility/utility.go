@@ -71,6 +71,9 @@ const (
 	CopiedBlockMaxSize     = CompressedBlockMaxSize
 	MetadataFileName       = "metadata.json"
 	StreamMetadataFileName = "stream_metadata.json"
+
+	// WalDictionaryPath is the folder of the zstd dictionaries WAL segments are compressed with
+	WalDictionaryPath = "wal_dictionaries_" + VersionStr + "/"
 )
 
 // MaxTime not really the maximal value, but high enough.